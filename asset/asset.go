@@ -0,0 +1,93 @@
+// Package asset serves the files the samples embed, optionally overlaid by
+// directories on disk so edited assets are picked up without rebuilding.
+package asset
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// OverlayEnv names the environment variable with overlay directories, in
+// the format of PATH.
+const OverlayEnv = "VULKAN_SAMPLES_ASSETS"
+
+// EnvOverlays returns the directories listed in OverlayEnv.
+func EnvOverlays() []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv(OverlayEnv)) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// FS looks files up in the overlay directories in order and then in the
+// embedded files. Names are slash separated and relative to the embed
+// root, like "shaders/tri-vert.spv".
+type FS struct {
+	embedded fs.FS
+	overlays []string
+
+	shadersOnce sync.Once
+	shaders     *ShaderRegistry
+	shadersErr  error
+}
+
+func New(embedded fs.FS, overlays ...string) *FS {
+	return &FS{
+		embedded: embedded,
+		overlays: overlays,
+	}
+}
+
+// Overlays returns the overlay directories.
+func (f *FS) Overlays() []string {
+	return append([]string(nil), f.overlays...)
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if path, ok := f.Locate(name); ok {
+		return os.Open(path)
+	}
+	return f.embedded.Open(name)
+}
+
+// ReadFile reads an asset, it has the signature PipelineBuilder.Build
+// expects to load shaders.
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path, ok := f.Locate(name); ok && fs.ValidPath(name) {
+		data, err = ioutil.ReadFile(path)
+	} else {
+		data, err = fs.ReadFile(f.embedded, name)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		if len(f.overlays) > 0 {
+			return nil, fmt.Errorf("asset %s not found in %v or the embedded files", name, f.overlays)
+		}
+		return nil, fmt.Errorf("asset %s not found", name)
+	}
+	return data, err
+}
+
+// Locate returns the path of the first overlay file for name, false if
+// it is only embedded.
+func (f *FS) Locate(name string) (string, bool) {
+	for _, dir := range f.overlays {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
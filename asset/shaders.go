@@ -0,0 +1,176 @@
+package asset
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	vk "github.com/vulkan-go/vulkan"
+
+	"github.com/vulkan-samples/spirv"
+)
+
+// shaderStages maps the stage suffix of shader file names to stages,
+// "tri-vert.spv" is the vertex stage of the program "tri".
+var shaderStages = map[string]vk.ShaderStageFlagBits{
+	"vert": vk.ShaderStageVertexBit,
+	"tesc": vk.ShaderStageTessellationControlBit,
+	"tese": vk.ShaderStageTessellationEvaluationBit,
+	"geom": vk.ShaderStageGeometryBit,
+	"frag": vk.ShaderStageFragmentBit,
+	"comp": vk.ShaderStageComputeBit,
+}
+
+func stageName(stage vk.ShaderStageFlagBits) string {
+	for name, s := range shaderStages {
+		if s == stage {
+			return name
+		}
+	}
+	return fmt.Sprintf("%#x", uint32(stage))
+}
+
+// Shader is one stage of a program.
+type Shader struct {
+	Stage vk.ShaderStageFlagBits
+	Name  string
+}
+
+// Program is a set of shaders ordered by stage.
+type Program struct {
+	Name    string
+	Shaders []Shader
+}
+
+// Shader returns the asset name of a stage.
+func (p Program) Shader(stage vk.ShaderStageFlagBits) (string, bool) {
+	for _, s := range p.Shaders {
+		if s.Stage == stage {
+			return s.Name, true
+		}
+	}
+	return "", false
+}
+
+// ShaderRegistry finds the programs among the SPIR-V files of an FS and
+// checks shaders as they are loaded.
+type ShaderRegistry struct {
+	fs       *FS
+	programs map[string]*Program
+}
+
+// NewShaderRegistry registers the "<program>-<stage>.spv" files in the
+// overlay directories and the embedded files. Programs are named by their
+// path without the stage, like "shaders/tri". Missing overlay directories
+// are skipped, as FS skips them when it looks files up.
+func NewShaderRegistry(f *FS) (*ShaderRegistry, error) {
+	r := &ShaderRegistry{
+		fs:       f,
+		programs: make(map[string]*Program),
+	}
+	for _, dir := range f.overlays {
+		err := r.walk(os.DirFS(dir))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("overlay %s: %s", dir, err)
+		}
+	}
+	if err := r.walk(f.embedded); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Shaders returns the shader registry of f, built on first use. Samples
+// keep their FS in a package variable, so a broken overlay is an error of
+// the first caller instead of a panic when the package is initialized.
+func (f *FS) Shaders() (*ShaderRegistry, error) {
+	f.shadersOnce.Do(func() {
+		f.shaders, f.shadersErr = NewShaderRegistry(f)
+	})
+	return f.shaders, f.shadersErr
+}
+
+func (r *ShaderRegistry) walk(files fs.FS) error {
+	return fs.WalkDir(files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		r.register(name)
+		return nil
+	})
+}
+
+func (r *ShaderRegistry) register(name string) {
+	base := strings.TrimSuffix(name, ".spv")
+	i := strings.LastIndex(base, "-")
+	if base == name || i < 0 || strings.Contains(base[i:], "/") {
+		return
+	}
+	stage, ok := shaderStages[base[i+1:]]
+	if !ok {
+		return
+	}
+	program := r.programs[base[:i]]
+	if program == nil {
+		program = &Program{Name: base[:i]}
+		r.programs[program.Name] = program
+	}
+	// Overlay files shadow the embedded file of the same name.
+	if _, ok := program.Shader(stage); ok {
+		return
+	}
+	program.Shaders = append(program.Shaders, Shader{Stage: stage, Name: name})
+	sort.Slice(program.Shaders, func(i, j int) bool {
+		return program.Shaders[i].Stage < program.Shaders[j].Stage
+	})
+}
+
+// Programs returns the names of all programs.
+func (r *ShaderRegistry) Programs() []string {
+	names := make([]string, 0, len(r.programs))
+	for name := range r.programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Program looks up a program and checks it has all the required stages.
+func (r *ShaderRegistry) Program(name string, required ...vk.ShaderStageFlagBits) (Program, error) {
+	program, ok := r.programs[name]
+	if !ok {
+		return Program{}, fmt.Errorf("shader program %s not found, there is no %s-<stage>.spv", name, name)
+	}
+	var missing []string
+	for _, stage := range required {
+		if _, ok := program.Shader(stage); !ok {
+			missing = append(missing, fmt.Sprintf("%s stage (%s-%s.spv)", stageName(stage), name, stageName(stage)))
+		}
+	}
+	if len(missing) > 0 {
+		return *program, fmt.Errorf("shader program %s has no %s", name, strings.Join(missing, ", "))
+	}
+	return *program, nil
+}
+
+// Load reads a shader and checks it is SPIR-V Vulkan can consume.
+func (r *ShaderRegistry) Load(name string) ([]byte, error) {
+	code, err := r.fs.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if path.Ext(name) != ".spv" {
+		return nil, fmt.Errorf("shader %s: not a .spv file", name)
+	}
+	if _, err := spirv.Check(code); err != nil {
+		return nil, fmt.Errorf("shader %s: %s", name, err)
+	}
+	return code, nil
+}
@@ -0,0 +1,147 @@
+package asset
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// spirvHeader is a SPIR-V header of version, enough for spirv.Check.
+func spirvHeader(magic, version uint32) []byte {
+	code := make([]byte, 20)
+	binary.LittleEndian.PutUint32(code, magic)
+	binary.LittleEndian.PutUint32(code[4:], version)
+	return code
+}
+
+func testShaderFS() fstest.MapFS {
+	spv := &fstest.MapFile{Data: spirvHeader(0x07230203, 0x00010000)}
+	return fstest.MapFS{
+		"shaders/tri-vert.spv":       spv,
+		"shaders/tri-frag.spv":       spv,
+		"shaders/blur-comp.spv":      spv,
+		"shaders/depth-vert.spv":     spv,
+		"shaders/tri.vert":           {Data: []byte("#version 450")},
+		"shaders/README.md":          {Data: []byte("shaders")},
+		"shaders/bad-magic-vert.spv": {Data: spirvHeader(0xdeadbeef, 0x00010000)},
+		"shaders/bad-ver-vert.spv":   {Data: spirvHeader(0x07230203, 0x00020000)},
+		"shaders/short-vert.spv":     {Data: []byte{3, 2, 0x23, 7}},
+	}
+}
+
+func TestShaderRegistryPrograms(t *testing.T) {
+	r, err := NewShaderRegistry(New(testShaderFS()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"shaders/bad-magic", "shaders/bad-ver", "shaders/blur", "shaders/depth", "shaders/short", "shaders/tri"}
+	if got := r.Programs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Programs = %q, want %q", got, want)
+	}
+	program, err := r.Program("shaders/tri", vk.ShaderStageVertexBit, vk.ShaderStageFragmentBit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantShaders := []Shader{
+		{vk.ShaderStageVertexBit, "shaders/tri-vert.spv"},
+		{vk.ShaderStageFragmentBit, "shaders/tri-frag.spv"},
+	}
+	if !reflect.DeepEqual(program.Shaders, wantShaders) {
+		t.Errorf("shaders/tri: %+v, want %+v", program.Shaders, wantShaders)
+	}
+}
+
+func TestShaderRegistryProgramErrors(t *testing.T) {
+	r, err := NewShaderRegistry(New(testShaderFS()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		required []vk.ShaderStageFlagBits
+		want     string
+	}{
+		{"shaders/none", nil, "there is no shaders/none-<stage>.spv"},
+		{"shaders/depth", []vk.ShaderStageFlagBits{vk.ShaderStageVertexBit, vk.ShaderStageFragmentBit},
+			"has no frag stage (shaders/depth-frag.spv)"},
+		{"shaders/blur", []vk.ShaderStageFlagBits{vk.ShaderStageVertexBit, vk.ShaderStageFragmentBit},
+			"has no vert stage (shaders/blur-vert.spv), frag stage (shaders/blur-frag.spv)"},
+	}
+	for _, test := range tests {
+		if _, err := r.Program(test.name, test.required...); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error %v, want one containing %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestShaderRegistryLoad(t *testing.T) {
+	r, err := NewShaderRegistry(New(testShaderFS()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, err := r.Load("shaders/tri-vert.spv"); err != nil || len(code) != 20 {
+		t.Errorf("Load of a valid shader = %d bytes, %v", len(code), err)
+	}
+	bad := map[string]string{
+		"shaders/bad-magic-vert.spv": "bad magic number",
+		"shaders/bad-ver-vert.spv":   "unsupported version 2.0",
+		"shaders/short-vert.spv":     "shorter than its header",
+		"shaders/tri.vert":           "not a .spv file",
+		"shaders/none-vert.spv":      "not found",
+	}
+	for name, want := range bad {
+		if _, err := r.Load(name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want one containing %q", name, err, want)
+		}
+	}
+}
+
+func TestShaderRegistryOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shaders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "shaders"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A program only in the overlay and a fixed stage of an embedded one.
+	for _, name := range []string{"glow-frag.spv", "bad-magic-vert.spv"} {
+		code := spirvHeader(0x07230203, 0x00010300)
+		if err := ioutil.WriteFile(filepath.Join(dir, "shaders", name), code, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := New(testShaderFS(), filepath.Join(dir, "missing"), dir)
+	r, err := f.Shaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := f.Shaders(); again != r {
+		t.Errorf("Shaders built the registry twice")
+	}
+	program, err := r.Program("shaders/glow", vk.ShaderStageFragmentBit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(program.Shaders) != 1 {
+		t.Errorf("shaders/glow: %+v, want one stage", program.Shaders)
+	}
+	if _, err := r.Load("shaders/glow-frag.spv"); err != nil {
+		t.Errorf("Load of an overlay shader: %s", err)
+	}
+	program, _ = r.Program("shaders/bad-magic")
+	if len(program.Shaders) != 1 {
+		t.Errorf("shaders/bad-magic: %+v, want the overlay stage once", program.Shaders)
+	}
+	if _, err := r.Load("shaders/bad-magic-vert.spv"); err != nil {
+		t.Errorf("Load of a shader fixed by the overlay: %s", err)
+	}
+}
@@ -0,0 +1,163 @@
+package asset
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Compiler compiles the GLSL source file src into the SPIR-V file dst.
+type Compiler func(src, dst string) error
+
+// GLSLangValidator returns a Compiler running the glslangValidator
+// executable at path, "glslangValidator" finds it in PATH.
+func GLSLangValidator(path string) Compiler {
+	return func(src, dst string) error {
+		out, err := exec.Command(path, "-V", src, "-o", dst).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s %s failed with %s: %s", path, src, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+}
+
+// SPIRVName is the name of the SPIR-V file a GLSL source compiles to,
+// "shaders/tri.vert" gives "shaders/tri-vert.spv". It is false for files
+// that aren't shader sources.
+func SPIRVName(source string) (string, bool) {
+	ext := filepath.Ext(source)
+	if _, ok := shaderStages[strings.TrimPrefix(ext, ".")]; !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(source, ext) + "-" + ext[1:] + ".spv", true
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher polls a directory laid out like the assets, usually an overlay
+// directory, for changed shaders.
+type Watcher struct {
+	dir     string
+	compile Compiler
+	stamps  map[string]fileStamp
+	// failed holds the stamps of sources that failed to compile, they
+	// aren't compiled again until they change.
+	failed map[string]fileStamp
+}
+
+// NewWatcher remembers the current state of the shaders in dir. Changed
+// GLSL sources are compiled with compile, nil only watches SPIR-V files.
+func NewWatcher(dir string, compile Compiler) (*Watcher, error) {
+	w := &Watcher{
+		dir:     dir,
+		compile: compile,
+		failed:  make(map[string]fileStamp),
+	}
+	stamps, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.stamps = stamps
+	return w, nil
+}
+
+func (w *Watcher) Dir() string {
+	return w.dir
+}
+
+// scan stats the shader files, keyed by their slash separated asset name.
+func (w *Watcher) scan() (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	err := filepath.Walk(w.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(w.dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if _, source := SPIRVName(name); source || filepath.Ext(name) == ".spv" {
+			stamps[name] = fileStamp{info.ModTime(), info.Size()}
+		}
+		return nil
+	})
+	return stamps, err
+}
+
+// Poll returns the asset names of the SPIR-V files that were added or
+// changed since the last call. GLSL sources that changed are compiled
+// first, their SPIR-V files are reported once the compile succeeds.
+// Compile errors are returned together with the files that did change,
+// a source that failed is compiled again once it changes, even back to a
+// version that compiled before.
+func (w *Watcher) Poll() ([]string, error) {
+	stamps, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	// Phase 1: compile changed sources
+
+	var errs []string
+	for _, name := range sortedNames(stamps) {
+		spv, source := SPIRVName(name)
+		if !source || w.compile == nil {
+			continue
+		}
+		failed, retry := w.failed[name]
+		if stamps[name] == w.stamps[name] && !retry || retry && stamps[name] == failed {
+			continue
+		}
+		src := filepath.Join(w.dir, filepath.FromSlash(name))
+		dst := filepath.Join(w.dir, filepath.FromSlash(spv))
+		if err := w.compile(src, dst); err != nil {
+			errs = append(errs, err.Error())
+			// Keep the stamp of the last good version.
+			w.failed[name] = stamps[name]
+			if old, ok := w.stamps[name]; ok {
+				stamps[name] = old
+			} else {
+				delete(stamps, name)
+			}
+			continue
+		}
+		delete(w.failed, name)
+		if info, err := os.Stat(dst); err == nil {
+			stamps[spv] = fileStamp{info.ModTime(), info.Size()}
+		}
+	}
+
+	// Phase 2: compare the SPIR-V files
+
+	var changed []string
+	for _, name := range sortedNames(stamps) {
+		if filepath.Ext(name) != ".spv" {
+			continue
+		}
+		if old, ok := w.stamps[name]; !ok || old != stamps[name] {
+			changed = append(changed, name)
+		}
+	}
+	w.stamps = stamps
+	if len(errs) > 0 {
+		return changed, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return changed, nil
+}
+
+func sortedNames(stamps map[string]fileStamp) []string {
+	names := make([]string, 0, len(stamps))
+	for name := range stamps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,165 @@
+package asset
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSPIRVName(t *testing.T) {
+	tests := []struct {
+		source string
+		spv    string
+		ok     bool
+	}{
+		{"shaders/tri.vert", "shaders/tri-vert.spv", true},
+		{"shaders/tri.frag", "shaders/tri-frag.spv", true},
+		{"tri.comp", "tri-comp.spv", true},
+		{"shaders/tri-vert.spv", "", false},
+		{"README.md", "", false},
+	}
+	for _, test := range tests {
+		spv, ok := SPIRVName(test.source)
+		if spv != test.spv || ok != test.ok {
+			t.Errorf("SPIRVName(%q) = %q, %v, want %q, %v", test.source, spv, ok, test.spv, test.ok)
+		}
+	}
+}
+
+// fakeCompiler copies sources to their SPIR-V file and fails on sources
+// containing "error", removing the SPIR-V file like a failed compile can.
+type fakeCompiler struct {
+	compiled []string
+}
+
+func (c *fakeCompiler) compile(src, dst string) error {
+	c.compiled = append(c.compiled, filepath.Base(src))
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(data, []byte("error")) {
+		os.Remove(dst)
+		return errors.New(filepath.Base(src) + ": syntax error")
+	}
+	return ioutil.WriteFile(dst, data, 0644)
+}
+
+// writeFile writes a file with a given modification time, so that stamps
+// don't depend on the resolution of the file system clock.
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherCompile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "shaders", "tri.vert")
+	good := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeFile(t, src, "void main() {}", good)
+
+	c := &fakeCompiler{}
+	w, err := NewWatcher(dir, c.compile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		content  string
+		modTime  time.Time
+		compiled []string
+		changed  []string
+		err      bool
+	}{
+		{name: "edited", content: "void main() { }", modTime: good.Add(time.Second),
+			compiled: []string{"tri.vert"}, changed: []string{"shaders/tri-vert.spv"}},
+		{name: "unchanged"},
+		{name: "broken", content: "void main() { error }", modTime: good.Add(2 * time.Second),
+			compiled: []string{"tri.vert"}, err: true},
+		{name: "still broken"},
+		{name: "reverted", content: "void main() { }", modTime: good.Add(time.Second),
+			compiled: []string{"tri.vert"}, changed: []string{"shaders/tri-vert.spv"}},
+		{name: "unchanged after the fix"},
+	}
+	for _, step := range steps {
+		if step.content != "" {
+			writeFile(t, src, step.content, step.modTime)
+		}
+		c.compiled = nil
+		changed, err := w.Poll()
+		if (err != nil) != step.err {
+			t.Fatalf("%s: Poll error %v, want an error: %v", step.name, err, step.err)
+		}
+		if !reflect.DeepEqual(c.compiled, step.compiled) {
+			t.Errorf("%s: compiled %v, want %v", step.name, c.compiled, step.compiled)
+		}
+		if !reflect.DeepEqual(changed, step.changed) {
+			t.Errorf("%s: Poll = %v, want %v", step.name, changed, step.changed)
+		}
+	}
+}
+
+func TestWatcherNewBrokenSource(t *testing.T) {
+	dir := t.TempDir()
+	c := &fakeCompiler{}
+	w, err := NewWatcher(dir, c.compile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "post.frag")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeFile(t, src, "error", start)
+	if _, err := w.Poll(); err == nil {
+		t.Fatalf("Poll of a broken new source succeeded")
+	}
+	c.compiled = nil
+	if _, err := w.Poll(); err != nil || len(c.compiled) != 0 {
+		t.Fatalf("unchanged broken source compiled again: %v, %v", c.compiled, err)
+	}
+	writeFile(t, src, "fixed", start.Add(time.Second))
+	changed, err := w.Poll()
+	if err != nil || !reflect.DeepEqual(changed, []string{"post-frag.spv"}) {
+		t.Errorf("fixed source: Poll = %v, %v, want post-frag.spv", changed, err)
+	}
+}
+
+func TestWatcherSPIRVOnly(t *testing.T) {
+	dir := t.TempDir()
+	spv := filepath.Join(dir, "shaders", "tri-vert.spv")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeFile(t, spv, "spirv", start)
+	writeFile(t, filepath.Join(dir, "shaders", "tri.vert"), "glsl", start)
+	w, err := NewWatcher(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := w.Poll(); err != nil || len(changed) != 0 {
+		t.Fatalf("nothing changed: Poll = %v, %v", changed, err)
+	}
+
+	writeFile(t, filepath.Join(dir, "shaders", "tri.vert"), "glsl changed", start.Add(time.Second))
+	writeFile(t, filepath.Join(dir, "tri-frag.spv"), "spirv", start)
+	changed, err := w.Poll()
+	if err != nil || !reflect.DeepEqual(changed, []string{"tri-frag.spv"}) {
+		t.Errorf("added SPIR-V file: Poll = %v, %v, want tri-frag.spv only", changed, err)
+	}
+	writeFile(t, spv, "spirv 2", start.Add(time.Second))
+	changed, err = w.Poll()
+	if err != nil || !reflect.DeepEqual(changed, []string{"shaders/tri-vert.spv"}) {
+		t.Errorf("changed SPIR-V file: Poll = %v, %v, want shaders/tri-vert.spv", changed, err)
+	}
+}
@@ -0,0 +1,82 @@
+// Command imgdiff compares a render with a golden image. It prints the
+// result, optionally as JSON, writes a heatmap of the differences and exits
+// with status 1 when the images don't match and 2 on errors.
+//
+//	imgdiff [flags] golden.png render.png
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/vulkan-samples/imgdiff"
+)
+
+var (
+	defaults  = imgdiff.DefaultOptions()
+	tolerance = flag.Uint("tolerance", uint(defaults.Tolerance),
+		"largest difference of a channel, 0 to 255, that still counts as equal")
+	maxDiff = flag.Float64("max-diff", defaults.MaxDiffFraction,
+		"fraction of pixels that may exceed the tolerance")
+	minSSIM = flag.Float64("min-ssim", defaults.MinSSIM,
+		"lowest SSIM score that matches, 0 skips the check")
+	alpha   = flag.Bool("alpha", !defaults.IgnoreAlpha, "compare the alpha channel too")
+	heatmap = flag.String("heatmap", "", "write a heatmap of the differences into this PNG file")
+	asJSON  = flag.Bool("json", false, "print the result as JSON")
+)
+
+// jsonResult replaces infinite PSNR, which JSON can't encode, with null.
+type jsonResult struct {
+	imgdiff.Result
+	PSNR *float64 `json:"psnr"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: imgdiff [flags] golden.png render.png")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 || *tolerance > 255 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opts := imgdiff.Options{
+		Tolerance:       uint8(*tolerance),
+		IgnoreAlpha:     !*alpha,
+		MaxDiffFraction: *maxDiff,
+		MinSSIM:         *minSSIM,
+	}
+	res, diff, err := imgdiff.CompareFiles(flag.Arg(0), flag.Arg(1), opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "imgdiff:", err)
+		os.Exit(2)
+	}
+	if *heatmap != "" {
+		if err := imgdiff.SavePNG(*heatmap, diff); err != nil {
+			fmt.Fprintln(os.Stderr, "imgdiff:", err)
+			os.Exit(2)
+		}
+	}
+
+	if *asJSON {
+		out := jsonResult{Result: res}
+		if !math.IsInf(res.PSNR, 0) {
+			out.PSNR = &res.PSNR
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintln(os.Stderr, "imgdiff:", err)
+			os.Exit(2)
+		}
+	} else {
+		fmt.Println(res)
+	}
+	if !res.Pass {
+		os.Exit(1)
+	}
+}
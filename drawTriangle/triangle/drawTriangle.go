@@ -6,6 +6,7 @@ import (
 	"unsafe"
 
 	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/renderer"
 )
 
 // enableDebug is disabled by default since VK_EXT_debug_report
@@ -53,11 +54,7 @@ type VulkanBufferInfo struct {
 }
 
 type VulkanGfxPipelineInfo struct {
-	device vk.Device
-
-	layout   vk.PipelineLayout
-	cache    vk.PipelineCache
-	pipeline vk.Pipeline
+	pipeline *renderer.Pipeline
 }
 
 func (v *VulkanSwapchainInfo) DefaultSwapchain() vk.Swapchain {
@@ -107,7 +104,7 @@ func VulkanInit(v *VulkanDeviceInfo, s *VulkanSwapchainInfo,
 		check(ret, "vk.BeginCommandBuffer")
 
 		vk.CmdBeginRenderPass(r.cmdBuffers[i], &renderPassBeginInfo, vk.SubpassContentsInline)
-		vk.CmdBindPipeline(r.cmdBuffers[i], vk.PipelineBindPointGraphics, gfx.pipeline)
+		vk.CmdBindPipeline(r.cmdBuffers[i], vk.PipelineBindPointGraphics, gfx.pipeline.Pipeline)
 		offsets := make([]vk.DeviceSize, len(vb.buffers))
 		vk.CmdBindVertexBuffers(r.cmdBuffers[i], 0, 1, vb.buffers, offsets)
 		vk.CmdBindIndexBuffer(r.cmdBuffers[i], ib.buffers[0], 0, vk.IndexTypeUint16);
@@ -131,209 +128,34 @@ func VulkanInit(v *VulkanDeviceInfo, s *VulkanSwapchainInfo,
 	check(ret, "vk.CreateSemaphore")
 }
 
-func LoadShader(device vk.Device, name string) (vk.ShaderModule, error) {
-	var module vk.ShaderModule
-	data, err := Asset(name)
-	if err != nil {
-		err := fmt.Errorf("asset %s not found: %s", name, err)
-		return module, err
-	}
-
-	// Phase 1: vk.CreateShaderModule
-
-	shaderModuleCreateInfo := vk.ShaderModuleCreateInfo{
-		SType:    vk.StructureTypeShaderModuleCreateInfo,
-		CodeSize: uint(len(data)),
-		PCode:    repackUint32(data),
-	}
-	err = vk.Error(vk.CreateShaderModule(device, &shaderModuleCreateInfo, nil, &module))
-	if err != nil {
-		err = fmt.Errorf("vk.CreateShaderModule failed with %s", err)
-		return module, err
-	}
-	return module, nil
-}
-
 func CreateGraphicsPipeline(device vk.Device,
 	displaySize vk.Extent2D, renderPass vk.RenderPass) (VulkanGfxPipelineInfo, error) {
 
 	var gfxPipeline VulkanGfxPipelineInfo
-
-	// Phase 1: vk.CreatePipelineLayout
-	//			create pipeline layout (empty)
-
-	pipelineLayoutCreateInfo := vk.PipelineLayoutCreateInfo{
-		SType: vk.StructureTypePipelineLayoutCreateInfo,
-	}
-	err := vk.Error(vk.CreatePipelineLayout(device, &pipelineLayoutCreateInfo, nil, &gfxPipeline.layout))
+	shaders, err := Assets.Shaders()
 	if err != nil {
-		err = fmt.Errorf("vk.CreatePipelineLayout failed with %s", err)
 		return gfxPipeline, err
 	}
-	dynamicState := vk.PipelineDynamicStateCreateInfo{
-		SType: vk.StructureTypePipelineDynamicStateCreateInfo,
-		// no dynamic state for this demo
-	}
-
-	// Phase 2: load shaders and specify shader stages
-
-	vertexShader, err := LoadShader(device, "shaders/tri-vert.spv")
-	if err != nil { // err has enough info
-		return gfxPipeline, err
-	}
-	defer vk.DestroyShaderModule(device, vertexShader, nil)
-
-	fragmentShader, err := LoadShader(device, "shaders/tri-frag.spv")
-	if err != nil { // err has enough info
+	program, err := shaders.Program("shaders/tri", vk.ShaderStageVertexBit, vk.ShaderStageFragmentBit)
+	if err != nil {
 		return gfxPipeline, err
 	}
-	defer vk.DestroyShaderModule(device, fragmentShader, nil)
-
-	shaderStages := []vk.PipelineShaderStageCreateInfo{
-		{
-			SType:  vk.StructureTypePipelineShaderStageCreateInfo,
-			Stage:  vk.ShaderStageVertexBit,
-			Module: vertexShader,
-			PName:  "main\x00",
-		},
-		{
-			SType:  vk.StructureTypePipelineShaderStageCreateInfo,
-			Stage:  vk.ShaderStageFragmentBit,
-			Module: fragmentShader,
-			PName:  "main\x00",
-		},
-	}
-
-	// Phase 3: specify viewport state
-
-	viewports := []vk.Viewport{{
-		MinDepth: 0.0,
-		MaxDepth: 1.0,
-		X:        0,
-		Y:        0,
-		Width:    float32(displaySize.Width),
-		Height:   float32(displaySize.Height),
-	}}
-	scissors := []vk.Rect2D{{
-		Extent: displaySize,
-		Offset: vk.Offset2D{
-			X: 0, Y: 0,
-		},
-	}}
-	viewportState := vk.PipelineViewportStateCreateInfo{
-		SType:         vk.StructureTypePipelineViewportStateCreateInfo,
-		ViewportCount: 1,
-		PViewports:    viewports,
-		ScissorCount:  1,
-		PScissors:     scissors,
-	}
-
-	// Phase 4: specify multisample state
-	//					color blend state
-	//					rasterizer state
-
-	sampleMask := []vk.SampleMask{vk.SampleMask(vk.MaxUint32)}
-	multisampleState := vk.PipelineMultisampleStateCreateInfo{
-		SType:                vk.StructureTypePipelineMultisampleStateCreateInfo,
-		RasterizationSamples: vk.SampleCount1Bit,
-		SampleShadingEnable:  vk.False,
-		PSampleMask:          sampleMask,
-	}
-	attachmentStates := []vk.PipelineColorBlendAttachmentState{{
-		ColorWriteMask: vk.ColorComponentFlags(
-			vk.ColorComponentRBit | vk.ColorComponentGBit |
-				vk.ColorComponentBBit | vk.ColorComponentABit,
-		),
-		BlendEnable: vk.False,
-	}}
-	colorBlendState := vk.PipelineColorBlendStateCreateInfo{
-		SType:           vk.StructureTypePipelineColorBlendStateCreateInfo,
-		LogicOpEnable:   vk.False,
-		LogicOp:         vk.LogicOpCopy,
-		AttachmentCount: 1,
-		PAttachments:    attachmentStates,
+	builder := renderer.NewPipelineBuilder()
+	for _, shader := range program.Shaders {
+		builder.Shader(shader.Stage, shader.Name)
 	}
 
 	// FrontFaceClockwise is by default in Vulkan?
 	// Otherwise, we need to flip projection matrix from GL to Vulkan orientation.
-	rasterState := vk.PipelineRasterizationStateCreateInfo{
-		SType:                   vk.StructureTypePipelineRasterizationStateCreateInfo,
-		DepthClampEnable:        vk.False,
-		RasterizerDiscardEnable: vk.False,
-		PolygonMode:             vk.PolygonModeFill,
-		CullMode:                vk.CullModeFlags(vk.CullModeBackBit),
-		FrontFace:               vk.FrontFaceClockwise,
-		DepthBiasEnable:         vk.False,
-		LineWidth:               1,
-	}
-
-	// Phase 5: specify input assembly state
-	//					vertex input state and attributes
-
-	inputAssemblyState := vk.PipelineInputAssemblyStateCreateInfo{
-		SType:                  vk.StructureTypePipelineInputAssemblyStateCreateInfo,
-		Topology:               vk.PrimitiveTopologyTriangleList,
-		PrimitiveRestartEnable: vk.True,
-	}
-	vertexInputBindings := []vk.VertexInputBindingDescription{{
-		Binding:   0,
-		Stride:    6 * 4, // 4 = sizeof(float32)
-		InputRate: vk.VertexInputRateVertex,
-	}}
-	vertexInputAttributes := []vk.VertexInputAttributeDescription{{
-		Binding:  0,
-		Location: 0,
-		Format:   vk.FormatR32g32b32Sfloat,
-		Offset:   0,
-	},
-	{
-		Binding:  0,
-		Location: 1,
-		Format:   vk.FormatR32g32b32Sfloat,
-		Offset:   3 * 4, // 4 = sizeof(float32)
-	}}
-	vertexInputState := vk.PipelineVertexInputStateCreateInfo{
-		SType:                           vk.StructureTypePipelineVertexInputStateCreateInfo,
-		VertexBindingDescriptionCount:   1,
-		PVertexBindingDescriptions:      vertexInputBindings,
-		VertexAttributeDescriptionCount: uint32(len(vertexInputAttributes)),//2,
-		PVertexAttributeDescriptions:    vertexInputAttributes,
-	}
-
-	// Phase 5: vk.CreatePipelineCache
-	//			vk.CreateGraphicsPipelines
-
-	pipelineCacheInfo := vk.PipelineCacheCreateInfo{
-		SType: vk.StructureTypePipelineCacheCreateInfo,
-	}
-	err = vk.Error(vk.CreatePipelineCache(device, &pipelineCacheInfo, nil, &gfxPipeline.cache))
-	if err != nil {
-		err = fmt.Errorf("vk.CreatePipelineCache failed with %s", err)
-		return gfxPipeline, err
-	}
-	pipelineCreateInfos := []vk.GraphicsPipelineCreateInfo{{
-		SType:               vk.StructureTypeGraphicsPipelineCreateInfo,
-		StageCount:          2, // vert + frag
-		PStages:             shaderStages,
-		PVertexInputState:   &vertexInputState,
-		PInputAssemblyState: &inputAssemblyState,
-		PViewportState:      &viewportState,
-		PRasterizationState: &rasterState,
-		PMultisampleState:   &multisampleState,
-		PColorBlendState:    &colorBlendState,
-		PDynamicState:       &dynamicState,
-		Layout:              gfxPipeline.layout,
-		RenderPass:          renderPass,
-	}}
-	pipelines := make([]vk.Pipeline, 1)
-	err = vk.Error(vk.CreateGraphicsPipelines(device,
-		gfxPipeline.cache, 1, pipelineCreateInfos, nil, pipelines))
+	pipeline, err := builder.
+		Vertex(0, vk.FormatR32g32b32Sfloat, vk.FormatR32g32b32Sfloat).
+		Cull(vk.CullModeBackBit, vk.FrontFaceClockwise).
+		StaticViewport(displaySize).
+		Build(device, renderPass, shaders.Load)
 	if err != nil {
-		err = fmt.Errorf("vk.CreateGraphicsPipelines failed with %s", err)
 		return gfxPipeline, err
 	}
-	gfxPipeline.pipeline = pipelines[0]
-	gfxPipeline.device = device
+	gfxPipeline.pipeline = pipeline
 	return gfxPipeline, nil
 }
 
@@ -956,9 +778,8 @@ func (gfx *VulkanGfxPipelineInfo) Destroy() {
 	if gfx == nil {
 		return
 	}
-	vk.DestroyPipeline(gfx.device, gfx.pipeline, nil)
-	vk.DestroyPipelineCache(gfx.device, gfx.cache, nil)
-	vk.DestroyPipelineLayout(gfx.device, gfx.layout, nil)
+	gfx.pipeline.Destroy()
+	gfx.pipeline = nil
 }
 
 func (s *VulkanSwapchainInfo) Destroy() {
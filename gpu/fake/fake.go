@@ -0,0 +1,519 @@
+// Package fake is a gpu.Device that records command streams and resource
+// lifetimes instead of rendering, for checking frames without a GPU:
+//
+//	d := fake.New()
+//	cmd, _ := d.CreateCommandBuffer("frame")
+//	... record a frame into d.Record(cmd) and d.End it ...
+//	draws := d.Draws(0)
+//	// draws[0].Pipeline, draws[0].Sets and draws[0].Count are what frame 0
+//	// bound and drew, d.Live() lists what was never destroyed.
+package fake
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vulkan-samples/gpu"
+)
+
+type Op string
+
+const (
+	OpBeginRenderPass    Op = "BeginRenderPass"
+	OpEndRenderPass      Op = "EndRenderPass"
+	OpBindPipeline       Op = "BindPipeline"
+	OpBindDescriptorSets Op = "BindDescriptorSets"
+	OpBindVertexBuffers  Op = "BindVertexBuffers"
+	OpBindIndexBuffer    Op = "BindIndexBuffer"
+	OpSetViewport        Op = "SetViewport"
+	OpSetScissor         Op = "SetScissor"
+	OpPushConstants      Op = "PushConstants"
+	OpDraw               Op = "Draw"
+	OpDrawIndexed        Op = "DrawIndexed"
+	OpCopyImageToBuffer  Op = "CopyImageToBuffer"
+)
+
+// Command is a recorded command, only the fields of its Op are set.
+type Command struct {
+	Op             Op
+	RenderPass     gpu.RenderPass
+	Framebuffer    gpu.Framebuffer
+	Clear          []gpu.ClearValue
+	Pipeline       gpu.Pipeline
+	First          uint32
+	Sets           []gpu.DescriptorSet
+	DynamicOffsets []uint32
+	Buffers        []gpu.Buffer
+	Offsets        []uint64
+	IndexType      gpu.IndexType
+	Image          gpu.Image
+	Viewport       gpu.Viewport
+	Rect           gpu.Rect
+	Stages         gpu.ShaderStage
+	Data           []byte
+	// Count is the vertex or index count of draws.
+	Count         uint32
+	Instances     uint32
+	VertexOffset  int32
+	FirstInstance uint32
+}
+
+// Kind is the kind of a resource.
+type Kind string
+
+const (
+	KindBuffer        Kind = "buffer"
+	KindImage         Kind = "image"
+	KindRenderPass    Kind = "render pass"
+	KindFramebuffer   Kind = "framebuffer"
+	KindPipeline      Kind = "pipeline"
+	KindDescriptorSet Kind = "descriptor set"
+	KindCommandBuffer Kind = "command buffer"
+)
+
+// Resource is a live resource. Frame is the number of frames submitted
+// before it was created.
+type Resource struct {
+	Kind   Kind
+	Handle uint64
+	Label  string
+	Frame  int
+}
+
+func (r Resource) String() string {
+	if r.Label != "" {
+		return fmt.Sprintf("%s %d %q created before frame %d", r.Kind, r.Handle, r.Label, r.Frame)
+	}
+	return fmt.Sprintf("%s %d created before frame %d", r.Kind, r.Handle, r.Frame)
+}
+
+// Device implements gpu.Device. It is not safe for concurrent use.
+type Device struct {
+	next       uint64
+	live       map[uint64]Resource
+	buffers    map[gpu.Buffer][]byte
+	pipelines  map[gpu.Pipeline]gpu.PipelineDesc
+	sets       map[gpu.DescriptorSet][]gpu.DescriptorWrite
+	recording  map[gpu.CommandBuffer]bool
+	frames     [][]Command
+	violations []string
+	// Fail makes the next create call of the kind fail, for error paths.
+	Fail map[Kind]error
+}
+
+func New() *Device {
+	return &Device{
+		live:      make(map[uint64]Resource),
+		buffers:   make(map[gpu.Buffer][]byte),
+		pipelines: make(map[gpu.Pipeline]gpu.PipelineDesc),
+		sets:      make(map[gpu.DescriptorSet][]gpu.DescriptorWrite),
+		recording: make(map[gpu.CommandBuffer]bool),
+		Fail:      make(map[Kind]error),
+	}
+}
+
+var _ gpu.Device = (*Device)(nil)
+
+func (d *Device) violate(format string, args ...interface{}) {
+	d.violations = append(d.violations, fmt.Sprintf(format, args...))
+}
+
+func (d *Device) create(kind Kind, label string) (uint64, error) {
+	if err := d.Fail[kind]; err != nil {
+		delete(d.Fail, kind)
+		return 0, err
+	}
+	d.next++
+	d.live[d.next] = Resource{Kind: kind, Handle: d.next, Label: label, Frame: len(d.frames)}
+	return d.next, nil
+}
+
+// check reports handles that aren't live resources of the kind.
+func (d *Device) check(kind Kind, handle uint64, use string) bool {
+	r, ok := d.live[handle]
+	if !ok || r.Kind != kind {
+		d.violate("%s: %s %d is not alive", use, kind, handle)
+		return false
+	}
+	return true
+}
+
+func (d *Device) destroy(kind Kind, handle uint64) {
+	if d.check(kind, handle, "destroy") {
+		delete(d.live, handle)
+	}
+}
+
+func (d *Device) CreateBuffer(desc gpu.BufferDesc) (gpu.Buffer, error) {
+	h, err := d.create(KindBuffer, desc.Label)
+	if err != nil {
+		return 0, err
+	}
+	var contents []byte
+	if desc.HostVisible {
+		contents = make([]byte, desc.Size)
+	}
+	d.buffers[gpu.Buffer(h)] = contents
+	return gpu.Buffer(h), nil
+}
+
+func (d *Device) WriteBuffer(buffer gpu.Buffer, offset uint64, data []byte) error {
+	if !d.check(KindBuffer, uint64(buffer), "WriteBuffer") {
+		return fmt.Errorf("buffer %d is not alive", buffer)
+	}
+	contents := d.buffers[buffer]
+	if contents == nil {
+		return fmt.Errorf("buffer %d is not host visible", buffer)
+	}
+	if offset+uint64(len(data)) > uint64(len(contents)) {
+		return fmt.Errorf("write of %d bytes at %d overflows buffer %d of %d bytes",
+			len(data), offset, buffer, len(contents))
+	}
+	copy(contents[offset:], data)
+	return nil
+}
+
+// Buffer returns the contents of a host visible buffer.
+func (d *Device) Buffer(buffer gpu.Buffer) []byte {
+	return d.buffers[buffer]
+}
+
+func (d *Device) DestroyBuffer(buffer gpu.Buffer) {
+	d.destroy(KindBuffer, uint64(buffer))
+	delete(d.buffers, buffer)
+}
+
+func (d *Device) CreateImage(desc gpu.ImageDesc) (gpu.Image, error) {
+	h, err := d.create(KindImage, desc.Label)
+	return gpu.Image(h), err
+}
+
+func (d *Device) DestroyImage(image gpu.Image) {
+	d.destroy(KindImage, uint64(image))
+}
+
+func (d *Device) CreateRenderPass(desc gpu.RenderPassDesc) (gpu.RenderPass, error) {
+	h, err := d.create(KindRenderPass, desc.Label)
+	return gpu.RenderPass(h), err
+}
+
+func (d *Device) DestroyRenderPass(pass gpu.RenderPass) {
+	d.destroy(KindRenderPass, uint64(pass))
+}
+
+func (d *Device) CreateFramebuffer(desc gpu.FramebufferDesc) (gpu.Framebuffer, error) {
+	d.check(KindRenderPass, uint64(desc.RenderPass), "CreateFramebuffer")
+	for _, image := range desc.Attachments {
+		d.check(KindImage, uint64(image), "CreateFramebuffer")
+	}
+	h, err := d.create(KindFramebuffer, desc.Label)
+	return gpu.Framebuffer(h), err
+}
+
+func (d *Device) DestroyFramebuffer(framebuffer gpu.Framebuffer) {
+	d.destroy(KindFramebuffer, uint64(framebuffer))
+}
+
+func (d *Device) CreatePipeline(desc gpu.PipelineDesc) (gpu.Pipeline, error) {
+	d.check(KindRenderPass, uint64(desc.RenderPass), "CreatePipeline")
+	h, err := d.create(KindPipeline, desc.Label)
+	if err != nil {
+		return 0, err
+	}
+	d.pipelines[gpu.Pipeline(h)] = desc
+	return gpu.Pipeline(h), nil
+}
+
+// Pipeline returns the description a pipeline was created with.
+func (d *Device) Pipeline(pipeline gpu.Pipeline) gpu.PipelineDesc {
+	return d.pipelines[pipeline]
+}
+
+func (d *Device) DestroyPipeline(pipeline gpu.Pipeline) {
+	d.destroy(KindPipeline, uint64(pipeline))
+}
+
+func (d *Device) AllocateDescriptorSet(bindings []gpu.DescriptorBinding) (gpu.DescriptorSet, error) {
+	h, err := d.create(KindDescriptorSet, "")
+	return gpu.DescriptorSet(h), err
+}
+
+func (d *Device) WriteDescriptorSet(set gpu.DescriptorSet, writes []gpu.DescriptorWrite) {
+	if !d.check(KindDescriptorSet, uint64(set), "WriteDescriptorSet") {
+		return
+	}
+	for _, w := range writes {
+		if w.Buffer != 0 {
+			d.check(KindBuffer, uint64(w.Buffer), "WriteDescriptorSet")
+		}
+		for _, image := range w.Images {
+			d.check(KindImage, uint64(image), "WriteDescriptorSet")
+		}
+	}
+	d.sets[set] = append(d.sets[set], writes...)
+}
+
+// DescriptorSet returns the writes made to a set.
+func (d *Device) DescriptorSet(set gpu.DescriptorSet) []gpu.DescriptorWrite {
+	return d.sets[set]
+}
+
+func (d *Device) FreeDescriptorSet(set gpu.DescriptorSet) {
+	d.destroy(KindDescriptorSet, uint64(set))
+	delete(d.sets, set)
+}
+
+// CreateCommandBuffer stands for a command buffer the caller owns, like
+// the one of a frame in flight, for Record.
+func (d *Device) CreateCommandBuffer(label string) (gpu.CommandBuffer, error) {
+	h, err := d.create(KindCommandBuffer, label)
+	return gpu.CommandBuffer(h), err
+}
+
+func (d *Device) DestroyCommandBuffer(cmd gpu.CommandBuffer) {
+	if d.recording[cmd] {
+		d.violate("command buffer %d destroyed while recording", cmd)
+	}
+	d.destroy(KindCommandBuffer, uint64(cmd))
+	delete(d.recording, cmd)
+}
+
+func (d *Device) Begin() (gpu.CommandEncoder, error) {
+	return &Encoder{device: d}, nil
+}
+
+func (d *Device) Submit(cmd gpu.CommandEncoder) error {
+	return d.end(cmd, "submitted")
+}
+
+// Record starts recording into a command buffer, the stream counts as a
+// frame once it is ended.
+func (d *Device) Record(cmd gpu.CommandBuffer) (gpu.CommandEncoder, error) {
+	if !d.check(KindCommandBuffer, uint64(cmd), "Record") {
+		return nil, fmt.Errorf("command buffer %d is not alive", cmd)
+	}
+	if d.recording[cmd] {
+		return nil, fmt.Errorf("command buffer %d is already recording", cmd)
+	}
+	d.recording[cmd] = true
+	return &Encoder{device: d, cmd: cmd}, nil
+}
+
+func (d *Device) End(cmd gpu.CommandEncoder) error {
+	return d.end(cmd, "ended")
+}
+
+func (d *Device) end(cmd gpu.CommandEncoder, what string) error {
+	e, ok := cmd.(*Encoder)
+	if !ok || e.device != d {
+		return fmt.Errorf("command encoder %T was not begun by this device", cmd)
+	}
+	if e.submitted {
+		return fmt.Errorf("command encoder was already %s", what)
+	}
+	if e.inPass {
+		d.violate("frame %d: %s inside a render pass", len(d.frames), what)
+	}
+	e.submitted = true
+	delete(d.recording, e.cmd)
+	d.frames = append(d.frames, e.commands)
+	return nil
+}
+
+func (d *Device) WaitIdle() error {
+	return nil
+}
+
+// Frames returns the number of submitted or ended command streams.
+func (d *Device) Frames() int {
+	return len(d.frames)
+}
+
+// Frame returns the commands of the n-th submitted or ended stream.
+func (d *Device) Frame(n int) []Command {
+	return d.frames[n]
+}
+
+// Live returns the resources that weren't destroyed, after a renderer
+// has been torn down these are its leaks.
+func (d *Device) Live() []Resource {
+	live := make([]Resource, 0, len(d.live))
+	for _, r := range d.live {
+		live = append(live, r)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].Handle < live[j].Handle })
+	return live
+}
+
+// Violations returns the misuses seen so far, like binding destroyed
+// resources or drawing outside a render pass.
+func (d *Device) Violations() []string {
+	return append([]string(nil), d.violations...)
+}
+
+// Draw is a draw call with the state bound when it was recorded.
+type Draw struct {
+	Indexed        bool
+	Pipeline       gpu.Pipeline
+	Sets           []gpu.DescriptorSet
+	DynamicOffsets []uint32
+	VertexBuffers  []gpu.Buffer
+	IndexBuffer    gpu.Buffer
+	Count          uint32
+	Instances      uint32
+}
+
+// Draws replays frame n and returns its draw calls.
+func (d *Device) Draws(n int) []Draw {
+	var draws []Draw
+	var state Draw
+	for _, c := range d.frames[n] {
+		switch c.Op {
+		case OpBindPipeline:
+			state.Pipeline = c.Pipeline
+		case OpBindDescriptorSets:
+			end := int(c.First) + len(c.Sets)
+			for len(state.Sets) < end {
+				state.Sets = append(state.Sets, 0)
+			}
+			copy(state.Sets[c.First:], c.Sets)
+			state.DynamicOffsets = c.DynamicOffsets
+		case OpBindVertexBuffers:
+			end := int(c.First) + len(c.Buffers)
+			for len(state.VertexBuffers) < end {
+				state.VertexBuffers = append(state.VertexBuffers, 0)
+			}
+			copy(state.VertexBuffers[c.First:], c.Buffers)
+		case OpBindIndexBuffer:
+			state.IndexBuffer = c.Buffers[0]
+		case OpDraw, OpDrawIndexed:
+			draw := state
+			draw.Indexed = c.Op == OpDrawIndexed
+			draw.Count = c.Count
+			draw.Instances = c.Instances
+			draw.Sets = append([]gpu.DescriptorSet(nil), state.Sets...)
+			draw.VertexBuffers = append([]gpu.Buffer(nil), state.VertexBuffers...)
+			draws = append(draws, draw)
+		}
+	}
+	return draws
+}
+
+// Encoder records into a Device.
+type Encoder struct {
+	device    *Device
+	cmd       gpu.CommandBuffer
+	commands  []Command
+	inPass    bool
+	pipeline  gpu.Pipeline
+	submitted bool
+}
+
+func (e *Encoder) record(c Command) {
+	if e.submitted {
+		e.device.violate("%s recorded after submit", c.Op)
+		return
+	}
+	e.commands = append(e.commands, c)
+}
+
+func (e *Encoder) where() string {
+	return fmt.Sprintf("frame %d", len(e.device.frames))
+}
+
+func (e *Encoder) BeginRenderPass(pass gpu.RenderPass, framebuffer gpu.Framebuffer, area gpu.Rect, clear []gpu.ClearValue) {
+	if e.inPass {
+		e.device.violate("%s: render pass begun inside a render pass", e.where())
+	}
+	e.device.check(KindRenderPass, uint64(pass), e.where())
+	e.device.check(KindFramebuffer, uint64(framebuffer), e.where())
+	e.inPass = true
+	e.record(Command{Op: OpBeginRenderPass, RenderPass: pass, Framebuffer: framebuffer, Rect: area,
+		Clear: append([]gpu.ClearValue(nil), clear...)})
+}
+
+func (e *Encoder) EndRenderPass() {
+	if !e.inPass {
+		e.device.violate("%s: render pass ended outside a render pass", e.where())
+	}
+	e.inPass = false
+	e.record(Command{Op: OpEndRenderPass})
+}
+
+func (e *Encoder) BindPipeline(pipeline gpu.Pipeline) {
+	e.device.check(KindPipeline, uint64(pipeline), e.where())
+	e.pipeline = pipeline
+	e.record(Command{Op: OpBindPipeline, Pipeline: pipeline})
+}
+
+func (e *Encoder) BindDescriptorSets(pipeline gpu.Pipeline, first uint32, sets []gpu.DescriptorSet, dynamicOffsets []uint32) {
+	e.device.check(KindPipeline, uint64(pipeline), e.where())
+	for _, set := range sets {
+		e.device.check(KindDescriptorSet, uint64(set), e.where())
+	}
+	e.record(Command{Op: OpBindDescriptorSets, Pipeline: pipeline, First: first,
+		Sets:           append([]gpu.DescriptorSet(nil), sets...),
+		DynamicOffsets: append([]uint32(nil), dynamicOffsets...)})
+}
+
+func (e *Encoder) BindVertexBuffers(first uint32, buffers []gpu.Buffer, offsets []uint64) {
+	for _, buffer := range buffers {
+		e.device.check(KindBuffer, uint64(buffer), e.where())
+	}
+	if len(offsets) != len(buffers) {
+		e.device.violate("%s: %d vertex buffers with %d offsets", e.where(), len(buffers), len(offsets))
+	}
+	e.record(Command{Op: OpBindVertexBuffers, First: first,
+		Buffers: append([]gpu.Buffer(nil), buffers...),
+		Offsets: append([]uint64(nil), offsets...)})
+}
+
+func (e *Encoder) BindIndexBuffer(buffer gpu.Buffer, offset uint64, indexType gpu.IndexType) {
+	e.device.check(KindBuffer, uint64(buffer), e.where())
+	e.record(Command{Op: OpBindIndexBuffer, Buffers: []gpu.Buffer{buffer}, Offsets: []uint64{offset},
+		IndexType: indexType})
+}
+
+func (e *Encoder) SetViewport(viewport gpu.Viewport) {
+	e.record(Command{Op: OpSetViewport, Viewport: viewport})
+}
+
+func (e *Encoder) SetScissor(scissor gpu.Rect) {
+	e.record(Command{Op: OpSetScissor, Rect: scissor})
+}
+
+func (e *Encoder) PushConstants(pipeline gpu.Pipeline, stages gpu.ShaderStage, offset uint32, data []byte) {
+	e.device.check(KindPipeline, uint64(pipeline), e.where())
+	e.record(Command{Op: OpPushConstants, Pipeline: pipeline, Stages: stages, First: offset,
+		Data: append([]byte(nil), data...)})
+}
+
+func (e *Encoder) draw(c Command) {
+	if !e.inPass {
+		e.device.violate("%s: %s outside a render pass", e.where(), c.Op)
+	}
+	if e.pipeline == 0 {
+		e.device.violate("%s: %s without a pipeline", e.where(), c.Op)
+	}
+	e.record(c)
+}
+
+func (e *Encoder) Draw(vertexCount, instanceCount, firstVertex, firstInstance uint32) {
+	e.draw(Command{Op: OpDraw, Count: vertexCount, Instances: instanceCount, First: firstVertex,
+		FirstInstance: firstInstance})
+}
+
+func (e *Encoder) DrawIndexed(indexCount, instanceCount, firstIndex uint32, vertexOffset int32, firstInstance uint32) {
+	e.draw(Command{Op: OpDrawIndexed, Count: indexCount, Instances: instanceCount, First: firstIndex,
+		VertexOffset: vertexOffset, FirstInstance: firstInstance})
+}
+
+func (e *Encoder) CopyImageToBuffer(image gpu.Image, buffer gpu.Buffer, width, height uint32) {
+	if e.inPass {
+		e.device.violate("%s: %s inside a render pass", e.where(), OpCopyImageToBuffer)
+	}
+	e.device.check(KindImage, uint64(image), e.where())
+	e.device.check(KindBuffer, uint64(buffer), e.where())
+	e.record(Command{Op: OpCopyImageToBuffer, Image: image, Buffers: []gpu.Buffer{buffer},
+		Rect: gpu.Rect{Width: width, Height: height}})
+}
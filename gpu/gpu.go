@@ -0,0 +1,213 @@
+// Package gpu is the thin device interface the samples record frames
+// through. Backends implement it over a real API; gpu/fake records what
+// it is asked to do so frames can be checked on machines without a GPU.
+//
+// Enumerations use the values of their Vulkan counterparts, so the Vulkan
+// backend converts them with a cast.
+package gpu
+
+// Handles are opaque, each backend maps them to its own objects. The zero
+// handle is never a valid object.
+type (
+	Buffer        uint64
+	Image         uint64
+	Pipeline      uint64
+	DescriptorSet uint64
+	RenderPass    uint64
+	Framebuffer   uint64
+	CommandBuffer uint64
+)
+
+// Format is a texel or vertex format, the values are those of VkFormat.
+type Format uint32
+
+// BufferUsage are the values of VkBufferUsageFlagBits.
+type BufferUsage uint32
+
+const (
+	BufferTransferSrc BufferUsage = 0x1
+	BufferTransferDst BufferUsage = 0x2
+	BufferUniform     BufferUsage = 0x10
+	BufferStorage     BufferUsage = 0x20
+	BufferIndex       BufferUsage = 0x40
+	BufferVertex      BufferUsage = 0x80
+)
+
+// ImageUsage are the values of VkImageUsageFlagBits.
+type ImageUsage uint32
+
+const (
+	ImageTransferSrc     ImageUsage = 0x1
+	ImageTransferDst     ImageUsage = 0x2
+	ImageSampled         ImageUsage = 0x4
+	ImageStorage         ImageUsage = 0x8
+	ImageColorAttachment ImageUsage = 0x10
+	ImageDepthAttachment ImageUsage = 0x20
+)
+
+// ShaderStage are the values of VkShaderStageFlagBits.
+type ShaderStage uint32
+
+const (
+	StageVertex   ShaderStage = 0x1
+	StageFragment ShaderStage = 0x10
+	StageCompute  ShaderStage = 0x20
+)
+
+// DescriptorType are the values of VkDescriptorType.
+type DescriptorType uint32
+
+const (
+	DescriptorSampler              DescriptorType = 0
+	DescriptorCombinedImageSampler DescriptorType = 1
+	DescriptorSampledImage         DescriptorType = 2
+	DescriptorStorageImage         DescriptorType = 3
+	DescriptorUniformBuffer        DescriptorType = 6
+	DescriptorStorageBuffer        DescriptorType = 7
+	DescriptorUniformBufferDynamic DescriptorType = 8
+	DescriptorStorageBufferDynamic DescriptorType = 9
+)
+
+// IndexType are the values of VkIndexType.
+type IndexType uint32
+
+const (
+	IndexUint16 IndexType = 0
+	IndexUint32 IndexType = 1
+)
+
+type BufferDesc struct {
+	Label string
+	Size  uint64
+	Usage BufferUsage
+	// HostVisible buffers can be written with Device.WriteBuffer.
+	HostVisible bool
+}
+
+type ImageDesc struct {
+	Label         string
+	Width, Height uint32
+	MipLevels     uint32
+	Format        Format
+	Usage         ImageUsage
+	Samples       uint32
+}
+
+// PipelineDesc names a pipeline to the device. Native carries the
+// backend's own description, like a *renderer.PipelineBuilder for the
+// Vulkan backend; the fake only looks at the rest.
+type PipelineDesc struct {
+	Label      string
+	Key        string
+	Shaders    []string
+	RenderPass RenderPass
+	Native     interface{}
+}
+
+type RenderPassDesc struct {
+	Label        string
+	ColorFormats []Format
+	DepthFormat  Format
+	Samples      uint32
+}
+
+type FramebufferDesc struct {
+	Label         string
+	RenderPass    RenderPass
+	Attachments   []Image
+	Width, Height uint32
+}
+
+type DescriptorBinding struct {
+	Binding uint32
+	Type    DescriptorType
+	Count   uint32
+	Stages  ShaderStage
+}
+
+// DescriptorWrite points a binding of a set at a buffer range or images.
+type DescriptorWrite struct {
+	Binding uint32
+	Type    DescriptorType
+	Buffer  Buffer
+	Offset  uint64
+	Range   uint64
+	Images  []Image
+}
+
+type Rect struct {
+	X, Y          int32
+	Width, Height uint32
+}
+
+type Viewport struct {
+	X, Y, Width, Height float32
+	MinDepth, MaxDepth  float32
+}
+
+// ClearValue is a clear color, or a depth and stencil value for depth
+// attachments.
+type ClearValue struct {
+	Color        [4]float32
+	DepthStencil bool
+	Depth        float32
+	Stencil      uint32
+}
+
+func ClearColor(r, g, b, a float32) ClearValue {
+	return ClearValue{Color: [4]float32{r, g, b, a}}
+}
+
+func ClearDepth(depth float32, stencil uint32) ClearValue {
+	return ClearValue{DepthStencil: true, Depth: depth, Stencil: stencil}
+}
+
+// Device creates resources and submits command streams.
+type Device interface {
+	CreateBuffer(desc BufferDesc) (Buffer, error)
+	WriteBuffer(buffer Buffer, offset uint64, data []byte) error
+	DestroyBuffer(buffer Buffer)
+	CreateImage(desc ImageDesc) (Image, error)
+	DestroyImage(image Image)
+	CreateRenderPass(desc RenderPassDesc) (RenderPass, error)
+	DestroyRenderPass(pass RenderPass)
+	CreateFramebuffer(desc FramebufferDesc) (Framebuffer, error)
+	DestroyFramebuffer(framebuffer Framebuffer)
+	CreatePipeline(desc PipelineDesc) (Pipeline, error)
+	DestroyPipeline(pipeline Pipeline)
+	// AllocateDescriptorSet allocates a set with the bindings, it lives
+	// until FreeDescriptorSet.
+	AllocateDescriptorSet(bindings []DescriptorBinding) (DescriptorSet, error)
+	WriteDescriptorSet(set DescriptorSet, writes []DescriptorWrite)
+	FreeDescriptorSet(set DescriptorSet)
+
+	// Begin starts recording a command stream.
+	Begin() (CommandEncoder, error)
+	// Submit ends recording and executes the stream.
+	Submit(cmd CommandEncoder) error
+	// Record resets a command buffer the caller submits itself, like the
+	// one of a frame in flight, and starts recording into it. End ends
+	// the recording.
+	Record(cmd CommandBuffer) (CommandEncoder, error)
+	End(cmd CommandEncoder) error
+	WaitIdle() error
+}
+
+// CommandEncoder records commands, it follows the vkCmd* functions.
+type CommandEncoder interface {
+	BeginRenderPass(pass RenderPass, framebuffer Framebuffer, area Rect, clear []ClearValue)
+	EndRenderPass()
+	BindPipeline(pipeline Pipeline)
+	BindDescriptorSets(pipeline Pipeline, first uint32, sets []DescriptorSet, dynamicOffsets []uint32)
+	BindVertexBuffers(first uint32, buffers []Buffer, offsets []uint64)
+	BindIndexBuffer(buffer Buffer, offset uint64, indexType IndexType)
+	SetViewport(viewport Viewport)
+	SetScissor(scissor Rect)
+	PushConstants(pipeline Pipeline, stages ShaderStage, offset uint32, data []byte)
+	Draw(vertexCount, instanceCount, firstVertex, firstInstance uint32)
+	DrawIndexed(indexCount, instanceCount, firstIndex uint32, vertexOffset int32, firstInstance uint32)
+	// CopyImageToBuffer copies an image in the transfer source layout to
+	// a buffer in rows of width texels, and makes the copy visible to
+	// host reads once the commands have completed.
+	CopyImageToBuffer(image Image, buffer Buffer, width, height uint32)
+}
@@ -0,0 +1,96 @@
+package imgdiff
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UpdateEnv names the environment variable that makes Golden write the
+// images it is given as the new golden images instead of comparing them.
+const UpdateEnv = "IMGDIFF_UPDATE"
+
+// Load decodes a PNG or JPEG file.
+func Load(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s failed with %s", path, err)
+	}
+	return img, nil
+}
+
+func SavePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// CompareFiles compares the images of two files.
+func CompareFiles(want, got string, opts Options) (Result, *image.NRGBA, error) {
+	a, err := Load(want)
+	if err != nil {
+		return Result{}, nil, err
+	}
+	b, err := Load(got)
+	if err != nil {
+		return Result{}, nil, err
+	}
+	return Compare(a, b, opts)
+}
+
+// Golden compares got with the golden PNG at path, use pixels.ToNRGBA for
+// images read back from the GPU and softraster.Target.Image for reference
+// renders. When they don't match it writes got and the heatmap next to the
+// golden image, as <name>.actual.png and <name>.diff.png, and returns an
+// error describing the difference. With UpdateEnv set it writes got to path
+// instead.
+func Golden(path string, got image.Image, opts Options) (Result, error) {
+	if os.Getenv(UpdateEnv) != "" {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return Result{}, err
+		}
+		err = SavePNG(path, got)
+		if err != nil {
+			return Result{}, err
+		}
+		size := got.Bounds().Size()
+		return Result{Width: size.X, Height: size.Y, PSNR: posInf, SSIM: 1, Pass: true}, nil
+	}
+	want, err := Load(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("%s, set %s=1 to create the golden image", err, UpdateEnv)
+	}
+	res, heatmap, err := Compare(want, got, opts)
+	if err != nil {
+		return res, fmt.Errorf("%s: %s", path, err)
+	}
+	if res.Pass {
+		return res, nil
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	err = SavePNG(base+".actual.png", got)
+	if err != nil {
+		return res, err
+	}
+	err = SavePNG(base+".diff.png", heatmap)
+	if err != nil {
+		return res, err
+	}
+	return res, fmt.Errorf("%s doesn't match: %s, see %s.actual.png and %s.diff.png", path, res, base, base)
+}
@@ -0,0 +1,161 @@
+// Package imgdiff compares renders with golden images. It counts the
+// pixels that differ by more than a per channel tolerance, computes a
+// structural similarity (SSIM) score of the luminance and draws a heatmap
+// of the differences.
+package imgdiff
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+type Options struct {
+	// Tolerance is the largest difference of a channel, 0 to 255, that
+	// still counts as equal.
+	Tolerance uint8
+	// IgnoreAlpha compares the color channels only.
+	IgnoreAlpha bool
+	// MaxDiffFraction is the fraction of pixels that may exceed the
+	// tolerance for the images to match.
+	MaxDiffFraction float64
+	// MinSSIM is the lowest SSIM score for the images to match, 0 skips
+	// the check.
+	MinSSIM float64
+}
+
+// DefaultOptions tolerate the rounding differences between GPUs.
+func DefaultOptions() Options {
+	return Options{
+		Tolerance:       2,
+		IgnoreAlpha:     true,
+		MaxDiffFraction: 0.001,
+		MinSSIM:         0.98,
+	}
+}
+
+type Result struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// DiffPixels exceed the tolerance, DiffFraction is their share of
+	// all pixels.
+	DiffPixels   int     `json:"diffPixels"`
+	DiffFraction float64 `json:"diffFraction"`
+	// MaxDelta and MeanDelta are taken over the largest channel
+	// difference of each pixel.
+	MaxDelta  uint8   `json:"maxDelta"`
+	MeanDelta float64 `json:"meanDelta"`
+	// PSNR in dB over the compared channels, +Inf for equal images.
+	PSNR float64 `json:"psnr"`
+	SSIM float64 `json:"ssim"`
+	Pass bool    `json:"pass"`
+}
+
+func (r Result) String() string {
+	verdict := "FAIL"
+	if r.Pass {
+		verdict = "PASS"
+	}
+	return fmt.Sprintf("%s: %d of %dx%d pixels differ (%.4f%%), max delta %d, mean delta %.3f, PSNR %.2f dB, SSIM %.5f",
+		verdict, r.DiffPixels, r.Width, r.Height, 100*r.DiffFraction, r.MaxDelta, r.MeanDelta, r.PSNR, r.SSIM)
+}
+
+// toNRGBA returns img with non-premultiplied 8 bit channels and its origin
+// at (0, 0).
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	if n, ok := img.(*image.NRGBA); ok && b.Min == (image.Point{}) {
+		return n
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			out.SetNRGBA(x, y, color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA))
+		}
+	}
+	return out
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// Compare compares got with want, the images must have the same size. The
+// heatmap shows want dimmed in gray where the pixels match and the size of
+// the difference from red to yellow to white where they don't.
+func Compare(want, got image.Image, opts Options) (Result, *image.NRGBA, error) {
+	if want.Bounds().Size() != got.Bounds().Size() {
+		return Result{}, nil, fmt.Errorf("image sizes differ: %v and %v", want.Bounds().Size(), got.Bounds().Size())
+	}
+	a, b := toNRGBA(want), toNRGBA(got)
+	size := a.Bounds().Size()
+	res := Result{Width: size.X, Height: size.Y}
+	heatmap := image.NewNRGBA(a.Bounds())
+	channels := 4
+	if opts.IgnoreAlpha {
+		channels = 3
+	}
+
+	var sumDelta, sumSquares float64
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			i := a.PixOffset(x, y)
+			var delta uint8
+			for c := 0; c < channels; c++ {
+				d := absDiff(a.Pix[i+c], b.Pix[i+c])
+				sumSquares += float64(d) * float64(d)
+				if d > delta {
+					delta = d
+				}
+			}
+			sumDelta += float64(delta)
+			if delta > res.MaxDelta {
+				res.MaxDelta = delta
+			}
+			if delta > opts.Tolerance {
+				res.DiffPixels++
+				heatmap.SetNRGBA(x, y, heat(float64(delta)/255))
+			} else {
+				l := uint8(luma(a.Pix[i:i+3]) / 4)
+				heatmap.SetNRGBA(x, y, color.NRGBA{l, l, l, 0xff})
+			}
+		}
+	}
+	pixels := float64(size.X * size.Y)
+	if pixels > 0 {
+		res.DiffFraction = float64(res.DiffPixels) / pixels
+		res.MeanDelta = sumDelta / pixels
+		mse := sumSquares / (pixels * float64(channels))
+		res.PSNR = posInf
+		if mse > 0 {
+			res.PSNR = 10 * math.Log10(255*255/mse)
+		}
+	}
+	res.SSIM = SSIM(a, b)
+	res.Pass = res.DiffFraction <= opts.MaxDiffFraction && (opts.MinSSIM == 0 || res.SSIM >= opts.MinSSIM)
+	return res, heatmap, nil
+}
+
+// heat maps t in [0, 1] to a color ramp starting at a visible red.
+func heat(t float64) color.NRGBA {
+	t = 0.25 + 0.75*clamp01(t)
+	channel := func(v float64) uint8 {
+		return uint8(255 * clamp01(v))
+	}
+	return color.NRGBA{channel(3 * t), channel(3*t - 1), channel(3*t - 2), 0xff}
+}
+
+// luma is the Rec. 601 luma of an 8 bit RGB pixel.
+func luma(rgb []uint8) float64 {
+	return 0.299*float64(rgb[0]) + 0.587*float64(rgb[1]) + 0.114*float64(rgb[2])
+}
+
+var posInf = math.Inf(1)
+
+func clamp01(v float64) float64 {
+	return math.Min(math.Max(v, 0), 1)
+}
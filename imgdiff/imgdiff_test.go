@@ -0,0 +1,194 @@
+package imgdiff
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// uniform returns a w x h image of one color.
+func uniform(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// gradient has structure for SSIM to compare, unlike a uniform image.
+func gradient(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + y*255/h) / 2)
+			img.SetNRGBA(x, y, color.NRGBA{v, v / 2, 255 - v, 0xff})
+		}
+	}
+	return img
+}
+
+func TestCompareIdentical(t *testing.T) {
+	a := gradient(32, 24)
+	res, heatmap, err := Compare(a, a, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(res.PSNR, 1) || res.SSIM != 1 || !res.Pass {
+		t.Errorf("identical images: %s, want an infinite PSNR and an SSIM of 1", res)
+	}
+	if res.DiffPixels != 0 || res.MaxDelta != 0 || res.MeanDelta != 0 {
+		t.Errorf("identical images: %s, want no difference", res)
+	}
+	if heatmap.Bounds() != a.Bounds() {
+		t.Errorf("heatmap bounds %v, want %v", heatmap.Bounds(), a.Bounds())
+	}
+}
+
+func TestCompareTolerance(t *testing.T) {
+	tests := []struct {
+		name  string
+		delta uint8
+		opts  Options
+		diff  int
+		pass  bool
+	}{
+		{"at the tolerance", 2, Options{Tolerance: 2}, 0, true},
+		{"past the tolerance", 3, Options{Tolerance: 2}, 1, false},
+		{"within the fraction", 3, Options{Tolerance: 2, MaxDiffFraction: 0.25}, 1, true},
+		{"past the fraction", 3, Options{Tolerance: 2, MaxDiffFraction: 0.24}, 1, false},
+		{"full range", 255, Options{Tolerance: 254}, 1, false},
+	}
+	for _, test := range tests {
+		// The green channel of one of 4 pixels differs, the squared error
+		// is spread over 16 channels.
+		want := uniform(2, 2, color.NRGBA{100, 0, 100, 0xff})
+		got := uniform(2, 2, color.NRGBA{100, 0, 100, 0xff})
+		got.SetNRGBA(1, 0, color.NRGBA{100, test.delta, 100, 0xff})
+		res, _, err := Compare(want, got, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.DiffPixels != test.diff || res.Pass != test.pass || res.MaxDelta != test.delta {
+			t.Errorf("%s: %s, want %d differing pixels, a max delta of %d and pass %v",
+				test.name, res, test.diff, test.delta, test.pass)
+		}
+		psnr := 10 * math.Log10(255*255*16/(float64(test.delta)*float64(test.delta)))
+		if math.Abs(res.PSNR-psnr) > 1e-9 {
+			t.Errorf("%s: PSNR %g, want %g", test.name, res.PSNR, psnr)
+		}
+		if mean := float64(test.delta) / 4; res.MeanDelta != mean {
+			t.Errorf("%s: mean delta %g, want %g", test.name, res.MeanDelta, mean)
+		}
+	}
+}
+
+func TestCompareAlpha(t *testing.T) {
+	want := uniform(4, 4, color.NRGBA{10, 20, 30, 0xff})
+	got := uniform(4, 4, color.NRGBA{10, 20, 30, 0x80})
+	res, _, err := Compare(want, got, Options{IgnoreAlpha: true})
+	if err != nil || !res.Pass || res.DiffPixels != 0 {
+		t.Errorf("alpha ignored: %s, %v, want a match", res, err)
+	}
+	res, _, err = Compare(want, got, Options{})
+	if err != nil || res.Pass || res.DiffPixels != 16 {
+		t.Errorf("alpha compared: %s, %v, want 16 differing pixels", res, err)
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	want := uniform(2, 1, color.NRGBA{200, 200, 200, 0xff})
+	got := uniform(2, 1, color.NRGBA{200, 200, 200, 0xff})
+	got.SetNRGBA(1, 0, color.NRGBA{210, 200, 200, 0xff})
+	_, heatmap, err := Compare(want, got, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Matching pixels are the want luma dimmed to a quarter, a difference
+	// of 10 starts the ramp at 0.25 + 0.75 * 10 / 255 of red.
+	if c := heatmap.NRGBAAt(0, 0); c != (color.NRGBA{50, 50, 50, 0xff}) {
+		t.Errorf("matching pixel %v, want dimmed gray 50", c)
+	}
+	if c := heatmap.NRGBAAt(1, 0); c != (color.NRGBA{213, 0, 0, 0xff}) {
+		t.Errorf("differing pixel %v, want red 213", c)
+	}
+	if c := heat(1); c != (color.NRGBA{255, 255, 255, 0xff}) {
+		t.Errorf("heat(1) = %v, want white", c)
+	}
+}
+
+func TestSSIMSmallImages(t *testing.T) {
+	tests := []struct {
+		name string
+		w, h int
+	}{
+		{"single pixel", 1, 1},
+		{"narrower than a window", 3, 20},
+		{"smaller than a window", 5, 4},
+		{"one window", 8, 8},
+		{"partial last window", 13, 9},
+	}
+	for _, test := range tests {
+		a := gradient(test.w, test.h)
+		if s := SSIM(a, a); math.Abs(s-1) > 1e-12 {
+			t.Errorf("%s: SSIM of equal images %g, want 1", test.name, s)
+		}
+		b := uniform(test.w, test.h, color.NRGBA{255, 255, 255, 0xff})
+		if s := SSIM(a, b); s >= 1 || math.IsNaN(s) {
+			t.Errorf("%s: SSIM of different images %g, want less than 1", test.name, s)
+		}
+	}
+	if s := SSIM(image.NewNRGBA(image.Rect(0, 0, 0, 0)), image.NewNRGBA(image.Rect(0, 0, 0, 0))); s != 1 {
+		t.Errorf("SSIM of empty images %g, want 1", s)
+	}
+}
+
+func TestCompareSizeMismatch(t *testing.T) {
+	if _, _, err := Compare(gradient(8, 8), gradient(8, 9), DefaultOptions()); err == nil {
+		t.Errorf("Compare of 8x8 and 8x9 images succeeded")
+	}
+	// Bounds not starting at the origin compare by size.
+	sub := gradient(16, 16).SubImage(image.Rect(8, 8, 16, 16))
+	if _, _, err := Compare(gradient(8, 8), sub, Options{}); err != nil {
+		t.Errorf("Compare of an 8x8 sub-image: %s", err)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cube.png")
+	img := gradient(16, 16)
+	if _, err := Golden(path, img, DefaultOptions()); err == nil {
+		t.Fatalf("Golden succeeded without a golden image")
+	}
+
+	t.Setenv(UpdateEnv, "1")
+	if res, err := Golden(path, img, DefaultOptions()); err != nil || !res.Pass {
+		t.Fatalf("updating the golden image: %s, %v", res, err)
+	}
+	t.Setenv(UpdateEnv, "")
+	if res, err := Golden(path, img, DefaultOptions()); err != nil || !res.Pass {
+		t.Errorf("comparing with the golden image: %s, %v", res, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cube.diff.png")); err == nil {
+		t.Errorf("a matching image wrote a heatmap")
+	}
+
+	changed := gradient(16, 16)
+	changed.SetNRGBA(3, 3, color.NRGBA{255, 0, 0, 0xff})
+	if _, err := Golden(path, changed, DefaultOptions()); err == nil {
+		t.Fatalf("Golden of a changed image succeeded")
+	}
+	for _, name := range []string{"cube.actual.png", "cube.diff.png"} {
+		if _, err := Load(filepath.Join(dir, name)); err != nil {
+			t.Errorf("mismatch didn't write %s: %s", name, err)
+		}
+	}
+	if _, err := Golden(path, gradient(8, 16), DefaultOptions()); err == nil {
+		t.Errorf("Golden of an image of another size succeeded")
+	}
+}
@@ -0,0 +1,86 @@
+package imgdiff
+
+import "image"
+
+// ssimWindow is the side of the square windows SSIM compares, windows
+// overlap by half their size.
+const ssimWindow = 8
+
+// Stabilizing constants of Wang et al. for 8 bit values.
+const (
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// SSIM is the mean structural similarity of the luma of two images of the
+// same size, 1 for equal images. Images smaller than a window are compared
+// as a single window.
+func SSIM(a, b *image.NRGBA) float64 {
+	la, lb := lumaPlane(a), lumaPlane(b)
+	w, h := a.Bounds().Dx(), a.Bounds().Dy()
+	if w == 0 || h == 0 {
+		return 1
+	}
+	winW, winH := min(ssimWindow, w), min(ssimWindow, h)
+
+	var sum float64
+	var windows int
+	for y := 0; ; y += ssimWindow / 2 {
+		y = min(y, h-winH)
+		for x := 0; ; x += ssimWindow / 2 {
+			x = min(x, w-winW)
+			sum += windowSSIM(la, lb, w, x, y, winW, winH)
+			windows++
+			if x+winW >= w {
+				break
+			}
+		}
+		if y+winH >= h {
+			break
+		}
+	}
+	return sum / float64(windows)
+}
+
+func windowSSIM(a, b []float64, stride, x0, y0, w, h int) float64 {
+	n := float64(w * h)
+	var meanA, meanB float64
+	for y := y0; y < y0+h; y++ {
+		for x := x0; x < x0+w; x++ {
+			meanA += a[y*stride+x]
+			meanB += b[y*stride+x]
+		}
+	}
+	meanA /= n
+	meanB /= n
+
+	var varA, varB, cov float64
+	for y := y0; y < y0+h; y++ {
+		for x := x0; x < x0+w; x++ {
+			da, db := a[y*stride+x]-meanA, b[y*stride+x]-meanB
+			varA += da * da
+			varB += db * db
+			cov += da * db
+		}
+	}
+	if n > 1 {
+		varA /= n - 1
+		varB /= n - 1
+		cov /= n - 1
+	}
+	return ((2*meanA*meanB + ssimC1) * (2*cov + ssimC2)) /
+		((meanA*meanA + meanB*meanB + ssimC1) * (varA + varB + ssimC2))
+}
+
+// lumaPlane returns the luma of each pixel, ignoring alpha.
+func lumaPlane(img *image.NRGBA) []float64 {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	plane := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			plane[y*w+x] = luma(img.Pix[i : i+3])
+		}
+	}
+	return plane
+}
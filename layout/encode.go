@@ -0,0 +1,79 @@
+package layout
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Encode packs v into a new buffer laid out by rules.
+func Encode(rules Rules, v interface{}) ([]byte, error) {
+	t, err := Compile(rules, v)
+	if err != nil {
+		return nil, err
+	}
+	return t.Encode(v)
+}
+
+// Encode packs v, which must be of the type t was compiled from, into a new
+// buffer of t.Size bytes. Padding is zeroed.
+func (t *Type) Encode(v interface{}) ([]byte, error) {
+	buf := make([]byte, t.Size)
+	if err := t.EncodeTo(buf, v); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// EncodeTo packs v into the first t.Size bytes of buf, padding bytes are
+// left untouched.
+func (t *Type) EncodeTo(buf []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return fmt.Errorf("layout: can't encode a nil value")
+	}
+	if rv.Type() != t.GoType {
+		return fmt.Errorf("layout: can't encode %s with the layout of %s", rv.Type(), t.GoType)
+	}
+	if len(buf) < t.Size {
+		return fmt.Errorf("layout: %d bytes don't fit %s of %d bytes", len(buf), t.GoType, t.Size)
+	}
+	t.encode(buf, rv)
+	return nil
+}
+
+// encode writes rv at the start of buf. Values are read with Float, Int and
+// friends which, unlike Interface, also work on unexported fields.
+func (t *Type) encode(buf []byte, rv reflect.Value) {
+	switch t.Kind {
+	case Scalar:
+		switch rv.Kind() {
+		case reflect.Float32:
+			binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(rv.Float())))
+		case reflect.Float64:
+			binary.LittleEndian.PutUint64(buf, math.Float64bits(rv.Float()))
+		case reflect.Int32:
+			binary.LittleEndian.PutUint32(buf, uint32(int32(rv.Int())))
+		case reflect.Uint32:
+			binary.LittleEndian.PutUint32(buf, uint32(rv.Uint()))
+		case reflect.Bool:
+			var b uint32
+			if rv.Bool() {
+				b = 1
+			}
+			binary.LittleEndian.PutUint32(buf, b)
+		}
+	case Vector, Matrix, Array:
+		for i := 0; i < t.Len; i++ {
+			t.Elem.encode(buf[i*t.Stride:], rv.Index(i))
+		}
+	case Struct:
+		for _, f := range t.Fields {
+			f.Type.encode(buf[f.Offset:], rv.Field(f.index))
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package layout packs Go values into the std140 and std430 memory layouts
+// of GLSL uniform and storage blocks.
+//
+// Go types map to GLSL types as follows:
+//
+//	float32, int32, uint32, bool     float, int, uint, bool
+//	float64                          double
+//	[N]scalar, N in 2..4             vecN (use `layout:"array"` for scalar arrays)
+//	[C][R]float32, C and R in 2..4   matCxR, column-major like linmath
+//	[N]T                             T[N]
+//	struct                           struct, fields tagged `layout:"-"` are skipped
+//
+// Matrices are laid out like arrays of their column vectors, which is
+// what both std140 and std430 prescribe for column-major matrices.
+package layout
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Rules selects the layout rules.
+type Rules int
+
+const (
+	// Std140 rounds the alignment of arrays and structs up to a vec4,
+	// it is the only layout for uniform blocks.
+	Std140 Rules = iota
+	// Std430 is the tighter layout of storage blocks and push constants.
+	Std430
+)
+
+func (r Rules) String() string {
+	switch r {
+	case Std140:
+		return "std140"
+	case Std430:
+		return "std430"
+	}
+	return fmt.Sprintf("Rules(%d)", int(r))
+}
+
+type Kind int
+
+const (
+	Scalar Kind = iota
+	Vector
+	Matrix
+	Array
+	Struct
+)
+
+// Type is the layout of a Go type under some rules.
+type Type struct {
+	Kind   Kind
+	GoType reflect.Type
+	// Size and Align in bytes. The size of a struct is rounded up to its
+	// alignment, arrays and matrices are Len times Stride.
+	Size  int
+	Align int
+	// Len and Stride of vectors, matrices (columns) and arrays.
+	Len    int
+	Stride int
+	Elem   *Type
+	Fields []Field
+}
+
+type Field struct {
+	Name   string
+	Offset int
+	Type   *Type
+	index  int
+}
+
+// Field returns the field of a struct layout by its Go name.
+func (t *Type) Field(name string) (Field, bool) {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// String lists the offset, size and alignment of every member, like the
+// reflection output of glslang.
+func (t *Type) String() string {
+	var b strings.Builder
+	t.describe(&b, "", 0)
+	return b.String()
+}
+
+func (t *Type) describe(b *strings.Builder, name string, offset int) {
+	if name != "" {
+		fmt.Fprintf(b, "%s: offset %d, size %d, align %d", name, offset, t.Size, t.Align)
+		if t.Kind == Array || t.Kind == Matrix {
+			fmt.Fprintf(b, ", stride %d", t.Stride)
+		}
+		b.WriteString("\n")
+	}
+	if t.Kind == Struct {
+		for _, f := range t.Fields {
+			fieldName := f.Name
+			if name != "" {
+				fieldName = name + "." + f.Name
+			}
+			f.Type.describe(b, fieldName, offset+f.Offset)
+		}
+	}
+}
+
+type cacheKey struct {
+	rules Rules
+	typ   reflect.Type
+}
+
+var cache sync.Map
+
+// Compile returns the layout of the type of v, which may be a value or a
+// pointer to it. Layouts are cached per type.
+func Compile(rules Rules, v interface{}) (*Type, error) {
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		return nil, fmt.Errorf("layout: nil value")
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return TypeOf(rules, typ)
+}
+
+// MustCompile is like Compile but panics on unsupported types.
+func MustCompile(rules Rules, v interface{}) *Type {
+	t, err := Compile(rules, v)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// TypeOf returns the layout of typ.
+func TypeOf(rules Rules, typ reflect.Type) (*Type, error) {
+	key := cacheKey{rules, typ}
+	if t, ok := cache.Load(key); ok {
+		return t.(*Type), nil
+	}
+	t, err := rules.compile(typ, false)
+	if err != nil {
+		return nil, fmt.Errorf("layout: %s: %s", typ, err)
+	}
+	cache.Store(key, t)
+	return t, nil
+}
+
+func (r Rules) compile(typ reflect.Type, forceArray bool) (*Type, error) {
+	switch typ.Kind() {
+	case reflect.Float32, reflect.Int32, reflect.Uint32, reflect.Bool:
+		return &Type{Kind: Scalar, GoType: typ, Size: 4, Align: 4}, nil
+	case reflect.Float64:
+		return &Type{Kind: Scalar, GoType: typ, Size: 8, Align: 8}, nil
+	case reflect.Array:
+		if !forceArray && isVector(typ) {
+			return r.vector(typ)
+		}
+		elem, err := r.compile(typ.Elem(), false)
+		if err != nil {
+			return nil, err
+		}
+		kind := Array
+		if !forceArray && elem.Kind == Vector && isFloat(elem.GoType.Elem()) &&
+			typ.Len() >= 2 && typ.Len() <= 4 {
+			kind = Matrix
+		}
+		return r.array(typ, kind, elem), nil
+	case reflect.Struct:
+		return r.structure(typ)
+	}
+	return nil, fmt.Errorf("unsupported kind %s", typ.Kind())
+}
+
+func isScalar(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Int32, reflect.Uint32, reflect.Bool:
+		return true
+	}
+	return false
+}
+
+func isFloat(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64
+}
+
+func isVector(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Array && typ.Len() >= 2 && typ.Len() <= 4 && isScalar(typ.Elem())
+}
+
+// vector: a two component vector is aligned to twice its scalar,
+// three and four component vectors to four times.
+func (r Rules) vector(typ reflect.Type) (*Type, error) {
+	scalar, err := r.compile(typ.Elem(), false)
+	if err != nil {
+		return nil, err
+	}
+	n := typ.Len()
+	align := 4 * scalar.Size
+	if n == 2 {
+		align = 2 * scalar.Size
+	}
+	return &Type{
+		Kind:   Vector,
+		GoType: typ,
+		Size:   n * scalar.Size,
+		Align:  align,
+		Len:    n,
+		Stride: scalar.Size,
+		Elem:   scalar,
+	}, nil
+}
+
+// array: the stride is the element size rounded up to its alignment,
+// std140 further rounds alignment and stride up to a vec4.
+func (r Rules) array(typ reflect.Type, kind Kind, elem *Type) *Type {
+	align := elem.Align
+	if r == Std140 {
+		align = roundUp(align, 16)
+	}
+	stride := roundUp(elem.Size, align)
+	return &Type{
+		Kind:   kind,
+		GoType: typ,
+		Size:   stride * typ.Len(),
+		Align:  align,
+		Len:    typ.Len(),
+		Stride: stride,
+		Elem:   elem,
+	}
+}
+
+// structure: members are placed at their alignment in order, the struct
+// is aligned to its largest member, std140 rounds it up to a vec4.
+func (r Rules) structure(typ reflect.Type) (*Type, error) {
+	t := &Type{
+		Kind:   Struct,
+		GoType: typ,
+		Align:  4,
+	}
+	offset := 0
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("layout")
+		if tag == "-" || sf.Name == "_" {
+			continue
+		}
+		ft, err := r.compile(sf.Type, tag == "array")
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %s", sf.Name, err)
+		}
+		offset = roundUp(offset, ft.Align)
+		t.Fields = append(t.Fields, Field{
+			Name:   sf.Name,
+			Offset: offset,
+			Type:   ft,
+			index:  i,
+		})
+		offset += ft.Size
+		if ft.Align > t.Align {
+			t.Align = ft.Align
+		}
+	}
+	if r == Std140 {
+		t.Align = roundUp(t.Align, 16)
+	}
+	t.Size = roundUp(offset, t.Align)
+	return t, nil
+}
+
+func roundUp(v, alignment int) int {
+	return (v + alignment - 1) / alignment * alignment
+}
@@ -0,0 +1,187 @@
+package layout
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type vec3Float struct {
+	V [3]float32
+	F float32
+}
+
+type floatArray struct {
+	A [4]float32 `layout:"array"`
+	F float32
+}
+
+type vec3Array struct {
+	A [5][3]float32
+	F float32
+}
+
+type mat3Float struct {
+	M [3][3]float32
+	F float32
+}
+
+type inner struct {
+	A float32
+	B [2]float32
+}
+
+type nested struct {
+	F  float32
+	In inner
+	G  float32
+	Ar [2]inner
+}
+
+// member is the expected offset, size and stride of a field, stride is
+// zero for scalars, vectors and structs.
+type member struct {
+	name                 string
+	offset, size, stride int
+}
+
+func TestLayouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		value   interface{}
+		size    int
+		members []member
+	}{
+		{"vec3 then float", Std140, vec3Float{}, 16, []member{{"V", 0, 12, 0}, {"F", 12, 4, 0}}},
+		{"vec3 then float", Std430, vec3Float{}, 16, []member{{"V", 0, 12, 0}, {"F", 12, 4, 0}}},
+		{"float array", Std140, floatArray{}, 80, []member{{"A", 0, 64, 16}, {"F", 64, 4, 0}}},
+		{"float array", Std430, floatArray{}, 20, []member{{"A", 0, 16, 4}, {"F", 16, 4, 0}}},
+		{"vec3 array", Std140, vec3Array{}, 96, []member{{"A", 0, 80, 16}, {"F", 80, 4, 0}}},
+		{"vec3 array", Std430, vec3Array{}, 96, []member{{"A", 0, 80, 16}, {"F", 80, 4, 0}}},
+		{"mat3", Std140, mat3Float{}, 64, []member{{"M", 0, 48, 16}, {"F", 48, 4, 0}}},
+		{"mat3", Std430, mat3Float{}, 64, []member{{"M", 0, 48, 16}, {"F", 48, 4, 0}}},
+		{"nested struct", Std140, nested{}, 80,
+			[]member{{"F", 0, 4, 0}, {"In", 16, 16, 0}, {"G", 32, 4, 0}, {"Ar", 48, 32, 16}}},
+		{"nested struct", Std430, nested{}, 64,
+			[]member{{"F", 0, 4, 0}, {"In", 8, 16, 0}, {"G", 24, 4, 0}, {"Ar", 32, 32, 16}}},
+	}
+	for _, test := range tests {
+		typ, err := Compile(test.rules, test.value)
+		if err != nil {
+			t.Fatalf("%s %s: %s", test.rules, test.name, err)
+		}
+		if typ.Size != test.size {
+			t.Errorf("%s %s: size %d, want %d", test.rules, test.name, typ.Size, test.size)
+		}
+		for _, m := range test.members {
+			f, ok := typ.Field(m.name)
+			if !ok {
+				t.Fatalf("%s %s: no field %s", test.rules, test.name, m.name)
+			}
+			if f.Offset != m.offset || f.Type.Size != m.size || f.Type.Stride != m.stride && m.stride != 0 {
+				t.Errorf("%s %s: %s at %d, size %d, stride %d, want %d, %d, %d", test.rules, test.name,
+					m.name, f.Offset, f.Type.Size, f.Type.Stride, m.offset, m.size, m.stride)
+			}
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	bad := []interface{}{
+		nil,
+		struct{ I int }{},
+		struct{ S []float32 }{},
+	}
+	for _, v := range bad {
+		if _, err := Compile(Std140, v); err == nil {
+			t.Errorf("Compile(%T) succeeded", v)
+		}
+	}
+	typ := MustCompile(Std140, vec3Float{})
+	if err := typ.EncodeTo(make([]byte, 8), vec3Float{}); err == nil {
+		t.Errorf("EncodeTo a short buffer succeeded")
+	}
+	if _, err := typ.Encode(mat3Float{}); err == nil {
+		t.Errorf("Encode of another type succeeded")
+	}
+}
+
+// decode is the inverse of Type.encode, for the round-trip test.
+func (t *Type) decode(buf []byte, rv reflect.Value) {
+	switch t.Kind {
+	case Scalar:
+		switch rv.Kind() {
+		case reflect.Float32:
+			rv.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(buf))))
+		case reflect.Float64:
+			rv.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(buf)))
+		case reflect.Int32:
+			rv.SetInt(int64(int32(binary.LittleEndian.Uint32(buf))))
+		case reflect.Uint32:
+			rv.SetUint(uint64(binary.LittleEndian.Uint32(buf)))
+		case reflect.Bool:
+			rv.SetBool(binary.LittleEndian.Uint32(buf) != 0)
+		}
+	case Vector, Matrix, Array:
+		for i := 0; i < t.Len; i++ {
+			t.Elem.decode(buf[i*t.Stride:], rv.Index(i))
+		}
+	case Struct:
+		for _, f := range t.Fields {
+			f.Type.decode(buf[f.Offset:], rv.Field(f.index))
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	type block struct {
+		MVP      [4][4]float32
+		Color    [3]float32
+		Scale    float32
+		Weights  [3]float32 `layout:"array"`
+		Count    int32
+		Flags    uint32
+		Enabled  bool
+		Exposure float64
+		Lights   [2]nested
+		Skipped  float32 `layout:"-"`
+	}
+	in := block{
+		Color:    [3]float32{0.25, 0.5, 0.75},
+		Scale:    2,
+		Weights:  [3]float32{1, 2, 3},
+		Count:    -7,
+		Flags:    0xdeadbeef,
+		Enabled:  true,
+		Exposure: math.Pi,
+		Lights: [2]nested{
+			{F: 1, In: inner{A: 2, B: [2]float32{3, 4}}, G: 5},
+			{F: 6, Ar: [2]inner{{A: 7}, {B: [2]float32{8, 9}}}},
+		},
+		Skipped: 42,
+	}
+	for i := range in.MVP {
+		for j := range in.MVP[i] {
+			in.MVP[i][j] = float32(i*4 + j)
+		}
+	}
+	for _, rules := range []Rules{Std140, Std430} {
+		buf, err := Encode(rules, &in)
+		if err != nil {
+			t.Fatalf("%s: %s", rules, err)
+		}
+		typ := MustCompile(rules, in)
+		if len(buf) != typ.Size {
+			t.Fatalf("%s: encoded %d bytes, the layout has %d", rules, len(buf), typ.Size)
+		}
+		var out block
+		typ.decode(buf, reflect.ValueOf(&out).Elem())
+		want := in
+		want.Skipped = 0
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("%s: decoded %+v, want %+v", rules, out, want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package memalloc sub-allocates large blocks of device memory.
+//
+// The bookkeeping doesn't depend on Vulkan: blocks are obtained from a
+// Backend, the renderer package provides one on top of vk.AllocateMemory.
+package memalloc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultBlockSize is the size of the blocks requested from the backend.
+const DefaultBlockSize = 64 << 20
+
+var ErrZeroSize = errors.New("memalloc: zero sized allocation")
+
+// Backend provides the memory blocks the allocator hands out.
+type Backend interface {
+	AllocateMemory(memoryType uint32, size uint64) (interface{}, error)
+	FreeMemory(memoryType uint32, memory interface{})
+}
+
+type Config struct {
+	// BlockSize is the size of a regular block, zero means DefaultBlockSize.
+	BlockSize uint64
+	// Granularity is the bufferImageGranularity limit of the device.
+	Granularity uint64
+}
+
+type Request struct {
+	Size      uint64
+	Alignment uint64
+	Kind      Kind
+	// Dedicated asks for a block of its own, requests larger than half
+	// a block always get one.
+	Dedicated bool
+	Movable   bool
+	UserData  interface{}
+}
+
+type pool struct {
+	memoryType uint32
+	blocks     []*Block
+}
+
+// Allocator keeps one pool of blocks per memory type.
+type Allocator struct {
+	backend Backend
+	config  Config
+	pools   map[uint32]*pool
+}
+
+func New(backend Backend, config Config) *Allocator {
+	if config.BlockSize == 0 {
+		config.BlockSize = DefaultBlockSize
+	}
+	return &Allocator{
+		backend: backend,
+		config:  config,
+		pools:   make(map[uint32]*pool),
+	}
+}
+
+func (a *Allocator) Config() Config {
+	return a.config
+}
+
+func (a *Allocator) pool(memoryType uint32) *pool {
+	p, ok := a.pools[memoryType]
+	if !ok {
+		p = &pool{memoryType: memoryType}
+		a.pools[memoryType] = p
+	}
+	return p
+}
+
+// Allocate hands out req.Size bytes of the given memory type, creating a
+// new block when none of the existing ones has room.
+func (a *Allocator) Allocate(memoryType uint32, req Request) (*Allocation, error) {
+	if req.Size == 0 {
+		return nil, ErrZeroSize
+	}
+	if req.Alignment == 0 {
+		req.Alignment = 1
+	}
+	alloc := &Allocation{
+		Size:      req.Size,
+		Alignment: req.Alignment,
+		Kind:      req.Kind,
+		Movable:   req.Movable,
+		UserData:  req.UserData,
+	}
+	p := a.pool(memoryType)
+
+	if req.Dedicated || req.Size > a.config.BlockSize/2 {
+		memory, err := a.backend.AllocateMemory(memoryType, req.Size)
+		if err != nil {
+			return nil, err
+		}
+		b := newBlock(memory, memoryType, req.Size, true)
+		b.place(0, 0, alloc)
+		p.blocks = append(p.blocks, b)
+		return alloc, nil
+	}
+
+	for _, b := range p.blocks {
+		if b.Dedicated || b.Size-b.used < req.Size {
+			continue
+		}
+		if i, offset, ok := b.fit(req.Size, req.Alignment, req.Kind, a.config.Granularity); ok {
+			b.place(i, offset, alloc)
+			return alloc, nil
+		}
+	}
+
+	memory, err := a.backend.AllocateMemory(memoryType, a.config.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	b := newBlock(memory, memoryType, a.config.BlockSize, false)
+	p.blocks = append(p.blocks, b)
+	i, offset, ok := b.fit(req.Size, req.Alignment, req.Kind, a.config.Granularity)
+	if !ok {
+		// Only possible when alignment padding exceeds the block.
+		return nil, fmt.Errorf("memalloc: %d bytes aligned to %d don't fit a block of %d bytes",
+			req.Size, req.Alignment, a.config.BlockSize)
+	}
+	b.place(i, offset, alloc)
+	return alloc, nil
+}
+
+// Free returns the allocation to its block. Dedicated blocks are released
+// right away, of the empty regular blocks one is kept per memory type.
+func (a *Allocator) Free(alloc *Allocation) error {
+	if alloc == nil || alloc.block == nil {
+		return nil
+	}
+	b := alloc.block
+	if !b.release(alloc) {
+		return fmt.Errorf("memalloc: allocation at %d is not part of its block", alloc.Offset)
+	}
+	alloc.block = nil
+	if b.Empty() {
+		a.trim(a.pool(b.MemoryType))
+	}
+	return nil
+}
+
+// trim frees dedicated blocks without allocations and all but one
+// empty regular block.
+func (a *Allocator) trim(p *pool) {
+	keptEmpty := false
+	blocks := p.blocks[:0]
+	for _, b := range p.blocks {
+		if b.Empty() && (b.Dedicated || keptEmpty) {
+			a.backend.FreeMemory(b.MemoryType, b.Memory)
+			continue
+		}
+		if b.Empty() {
+			keptEmpty = true
+		}
+		blocks = append(blocks, b)
+	}
+	for i := len(blocks); i < len(p.blocks); i++ {
+		p.blocks[i] = nil
+	}
+	p.blocks = blocks
+}
+
+// Blocks returns the blocks of a memory type.
+func (a *Allocator) Blocks(memoryType uint32) []*Block {
+	p, ok := a.pools[memoryType]
+	if !ok {
+		return nil
+	}
+	return append([]*Block(nil), p.blocks...)
+}
+
+// Destroy frees every block, allocations still alive become invalid.
+func (a *Allocator) Destroy() {
+	for _, p := range a.pools {
+		for _, b := range p.blocks {
+			a.backend.FreeMemory(b.MemoryType, b.Memory)
+		}
+		p.blocks = nil
+	}
+	a.pools = make(map[uint32]*pool)
+}
+
+// Validate checks the bookkeeping of every block.
+func (a *Allocator) Validate() error {
+	for _, memoryType := range a.memoryTypes() {
+		for i, b := range a.pools[memoryType].blocks {
+			if err := b.Validate(a.config.Granularity); err != nil {
+				return fmt.Errorf("memory type %d block %d: %s", memoryType, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Allocator) memoryTypes() []uint32 {
+	types := make([]uint32, 0, len(a.pools))
+	for memoryType := range a.pools {
+		types = append(types, memoryType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+type Stats struct {
+	Blocks      int
+	Allocations int
+	// BlockBytes is the memory taken from the backend, UsedBytes the
+	// part of it handed out.
+	BlockBytes  uint64
+	UsedBytes   uint64
+	FreeRanges  int
+	LargestFree uint64
+}
+
+func (s *Stats) add(o Stats) {
+	s.Blocks += o.Blocks
+	s.Allocations += o.Allocations
+	s.BlockBytes += o.BlockBytes
+	s.UsedBytes += o.UsedBytes
+	s.FreeRanges += o.FreeRanges
+	if o.LargestFree > s.LargestFree {
+		s.LargestFree = o.LargestFree
+	}
+}
+
+// Fragmentation is 0 when all free memory is one range and approaches
+// 1 as it is split into many small ones.
+func (s Stats) Fragmentation() float64 {
+	free := s.BlockBytes - s.UsedBytes
+	if free == 0 {
+		return 0
+	}
+	return 1 - float64(s.LargestFree)/float64(free)
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d allocations in %d blocks, %d of %d bytes used, %d free ranges",
+		s.Allocations, s.Blocks, s.UsedBytes, s.BlockBytes, s.FreeRanges)
+}
+
+// Stats returns the usage of a single memory type.
+func (a *Allocator) Stats(memoryType uint32) Stats {
+	var stats Stats
+	p, ok := a.pools[memoryType]
+	if !ok {
+		return stats
+	}
+	for _, b := range p.blocks {
+		count, _, largest := b.freeRanges()
+		stats.add(Stats{
+			Blocks:      1,
+			Allocations: b.count,
+			BlockBytes:  b.Size,
+			UsedBytes:   b.used,
+			FreeRanges:  count,
+			LargestFree: largest,
+		})
+	}
+	return stats
+}
+
+// TotalStats returns the usage summed over all memory types.
+func (a *Allocator) TotalStats() Stats {
+	var stats Stats
+	for memoryType := range a.pools {
+		stats.add(a.Stats(memoryType))
+	}
+	return stats
+}
@@ -0,0 +1,281 @@
+package memalloc
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Kind is what a range of memory gets bound to. Linear and optimally tiled
+// resources must not share a page of bufferImageGranularity bytes.
+type Kind int
+
+const (
+	// KindUnknown conflicts with every other kind.
+	KindUnknown Kind = iota
+	KindBuffer
+	KindImageLinear
+	KindImageOptimal
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindUnknown:
+		return "unknown"
+	case KindBuffer:
+		return "buffer"
+	case KindImageLinear:
+		return "linear image"
+	case KindImageOptimal:
+		return "optimal image"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+func conflicts(a, b Kind) bool {
+	if a == KindUnknown || b == KindUnknown {
+		return true
+	}
+	return (a == KindImageOptimal) != (b == KindImageOptimal)
+}
+
+// Allocation is a range of a Block handed out by Allocate. Offset and the
+// block may change when the allocation is moved by Defragment.
+type Allocation struct {
+	block *Block
+
+	Offset    uint64
+	Size      uint64
+	Alignment uint64
+	Kind      Kind
+	// Movable allows Defragment to move the allocation.
+	Movable bool
+	// UserData is not used by the allocator, it lets a Mover find the
+	// resource bound to the allocation.
+	UserData interface{}
+}
+
+func (a *Allocation) Block() *Block {
+	return a.block
+}
+
+// Memory returns the backend handle of the block the allocation lives in.
+func (a *Allocation) Memory() interface{} {
+	return a.block.Memory
+}
+
+func (a *Allocation) MemoryType() uint32 {
+	return a.block.MemoryType
+}
+
+// segment is a used or free range of a block, alloc is nil when free.
+type segment struct {
+	offset uint64
+	size   uint64
+	alloc  *Allocation
+}
+
+func (s *segment) end() uint64 {
+	return s.offset + s.size
+}
+
+// Block is a single allocation of the backend that is handed out in
+// smaller pieces. The segments cover the whole block in offset order and
+// two free segments are never adjacent.
+type Block struct {
+	// Memory is the handle returned by Backend.AllocateMemory.
+	Memory     interface{}
+	MemoryType uint32
+	Size       uint64
+	// Dedicated blocks hold a single allocation and are freed with it.
+	Dedicated bool
+
+	segments []segment
+	used     uint64
+	count    int
+}
+
+func newBlock(memory interface{}, memoryType uint32, size uint64, dedicated bool) *Block {
+	return &Block{
+		Memory:     memory,
+		MemoryType: memoryType,
+		Size:       size,
+		Dedicated:  dedicated,
+		segments:   []segment{{offset: 0, size: size}},
+	}
+}
+
+// Used returns the bytes handed out, not counting alignment padding.
+func (b *Block) Used() uint64 {
+	return b.used
+}
+
+// Allocations returns the number of live allocations in the block.
+func (b *Block) Allocations() int {
+	return b.count
+}
+
+func (b *Block) Empty() bool {
+	return b.count == 0
+}
+
+func alignUp(v, alignment uint64) uint64 {
+	if alignment <= 1 {
+		return v
+	}
+	return (v + alignment - 1) / alignment * alignment
+}
+
+func samePage(lastByte, firstByte, pageSize uint64) bool {
+	return lastByte/pageSize == firstByte/pageSize
+}
+
+// fit finds the best fitting free segment for size bytes. It returns the
+// segment index and the offset the allocation would start at.
+func (b *Block) fit(size, alignment uint64, kind Kind, granularity uint64) (int, uint64, bool) {
+	best, bestOffset, found := -1, uint64(0), false
+	for i := range b.segments {
+		seg := &b.segments[i]
+		if seg.alloc != nil || seg.size < size {
+			continue
+		}
+		offset := alignUp(seg.offset, alignment)
+		if granularity > 1 && i > 0 {
+			prev := &b.segments[i-1]
+			if prev.alloc != nil && conflicts(prev.alloc.Kind, kind) &&
+				samePage(prev.end()-1, offset, granularity) {
+				offset = alignUp(offset, granularity)
+			}
+		}
+		if offset < seg.offset || offset+size < offset || offset+size > seg.end() {
+			continue
+		}
+		if granularity > 1 && i+1 < len(b.segments) {
+			next := &b.segments[i+1]
+			if next.alloc != nil && conflicts(next.alloc.Kind, kind) &&
+				samePage(offset+size-1, next.offset, granularity) {
+				continue
+			}
+		}
+		if !found || seg.size < b.segments[best].size {
+			best, bestOffset, found = i, offset, true
+		}
+	}
+	return best, bestOffset, found
+}
+
+// place splits the free segment i and puts alloc at offset in it.
+func (b *Block) place(i int, offset uint64, alloc *Allocation) {
+	seg := b.segments[i]
+	var parts []segment
+	if offset > seg.offset {
+		parts = append(parts, segment{offset: seg.offset, size: offset - seg.offset})
+	}
+	parts = append(parts, segment{offset: offset, size: alloc.Size, alloc: alloc})
+	if end := offset + alloc.Size; end < seg.end() {
+		parts = append(parts, segment{offset: end, size: seg.end() - end})
+	}
+
+	segments := make([]segment, 0, len(b.segments)+len(parts)-1)
+	segments = append(segments, b.segments[:i]...)
+	segments = append(segments, parts...)
+	segments = append(segments, b.segments[i+1:]...)
+	b.segments = segments
+
+	alloc.block = b
+	alloc.Offset = offset
+	b.used += alloc.Size
+	b.count++
+}
+
+func (b *Block) find(alloc *Allocation) int {
+	i := sort.Search(len(b.segments), func(i int) bool {
+		return b.segments[i].offset >= alloc.Offset
+	})
+	if i < len(b.segments) && b.segments[i].alloc == alloc {
+		return i
+	}
+	return -1
+}
+
+// release frees the segment of alloc and merges it with free neighbours.
+func (b *Block) release(alloc *Allocation) bool {
+	i := b.find(alloc)
+	if i < 0 {
+		return false
+	}
+	b.segments[i].alloc = nil
+	b.used -= alloc.Size
+	b.count--
+
+	if i+1 < len(b.segments) && b.segments[i+1].alloc == nil {
+		b.segments[i].size += b.segments[i+1].size
+		b.segments = append(b.segments[:i+1], b.segments[i+2:]...)
+	}
+	if i > 0 && b.segments[i-1].alloc == nil {
+		b.segments[i-1].size += b.segments[i].size
+		b.segments = append(b.segments[:i], b.segments[i+1:]...)
+	}
+	return true
+}
+
+func (b *Block) freeRanges() (count int, free, largest uint64) {
+	for i := range b.segments {
+		seg := &b.segments[i]
+		if seg.alloc != nil {
+			continue
+		}
+		count++
+		free += seg.size
+		if seg.size > largest {
+			largest = seg.size
+		}
+	}
+	return count, free, largest
+}
+
+// Validate checks the bookkeeping invariants of the block.
+func (b *Block) Validate(granularity uint64) error {
+	var offset, used uint64
+	count := 0
+	for i := range b.segments {
+		seg := &b.segments[i]
+		if seg.offset != offset {
+			return fmt.Errorf("segment %d starts at %d, expected %d", i, seg.offset, offset)
+		}
+		if seg.size == 0 {
+			return fmt.Errorf("segment %d is empty", i)
+		}
+		if seg.alloc == nil {
+			if i > 0 && b.segments[i-1].alloc == nil {
+				return fmt.Errorf("free segments %d and %d are not merged", i-1, i)
+			}
+		} else {
+			a := seg.alloc
+			if a.block != b || a.Offset != seg.offset || a.Size != seg.size {
+				return fmt.Errorf("segment %d doesn't match its allocation", i)
+			}
+			if a.Alignment > 1 && a.Offset%a.Alignment != 0 {
+				return fmt.Errorf("allocation at %d is not aligned to %d", a.Offset, a.Alignment)
+			}
+			if granularity > 1 {
+				for j := i - 1; j >= 0 && samePage(b.segments[j].end()-1, a.Offset, granularity); j-- {
+					if prev := b.segments[j].alloc; prev != nil && conflicts(prev.Kind, a.Kind) {
+						return fmt.Errorf("%s at %d shares a page with %s at %d",
+							a.Kind, a.Offset, prev.Kind, prev.Offset)
+					}
+				}
+			}
+			used += a.Size
+			count++
+		}
+		offset = seg.end()
+	}
+	if offset != b.Size {
+		return fmt.Errorf("segments end at %d, block size is %d", offset, b.Size)
+	}
+	if used != b.used || count != b.count {
+		return fmt.Errorf("block counts %d allocations of %d bytes, found %d of %d",
+			b.count, b.used, count, used)
+	}
+	return nil
+}
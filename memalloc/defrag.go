@@ -0,0 +1,120 @@
+package memalloc
+
+import (
+	"sort"
+)
+
+// Move describes an allocation being relocated by Defragment.
+type Move struct {
+	Allocation *Allocation
+
+	SrcBlock  *Block
+	SrcOffset uint64
+	DstBlock  *Block
+	DstOffset uint64
+}
+
+// Mover copies the content of an allocation to its new place and rebinds
+// the resource using it. If it returns an error the allocation stays
+// where it was and defragmentation stops.
+type Mover func(m Move) error
+
+// Defragment moves movable allocations out of the least used blocks of a
+// memory type into fuller ones, so the emptied blocks can be released.
+// At most maxMoves allocations are moved, zero means no limit. It returns
+// the number of moves done.
+func (a *Allocator) Defragment(memoryType uint32, mover Mover, maxMoves int) (int, error) {
+	p, ok := a.pools[memoryType]
+	if !ok {
+		return 0, nil
+	}
+	blocks := make([]*Block, 0, len(p.blocks))
+	for _, b := range p.blocks {
+		if !b.Dedicated {
+			blocks = append(blocks, b)
+		}
+	}
+	// Fullest blocks first, they are the destinations.
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].used > blocks[j].used
+	})
+
+	moves := 0
+	defer a.trim(p)
+	for src := len(blocks) - 1; src > 0; src-- {
+		for _, alloc := range blocks[src].allocations() {
+			if !alloc.Movable {
+				continue
+			}
+			if maxMoves > 0 && moves >= maxMoves {
+				return moves, nil
+			}
+			moved, err := a.move(alloc, blocks[:src], mover)
+			if err != nil {
+				return moves, err
+			}
+			if moved {
+				moves++
+			}
+		}
+	}
+	return moves, nil
+}
+
+func (b *Block) allocations() []*Allocation {
+	allocs := make([]*Allocation, 0, b.count)
+	for i := range b.segments {
+		if b.segments[i].alloc != nil {
+			allocs = append(allocs, b.segments[i].alloc)
+		}
+	}
+	return allocs
+}
+
+func (a *Allocator) move(alloc *Allocation, dst []*Block, mover Mover) (bool, error) {
+	for _, b := range dst {
+		if b.Size-b.used < alloc.Size {
+			continue
+		}
+		i, offset, ok := b.fit(alloc.Size, alloc.Alignment, alloc.Kind, a.config.Granularity)
+		if !ok {
+			continue
+		}
+		m := Move{
+			Allocation: alloc,
+			SrcBlock:   alloc.block,
+			SrcOffset:  alloc.Offset,
+			DstBlock:   b,
+			DstOffset:  offset,
+		}
+		// Reserve the destination with a placeholder so the mover
+		// sees both ranges as in use.
+		placeholder := &Allocation{
+			Size:      alloc.Size,
+			Alignment: alloc.Alignment,
+			Kind:      alloc.Kind,
+		}
+		b.place(i, offset, placeholder)
+		if err := mover(m); err != nil {
+			b.release(placeholder)
+			return false, err
+		}
+		b.release(placeholder)
+		m.SrcBlock.release(alloc)
+		i, _ = b.findFree(offset, alloc.Size)
+		b.place(i, offset, alloc)
+		return true, nil
+	}
+	return false, nil
+}
+
+// findFree returns the free segment containing [offset, offset+size).
+func (b *Block) findFree(offset, size uint64) (int, bool) {
+	for i := range b.segments {
+		seg := &b.segments[i]
+		if seg.alloc == nil && seg.offset <= offset && offset+size <= seg.end() {
+			return i, true
+		}
+	}
+	return -1, false
+}
@@ -0,0 +1,265 @@
+package memalloc
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+// fakeBackend hands out numbered blocks and tracks which are live.
+type fakeBackend struct {
+	next int
+	live map[int]uint64
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{live: make(map[int]uint64)}
+}
+
+func (f *fakeBackend) AllocateMemory(memoryType uint32, size uint64) (interface{}, error) {
+	f.next++
+	f.live[f.next] = size
+	return f.next, nil
+}
+
+func (f *fakeBackend) FreeMemory(memoryType uint32, memory interface{}) {
+	delete(f.live, memory.(int))
+}
+
+func TestAllocateAlignment(t *testing.T) {
+	a := New(newFakeBackend(), Config{BlockSize: 4096})
+	tests := []struct {
+		size, alignment uint64
+		offset          uint64
+	}{
+		{3, 1, 0},
+		{8, 16, 16},
+		{1, 0, 3},
+		{100, 256, 256},
+		{4, 4, 4},
+	}
+	for _, test := range tests {
+		alloc, err := a.Allocate(0, Request{Size: test.size, Alignment: test.alignment, Kind: KindBuffer})
+		if err != nil {
+			t.Fatalf("Allocate(%d, %d): %s", test.size, test.alignment, err)
+		}
+		if alloc.Offset != test.offset {
+			t.Errorf("Allocate(%d, %d) at %d, want %d", test.size, test.alignment, alloc.Offset, test.offset)
+		}
+	}
+	if err := a.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Allocate(0, Request{}); !errors.Is(err, ErrZeroSize) {
+		t.Errorf("Allocate of zero bytes: %v, want ErrZeroSize", err)
+	}
+}
+
+func TestAllocateGranularity(t *testing.T) {
+	a := New(newFakeBackend(), Config{BlockSize: 8192, Granularity: 1024})
+	tests := []struct {
+		name   string
+		size   uint64
+		kind   Kind
+		offset uint64
+	}{
+		{"buffer", 100, KindBuffer, 0},
+		{"optimal image after a buffer", 512, KindImageOptimal, 1024},
+		{"buffer next to a buffer", 50, KindBuffer, 100},
+		{"optimal image next to an optimal image", 256, KindImageOptimal, 1536},
+		{"linear image in the page of a buffer", 64, KindImageLinear, 150},
+		{"unknown conflicts with everything", 64, KindUnknown, 2048},
+	}
+	for _, test := range tests {
+		alloc, err := a.Allocate(0, Request{Size: test.size, Kind: test.kind})
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if alloc.Offset != test.offset {
+			t.Errorf("%s: at %d, want %d", test.name, alloc.Offset, test.offset)
+		}
+		if err := a.Validate(); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+	}
+}
+
+func TestAllocateDedicated(t *testing.T) {
+	backend := newFakeBackend()
+	a := New(backend, Config{BlockSize: 4096})
+	alloc, err := a.Allocate(0, Request{Size: 3000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !alloc.Block().Dedicated || alloc.Block().Size != 3000 {
+		t.Errorf("allocation of more than half a block isn't dedicated")
+	}
+	if err := a.Free(alloc); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.live) != 0 || len(a.Blocks(0)) != 0 {
+		t.Errorf("dedicated block not released, %d live blocks", len(backend.live))
+	}
+}
+
+func TestRingWraparound(t *testing.T) {
+	r := NewRing(256)
+	steps := []struct {
+		name      string
+		release   uint64
+		commit    uint64
+		size      uint64
+		alignment uint64
+		offset    uint64
+		ok        bool
+	}{
+		{name: "first", commit: 1, size: 100, offset: 0, ok: true},
+		{name: "second", commit: 2, size: 100, offset: 100, ok: true},
+		{name: "no room at the end nor the start", size: 100},
+		{name: "wraps around", release: 1, commit: 3, size: 100, offset: 0, ok: true},
+		{name: "full", size: 1},
+		{name: "aligned between head and tail", release: 2, size: 64, alignment: 64, offset: 128, ok: true},
+		{name: "past the tail", size: 16},
+		{name: "larger than the ring", size: 512},
+	}
+	for _, step := range steps {
+		if step.release != 0 {
+			r.Release(step.release)
+		}
+		offset, ok := r.Alloc(step.size, step.alignment)
+		if ok != step.ok || (ok && offset != step.offset) {
+			t.Fatalf("%s: Alloc(%d, %d) = %d, %v, want %d, %v",
+				step.name, step.size, step.alignment, offset, ok, step.offset, step.ok)
+		}
+		if step.commit != 0 {
+			r.Commit(step.commit)
+		}
+	}
+	if ticket, ok := r.Pending(); !ok || ticket != 3 {
+		t.Errorf("Pending() = %d, %v, want 3", ticket, ok)
+	}
+
+	// Releasing everything resets the ring once nothing is uncommitted.
+	r.Commit(4)
+	r.Release(4)
+	if _, ok := r.Pending(); ok {
+		t.Errorf("tickets pending after releasing all of them")
+	}
+	if offset, ok := r.Alloc(256, 1); !ok || offset != 0 {
+		t.Errorf("Alloc of the whole empty ring = %d, %v", offset, ok)
+	}
+}
+
+// checkAllocations verifies that the live allocations are in blocks of the
+// allocator, inside them and don't overlap.
+func checkAllocations(t *testing.T, a *Allocator, live []*Allocation) {
+	t.Helper()
+	blocks := make(map[*Block]bool)
+	for _, memoryType := range a.memoryTypes() {
+		for _, b := range a.Blocks(memoryType) {
+			blocks[b] = true
+		}
+	}
+	byBlock := make(map[*Block][]*Allocation)
+	for _, alloc := range live {
+		b := alloc.Block()
+		if !blocks[b] {
+			t.Fatalf("allocation at %d is in a released block", alloc.Offset)
+		}
+		if alloc.Offset+alloc.Size > b.Size {
+			t.Fatalf("allocation at %d of %d bytes ends past its block of %d", alloc.Offset, alloc.Size, b.Size)
+		}
+		byBlock[b] = append(byBlock[b], alloc)
+	}
+	for _, allocs := range byBlock {
+		sort.Slice(allocs, func(i, j int) bool { return allocs[i].Offset < allocs[j].Offset })
+		for i := 1; i < len(allocs); i++ {
+			if prev := allocs[i-1]; prev.Offset+prev.Size > allocs[i].Offset {
+				t.Fatalf("allocations at %d and %d overlap", prev.Offset, allocs[i].Offset)
+			}
+		}
+	}
+}
+
+func FuzzAllocator(f *testing.F) {
+	f.Add([]byte{0, 10, 1, 3, 0, 200, 4, 1, 1, 0, 2, 0})
+	f.Add([]byte{0, 255, 7, 2, 0, 1, 0, 3, 0, 90, 2, 5, 1, 1, 2, 0, 0, 128, 1, 0})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		backend := newFakeBackend()
+		a := New(backend, Config{BlockSize: 4096, Granularity: 256})
+		var live []*Allocation
+		next := func() byte {
+			if len(ops) == 0 {
+				return 0
+			}
+			b := ops[0]
+			ops = ops[1:]
+			return b
+		}
+		for len(ops) > 0 {
+			switch next() % 3 {
+			case 0:
+				size := uint64(next())*16 + 1
+				flags := next()
+				req := Request{
+					Size:      size,
+					Alignment: 1 << (flags % 8),
+					Kind:      Kind(flags >> 3 % 4),
+					Dedicated: flags&0x80 != 0 && flags&0x20 != 0,
+					Movable:   flags&0x40 != 0,
+				}
+				memoryType := uint32(next() % 2)
+				alloc, err := a.Allocate(memoryType, req)
+				if err != nil {
+					t.Fatalf("Allocate(%d, %+v): %s", memoryType, req, err)
+				}
+				live = append(live, alloc)
+			case 1:
+				if len(live) == 0 {
+					continue
+				}
+				i := int(next()) % len(live)
+				if err := a.Free(live[i]); err != nil {
+					t.Fatal(err)
+				}
+				live = append(live[:i], live[i+1:]...)
+			case 2:
+				memoryType := uint32(next() % 2)
+				_, err := a.Defragment(memoryType, func(m Move) error {
+					if m.DstBlock == m.SrcBlock && m.DstOffset < m.SrcOffset+m.Allocation.Size &&
+						m.SrcOffset < m.DstOffset+m.Allocation.Size {
+						t.Fatalf("move from %d to %d overlaps itself", m.SrcOffset, m.DstOffset)
+					}
+					return nil
+				}, int(next()%4))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := a.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			checkAllocations(t, a, live)
+		}
+
+		blocks := 0
+		for _, memoryType := range a.memoryTypes() {
+			blocks += len(a.Blocks(memoryType))
+		}
+		if blocks != len(backend.live) {
+			t.Fatalf("allocator has %d blocks, backend %d", blocks, len(backend.live))
+		}
+		for _, alloc := range live {
+			if err := a.Free(alloc); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if stats := a.TotalStats(); stats.Allocations != 0 || stats.Blocks > 2 {
+			t.Fatalf("after freeing everything: %s", stats)
+		}
+		a.Destroy()
+		if len(backend.live) != 0 {
+			t.Fatalf("%d blocks leaked", len(backend.live))
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package memalloc
+
+// Ring hands out ranges of a fixed size buffer in FIFO order, like a
+// staging or per-frame buffer that the GPU reads behind the CPU.
+// Allocations are grouped under a ticket with Commit and released
+// together once the GPU is done with that ticket.
+type Ring struct {
+	size uint64
+
+	// head is where the next allocation starts, tail is the start of
+	// the oldest live range. They are equal both when the ring is
+	// empty and when it is full, live tells them apart.
+	head, tail uint64
+	live       bool
+	dirty      bool
+
+	spans []ringSpan
+}
+
+type ringSpan struct {
+	ticket uint64
+	end    uint64
+}
+
+func NewRing(size uint64) *Ring {
+	return &Ring{size: size}
+}
+
+func (r *Ring) Size() uint64 {
+	return r.size
+}
+
+// Alloc reserves size bytes aligned to alignment. It fails when the
+// free space in front of head is too small, the caller has to release
+// older tickets and try again.
+func (r *Ring) Alloc(size, alignment uint64) (uint64, bool) {
+	if size == 0 || size > r.size {
+		return 0, false
+	}
+	if !r.live {
+		r.head, r.tail = 0, 0
+	}
+	var offset uint64
+	switch {
+	case !r.live || r.head > r.tail:
+		// Free space is [head, size) and [0, tail).
+		offset = alignUp(r.head, alignment)
+		if offset < r.head || offset+size > r.size {
+			if r.live && size > r.tail {
+				return 0, false
+			}
+			offset = 0
+		}
+	case r.head < r.tail:
+		offset = alignUp(r.head, alignment)
+		if offset < r.head || offset+size > r.tail {
+			return 0, false
+		}
+	default:
+		// head == tail on a live ring, it is full.
+		return 0, false
+	}
+	r.head = offset + size
+	r.live = true
+	r.dirty = true
+	return offset, true
+}
+
+// Commit assigns all allocations since the previous Commit to ticket.
+// Tickets have to increase.
+func (r *Ring) Commit(ticket uint64) {
+	if !r.dirty {
+		return
+	}
+	r.spans = append(r.spans, ringSpan{ticket: ticket, end: r.head})
+	r.dirty = false
+}
+
+// Release frees the ranges of every ticket up to and including ticket.
+func (r *Ring) Release(ticket uint64) {
+	n := 0
+	for n < len(r.spans) && r.spans[n].ticket <= ticket {
+		r.tail = r.spans[n].end
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	r.spans = append(r.spans[:0], r.spans[n:]...)
+	if len(r.spans) == 0 && !r.dirty {
+		r.live = false
+		r.head, r.tail = 0, 0
+	}
+}
+
+// Pending returns the oldest ticket still holding ranges of the ring.
+func (r *Ring) Pending() (uint64, bool) {
+	if len(r.spans) == 0 {
+		return 0, false
+	}
+	return r.spans[0].ticket, true
+}
+
+// Reset drops all allocations, live or not.
+func (r *Ring) Reset() {
+	r.head, r.tail = 0, 0
+	r.live, r.dirty = false, false
+	r.spans = r.spans[:0]
+}
@@ -0,0 +1,154 @@
+// Package pixels converts texels read back from the GPU into images and
+// encodes them as PNG. It has no Vulkan dependency, buffers can be built
+// by hand.
+package pixels
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+)
+
+// Format is the order of the four 8 bit channels of a texel.
+type Format int
+
+const (
+	RGBA8 Format = iota
+	BGRA8
+)
+
+func (f Format) String() string {
+	switch f {
+	case RGBA8:
+		return "RGBA8"
+	case BGRA8:
+		return "BGRA8"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Buffer is a copy of an image in host memory.
+type Buffer struct {
+	Data          []byte
+	Width, Height int
+	// RowPitch is the distance between the starts of two rows in bytes,
+	// zero for tightly packed rows.
+	RowPitch int
+	Format   Format
+	// Opaque ignores the alpha channel, swapchain images are usually
+	// presented without it.
+	Opaque bool
+}
+
+const texelSize = 4
+
+func (b *Buffer) pitch() int {
+	if b.RowPitch == 0 {
+		return b.Width * texelSize
+	}
+	return b.RowPitch
+}
+
+// Validate checks that the rows fit the data.
+func (b *Buffer) Validate() error {
+	if b.Width <= 0 || b.Height <= 0 {
+		return fmt.Errorf("invalid size %dx%d", b.Width, b.Height)
+	}
+	if b.Format != RGBA8 && b.Format != BGRA8 {
+		return fmt.Errorf("unsupported format %s", b.Format)
+	}
+	pitch := b.pitch()
+	if pitch < b.Width*texelSize {
+		return fmt.Errorf("row pitch %d is less than a row of %d bytes", pitch, b.Width*texelSize)
+	}
+	if need := pitch*(b.Height-1) + b.Width*texelSize; len(b.Data) < need {
+		return fmt.Errorf("%d bytes of data for %dx%d texels with a row pitch of %d, want %d",
+			len(b.Data), b.Width, b.Height, pitch, need)
+	}
+	return nil
+}
+
+// LinearToSRGB applies the sRGB transfer function to v in [0, 1].
+func LinearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// SRGBToLinear is the inverse of LinearToSRGB.
+func SRGBToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// ToNRGBA converts the texels into an image with tightly packed RGBA rows.
+// Texels are copied as they are, those of UNORM and SRGB images are both
+// already what the display shows.
+func ToNRGBA(b Buffer) (*image.NRGBA, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, b.Width, b.Height))
+	pitch := b.pitch()
+	for y := 0; y < b.Height; y++ {
+		src := b.Data[y*pitch : y*pitch+b.Width*texelSize]
+		dst := img.Pix[y*img.Stride : y*img.Stride+b.Width*texelSize]
+		for x := 0; x < len(src); x += texelSize {
+			r, g, bl, a := src[x], src[x+1], src[x+2], src[x+3]
+			if b.Format == BGRA8 {
+				r, bl = bl, r
+			}
+			if b.Opaque {
+				a = 0xff
+			}
+			dst[x], dst[x+1], dst[x+2], dst[x+3] = r, g, bl, a
+		}
+	}
+	return img, nil
+}
+
+// FromImage copies any image into a tightly packed RGBA8 buffer.
+func FromImage(img image.Image) Buffer {
+	bounds := img.Bounds()
+	b := Buffer{
+		Data:   make([]byte, bounds.Dx()*bounds.Dy()*texelSize),
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		Format: RGBA8,
+	}
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			i := (y*b.Width + x) * texelSize
+			b.Data[i], b.Data[i+1], b.Data[i+2], b.Data[i+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	return b
+}
+
+func EncodePNG(w io.Writer, b Buffer) error {
+	img, err := ToNRGBA(b)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+func SavePNG(path string, b Buffer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = EncodePNG(f, b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
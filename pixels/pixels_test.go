@@ -0,0 +1,123 @@
+package pixels
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestToNRGBA(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  Buffer
+		want []byte
+	}{
+		{
+			name: "RGBA",
+			buf:  Buffer{Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}, Width: 2, Height: 1, Format: RGBA8},
+			want: []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		{
+			name: "BGRA swizzle",
+			buf:  Buffer{Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}, Width: 2, Height: 1, Format: BGRA8},
+			want: []byte{3, 2, 1, 4, 7, 6, 5, 8},
+		},
+		{
+			// Rows of 2 texels 12 bytes apart, the padding isn't copied and
+			// the last row has none.
+			name: "padded rows",
+			buf: Buffer{Data: []byte{
+				1, 2, 3, 4, 5, 6, 7, 8, 0xee, 0xee, 0xee, 0xee,
+				9, 10, 11, 12, 13, 14, 15, 16,
+			}, Width: 2, Height: 2, RowPitch: 12, Format: RGBA8},
+			want: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		},
+		{
+			name: "opaque",
+			buf:  Buffer{Data: []byte{1, 2, 3, 0, 5, 6, 7, 128}, Width: 2, Height: 1, Format: BGRA8, Opaque: true},
+			want: []byte{3, 2, 1, 255, 7, 6, 5, 255},
+		},
+	}
+	for _, test := range tests {
+		img, err := ToNRGBA(test.buf)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if img.Stride != test.buf.Width*4 || !bytes.Equal(img.Pix, test.want) {
+			t.Errorf("%s: ToNRGBA = %v, want %v", test.name, img.Pix, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	data := make([]byte, 2*2*4)
+	bad := map[string]Buffer{
+		"no width":       {Data: data, Width: 0, Height: 2},
+		"negative":       {Data: data, Width: 2, Height: -1},
+		"unknown format": {Data: data, Width: 2, Height: 2, Format: Format(7)},
+		"short pitch":    {Data: data, Width: 2, Height: 2, RowPitch: 4},
+		"short data":     {Data: data[:15], Width: 2, Height: 2},
+		"short padded":   {Data: data, Width: 2, Height: 2, RowPitch: 12},
+	}
+	for name, b := range bad {
+		if err := b.Validate(); err == nil {
+			t.Errorf("%s: Validate succeeded", name)
+		}
+		if _, err := ToNRGBA(b); err == nil {
+			t.Errorf("%s: ToNRGBA succeeded", name)
+		}
+	}
+	b := Buffer{Data: make([]byte, 12+8), Width: 2, Height: 2, RowPitch: 12}
+	if err := b.Validate(); err != nil {
+		t.Errorf("padded rows without padding after the last one: %s", err)
+	}
+}
+
+func TestSRGBTransfer(t *testing.T) {
+	tests := []struct {
+		linear, srgb float64
+	}{
+		{0, 0},
+		{0.002, 0.002 * 12.92},
+		{0.0031308, 0.0031308 * 12.92},
+		{0.214041, 0.5},
+		{0.5, 0.735357},
+		{1, 1},
+	}
+	for _, test := range tests {
+		if got := LinearToSRGB(test.linear); math.Abs(got-test.srgb) > 1e-5 {
+			t.Errorf("LinearToSRGB(%g) = %g, want %g", test.linear, got, test.srgb)
+		}
+		if got := SRGBToLinear(test.srgb); math.Abs(got-test.linear) > 1e-5 {
+			t.Errorf("SRGBToLinear(%g) = %g, want %g", test.srgb, got, test.linear)
+		}
+	}
+	for i := 0; i <= 255; i++ {
+		v := float64(i) / 255
+		if got := SRGBToLinear(LinearToSRGB(v)); math.Abs(got-v) > 1e-9 {
+			t.Errorf("round trip of %g = %g", v, got)
+		}
+	}
+}
+
+func TestEncodePNG(t *testing.T) {
+	b := Buffer{Data: []byte{
+		10, 20, 30, 255, 40, 50, 60, 255, 0, 0, 0, 0,
+		70, 80, 90, 255, 100, 110, 120, 255,
+	}, Width: 2, Height: 2, RowPitch: 12, Format: BGRA8}
+	var buf bytes.Buffer
+	if err := EncodePNG(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := FromImage(img)
+	want := []byte{30, 20, 10, 255, 60, 50, 40, 255, 90, 80, 70, 255, 120, 110, 100, 255}
+	if got.Width != 2 || got.Height != 2 || !bytes.Equal(got.Data, want) {
+		t.Errorf("decoded %dx%d %v, want 2x2 %v", got.Width, got.Height, got.Data, want)
+	}
+}
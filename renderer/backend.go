@@ -0,0 +1,739 @@
+package renderer
+
+import (
+	"fmt"
+	"log"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+
+	"github.com/vulkan-samples/gpu"
+	"github.com/vulkan-samples/memalloc"
+)
+
+type backendBuffer struct {
+	buffer vk.Buffer
+	alloc  *memalloc.Allocation
+	owned  bool
+}
+
+type backendImage struct {
+	image attachmentImage
+	owned bool
+	// present images are swapchain images, in the present source layout
+	// after their render pass.
+	present bool
+}
+
+type backendPipeline struct {
+	pipeline *Pipeline
+	owned    bool
+}
+
+// VulkanBackend implements gpu.Device on a VulkanDeviceInfo. Besides the
+// resources it creates, it hands out handles for objects the renderer
+// already owns with the Import methods; destroying an imported handle
+// only forgets it.
+type VulkanBackend struct {
+	v       *VulkanDeviceInfo
+	cmdPool vk.CommandPool
+	// Load reads shaders for CreatePipeline.
+	Load func(name string) ([]byte, error)
+
+	next         uint64
+	imported     map[interface{}]uint64
+	buffers      map[gpu.Buffer]*backendBuffer
+	images       map[gpu.Image]*backendImage
+	passes       map[gpu.RenderPass]vk.RenderPass
+	ownedPasses  map[gpu.RenderPass]bool
+	framebuffers map[gpu.Framebuffer]vk.Framebuffer
+	ownedFbs     map[gpu.Framebuffer]bool
+	pipelines    map[gpu.Pipeline]*backendPipeline
+	sets         map[gpu.DescriptorSet]vk.DescriptorSet
+	commands     map[gpu.CommandBuffer]vk.CommandBuffer
+	descriptors  *DescriptorAllocator
+}
+
+var _ gpu.Device = (*VulkanBackend)(nil)
+
+// NewBackend creates a backend recording into command buffers of cmdPool.
+func (v *VulkanDeviceInfo) NewBackend(cmdPool vk.CommandPool, load func(string) ([]byte, error)) *VulkanBackend {
+	return &VulkanBackend{
+		v:            v,
+		cmdPool:      cmdPool,
+		Load:         load,
+		imported:     make(map[interface{}]uint64),
+		buffers:      make(map[gpu.Buffer]*backendBuffer),
+		images:       make(map[gpu.Image]*backendImage),
+		passes:       make(map[gpu.RenderPass]vk.RenderPass),
+		ownedPasses:  make(map[gpu.RenderPass]bool),
+		framebuffers: make(map[gpu.Framebuffer]vk.Framebuffer),
+		ownedFbs:     make(map[gpu.Framebuffer]bool),
+		pipelines:    make(map[gpu.Pipeline]*backendPipeline),
+		sets:         make(map[gpu.DescriptorSet]vk.DescriptorSet),
+		commands:     make(map[gpu.CommandBuffer]vk.CommandBuffer),
+		descriptors:  NewDescriptorAllocator(v.Device, 0, nil),
+	}
+}
+
+func (b *VulkanBackend) handle() uint64 {
+	b.next++
+	return b.next
+}
+
+// importHandle returns the handle of an imported object, the same object
+// always gets the same handle.
+func (b *VulkanBackend) importHandle(object interface{}) (uint64, bool) {
+	if h, ok := b.imported[object]; ok {
+		return h, true
+	}
+	h := b.handle()
+	b.imported[object] = h
+	return h, false
+}
+
+func (b *VulkanBackend) forget(h uint64) {
+	for object, imported := range b.imported {
+		if imported == h {
+			delete(b.imported, object)
+			return
+		}
+	}
+}
+
+func (b *VulkanBackend) ImportBuffer(buffer vk.Buffer) gpu.Buffer {
+	h, ok := b.importHandle(buffer)
+	if !ok {
+		b.buffers[gpu.Buffer(h)] = &backendBuffer{buffer: buffer}
+	}
+	return gpu.Buffer(h)
+}
+
+// ImportImage imports an image without a view, it can only be copied.
+func (b *VulkanBackend) ImportImage(image vk.Image) gpu.Image {
+	h, ok := b.importHandle(image)
+	if !ok {
+		b.images[gpu.Image(h)] = &backendImage{image: attachmentImage{image: image}}
+	}
+	return gpu.Image(h)
+}
+
+// ImportPresentImage imports a swapchain image, copies from it recorded
+// before the frame is presented leave it in the present source layout.
+func (b *VulkanBackend) ImportPresentImage(image vk.Image) gpu.Image {
+	h, ok := b.importHandle(image)
+	if !ok {
+		b.images[gpu.Image(h)] = &backendImage{image: attachmentImage{image: image}}
+	}
+	b.images[gpu.Image(h)].present = true
+	return gpu.Image(h)
+}
+
+func (b *VulkanBackend) ImportRenderPass(pass vk.RenderPass) gpu.RenderPass {
+	h, ok := b.importHandle(pass)
+	if !ok {
+		b.passes[gpu.RenderPass(h)] = pass
+	}
+	return gpu.RenderPass(h)
+}
+
+func (b *VulkanBackend) ImportFramebuffer(framebuffer vk.Framebuffer) gpu.Framebuffer {
+	h, ok := b.importHandle(framebuffer)
+	if !ok {
+		b.framebuffers[gpu.Framebuffer(h)] = framebuffer
+	}
+	return gpu.Framebuffer(h)
+}
+
+func (b *VulkanBackend) ImportPipeline(pipeline *Pipeline) gpu.Pipeline {
+	h, ok := b.importHandle(pipeline)
+	if !ok {
+		b.pipelines[gpu.Pipeline(h)] = &backendPipeline{pipeline: pipeline}
+	}
+	return gpu.Pipeline(h)
+}
+
+func (b *VulkanBackend) ImportDescriptorSet(set vk.DescriptorSet) gpu.DescriptorSet {
+	h, ok := b.importHandle(set)
+	if !ok {
+		b.sets[gpu.DescriptorSet(h)] = set
+	}
+	return gpu.DescriptorSet(h)
+}
+
+func (b *VulkanBackend) ImportCommandBuffer(cmd vk.CommandBuffer) gpu.CommandBuffer {
+	h, ok := b.importHandle(cmd)
+	if !ok {
+		b.commands[gpu.CommandBuffer(h)] = cmd
+	}
+	return gpu.CommandBuffer(h)
+}
+
+func (b *VulkanBackend) CreateBuffer(desc gpu.BufferDesc) (gpu.Buffer, error) {
+	usage := vk.BufferUsageFlags(desc.Usage)
+	required := vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit)
+	preferred := required
+	if desc.HostVisible {
+		required = vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit)
+		preferred = required | vk.MemoryPropertyFlags(vk.MemoryPropertyHostCoherentBit)
+	} else {
+		usage |= vk.BufferUsageFlags(vk.BufferUsageTransferDstBit)
+	}
+	var buffer vk.Buffer
+	err := vk.Error(vk.CreateBuffer(b.v.Device, &vk.BufferCreateInfo{
+		SType:       vk.StructureTypeBufferCreateInfo,
+		Size:        vk.DeviceSize(desc.Size),
+		Usage:       usage,
+		SharingMode: vk.SharingModeExclusive,
+	}, nil, &buffer))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateBuffer failed with %s", err)
+		return 0, err
+	}
+	alloc, err := b.v.Memory.AllocateBuffer(buffer, required, preferred)
+	if err != nil {
+		vk.DestroyBuffer(b.v.Device, buffer, nil)
+		return 0, err
+	}
+	h := gpu.Buffer(b.handle())
+	b.buffers[h] = &backendBuffer{buffer: buffer, alloc: alloc, owned: true}
+	return h, nil
+}
+
+// WriteBuffer copies into host visible buffers, device local ones are
+// written through the upload manager.
+func (b *VulkanBackend) WriteBuffer(buffer gpu.Buffer, offset uint64, data []byte) error {
+	buf, ok := b.buffers[buffer]
+	if !ok || buf.alloc == nil {
+		return fmt.Errorf("buffer %d was not created by the backend", buffer)
+	}
+	if offset+uint64(len(data)) > buf.alloc.Size {
+		return fmt.Errorf("write of %d bytes at %d overflows an allocation of %d", len(data), offset, buf.alloc.Size)
+	}
+	ptr, err := b.v.Memory.Map(buf.alloc)
+	if err != nil {
+		_, err = b.v.Uploads.UploadBuffer(buf.buffer, offset, data,
+			vk.AccessFlags(vk.AccessMemoryReadBit), vk.PipelineStageFlags(vk.PipelineStageAllCommandsBit))
+		return err
+	}
+	vk.Memcopy(unsafe.Pointer(uintptr(ptr)+uintptr(offset)), data)
+	return b.v.Memory.Flush(buf.alloc, offset, uint64(len(data)))
+}
+
+func (b *VulkanBackend) DestroyBuffer(buffer gpu.Buffer) {
+	buf, ok := b.buffers[buffer]
+	if !ok {
+		return
+	}
+	if buf.owned {
+		vk.DestroyBuffer(b.v.Device, buf.buffer, nil)
+		b.v.Memory.Free(buf.alloc)
+	} else {
+		b.forget(uint64(buffer))
+	}
+	delete(b.buffers, buffer)
+}
+
+// CreateImage creates a single level 2D image with a view.
+func (b *VulkanBackend) CreateImage(desc gpu.ImageDesc) (gpu.Image, error) {
+	aspect := vk.ImageAspectFlags(vk.ImageAspectColorBit)
+	if desc.Usage&gpu.ImageDepthAttachment != 0 {
+		aspect = vk.ImageAspectFlags(vk.ImageAspectDepthBit)
+		if HasStencil(vk.Format(desc.Format)) {
+			aspect |= vk.ImageAspectFlags(vk.ImageAspectStencilBit)
+		}
+	}
+	image, err := b.v.createAttachmentImage(vk.Format(desc.Format),
+		vk.Extent2D{Width: desc.Width, Height: desc.Height},
+		vk.SampleCountFlagBits(desc.Samples), vk.ImageUsageFlags(desc.Usage), aspect)
+	if err != nil {
+		return 0, err
+	}
+	h := gpu.Image(b.handle())
+	b.images[h] = &backendImage{image: image, owned: true}
+	return h, nil
+}
+
+func (b *VulkanBackend) DestroyImage(image gpu.Image) {
+	img, ok := b.images[image]
+	if !ok {
+		return
+	}
+	if img.owned {
+		img.image.Destroy()
+	} else {
+		b.forget(uint64(image))
+	}
+	delete(b.images, image)
+}
+
+func (b *VulkanBackend) CreateRenderPass(desc gpu.RenderPassDesc) (gpu.RenderPass, error) {
+	if len(desc.ColorFormats) != 1 {
+		return 0, fmt.Errorf("render passes have one color attachment, not %d", len(desc.ColorFormats))
+	}
+	pass, err := CreateRenderPass(b.v.Device, vk.Format(desc.ColorFormats[0]), vk.Format(desc.DepthFormat),
+		vk.SampleCountFlagBits(desc.Samples))
+	if err != nil {
+		return 0, err
+	}
+	h := gpu.RenderPass(b.handle())
+	b.passes[h] = pass
+	b.ownedPasses[h] = true
+	return h, nil
+}
+
+func (b *VulkanBackend) DestroyRenderPass(pass gpu.RenderPass) {
+	if b.ownedPasses[pass] {
+		vk.DestroyRenderPass(b.v.Device, b.passes[pass], nil)
+	} else {
+		b.forget(uint64(pass))
+	}
+	delete(b.passes, pass)
+	delete(b.ownedPasses, pass)
+}
+
+func (b *VulkanBackend) CreateFramebuffer(desc gpu.FramebufferDesc) (gpu.Framebuffer, error) {
+	pass, ok := b.passes[desc.RenderPass]
+	if !ok {
+		return 0, fmt.Errorf("unknown render pass %d", desc.RenderPass)
+	}
+	views := make([]vk.ImageView, 0, len(desc.Attachments))
+	for _, image := range desc.Attachments {
+		img, ok := b.images[image]
+		if !ok {
+			return 0, fmt.Errorf("unknown image %d", image)
+		}
+		views = append(views, img.image.view)
+	}
+	var framebuffer vk.Framebuffer
+	err := vk.Error(vk.CreateFramebuffer(b.v.Device, &vk.FramebufferCreateInfo{
+		SType:           vk.StructureTypeFramebufferCreateInfo,
+		RenderPass:      pass,
+		AttachmentCount: uint32(len(views)),
+		PAttachments:    views,
+		Width:           desc.Width,
+		Height:          desc.Height,
+		Layers:          1,
+	}, nil, &framebuffer))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateFramebuffer failed with %s", err)
+		return 0, err
+	}
+	h := gpu.Framebuffer(b.handle())
+	b.framebuffers[h] = framebuffer
+	b.ownedFbs[h] = true
+	return h, nil
+}
+
+func (b *VulkanBackend) DestroyFramebuffer(framebuffer gpu.Framebuffer) {
+	if b.ownedFbs[framebuffer] {
+		vk.DestroyFramebuffer(b.v.Device, b.framebuffers[framebuffer], nil)
+	} else {
+		b.forget(uint64(framebuffer))
+	}
+	delete(b.framebuffers, framebuffer)
+	delete(b.ownedFbs, framebuffer)
+}
+
+// CreatePipeline builds desc.Native, which must be a *PipelineBuilder.
+func (b *VulkanBackend) CreatePipeline(desc gpu.PipelineDesc) (gpu.Pipeline, error) {
+	builder, ok := desc.Native.(*PipelineBuilder)
+	if !ok {
+		return 0, fmt.Errorf("pipeline %q: native description is %T, not a *PipelineBuilder", desc.Label, desc.Native)
+	}
+	pass, ok := b.passes[desc.RenderPass]
+	if !ok {
+		return 0, fmt.Errorf("pipeline %q: unknown render pass %d", desc.Label, desc.RenderPass)
+	}
+	pipeline, err := builder.Build(b.v.Device, pass, b.Load)
+	if err != nil {
+		return 0, err
+	}
+	h := gpu.Pipeline(b.handle())
+	b.pipelines[h] = &backendPipeline{pipeline: pipeline, owned: true}
+	return h, nil
+}
+
+func (b *VulkanBackend) DestroyPipeline(pipeline gpu.Pipeline) {
+	p, ok := b.pipelines[pipeline]
+	if !ok {
+		return
+	}
+	if p.owned {
+		p.pipeline.Destroy()
+	} else {
+		b.forget(uint64(pipeline))
+	}
+	delete(b.pipelines, pipeline)
+}
+
+// AllocateDescriptorSet allocates from a growing pool, sets are released
+// when the backend is destroyed.
+func (b *VulkanBackend) AllocateDescriptorSet(bindings []gpu.DescriptorBinding) (gpu.DescriptorSet, error) {
+	layout := NewLayoutBuilder()
+	for _, binding := range bindings {
+		layout.Binding(DescriptorBinding{
+			Binding: binding.Binding,
+			Type:    vk.DescriptorType(binding.Type),
+			Count:   binding.Count,
+			Stages:  vk.ShaderStageFlags(binding.Stages),
+		})
+	}
+	descLayout, err := b.v.Layouts.Get(layout)
+	if err != nil {
+		return 0, err
+	}
+	set, err := b.descriptors.Allocate(descLayout)
+	if err != nil {
+		return 0, err
+	}
+	h := gpu.DescriptorSet(b.handle())
+	b.sets[h] = set
+	return h, nil
+}
+
+// WriteDescriptorSet updates buffer bindings and storage or sampled image
+// bindings. Combined image samplers need a sampler, bind Textures with
+// DescriptorWriter for those.
+func (b *VulkanBackend) WriteDescriptorSet(set gpu.DescriptorSet, writes []gpu.DescriptorWrite) {
+	vkSet, ok := b.sets[set]
+	if !ok {
+		log.Println("[WARN] write to unknown descriptor set", set)
+		return
+	}
+	w := NewDescriptorWriter()
+	for _, write := range writes {
+		typ := vk.DescriptorType(write.Type)
+		switch write.Type {
+		case gpu.DescriptorUniformBuffer, gpu.DescriptorStorageBuffer,
+			gpu.DescriptorUniformBufferDynamic, gpu.DescriptorStorageBufferDynamic:
+			buf, ok := b.buffers[write.Buffer]
+			if !ok {
+				log.Println("[WARN] descriptor write of unknown buffer", write.Buffer)
+				continue
+			}
+			w.Buffer(vkSet, write.Binding, typ, buf.buffer, vk.DeviceSize(write.Offset), vk.DeviceSize(write.Range))
+		case gpu.DescriptorSampledImage, gpu.DescriptorStorageImage:
+			layout := vk.ImageLayoutShaderReadOnlyOptimal
+			if write.Type == gpu.DescriptorStorageImage {
+				layout = vk.ImageLayoutGeneral
+			}
+			infos := make([]vk.DescriptorImageInfo, 0, len(write.Images))
+			for _, image := range write.Images {
+				if img, ok := b.images[image]; ok {
+					infos = append(infos, vk.DescriptorImageInfo{ImageView: img.image.view, ImageLayout: layout})
+				}
+			}
+			w.Images(vkSet, write.Binding, typ, infos)
+		default:
+			log.Println("[WARN] unsupported descriptor write of type", write.Type)
+		}
+	}
+	w.Update(b.v.Device)
+}
+
+func (b *VulkanBackend) FreeDescriptorSet(set gpu.DescriptorSet) {
+	if _, ok := b.sets[set]; ok {
+		b.forget(uint64(set))
+		delete(b.sets, set)
+	}
+}
+
+// Begin allocates a one time command buffer, Submit executes it and waits
+// for it to complete.
+func (b *VulkanBackend) Begin() (gpu.CommandEncoder, error) {
+	cmd, err := allocateCommandBuffer(b.v.Device, b.cmdPool)
+	if err != nil {
+		return nil, err
+	}
+	err = vk.Error(vk.BeginCommandBuffer(cmd, &vk.CommandBufferBeginInfo{
+		SType: vk.StructureTypeCommandBufferBeginInfo,
+		Flags: vk.CommandBufferUsageFlags(vk.CommandBufferUsageOneTimeSubmitBit),
+	}))
+	if err != nil {
+		vk.FreeCommandBuffers(b.v.Device, b.cmdPool, 1, []vk.CommandBuffer{cmd})
+		err = fmt.Errorf("vk.BeginCommandBuffer failed with %s", err)
+		return nil, err
+	}
+	return &vulkanEncoder{b: b, cmd: cmd}, nil
+}
+
+func (b *VulkanBackend) Submit(cmd gpu.CommandEncoder) error {
+	e, ok := cmd.(*vulkanEncoder)
+	if !ok || e.b != b {
+		return fmt.Errorf("command encoder %T was not begun by this backend", cmd)
+	}
+	defer vk.FreeCommandBuffers(b.v.Device, b.cmdPool, 1, []vk.CommandBuffer{e.cmd})
+
+	err := vk.Error(vk.EndCommandBuffer(e.cmd))
+	if err != nil {
+		return fmt.Errorf("vk.EndCommandBuffer failed with %s", err)
+	}
+	var fence vk.Fence
+	err = vk.Error(vk.CreateFence(b.v.Device, &vk.FenceCreateInfo{
+		SType: vk.StructureTypeFenceCreateInfo,
+	}, nil, &fence))
+	if err != nil {
+		return fmt.Errorf("vk.CreateFence failed with %s", err)
+	}
+	defer vk.DestroyFence(b.v.Device, fence, nil)
+	err = vk.Error(vk.QueueSubmit(b.v.Queue, 1, []vk.SubmitInfo{{
+		SType:              vk.StructureTypeSubmitInfo,
+		CommandBufferCount: 1,
+		PCommandBuffers:    []vk.CommandBuffer{e.cmd},
+	}}, fence))
+	if err != nil {
+		return fmt.Errorf("vk.QueueSubmit failed with %s", err)
+	}
+	err = vk.Error(vk.WaitForFences(b.v.Device, 1, []vk.Fence{fence}, vk.True, vk.MaxUint64))
+	if err != nil {
+		return fmt.Errorf("vk.WaitForFences failed with %s", err)
+	}
+	return nil
+}
+
+func (b *VulkanBackend) WaitIdle() error {
+	err := vk.Error(vk.DeviceWaitIdle(b.v.Device))
+	if err != nil {
+		return fmt.Errorf("vk.DeviceWaitIdle failed with %s", err)
+	}
+	return nil
+}
+
+// Record resets and begins an imported command buffer, like the one of a
+// Frame, for a single submit.
+func (b *VulkanBackend) Record(cmd gpu.CommandBuffer) (gpu.CommandEncoder, error) {
+	vkCmd, ok := b.commands[cmd]
+	if !ok {
+		return nil, fmt.Errorf("unknown command buffer %d", cmd)
+	}
+	err := vk.Error(vk.ResetCommandBuffer(vkCmd, 0))
+	if err != nil {
+		return nil, fmt.Errorf("vk.ResetCommandBuffer failed with %s", err)
+	}
+	err = vk.Error(vk.BeginCommandBuffer(vkCmd, &vk.CommandBufferBeginInfo{
+		SType: vk.StructureTypeCommandBufferBeginInfo,
+		Flags: vk.CommandBufferUsageFlags(vk.CommandBufferUsageOneTimeSubmitBit),
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("vk.BeginCommandBuffer failed with %s", err)
+	}
+	return &vulkanEncoder{b: b, cmd: vkCmd}, nil
+}
+
+func (b *VulkanBackend) End(cmd gpu.CommandEncoder) error {
+	e, ok := cmd.(*vulkanEncoder)
+	if !ok || e.b != b {
+		return fmt.Errorf("command encoder %T was not begun by this backend", cmd)
+	}
+	err := vk.Error(vk.EndCommandBuffer(e.cmd))
+	if err != nil {
+		return fmt.Errorf("vk.EndCommandBuffer failed with %s", err)
+	}
+	return nil
+}
+
+// ForgetImported drops the handles of imported objects, call it when the
+// objects are recreated, like the framebuffers of a new swapchain.
+func (b *VulkanBackend) ForgetImported() {
+	for object, h := range b.imported {
+		delete(b.imported, object)
+		delete(b.buffers, gpu.Buffer(h))
+		delete(b.images, gpu.Image(h))
+		delete(b.passes, gpu.RenderPass(h))
+		delete(b.framebuffers, gpu.Framebuffer(h))
+		delete(b.pipelines, gpu.Pipeline(h))
+		delete(b.sets, gpu.DescriptorSet(h))
+		delete(b.commands, gpu.CommandBuffer(h))
+	}
+}
+
+// Destroy destroys what the backend created, imported objects are left
+// to their owners.
+func (b *VulkanBackend) Destroy() {
+	if b == nil {
+		return
+	}
+	for h := range b.pipelines {
+		b.DestroyPipeline(h)
+	}
+	for h := range b.framebuffers {
+		b.DestroyFramebuffer(h)
+	}
+	for h := range b.passes {
+		b.DestroyRenderPass(h)
+	}
+	for h := range b.images {
+		b.DestroyImage(h)
+	}
+	for h := range b.buffers {
+		b.DestroyBuffer(h)
+	}
+	b.descriptors.Destroy()
+	b.sets = make(map[gpu.DescriptorSet]vk.DescriptorSet)
+	b.commands = make(map[gpu.CommandBuffer]vk.CommandBuffer)
+	b.imported = make(map[interface{}]uint64)
+}
+
+type vulkanEncoder struct {
+	b   *VulkanBackend
+	cmd vk.CommandBuffer
+}
+
+func (e *vulkanEncoder) BeginRenderPass(pass gpu.RenderPass, framebuffer gpu.Framebuffer, area gpu.Rect, clear []gpu.ClearValue) {
+	clearValues := make([]vk.ClearValue, len(clear))
+	for i, c := range clear {
+		if c.DepthStencil {
+			clearValues[i] = vk.NewClearDepthStencil(c.Depth, c.Stencil)
+		} else {
+			clearValues[i] = vk.NewClearValue(c.Color[:])
+		}
+	}
+	vk.CmdBeginRenderPass(e.cmd, &vk.RenderPassBeginInfo{
+		SType:           vk.StructureTypeRenderPassBeginInfo,
+		RenderPass:      e.b.passes[pass],
+		Framebuffer:     e.b.framebuffers[framebuffer],
+		RenderArea:      rect(area),
+		ClearValueCount: uint32(len(clearValues)),
+		PClearValues:    clearValues,
+	}, vk.SubpassContentsInline)
+}
+
+func rect(r gpu.Rect) vk.Rect2D {
+	return vk.Rect2D{
+		Offset: vk.Offset2D{X: r.X, Y: r.Y},
+		Extent: vk.Extent2D{Width: r.Width, Height: r.Height},
+	}
+}
+
+func (e *vulkanEncoder) EndRenderPass() {
+	vk.CmdEndRenderPass(e.cmd)
+}
+
+func (e *vulkanEncoder) pipeline(pipeline gpu.Pipeline) *Pipeline {
+	if p, ok := e.b.pipelines[pipeline]; ok {
+		return p.pipeline
+	}
+	return &Pipeline{}
+}
+
+func (e *vulkanEncoder) BindPipeline(pipeline gpu.Pipeline) {
+	vk.CmdBindPipeline(e.cmd, vk.PipelineBindPointGraphics, e.pipeline(pipeline).Pipeline)
+}
+
+func (e *vulkanEncoder) BindDescriptorSets(pipeline gpu.Pipeline, first uint32, sets []gpu.DescriptorSet, dynamicOffsets []uint32) {
+	vkSets := make([]vk.DescriptorSet, len(sets))
+	for i, set := range sets {
+		vkSets[i] = e.b.sets[set]
+	}
+	vk.CmdBindDescriptorSets(e.cmd, vk.PipelineBindPointGraphics, e.pipeline(pipeline).Layout,
+		first, uint32(len(vkSets)), vkSets, uint32(len(dynamicOffsets)), dynamicOffsets)
+}
+
+func (e *vulkanEncoder) BindVertexBuffers(first uint32, buffers []gpu.Buffer, offsets []uint64) {
+	vkBuffers := make([]vk.Buffer, len(buffers))
+	vkOffsets := make([]vk.DeviceSize, len(buffers))
+	for i, buffer := range buffers {
+		if buf, ok := e.b.buffers[buffer]; ok {
+			vkBuffers[i] = buf.buffer
+		}
+		if i < len(offsets) {
+			vkOffsets[i] = vk.DeviceSize(offsets[i])
+		}
+	}
+	vk.CmdBindVertexBuffers(e.cmd, first, uint32(len(vkBuffers)), vkBuffers, vkOffsets)
+}
+
+func (e *vulkanEncoder) BindIndexBuffer(buffer gpu.Buffer, offset uint64, indexType gpu.IndexType) {
+	var vkBuffer vk.Buffer
+	if buf, ok := e.b.buffers[buffer]; ok {
+		vkBuffer = buf.buffer
+	}
+	vk.CmdBindIndexBuffer(e.cmd, vkBuffer, vk.DeviceSize(offset), vk.IndexType(indexType))
+}
+
+func (e *vulkanEncoder) SetViewport(viewport gpu.Viewport) {
+	vk.CmdSetViewport(e.cmd, 0, 1, []vk.Viewport{{
+		X:        viewport.X,
+		Y:        viewport.Y,
+		Width:    viewport.Width,
+		Height:   viewport.Height,
+		MinDepth: viewport.MinDepth,
+		MaxDepth: viewport.MaxDepth,
+	}})
+}
+
+func (e *vulkanEncoder) SetScissor(scissor gpu.Rect) {
+	vk.CmdSetScissor(e.cmd, 0, 1, []vk.Rect2D{rect(scissor)})
+}
+
+func (e *vulkanEncoder) PushConstants(pipeline gpu.Pipeline, stages gpu.ShaderStage, offset uint32, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	vk.CmdPushConstants(e.cmd, e.pipeline(pipeline).Layout, vk.ShaderStageFlags(stages), offset,
+		uint32(len(data)), unsafe.Pointer(&data[0]))
+}
+
+func (e *vulkanEncoder) Draw(vertexCount, instanceCount, firstVertex, firstInstance uint32) {
+	vk.CmdDraw(e.cmd, vertexCount, instanceCount, firstVertex, firstInstance)
+}
+
+func (e *vulkanEncoder) DrawIndexed(indexCount, instanceCount, firstIndex uint32, vertexOffset int32, firstInstance uint32) {
+	vk.CmdDrawIndexed(e.cmd, indexCount, instanceCount, firstIndex, vertexOffset, firstInstance)
+}
+
+func (e *vulkanEncoder) CopyImageToBuffer(image gpu.Image, buffer gpu.Buffer, width, height uint32) {
+	img, ok := e.b.images[image]
+	if !ok {
+		log.Println("[WARN] copy of unknown image", image)
+		return
+	}
+	buf, ok := e.b.buffers[buffer]
+	if !ok {
+		log.Println("[WARN] copy to unknown buffer", buffer)
+		return
+	}
+	if img.present {
+		vk.CmdPipelineBarrier(e.cmd,
+			vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit),
+			vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+			0, 0, nil, 0, nil, 1, []vk.ImageMemoryBarrier{
+				imageBarrier(img.image.image, vk.ImageLayoutPresentSrc, vk.ImageLayoutTransferSrcOptimal,
+					vk.AccessFlags(vk.AccessColorAttachmentWriteBit), vk.AccessFlags(vk.AccessTransferReadBit)),
+			})
+	}
+	vk.CmdCopyImageToBuffer(e.cmd, img.image.image, vk.ImageLayoutTransferSrcOptimal,
+		buf.buffer, 1, []vk.BufferImageCopy{{
+			ImageSubresource: vk.ImageSubresourceLayers{
+				AspectMask: vk.ImageAspectFlags(vk.ImageAspectColorBit),
+				LayerCount: 1,
+			},
+			ImageExtent: vk.Extent3D{
+				Width:  width,
+				Height: height,
+				Depth:  1,
+			},
+		}})
+	vk.CmdPipelineBarrier(e.cmd,
+		vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+		vk.PipelineStageFlags(vk.PipelineStageHostBit),
+		0, 0, nil, 1, []vk.BufferMemoryBarrier{{
+			SType:               vk.StructureTypeBufferMemoryBarrier,
+			SrcAccessMask:       vk.AccessFlags(vk.AccessTransferWriteBit),
+			DstAccessMask:       vk.AccessFlags(vk.AccessHostReadBit),
+			SrcQueueFamilyIndex: vk.QueueFamilyIgnored,
+			DstQueueFamilyIndex: vk.QueueFamilyIgnored,
+			Buffer:              buf.buffer,
+			Size:                vk.DeviceSize(vk.WholeSize),
+		}}, 0, nil)
+	if img.present {
+		vk.CmdPipelineBarrier(e.cmd,
+			vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+			vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit),
+			0, 0, nil, 0, nil, 1, []vk.ImageMemoryBarrier{
+				imageBarrier(img.image.image, vk.ImageLayoutTransferSrcOptimal, vk.ImageLayoutPresentSrc,
+					vk.AccessFlags(vk.AccessTransferReadBit), 0),
+			})
+	}
+}
@@ -0,0 +1,89 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// DepthFormats are the depth formats tried by FindDepthFormat, best first.
+// D16 is the only one every implementation has to support.
+var DepthFormats = []vk.Format{
+	vk.FormatD32Sfloat,
+	vk.FormatD32SfloatS8Uint,
+	vk.FormatD24UnormS8Uint,
+	vk.FormatD16Unorm,
+}
+
+// SelectDepthFormat returns the first candidate that can be used as an
+// optimally tiled depth attachment according to features.
+func SelectDepthFormat(candidates []vk.Format, features func(vk.Format) vk.FormatProperties) (vk.Format, bool) {
+	for _, format := range candidates {
+		props := features(format)
+		if props.OptimalTilingFeatures&vk.FormatFeatureFlags(vk.FormatFeatureDepthStencilAttachmentBit) != 0 {
+			return format, true
+		}
+	}
+	return vk.FormatUndefined, false
+}
+
+// HasStencil reports whether a depth format has a stencil component.
+func HasStencil(format vk.Format) bool {
+	switch format {
+	case vk.FormatD32SfloatS8Uint, vk.FormatD24UnormS8Uint, vk.FormatD16UnormS8Uint:
+		return true
+	}
+	return false
+}
+
+// FindDepthFormat picks the depth format from DepthFormats the GPU supports.
+func (v *VulkanDeviceInfo) FindDepthFormat() (vk.Format, error) {
+	format, ok := SelectDepthFormat(DepthFormats, func(format vk.Format) vk.FormatProperties {
+		var props vk.FormatProperties
+		vk.GetPhysicalDeviceFormatProperties(v.gpuDevices[0], format, &props)
+		props.Deref()
+		return props
+	})
+	if !ok {
+		return format, fmt.Errorf("no supported depth format among %d candidates", len(DepthFormats))
+	}
+	return format, nil
+}
+
+// DepthBuffer is a depth attachment matching the swapchain extent. It has
+// to be recreated whenever the swapchain is.
+type DepthBuffer struct {
+	attachmentImage
+}
+
+func (d *DepthBuffer) View() vk.ImageView {
+	if d == nil {
+		return vk.NullImageView
+	}
+	return d.view
+}
+
+// CreateDepthBuffer creates a device local depth image of the given format,
+// extent and sample count with a view on it. The image is left in the
+// undefined layout, the render pass transitions it on first use.
+func (v *VulkanDeviceInfo) CreateDepthBuffer(format vk.Format, extent vk.Extent2D,
+	samples vk.SampleCountFlagBits) (*DepthBuffer, error) {
+
+	aspect := vk.ImageAspectFlags(vk.ImageAspectDepthBit)
+	if HasStencil(format) {
+		aspect |= vk.ImageAspectFlags(vk.ImageAspectStencilBit)
+	}
+	img, err := v.createAttachmentImage(format, extent, samples,
+		vk.ImageUsageFlags(vk.ImageUsageDepthStencilAttachmentBit), aspect)
+	if err != nil {
+		return nil, err
+	}
+	return &DepthBuffer{img}, nil
+}
+
+func (d *DepthBuffer) Destroy() {
+	if d == nil {
+		return
+	}
+	d.attachmentImage.Destroy()
+}
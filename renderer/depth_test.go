@@ -0,0 +1,44 @@
+package renderer
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestSelectDepthFormat(t *testing.T) {
+	attachment := vk.FormatProperties{
+		OptimalTilingFeatures: vk.FormatFeatureFlags(vk.FormatFeatureDepthStencilAttachmentBit),
+	}
+	// Linear tiling support alone doesn't make a depth attachment.
+	linearOnly := vk.FormatProperties{
+		LinearTilingFeatures:  vk.FormatFeatureFlags(vk.FormatFeatureDepthStencilAttachmentBit),
+		OptimalTilingFeatures: vk.FormatFeatureFlags(vk.FormatFeatureSampledImageBit),
+	}
+	tests := []struct {
+		name      string
+		supported map[vk.Format]vk.FormatProperties
+		want      vk.Format
+		ok        bool
+	}{
+		{"best", map[vk.Format]vk.FormatProperties{vk.FormatD32Sfloat: attachment, vk.FormatD16Unorm: attachment},
+			vk.FormatD32Sfloat, true},
+		{"only D16", map[vk.Format]vk.FormatProperties{vk.FormatD16Unorm: attachment},
+			vk.FormatD16Unorm, true},
+		{"linear tiling only", map[vk.Format]vk.FormatProperties{vk.FormatD32Sfloat: linearOnly, vk.FormatD24UnormS8Uint: attachment},
+			vk.FormatD24UnormS8Uint, true},
+		{"none", map[vk.Format]vk.FormatProperties{vk.FormatD32Sfloat: linearOnly},
+			vk.FormatUndefined, false},
+	}
+	for _, test := range tests {
+		got, ok := SelectDepthFormat(DepthFormats, func(format vk.Format) vk.FormatProperties {
+			return test.supported[format]
+		})
+		if got != test.want || ok != test.ok {
+			t.Errorf("%s: SelectDepthFormat = %d, %v, want %d, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+	if !HasStencil(vk.FormatD24UnormS8Uint) || HasStencil(vk.FormatD32Sfloat) {
+		t.Errorf("HasStencil of D24S8 or D32 is wrong")
+	}
+}
@@ -0,0 +1,371 @@
+package renderer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// DescriptorBinding describes one binding of a descriptor set layout.
+type DescriptorBinding struct {
+	Binding uint32
+	Type    vk.DescriptorType
+	Count   uint32
+	Stages  vk.ShaderStageFlags
+}
+
+// LayoutBuilder collects the bindings of a descriptor set layout.
+//
+//	layout, err := v.Layouts.Get(renderer.NewLayoutBuilder().
+//		UniformBufferDynamic(0, vk.ShaderStageVertexBit).
+//		CombinedImageSamplers(1, 2, vk.ShaderStageFragmentBit))
+type LayoutBuilder struct {
+	bindings []DescriptorBinding
+}
+
+func NewLayoutBuilder() *LayoutBuilder {
+	return &LayoutBuilder{}
+}
+
+// Binding adds a binding, replacing an earlier one with the same number.
+// Bindings with a zero count are left out.
+func (b *LayoutBuilder) Binding(binding DescriptorBinding) *LayoutBuilder {
+	for i := range b.bindings {
+		if b.bindings[i].Binding == binding.Binding {
+			b.bindings = append(b.bindings[:i], b.bindings[i+1:]...)
+			break
+		}
+	}
+	if binding.Count > 0 {
+		b.bindings = append(b.bindings, binding)
+	}
+	return b
+}
+
+func (b *LayoutBuilder) add(binding uint32, typ vk.DescriptorType, count uint32, stages vk.ShaderStageFlagBits) *LayoutBuilder {
+	return b.Binding(DescriptorBinding{
+		Binding: binding,
+		Type:    typ,
+		Count:   count,
+		Stages:  vk.ShaderStageFlags(stages),
+	})
+}
+
+func (b *LayoutBuilder) UniformBuffer(binding uint32, stages vk.ShaderStageFlagBits) *LayoutBuilder {
+	return b.add(binding, vk.DescriptorTypeUniformBuffer, 1, stages)
+}
+
+func (b *LayoutBuilder) UniformBufferDynamic(binding uint32, stages vk.ShaderStageFlagBits) *LayoutBuilder {
+	return b.add(binding, vk.DescriptorTypeUniformBufferDynamic, 1, stages)
+}
+
+func (b *LayoutBuilder) StorageBuffer(binding uint32, stages vk.ShaderStageFlagBits) *LayoutBuilder {
+	return b.add(binding, vk.DescriptorTypeStorageBuffer, 1, stages)
+}
+
+func (b *LayoutBuilder) CombinedImageSamplers(binding, count uint32, stages vk.ShaderStageFlagBits) *LayoutBuilder {
+	return b.add(binding, vk.DescriptorTypeCombinedImageSampler, count, stages)
+}
+
+// Bindings returns the bindings ordered by binding number.
+func (b *LayoutBuilder) Bindings() []DescriptorBinding {
+	bindings := append([]DescriptorBinding(nil), b.bindings...)
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Binding < bindings[j].Binding })
+	return bindings
+}
+
+// Key identifies the layout, builders with equal bindings in any order
+// have the same key.
+func (b *LayoutBuilder) Key() string {
+	var key strings.Builder
+	for _, binding := range b.Bindings() {
+		fmt.Fprintf(&key, "%d:%d:%d:%#x;", binding.Binding, binding.Type, binding.Count, binding.Stages)
+	}
+	return key.String()
+}
+
+// Build creates the descriptor set layout, it is owned by the caller.
+// Prefer LayoutCache.Get to share equal layouts.
+func (b *LayoutBuilder) Build(device vk.Device) (vk.DescriptorSetLayout, error) {
+	bindings := b.Bindings()
+	layoutBindings := make([]vk.DescriptorSetLayoutBinding, len(bindings))
+	for i, binding := range bindings {
+		layoutBindings[i] = vk.DescriptorSetLayoutBinding{
+			Binding:         binding.Binding,
+			DescriptorType:  binding.Type,
+			DescriptorCount: binding.Count,
+			StageFlags:      binding.Stages,
+		}
+	}
+	var layout vk.DescriptorSetLayout
+	err := vk.Error(vk.CreateDescriptorSetLayout(device, &vk.DescriptorSetLayoutCreateInfo{
+		SType:        vk.StructureTypeDescriptorSetLayoutCreateInfo,
+		BindingCount: uint32(len(layoutBindings)),
+		PBindings:    layoutBindings,
+	}, nil, &layout))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateDescriptorSetLayout failed with %s", err)
+		return vk.NullDescriptorSetLayout, err
+	}
+	return layout, nil
+}
+
+// LayoutCache shares descriptor set layouts between everything that uses
+// the same bindings. The layouts live as long as the cache.
+type LayoutCache struct {
+	device  vk.Device
+	layouts map[string]vk.DescriptorSetLayout
+}
+
+func NewLayoutCache(device vk.Device) *LayoutCache {
+	return &LayoutCache{
+		device:  device,
+		layouts: make(map[string]vk.DescriptorSetLayout),
+	}
+}
+
+// Get returns the layout for the bindings of b, creating it on first use.
+func (c *LayoutCache) Get(b *LayoutBuilder) (vk.DescriptorSetLayout, error) {
+	key := b.Key()
+	if layout, ok := c.layouts[key]; ok {
+		return layout, nil
+	}
+	layout, err := b.Build(c.device)
+	if err != nil {
+		return layout, err
+	}
+	c.layouts[key] = layout
+	return layout, nil
+}
+
+func (c *LayoutCache) Destroy() {
+	if c == nil {
+		return
+	}
+	for key, layout := range c.layouts {
+		vk.DestroyDescriptorSetLayout(c.device, layout, nil)
+		delete(c.layouts, key)
+	}
+}
+
+// PoolSize is the number of descriptors of a type reserved per set.
+type PoolSize struct {
+	Type  vk.DescriptorType
+	Ratio float32
+}
+
+// DefaultPoolSizes fit sets with a few buffers and textures each.
+var DefaultPoolSizes = []PoolSize{
+	{vk.DescriptorTypeUniformBuffer, 1},
+	{vk.DescriptorTypeUniformBufferDynamic, 1},
+	{vk.DescriptorTypeStorageBuffer, 1},
+	{vk.DescriptorTypeCombinedImageSampler, 4},
+}
+
+// DefaultSetsPerPool is the capacity of every pool of a DescriptorAllocator.
+const DefaultSetsPerPool = 64
+
+// DescriptorAllocator allocates descriptor sets from a growing list of
+// pools. Sets are never freed one by one, Reset recycles all of them at
+// once, e.g. for the sets of a frame in flight.
+type DescriptorAllocator struct {
+	device      vk.Device
+	sizes       []PoolSize
+	setsPerPool uint32
+
+	current vk.DescriptorPool
+	used    []vk.DescriptorPool
+	free    []vk.DescriptorPool
+}
+
+// NewDescriptorAllocator creates an allocator whose pools hold setsPerPool
+// sets, sizes gives the descriptors of each type reserved per set.
+func NewDescriptorAllocator(device vk.Device, setsPerPool uint32, sizes []PoolSize) *DescriptorAllocator {
+	if setsPerPool == 0 {
+		setsPerPool = DefaultSetsPerPool
+	}
+	if len(sizes) == 0 {
+		sizes = DefaultPoolSizes
+	}
+	return &DescriptorAllocator{
+		device:      device,
+		sizes:       sizes,
+		setsPerPool: setsPerPool,
+	}
+}
+
+func (a *DescriptorAllocator) createPool() (vk.DescriptorPool, error) {
+	poolSizes := make([]vk.DescriptorPoolSize, 0, len(a.sizes))
+	for _, size := range a.sizes {
+		count := uint32(size.Ratio * float32(a.setsPerPool))
+		if count == 0 {
+			continue
+		}
+		poolSizes = append(poolSizes, vk.DescriptorPoolSize{
+			Type:            size.Type,
+			DescriptorCount: count,
+		})
+	}
+	var pool vk.DescriptorPool
+	err := vk.Error(vk.CreateDescriptorPool(a.device, &vk.DescriptorPoolCreateInfo{
+		SType:         vk.StructureTypeDescriptorPoolCreateInfo,
+		MaxSets:       a.setsPerPool,
+		PoolSizeCount: uint32(len(poolSizes)),
+		PPoolSizes:    poolSizes,
+	}, nil, &pool))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateDescriptorPool failed with %s", err)
+		return vk.NullDescriptorPool, err
+	}
+	return pool, nil
+}
+
+// grab makes a reset pool or a new one current.
+func (a *DescriptorAllocator) grab() error {
+	if n := len(a.free); n > 0 {
+		a.current = a.free[n-1]
+		a.free = a.free[:n-1]
+	} else {
+		pool, err := a.createPool()
+		if err != nil {
+			return err
+		}
+		a.current = pool
+	}
+	a.used = append(a.used, a.current)
+	return nil
+}
+
+// Allocate allocates a set of layout, adding a pool when the current one
+// is exhausted.
+func (a *DescriptorAllocator) Allocate(layout vk.DescriptorSetLayout) (vk.DescriptorSet, error) {
+	if a.current == vk.NullDescriptorPool {
+		if err := a.grab(); err != nil {
+			return vk.NullDescriptorSet, err
+		}
+	}
+	set, ret := a.allocate(layout)
+	switch ret {
+	case vk.Success:
+		return set, nil
+	case vk.ErrorOutOfPoolMemory, vk.ErrorFragmentedPool:
+		if err := a.grab(); err != nil {
+			return vk.NullDescriptorSet, err
+		}
+		set, ret = a.allocate(layout)
+	}
+	if err := vk.Error(ret); err != nil {
+		return vk.NullDescriptorSet, fmt.Errorf("vk.AllocateDescriptorSets failed with %s", err)
+	}
+	return set, nil
+}
+
+func (a *DescriptorAllocator) allocate(layout vk.DescriptorSetLayout) (vk.DescriptorSet, vk.Result) {
+	var set vk.DescriptorSet
+	ret := vk.AllocateDescriptorSets(a.device, &vk.DescriptorSetAllocateInfo{
+		SType:              vk.StructureTypeDescriptorSetAllocateInfo,
+		DescriptorPool:     a.current,
+		DescriptorSetCount: 1,
+		PSetLayouts:        []vk.DescriptorSetLayout{layout},
+	}, &set)
+	return set, ret
+}
+
+// Reset frees every set allocated so far, the GPU must be done with them.
+func (a *DescriptorAllocator) Reset() {
+	// vk.ResetDescriptorPool can't fail.
+	for _, pool := range a.used {
+		vk.ResetDescriptorPool(a.device, pool, 0)
+		a.free = append(a.free, pool)
+	}
+	a.used = a.used[:0]
+	a.current = vk.NullDescriptorPool
+}
+
+func (a *DescriptorAllocator) Destroy() {
+	if a == nil {
+		return
+	}
+	for _, pool := range a.used {
+		vk.DestroyDescriptorPool(a.device, pool, nil)
+	}
+	for _, pool := range a.free {
+		vk.DestroyDescriptorPool(a.device, pool, nil)
+	}
+	a.used = nil
+	a.free = nil
+	a.current = vk.NullDescriptorPool
+}
+
+// DescriptorWriter batches descriptor updates into one
+// vk.UpdateDescriptorSets call.
+type DescriptorWriter struct {
+	writes []vk.WriteDescriptorSet
+}
+
+func NewDescriptorWriter() *DescriptorWriter {
+	return &DescriptorWriter{}
+}
+
+// Buffer writes a buffer descriptor, for dynamic buffers offset is the
+// base the dynamic offset is added to.
+func (w *DescriptorWriter) Buffer(set vk.DescriptorSet, binding uint32, typ vk.DescriptorType,
+	buffer vk.Buffer, offset, size vk.DeviceSize) *DescriptorWriter {
+
+	w.writes = append(w.writes, vk.WriteDescriptorSet{
+		SType:           vk.StructureTypeWriteDescriptorSet,
+		DstSet:          set,
+		DstBinding:      binding,
+		DescriptorCount: 1,
+		DescriptorType:  typ,
+		PBufferInfo: []vk.DescriptorBufferInfo{{
+			Buffer: buffer,
+			Offset: offset,
+			Range:  size,
+		}},
+	})
+	return w
+}
+
+// Images writes consecutive array elements of an image binding starting
+// at element 0.
+func (w *DescriptorWriter) Images(set vk.DescriptorSet, binding uint32, typ vk.DescriptorType,
+	images []vk.DescriptorImageInfo) *DescriptorWriter {
+
+	if len(images) == 0 {
+		return w
+	}
+	w.writes = append(w.writes, vk.WriteDescriptorSet{
+		SType:           vk.StructureTypeWriteDescriptorSet,
+		DstSet:          set,
+		DstBinding:      binding,
+		DescriptorCount: uint32(len(images)),
+		DescriptorType:  typ,
+		PImageInfo:      images,
+	})
+	return w
+}
+
+// Textures writes combined image samplers for textures.
+func (w *DescriptorWriter) Textures(set vk.DescriptorSet, binding uint32, textures []*Texture) *DescriptorWriter {
+	images := make([]vk.DescriptorImageInfo, 0, len(textures))
+	for _, tex := range textures {
+		images = append(images, vk.DescriptorImageInfo{
+			Sampler:     tex.sampler,
+			ImageView:   tex.view,
+			ImageLayout: tex.imageLayout,
+		})
+	}
+	return w.Images(set, binding, vk.DescriptorTypeCombinedImageSampler, images)
+}
+
+// Update applies all writes and clears the writer for reuse.
+func (w *DescriptorWriter) Update(device vk.Device) {
+	if len(w.writes) == 0 {
+		return
+	}
+	vk.UpdateDescriptorSets(device, uint32(len(w.writes)), w.writes, 0, nil)
+	w.writes = w.writes[:0]
+}
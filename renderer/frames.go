@@ -0,0 +1,308 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// DefaultFramesInFlight is how many frames the CPU may record ahead of the GPU.
+const DefaultFramesInFlight = 2
+
+// FrameResources are owned by a single frame in flight and reused
+// once the GPU has finished with that frame.
+type FrameResources struct {
+	CommandBuffer  vk.CommandBuffer
+	ImageAvailable vk.Semaphore
+	RenderFinished vk.Semaphore
+	InFlight       vk.Fence
+	// Descriptors allocates sets used by this frame only, they are
+	// recycled when the frame slot is reused.
+	Descriptors *DescriptorAllocator
+}
+
+// Frame is a frame in flight with the swapchain image it renders to.
+type Frame struct {
+	*FrameResources
+
+	Index      int
+	ImageIndex uint32
+	// Offscreen frames render to an OffscreenTarget, they don't wait for
+	// an image to be acquired and aren't presented.
+	Offscreen bool
+}
+
+// FrameScheduler rotates over N sets of FrameResources so the CPU can
+// prepare the next frame while the GPU still renders the previous ones.
+type FrameScheduler struct {
+	device  vk.Device
+	cmdPool vk.CommandPool
+
+	frames []FrameResources
+	// imagesInFlight holds the fence of the frame that last used
+	// a swapchain image, images may be acquired out of order.
+	imagesInFlight []vk.Fence
+	current        int
+}
+
+// CreateFrameScheduler creates framesInFlight frames, each with its own
+// command buffer from cmdPool and sync objects.
+func (v *VulkanDeviceInfo) CreateFrameScheduler(framesInFlight int, cmdPool vk.CommandPool,
+	imageCount uint32) (*FrameScheduler, error) {
+
+	if framesInFlight < 1 {
+		framesInFlight = DefaultFramesInFlight
+	}
+	f := &FrameScheduler{
+		device:         v.Device,
+		cmdPool:        cmdPool,
+		frames:         make([]FrameResources, framesInFlight),
+		imagesInFlight: make([]vk.Fence, imageCount),
+	}
+	cmdBuffers, err := v.CreateCommandBuffers(uint32(framesInFlight), cmdPool)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fences start signaled, so waiting on a frame that never
+	// was submitted doesn't block.
+	fenceCreateInfo := vk.FenceCreateInfo{
+		SType: vk.StructureTypeFenceCreateInfo,
+		Flags: vk.FenceCreateFlags(vk.FenceCreateSignaledBit),
+	}
+	semaphoreCreateInfo := vk.SemaphoreCreateInfo{
+		SType: vk.StructureTypeSemaphoreCreateInfo,
+	}
+	for i := range f.frames {
+		frame := &f.frames[i]
+		frame.CommandBuffer = cmdBuffers[i]
+
+		frame.Descriptors = NewDescriptorAllocator(v.Device, 0, nil)
+		err = vk.Error(vk.CreateFence(v.Device, &fenceCreateInfo, nil, &frame.InFlight))
+		if err != nil {
+			f.Destroy()
+			return nil, fmt.Errorf("vk.CreateFence failed with %s", err)
+		}
+		err = vk.Error(vk.CreateSemaphore(v.Device, &semaphoreCreateInfo, nil, &frame.ImageAvailable))
+		if err != nil {
+			f.Destroy()
+			return nil, fmt.Errorf("vk.CreateSemaphore failed with %s", err)
+		}
+		err = vk.Error(vk.CreateSemaphore(v.Device, &semaphoreCreateInfo, nil, &frame.RenderFinished))
+		if err != nil {
+			f.Destroy()
+			return nil, fmt.Errorf("vk.CreateSemaphore failed with %s", err)
+		}
+	}
+	return f, nil
+}
+
+// Len returns the number of frames in flight.
+func (f *FrameScheduler) Len() int {
+	return len(f.frames)
+}
+
+// Current returns the frame slot the next frame uses.
+func (f *FrameScheduler) Current() int {
+	return f.current
+}
+
+// Resources returns the resources of frame slot i.
+func (f *FrameScheduler) Resources(i int) *FrameResources {
+	return &f.frames[i]
+}
+
+// ResetImages forgets the image tracking after the swapchain was
+// recreated with imageCount images.
+func (f *FrameScheduler) ResetImages(imageCount uint32) {
+	f.imagesInFlight = make([]vk.Fence, imageCount)
+}
+
+// BeginFrame waits until the GPU is done with the current frame slot
+// and acquires the next swapchain image for it. A result other than
+// vk.Success or vk.Suboptimal means no frame was started.
+func (f *FrameScheduler) BeginFrame(s *VulkanSwapchainInfo) (*Frame, vk.Result) {
+	frame := &Frame{
+		FrameResources: &f.frames[f.current],
+		Index:          f.current,
+	}
+
+	// Phase 1: vk.WaitForFences
+	//			wait for the GPU to finish the previous use of this slot
+
+	ret := vk.WaitForFences(f.device, 1, []vk.Fence{frame.InFlight}, vk.True, vk.MaxUint64)
+	if ret != vk.Success {
+		return frame, ret
+	}
+	frame.Descriptors.Reset()
+
+	// Phase 2: vk.AcquireNextImage
+	//			the semaphore is signaled once the image can be written
+
+	ret = vk.AcquireNextImage(f.device, s.DefaultSwapchain(),
+		vk.MaxUint64, frame.ImageAvailable, vk.NullFence, &frame.ImageIndex)
+	if ret != vk.Success && ret != vk.Suboptimal {
+		return frame, ret
+	}
+
+	// Phase 3: wait for an older frame that still renders into
+	//			this image, then claim the image for this frame
+
+	if fence := f.imagesInFlight[frame.ImageIndex]; fence != vk.NullFence && fence != frame.InFlight {
+		waitRet := vk.WaitForFences(f.device, 1, []vk.Fence{fence}, vk.True, vk.MaxUint64)
+		if waitRet != vk.Success {
+			return frame, waitRet
+		}
+	}
+	f.imagesInFlight[frame.ImageIndex] = frame.InFlight
+	return frame, ret
+}
+
+// BeginOffscreenFrame waits until the GPU is done with the current frame
+// slot, the frame renders to the image of t with the index of the slot.
+func (f *FrameScheduler) BeginOffscreenFrame(t *OffscreenTarget) (*Frame, error) {
+	if int(t.ImageCount()) < len(f.frames) {
+		return nil, fmt.Errorf("%d offscreen images for %d frames in flight", t.ImageCount(), len(f.frames))
+	}
+	frame := &Frame{
+		FrameResources: &f.frames[f.current],
+		Index:          f.current,
+		ImageIndex:     uint32(f.current),
+		Offscreen:      true,
+	}
+	err := vk.Error(vk.WaitForFences(f.device, 1, []vk.Fence{frame.InFlight}, vk.True, vk.MaxUint64))
+	if err != nil {
+		return nil, fmt.Errorf("vk.WaitForFences failed with %s", err)
+	}
+	frame.Descriptors.Reset()
+	return frame, nil
+}
+
+// Submit submits the command buffer of the frame. It waits for the image
+// to become available and signals RenderFinished and the frame fence.
+// Offscreen frames only signal the fence and advance to the next frame
+// slot, there is nothing to present.
+func (f *FrameScheduler) Submit(queue vk.Queue, frame *Frame) error {
+	// The fence is only reset right before a submit, so a frame that
+	// was abandoned after BeginFrame doesn't leave it unsignaled.
+	err := vk.Error(vk.ResetFences(f.device, 1, []vk.Fence{frame.InFlight}))
+	if err != nil {
+		return fmt.Errorf("vk.ResetFences failed with %s", err)
+	}
+	submitInfo := []vk.SubmitInfo{{
+		SType:                vk.StructureTypeSubmitInfo,
+		WaitSemaphoreCount:   1,
+		PWaitSemaphores:      []vk.Semaphore{frame.ImageAvailable},
+		PWaitDstStageMask:    []vk.PipelineStageFlags{vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit)},
+		CommandBufferCount:   1,
+		PCommandBuffers:      []vk.CommandBuffer{frame.CommandBuffer},
+		SignalSemaphoreCount: 1,
+		PSignalSemaphores:    []vk.Semaphore{frame.RenderFinished},
+	}}
+	if frame.Offscreen {
+		submitInfo[0] = vk.SubmitInfo{
+			SType:              vk.StructureTypeSubmitInfo,
+			CommandBufferCount: 1,
+			PCommandBuffers:    []vk.CommandBuffer{frame.CommandBuffer},
+		}
+	}
+	err = vk.Error(vk.QueueSubmit(queue, 1, submitInfo, frame.InFlight))
+	if err != nil {
+		return fmt.Errorf("vk.QueueSubmit failed with %s", err)
+	}
+	if frame.Offscreen {
+		f.current = (f.current + 1) % len(f.frames)
+	}
+	return nil
+}
+
+// Abandon consumes the ImageAvailable semaphore of a frame that acquired
+// an image but fails before Submit, with an empty submit that waits on it
+// and signals the frame fence. Otherwise the next acquire in this slot
+// would signal a semaphore that is still signaled.
+func (f *FrameScheduler) Abandon(queue vk.Queue, frame *Frame) error {
+	if frame.Offscreen {
+		return nil
+	}
+	err := vk.Error(vk.ResetFences(f.device, 1, []vk.Fence{frame.InFlight}))
+	if err != nil {
+		return fmt.Errorf("vk.ResetFences failed with %s", err)
+	}
+	submitInfo := []vk.SubmitInfo{{
+		SType:              vk.StructureTypeSubmitInfo,
+		WaitSemaphoreCount: 1,
+		PWaitSemaphores:    []vk.Semaphore{frame.ImageAvailable},
+		PWaitDstStageMask:  []vk.PipelineStageFlags{vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit)},
+	}}
+	err = vk.Error(vk.QueueSubmit(queue, 1, submitInfo, frame.InFlight))
+	if err != nil {
+		return fmt.Errorf("vk.QueueSubmit failed with %s", err)
+	}
+	return nil
+}
+
+// WaitFrame blocks until a submitted frame has completed on the GPU, for
+// example to read back what it rendered.
+func (f *FrameScheduler) WaitFrame(frame *Frame) error {
+	err := vk.Error(vk.WaitForFences(f.device, 1, []vk.Fence{frame.InFlight}, vk.True, vk.MaxUint64))
+	if err != nil {
+		return fmt.Errorf("vk.WaitForFences failed with %s", err)
+	}
+	return nil
+}
+
+// Present queues the frame image for presentation once rendering has
+// finished and advances to the next frame slot.
+func (f *FrameScheduler) Present(queue vk.Queue, s *VulkanSwapchainInfo, frame *Frame) vk.Result {
+	presentInfo := vk.PresentInfo{
+		SType:              vk.StructureTypePresentInfo,
+		WaitSemaphoreCount: 1,
+		PWaitSemaphores:    []vk.Semaphore{frame.RenderFinished},
+		SwapchainCount:     1,
+		PSwapchains:        s.Swapchains,
+		PImageIndices:      []uint32{frame.ImageIndex},
+	}
+	ret := vk.QueuePresent(queue, &presentInfo)
+	f.current = (f.current + 1) % len(f.frames)
+	return ret
+}
+
+// Wait blocks until every frame in flight has completed on the GPU.
+func (f *FrameScheduler) Wait() error {
+	fences := make([]vk.Fence, 0, len(f.frames))
+	for i := range f.frames {
+		if f.frames[i].InFlight != vk.NullFence {
+			fences = append(fences, f.frames[i].InFlight)
+		}
+	}
+	if len(fences) == 0 {
+		return nil
+	}
+	err := vk.Error(vk.WaitForFences(f.device, uint32(len(fences)), fences, vk.True, vk.MaxUint64))
+	if err != nil {
+		return fmt.Errorf("vk.WaitForFences failed with %s", err)
+	}
+	return nil
+}
+
+func (f *FrameScheduler) Destroy() {
+	if f == nil {
+		return
+	}
+	cmdBuffers := make([]vk.CommandBuffer, 0, len(f.frames))
+	for i := range f.frames {
+		frame := &f.frames[i]
+		if frame.CommandBuffer != nil {
+			cmdBuffers = append(cmdBuffers, frame.CommandBuffer)
+		}
+		vk.DestroyFence(f.device, frame.InFlight, nil)
+		vk.DestroySemaphore(f.device, frame.ImageAvailable, nil)
+		vk.DestroySemaphore(f.device, frame.RenderFinished, nil)
+		frame.Descriptors.Destroy()
+	}
+	if len(cmdBuffers) > 0 {
+		vk.FreeCommandBuffers(f.device, f.cmdPool, uint32(len(cmdBuffers)), cmdBuffers)
+	}
+	f.frames = nil
+	f.imagesInFlight = nil
+}
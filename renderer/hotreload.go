@@ -0,0 +1,130 @@
+package renderer
+
+import (
+	"fmt"
+	"log"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// PipelinesUsing returns the indices of the descriptions that use any of
+// the shaders.
+func PipelinesUsing(descs []PipelineDesc, shaders []string) []int {
+	changed := make(map[string]bool, len(shaders))
+	for _, name := range shaders {
+		changed[name] = true
+	}
+	var affected []int
+	for i, desc := range descs {
+		for _, stage := range desc.Shaders {
+			if changed[stage.Name] {
+				affected = append(affected, i)
+				break
+			}
+		}
+	}
+	return affected
+}
+
+type reloadEntry struct {
+	builder    PipelineBuilder
+	renderPass vk.RenderPass
+	pipeline   *Pipeline
+}
+
+// PipelineReloader rebuilds the pipelines it built when their shaders
+// change. A rebuilt pipeline replaces the old one in place, so holders of
+// the *Pipeline pick it up without being told. The vertex input and
+// layouts are kept, only the shader code is reloaded.
+type PipelineReloader struct {
+	device  vk.Device
+	load    func(string) ([]byte, error)
+	entries []reloadEntry
+}
+
+func NewPipelineReloader(device vk.Device, load func(string) ([]byte, error)) *PipelineReloader {
+	return &PipelineReloader{
+		device: device,
+		load:   load,
+	}
+}
+
+// Build builds a pipeline and tracks it until it is destroyed.
+func (r *PipelineReloader) Build(b *PipelineBuilder, renderPass vk.RenderPass) (*Pipeline, error) {
+	p, err := b.Build(r.device, renderPass, r.load)
+	if err != nil {
+		return nil, err
+	}
+	r.prune()
+	builder := *b
+	builder.desc = b.Desc()
+	builder.setLayouts = append([]vk.DescriptorSetLayout(nil), b.setLayouts...)
+	r.entries = append(r.entries, reloadEntry{
+		builder:    builder,
+		renderPass: renderPass,
+		pipeline:   p,
+	})
+	return p, nil
+}
+
+// prune forgets destroyed pipelines.
+func (r *PipelineReloader) prune() {
+	live := r.entries[:0]
+	for _, e := range r.entries {
+		if e.pipeline.Pipeline != vk.NullPipeline {
+			live = append(live, e)
+		}
+	}
+	r.entries = live
+}
+
+// Reload rebuilds the pipelines using the changed shaders and returns how
+// many were replaced. A pipeline that fails to build is kept and the error
+// logged. It waits for the device to be idle before replacing anything,
+// call it between frames.
+func (r *PipelineReloader) Reload(changed []string) int {
+	r.prune()
+	descs := make([]PipelineDesc, len(r.entries))
+	for i, e := range r.entries {
+		descs[i] = e.pipeline.Desc
+	}
+	affected := PipelinesUsing(descs, changed)
+	if len(affected) == 0 {
+		return 0
+	}
+
+	// Phase 1: build the new pipelines next to the old ones
+
+	rebuilt := make(map[int]*Pipeline, len(affected))
+	for _, i := range affected {
+		e := r.entries[i]
+		p, err := e.builder.Build(r.device, e.renderPass, r.load)
+		if err != nil {
+			log.Println("[WARN] keeping the old pipeline:", err)
+			continue
+		}
+		rebuilt[i] = p
+	}
+	if len(rebuilt) == 0 {
+		return 0
+	}
+
+	// Phase 2: vk.DeviceWaitIdle
+	//			swap them in once the old ones are no longer in use
+
+	err := vk.Error(vk.DeviceWaitIdle(r.device))
+	if err != nil {
+		log.Println("[WARN]", fmt.Errorf("vk.DeviceWaitIdle failed with %s", err))
+		for _, p := range rebuilt {
+			p.Destroy()
+		}
+		return 0
+	}
+	for i, p := range rebuilt {
+		old := r.entries[i].pipeline
+		vk.DestroyPipeline(r.device, old.Pipeline, nil)
+		vk.DestroyPipelineLayout(r.device, old.Layout, nil)
+		*old = *p
+	}
+	return len(rebuilt)
+}
@@ -0,0 +1,307 @@
+package renderer
+
+import (
+	"fmt"
+	"log"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/memalloc"
+)
+
+// deviceMemory is the backend handle of a memalloc block. Host visible
+// blocks stay mapped for their whole lifetime since a vk.DeviceMemory
+// can't be mapped twice.
+type deviceMemory struct {
+	memory vk.DeviceMemory
+	mapped unsafe.Pointer
+}
+
+type memoryBackend struct {
+	device vk.Device
+	props  vk.PhysicalDeviceMemoryProperties
+}
+
+func (b *memoryBackend) AllocateMemory(memoryType uint32, size uint64) (interface{}, error) {
+	allocInfo := vk.MemoryAllocateInfo{
+		SType:           vk.StructureTypeMemoryAllocateInfo,
+		AllocationSize:  vk.DeviceSize(size),
+		MemoryTypeIndex: memoryType,
+	}
+	mem := &deviceMemory{}
+	err := vk.Error(vk.AllocateMemory(b.device, &allocInfo, nil, &mem.memory))
+	if err != nil {
+		err = fmt.Errorf("vk.AllocateMemory failed with %s", err)
+		return nil, err
+	}
+	flags := b.props.MemoryTypes[memoryType].PropertyFlags
+	if flags&vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit) != 0 {
+		err = vk.Error(vk.MapMemory(b.device, mem.memory, 0, vk.DeviceSize(vk.WholeSize), 0, &mem.mapped))
+		if err != nil {
+			vk.FreeMemory(b.device, mem.memory, nil)
+			err = fmt.Errorf("vk.MapMemory failed with %s", err)
+			return nil, err
+		}
+	}
+	return mem, nil
+}
+
+func (b *memoryBackend) FreeMemory(memoryType uint32, memory interface{}) {
+	mem := memory.(*deviceMemory)
+	if mem.mapped != nil {
+		vk.UnmapMemory(b.device, mem.memory)
+	}
+	vk.FreeMemory(b.device, mem.memory, nil)
+}
+
+// MemoryAllocator hands out device memory for buffers and images from
+// large blocks instead of one vk.AllocateMemory per resource.
+type MemoryAllocator struct {
+	device  vk.Device
+	props   vk.PhysicalDeviceMemoryProperties
+	limits  vk.PhysicalDeviceLimits
+	backend *memoryBackend
+	alloc   *memalloc.Allocator
+}
+
+// NewMemoryAllocator creates the allocator for the logical device. Blocks
+// are an eighth of the smallest heap but at most memalloc.DefaultBlockSize.
+func (v *VulkanDeviceInfo) NewMemoryAllocator() *MemoryAllocator {
+	var props vk.PhysicalDeviceMemoryProperties
+	vk.GetPhysicalDeviceMemoryProperties(v.gpuDevices[0], &props)
+	props.Deref()
+	for i := uint32(0); i < props.MemoryTypeCount; i++ {
+		props.MemoryTypes[i].Deref()
+	}
+	blockSize := uint64(memalloc.DefaultBlockSize)
+	for i := uint32(0); i < props.MemoryHeapCount; i++ {
+		props.MemoryHeaps[i].Deref()
+		if size := uint64(props.MemoryHeaps[i].Size) / 8; size > 0 && size < blockSize {
+			blockSize = size
+		}
+	}
+	limits := v.Limits()
+
+	backend := &memoryBackend{
+		device: v.Device,
+		props:  props,
+	}
+	return &MemoryAllocator{
+		device:  v.Device,
+		props:   props,
+		limits:  limits,
+		backend: backend,
+		alloc: memalloc.New(backend, memalloc.Config{
+			BlockSize:   blockSize,
+			Granularity: uint64(limits.BufferImageGranularity),
+		}),
+	}
+}
+
+// FindMemoryType returns the first memory type allowed by typeBits that has
+// all required properties, types that also have the preferred ones win.
+func (m *MemoryAllocator) FindMemoryType(typeBits uint32, required, preferred vk.MemoryPropertyFlags) (uint32, bool) {
+	fallback, found := uint32(0), false
+	for i := uint32(0); i < m.props.MemoryTypeCount; i++ {
+		if typeBits&(1<<i) == 0 {
+			continue
+		}
+		flags := m.props.MemoryTypes[i].PropertyFlags
+		if flags&required != required {
+			continue
+		}
+		if flags&preferred == preferred {
+			return i, true
+		}
+		if !found {
+			fallback, found = i, true
+		}
+	}
+	return fallback, found
+}
+
+// Allocate sub-allocates memory satisfying the requirements with at
+// least the properties in required.
+func (m *MemoryAllocator) Allocate(memReq vk.MemoryRequirements, required, preferred vk.MemoryPropertyFlags,
+	kind memalloc.Kind) (*memalloc.Allocation, error) {
+
+	memoryType, ok := m.FindMemoryType(memReq.MemoryTypeBits, required, preferred)
+	if !ok {
+		return nil, fmt.Errorf("no memory type with properties %#x among %#b", required, memReq.MemoryTypeBits)
+	}
+	size, alignment := uint64(memReq.Size), uint64(memReq.Alignment)
+	if m.nonCoherent(memoryType) {
+		// Flushed ranges are rounded to whole atoms, keep them inside
+		// the allocation.
+		atom := uint64(m.limits.NonCoherentAtomSize)
+		alignment = lcm(alignment, atom)
+		size = (size + atom - 1) / atom * atom
+	}
+	return m.alloc.Allocate(memoryType, memalloc.Request{
+		Size:      size,
+		Alignment: alignment,
+		Kind:      kind,
+	})
+}
+
+func (m *MemoryAllocator) nonCoherent(memoryType uint32) bool {
+	flags := m.props.MemoryTypes[memoryType].PropertyFlags
+	return flags&vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit) != 0 &&
+		flags&vk.MemoryPropertyFlags(vk.MemoryPropertyHostCoherentBit) == 0
+}
+
+// Coherent reports whether host writes to an allocation are visible to the
+// device without Flush.
+func (m *MemoryAllocator) Coherent(alloc *memalloc.Allocation) bool {
+	return !m.nonCoherent(alloc.MemoryType())
+}
+
+// mappedRange returns the range of the device memory covering size bytes at
+// offset in alloc, widened to whole non-coherent atoms.
+func (m *MemoryAllocator) mappedRange(alloc *memalloc.Allocation, offset, size uint64) vk.MappedMemoryRange {
+	atom := uint64(m.limits.NonCoherentAtomSize)
+	if atom == 0 {
+		atom = 1
+	}
+	start := offset / atom * atom
+	end := (offset + size + atom - 1) / atom * atom
+	if end > alloc.Size {
+		end = alloc.Size
+	}
+	return vk.MappedMemoryRange{
+		SType:  vk.StructureTypeMappedMemoryRange,
+		Memory: m.Memory(alloc),
+		Offset: vk.DeviceSize(alloc.Offset + start),
+		Size:   vk.DeviceSize(end - start),
+	}
+}
+
+// Flush makes host writes to size bytes at offset in alloc visible to the
+// device, it does nothing for host coherent memory.
+func (m *MemoryAllocator) Flush(alloc *memalloc.Allocation, offset, size uint64) error {
+	if !m.nonCoherent(alloc.MemoryType()) {
+		return nil
+	}
+	r := m.mappedRange(alloc, offset, size)
+	err := vk.Error(vk.FlushMappedMemoryRanges(m.device, 1, []vk.MappedMemoryRange{r}))
+	if err != nil {
+		return fmt.Errorf("vk.FlushMappedMemoryRanges failed with %s", err)
+	}
+	return nil
+}
+
+// Invalidate makes device writes to size bytes at offset in alloc visible
+// to the host, it does nothing for host coherent memory.
+func (m *MemoryAllocator) Invalidate(alloc *memalloc.Allocation, offset, size uint64) error {
+	if !m.nonCoherent(alloc.MemoryType()) {
+		return nil
+	}
+	r := m.mappedRange(alloc, offset, size)
+	err := vk.Error(vk.InvalidateMappedMemoryRanges(m.device, 1, []vk.MappedMemoryRange{r}))
+	if err != nil {
+		return fmt.Errorf("vk.InvalidateMappedMemoryRanges failed with %s", err)
+	}
+	return nil
+}
+
+// AllocateBuffer allocates memory for buffer and binds it.
+func (m *MemoryAllocator) AllocateBuffer(buffer vk.Buffer, required, preferred vk.MemoryPropertyFlags) (*memalloc.Allocation, error) {
+	var memReq vk.MemoryRequirements
+	vk.GetBufferMemoryRequirements(m.device, buffer, &memReq)
+	memReq.Deref()
+	alloc, err := m.Allocate(memReq, required, preferred, memalloc.KindBuffer)
+	if err != nil {
+		return nil, err
+	}
+	err = vk.Error(vk.BindBufferMemory(m.device, buffer, m.Memory(alloc), vk.DeviceSize(alloc.Offset)))
+	if err != nil {
+		m.Free(alloc)
+		err = fmt.Errorf("vk.BindBufferMemory failed with %s", err)
+		return nil, err
+	}
+	return alloc, nil
+}
+
+// AllocateImage allocates memory for image and binds it.
+func (m *MemoryAllocator) AllocateImage(image vk.Image, tiling vk.ImageTiling,
+	required, preferred vk.MemoryPropertyFlags) (*memalloc.Allocation, error) {
+
+	var memReq vk.MemoryRequirements
+	vk.GetImageMemoryRequirements(m.device, image, &memReq)
+	memReq.Deref()
+	kind := memalloc.KindImageOptimal
+	if tiling == vk.ImageTilingLinear {
+		kind = memalloc.KindImageLinear
+	}
+	alloc, err := m.Allocate(memReq, required, preferred, kind)
+	if err != nil {
+		return nil, err
+	}
+	err = vk.Error(vk.BindImageMemory(m.device, image, m.Memory(alloc), vk.DeviceSize(alloc.Offset)))
+	if err != nil {
+		m.Free(alloc)
+		err = fmt.Errorf("vk.BindImageMemory failed with %s", err)
+		return nil, err
+	}
+	return alloc, nil
+}
+
+// Memory returns the device memory an allocation lives in, resources are
+// bound to it at alloc.Offset.
+func (m *MemoryAllocator) Memory(alloc *memalloc.Allocation) vk.DeviceMemory {
+	return alloc.Memory().(*deviceMemory).memory
+}
+
+// Map returns a pointer to the start of a host visible allocation.
+func (m *MemoryAllocator) Map(alloc *memalloc.Allocation) (unsafe.Pointer, error) {
+	mem := alloc.Memory().(*deviceMemory)
+	if mem.mapped == nil {
+		return nil, fmt.Errorf("memory type %d is not host visible", alloc.MemoryType())
+	}
+	return unsafe.Pointer(uintptr(mem.mapped) + uintptr(alloc.Offset)), nil
+}
+
+// Write copies data to the start of a host visible allocation.
+func (m *MemoryAllocator) Write(alloc *memalloc.Allocation, data []byte) error {
+	if uint64(len(data)) > alloc.Size {
+		return fmt.Errorf("%d bytes don't fit an allocation of %d", len(data), alloc.Size)
+	}
+	ptr, err := m.Map(alloc)
+	if err != nil {
+		return err
+	}
+	n := vk.Memcopy(ptr, data)
+	if n != len(data) {
+		return fmt.Errorf("copied %d of %d bytes", n, len(data))
+	}
+	return m.Flush(alloc, 0, uint64(n))
+}
+
+func (m *MemoryAllocator) Free(alloc *memalloc.Allocation) {
+	if m == nil || alloc == nil {
+		return
+	}
+	if err := m.alloc.Free(alloc); err != nil {
+		log.Println("[WARN]", err)
+	}
+}
+
+// Defragment compacts the blocks of a memory type, see memalloc.Defragment.
+func (m *MemoryAllocator) Defragment(memoryType uint32, mover memalloc.Mover, maxMoves int) (int, error) {
+	return m.alloc.Defragment(memoryType, mover, maxMoves)
+}
+
+func (m *MemoryAllocator) Stats() memalloc.Stats {
+	return m.alloc.TotalStats()
+}
+
+// Destroy frees all blocks, it must be called before the device is destroyed.
+func (m *MemoryAllocator) Destroy() {
+	if m == nil {
+		return
+	}
+	if stats := m.alloc.TotalStats(); stats.Allocations > 0 {
+		log.Println("[WARN] destroying memory allocator with", stats)
+	}
+	m.alloc.Destroy()
+}
@@ -0,0 +1,120 @@
+package renderer
+
+import (
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// MipmapBlit describes the generation of the mip levels of an image from
+// its first level with linear filtered blits.
+type MipmapBlit struct {
+	Image         vk.Image
+	Width, Height uint32
+	Levels        uint32
+	Layers        uint32
+	// FinalLayout defaults to vk.ImageLayoutShaderReadOnlyOptimal.
+	FinalLayout vk.ImageLayout
+	// DstAccess and DstStage describe the first use of the image
+	// and default to a fragment shader read.
+	DstAccess vk.AccessFlags
+	DstStage  vk.PipelineStageFlags
+}
+
+// CanBlitMipmaps reports whether the mip levels of optimally tiled images
+// of format can be generated with linear filtered blits.
+func (v VulkanDeviceInfo) CanBlitMipmaps(format vk.Format) bool {
+	required := vk.FormatFeatureFlags(vk.FormatFeatureBlitSrcBit | vk.FormatFeatureBlitDstBit |
+		vk.FormatFeatureSampledImageFilterLinearBit)
+	return v.optimalFeatures(format)&required == required
+}
+
+// GenerateMipmaps blits the levels of m.Image once the uploads recorded so
+// far are done. The first level of every layer has to be uploaded with a
+// FinalLayout of vk.ImageLayoutTransferSrcOptimal and a transfer read as
+// first use, the image must have the transfer source usage.
+func (u *UploadManager) GenerateMipmaps(m MipmapBlit) (UploadTicket, error) {
+	if m.FinalLayout == vk.ImageLayoutUndefined {
+		m.FinalLayout = vk.ImageLayoutShaderReadOnlyOptimal
+	}
+	if m.DstAccess == 0 {
+		m.DstAccess = vk.AccessFlags(vk.AccessShaderReadBit)
+		m.DstStage = vk.PipelineStageFlags(vk.PipelineStageFragmentShaderBit)
+	}
+	if m.Layers == 0 {
+		m.Layers = 1
+	}
+	b, err := u.begin()
+	if err != nil {
+		return 0, err
+	}
+	b.mipmaps = append(b.mipmaps, m)
+	return b.ticket, nil
+}
+
+// recordMipmapBlits blits each level from the previous one. Every level
+// ends up in the transfer source layout, then all move to m.FinalLayout.
+func recordMipmapBlits(cmd vk.CommandBuffer, m MipmapBlit) {
+	transfer := vk.PipelineStageFlags(vk.PipelineStageTransferBit)
+	levelBarrier := func(level, count uint32, oldLayout, newLayout vk.ImageLayout,
+		srcAccess, dstAccess vk.AccessFlags) vk.ImageMemoryBarrier {
+
+		return vk.ImageMemoryBarrier{
+			SType:               vk.StructureTypeImageMemoryBarrier,
+			SrcAccessMask:       srcAccess,
+			DstAccessMask:       dstAccess,
+			OldLayout:           oldLayout,
+			NewLayout:           newLayout,
+			SrcQueueFamilyIndex: vk.QueueFamilyIgnored,
+			DstQueueFamilyIndex: vk.QueueFamilyIgnored,
+			Image:               m.Image,
+			SubresourceRange: vk.ImageSubresourceRange{
+				AspectMask:   vk.ImageAspectFlags(vk.ImageAspectColorBit),
+				BaseMipLevel: level,
+				LevelCount:   count,
+				LayerCount:   m.Layers,
+			},
+		}
+	}
+
+	// Phase 1: move the levels to generate to the transfer destination
+	//			layout
+
+	vk.CmdPipelineBarrier(cmd, vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit), transfer,
+		0, 0, nil, 0, nil, 1, []vk.ImageMemoryBarrier{
+			levelBarrier(1, m.Levels-1, vk.ImageLayoutUndefined, vk.ImageLayoutTransferDstOptimal,
+				0, vk.AccessFlags(vk.AccessTransferWriteBit)),
+		})
+
+	// Phase 2: vk.CmdBlitImage
+	//			each level becomes the source of the next one
+
+	for level := uint32(1); level < m.Levels; level++ {
+		srcWidth, srcHeight := max(m.Width>>(level-1), 1), max(m.Height>>(level-1), 1)
+		dstWidth, dstHeight := max(m.Width>>level, 1), max(m.Height>>level, 1)
+		vk.CmdBlitImage(cmd, m.Image, vk.ImageLayoutTransferSrcOptimal, m.Image, vk.ImageLayoutTransferDstOptimal,
+			1, []vk.ImageBlit{{
+				SrcSubresource: vk.ImageSubresourceLayers{
+					AspectMask: vk.ImageAspectFlags(vk.ImageAspectColorBit),
+					MipLevel:   level - 1,
+					LayerCount: m.Layers,
+				},
+				SrcOffsets: [2]vk.Offset3D{{}, {X: int32(srcWidth), Y: int32(srcHeight), Z: 1}},
+				DstSubresource: vk.ImageSubresourceLayers{
+					AspectMask: vk.ImageAspectFlags(vk.ImageAspectColorBit),
+					MipLevel:   level,
+					LayerCount: m.Layers,
+				},
+				DstOffsets: [2]vk.Offset3D{{}, {X: int32(dstWidth), Y: int32(dstHeight), Z: 1}},
+			}}, vk.FilterLinear)
+		vk.CmdPipelineBarrier(cmd, transfer, transfer, 0, 0, nil, 0, nil, 1, []vk.ImageMemoryBarrier{
+			levelBarrier(level, 1, vk.ImageLayoutTransferDstOptimal, vk.ImageLayoutTransferSrcOptimal,
+				vk.AccessFlags(vk.AccessTransferWriteBit), vk.AccessFlags(vk.AccessTransferReadBit)),
+		})
+	}
+
+	// Phase 3: transition all levels to the final layout
+
+	vk.CmdPipelineBarrier(cmd, transfer, m.DstStage, 0, 0, nil, 0, nil, 1, []vk.ImageMemoryBarrier{
+		levelBarrier(0, m.Levels, vk.ImageLayoutTransferSrcOptimal, m.FinalLayout,
+			vk.AccessFlags(vk.AccessTransferReadBit|vk.AccessTransferWriteBit), m.DstAccess),
+	})
+}
@@ -0,0 +1,60 @@
+package renderer
+
+import (
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// sampleCounts are the valid sample counts, highest first.
+var sampleCounts = []vk.SampleCountFlagBits{
+	vk.SampleCount64Bit,
+	vk.SampleCount32Bit,
+	vk.SampleCount16Bit,
+	vk.SampleCount8Bit,
+	vk.SampleCount4Bit,
+	vk.SampleCount2Bit,
+	vk.SampleCount1Bit,
+}
+
+// ChooseSampleCount returns the highest sample count not above requested
+// that both color and depth attachments support. Zero depthCounts means
+// there is no depth attachment to take into account.
+func ChooseSampleCount(requested vk.SampleCountFlagBits, colorCounts, depthCounts vk.SampleCountFlags) vk.SampleCountFlagBits {
+	supported := colorCounts
+	if depthCounts != 0 {
+		supported &= depthCounts
+	}
+	for _, count := range sampleCounts {
+		if count > requested {
+			continue
+		}
+		if supported&vk.SampleCountFlags(count) != 0 {
+			return count
+		}
+	}
+	// One sample is always supported.
+	return vk.SampleCount1Bit
+}
+
+// ChooseSampleCount clamps requested to the framebuffer sample counts of the GPU.
+func (v *VulkanDeviceInfo) ChooseSampleCount(requested vk.SampleCountFlagBits, withDepth bool) vk.SampleCountFlagBits {
+	limits := v.Limits()
+	var depthCounts vk.SampleCountFlags
+	if withDepth {
+		depthCounts = limits.FramebufferDepthSampleCounts
+	}
+	return ChooseSampleCount(requested, limits.FramebufferColorSampleCounts, depthCounts)
+}
+
+// MultisampleState is the multisample state of a pipeline drawing into
+// targets with the given sample count.
+func MultisampleState(samples vk.SampleCountFlagBits) vk.PipelineMultisampleStateCreateInfo {
+	if samples == 0 {
+		samples = vk.SampleCount1Bit
+	}
+	return vk.PipelineMultisampleStateCreateInfo{
+		SType:                vk.StructureTypePipelineMultisampleStateCreateInfo,
+		RasterizationSamples: samples,
+		SampleShadingEnable:  vk.False,
+		PSampleMask:          []vk.SampleMask{vk.SampleMask(vk.MaxUint32)},
+	}
+}
@@ -0,0 +1,39 @@
+package renderer
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestChooseSampleCount(t *testing.T) {
+	counts := func(bits ...vk.SampleCountFlagBits) vk.SampleCountFlags {
+		var flags vk.SampleCountFlags
+		for _, bit := range bits {
+			flags |= vk.SampleCountFlags(bit)
+		}
+		return flags
+	}
+	upTo8 := counts(vk.SampleCount1Bit, vk.SampleCount2Bit, vk.SampleCount4Bit, vk.SampleCount8Bit)
+	tests := []struct {
+		name      string
+		requested vk.SampleCountFlagBits
+		color     vk.SampleCountFlags
+		depth     vk.SampleCountFlags
+		want      vk.SampleCountFlagBits
+	}{
+		{"supported", vk.SampleCount4Bit, upTo8, upTo8, vk.SampleCount4Bit},
+		{"one sample", vk.SampleCount1Bit, upTo8, upTo8, vk.SampleCount1Bit},
+		{"above the maximum", vk.SampleCount64Bit, upTo8, upTo8, vk.SampleCount8Bit},
+		{"unsupported by color", vk.SampleCount8Bit, counts(vk.SampleCount1Bit, vk.SampleCount2Bit), upTo8, vk.SampleCount2Bit},
+		{"unsupported by depth", vk.SampleCount8Bit, upTo8, counts(vk.SampleCount1Bit, vk.SampleCount4Bit), vk.SampleCount4Bit},
+		{"without depth", vk.SampleCount8Bit, upTo8, 0, vk.SampleCount8Bit},
+		{"no common count", vk.SampleCount8Bit, counts(vk.SampleCount8Bit), counts(vk.SampleCount4Bit), vk.SampleCount1Bit},
+		{"no count supported", vk.SampleCount4Bit, 0, 0, vk.SampleCount1Bit},
+	}
+	for _, test := range tests {
+		if got := ChooseSampleCount(test.requested, test.color, test.depth); got != test.want {
+			t.Errorf("%s: ChooseSampleCount = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
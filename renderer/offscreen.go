@@ -0,0 +1,194 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/memalloc"
+)
+
+// DefaultOffscreenFormat is the color format of offscreen targets when
+// none is given, its texels read back as RGBA bytes.
+const DefaultOffscreenFormat = vk.FormatR8g8b8a8Unorm
+
+// readbackBuffer is a host visible buffer an image is copied to.
+type readbackBuffer struct {
+	buffer vk.Buffer
+	alloc  *memalloc.Allocation
+}
+
+// createReadbackBuffer creates a readback buffer of size bytes, cached
+// memory makes reading it faster.
+func (v *VulkanDeviceInfo) createReadbackBuffer(size uint64) (readbackBuffer, error) {
+	var rb readbackBuffer
+	err := vk.Error(vk.CreateBuffer(v.Device, &vk.BufferCreateInfo{
+		SType:       vk.StructureTypeBufferCreateInfo,
+		Size:        vk.DeviceSize(size),
+		Usage:       vk.BufferUsageFlags(vk.BufferUsageTransferDstBit),
+		SharingMode: vk.SharingModeExclusive,
+	}, nil, &rb.buffer))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateBuffer failed with %s", err)
+		return rb, err
+	}
+	rb.alloc, err = v.Memory.AllocateBuffer(rb.buffer,
+		vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit),
+		vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit|vk.MemoryPropertyHostCachedBit))
+	if err != nil {
+		vk.DestroyBuffer(v.Device, rb.buffer, nil)
+		return readbackBuffer{}, err
+	}
+	return rb, nil
+}
+
+// read copies the first size bytes out of the buffer.
+func (rb readbackBuffer) read(memory *MemoryAllocator, size uint64) ([]byte, error) {
+	ptr, err := memory.Map(rb.alloc)
+	if err != nil {
+		return nil, err
+	}
+	err = memory.Invalidate(rb.alloc, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	copy(data, (*[1 << 30]byte)(ptr)[:size:size])
+	return data, nil
+}
+
+func (rb readbackBuffer) destroy(device vk.Device, memory *MemoryAllocator) {
+	vk.DestroyBuffer(device, rb.buffer, nil)
+	memory.Free(rb.alloc)
+}
+
+// OffscreenTarget is a set of color images rendered to in place of the
+// images of a swapchain, each with a framebuffer and a buffer its pixels
+// are read back through. Frames of a FrameScheduler use image
+// Frame.Index, so images are reused once their frame has completed.
+type OffscreenTarget struct {
+	device vk.Device
+	memory *MemoryAllocator
+	format vk.Format
+	extent vk.Extent2D
+
+	images       []attachmentImage
+	framebuffers []vk.Framebuffer
+	readback     []readbackBuffer
+}
+
+// CreateOffscreenTarget creates count color images of format and extent
+// with framebuffers for renderPass, from CreateOffscreenRenderPass. The
+// attachments of targets are shared by all images like with a swapchain.
+func (v *VulkanDeviceInfo) CreateOffscreenTarget(renderPass vk.RenderPass, format vk.Format, extent vk.Extent2D,
+	targets *RenderTargets, count int) (*OffscreenTarget, error) {
+
+	if format == vk.FormatUndefined {
+		format = DefaultOffscreenFormat
+	}
+	texelSize := FormatSize(format)
+	if texelSize == 0 {
+		return nil, fmt.Errorf("offscreen format %d can't be read back", format)
+	}
+	t := &OffscreenTarget{
+		device: v.Device,
+		memory: v.Memory,
+		format: format,
+		extent: extent,
+	}
+	usage := vk.ImageUsageFlags(vk.ImageUsageColorAttachmentBit) |
+		vk.ImageUsageFlags(vk.ImageUsageTransferSrcBit)
+	size := uint64(extent.Width) * uint64(extent.Height) * uint64(texelSize)
+	for i := 0; i < count; i++ {
+
+		// Phase 1: create the color image and its framebuffer
+
+		img, err := v.createAttachmentImage(format, extent, vk.SampleCount1Bit, usage,
+			vk.ImageAspectFlags(vk.ImageAspectColorBit))
+		if err != nil {
+			t.Destroy()
+			return nil, err
+		}
+		t.images = append(t.images, img)
+
+		attachments := targets.Attachments(img.view)
+		var framebuffer vk.Framebuffer
+		err = vk.Error(vk.CreateFramebuffer(v.Device, &vk.FramebufferCreateInfo{
+			SType:           vk.StructureTypeFramebufferCreateInfo,
+			RenderPass:      renderPass,
+			Layers:          1,
+			AttachmentCount: uint32(len(attachments)),
+			PAttachments:    attachments,
+			Width:           extent.Width,
+			Height:          extent.Height,
+		}, nil, &framebuffer))
+		if err != nil {
+			t.Destroy()
+			err = fmt.Errorf("vk.CreateFramebuffer failed with %s", err)
+			return nil, err
+		}
+		t.framebuffers = append(t.framebuffers, framebuffer)
+
+		// Phase 2: create a host visible buffer to copy the image to
+
+		rb, err := v.createReadbackBuffer(size)
+		if err != nil {
+			t.Destroy()
+			return nil, err
+		}
+		t.readback = append(t.readback, rb)
+	}
+	return t, nil
+}
+
+func (t *OffscreenTarget) Extent() vk.Extent2D {
+	return t.extent
+}
+
+func (t *OffscreenTarget) Format() vk.Format {
+	return t.format
+}
+
+func (t *OffscreenTarget) ImageCount() uint32 {
+	return uint32(len(t.images))
+}
+
+func (t *OffscreenTarget) Framebuffer(image uint32) vk.Framebuffer {
+	return t.framebuffers[image]
+}
+
+func (t *OffscreenTarget) Image(image uint32) vk.Image {
+	return t.images[image].image
+}
+
+// ReadbackBuffer returns the buffer the pixels of an image are read back
+// through, record the copy to it after the render pass that rendered the
+// image.
+func (t *OffscreenTarget) ReadbackBuffer(image uint32) vk.Buffer {
+	return t.readback[image].buffer
+}
+
+// Pixels returns a copy of what the last readback of an image copied, in
+// rows of Extent().Width texels of Format() without padding. The frame that
+// recorded the readback must have completed.
+func (t *OffscreenTarget) Pixels(image uint32) ([]byte, error) {
+	size := uint64(t.extent.Width) * uint64(t.extent.Height) * uint64(FormatSize(t.format))
+	return t.readback[image].read(t.memory, size)
+}
+
+func (t *OffscreenTarget) Destroy() {
+	if t == nil {
+		return
+	}
+	for _, framebuffer := range t.framebuffers {
+		vk.DestroyFramebuffer(t.device, framebuffer, nil)
+	}
+	for i := range t.images {
+		t.images[i].Destroy()
+	}
+	for _, rb := range t.readback {
+		rb.destroy(t.device, t.memory)
+	}
+	t.framebuffers = nil
+	t.images = nil
+	t.readback = nil
+}
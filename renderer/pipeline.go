@@ -0,0 +1,683 @@
+package renderer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/util"
+)
+
+type ShaderStage struct {
+	Stage vk.ShaderStageFlagBits
+	// Name is the asset name of the SPIR-V code.
+	Name  string
+	Entry string
+}
+
+type VertexBinding struct {
+	Binding   uint32
+	Stride    uint32
+	InputRate vk.VertexInputRate
+}
+
+type VertexAttribute struct {
+	Location uint32
+	Binding  uint32
+	Format   vk.Format
+	Offset   uint32
+}
+
+type RasterState struct {
+	PolygonMode vk.PolygonMode
+	CullMode    vk.CullModeFlagBits
+	FrontFace   vk.FrontFace
+	DepthClamp  bool
+	DepthBias   bool
+	// DepthBiasConstant and DepthBiasSlope are used with DepthBias.
+	DepthBiasConstant float32
+	DepthBiasSlope    float32
+	LineWidth         float32
+}
+
+type StencilState struct {
+	FailOp      vk.StencilOp
+	PassOp      vk.StencilOp
+	DepthFailOp vk.StencilOp
+	CompareOp   vk.CompareOp
+	CompareMask uint32
+	WriteMask   uint32
+	Reference   uint32
+}
+
+type DepthState struct {
+	Test      bool
+	Write     bool
+	CompareOp vk.CompareOp
+	Stencil   bool
+	Front     StencilState
+	Back      StencilState
+}
+
+// BlendState is the blending of one color attachment.
+type BlendState struct {
+	Enable    bool
+	SrcColor  vk.BlendFactor
+	DstColor  vk.BlendFactor
+	ColorOp   vk.BlendOp
+	SrcAlpha  vk.BlendFactor
+	DstAlpha  vk.BlendFactor
+	AlphaOp   vk.BlendOp
+	WriteMask vk.ColorComponentFlagBits
+}
+
+// Opaque writes all channels without blending.
+var Opaque = BlendState{
+	WriteMask: vk.ColorComponentRBit | vk.ColorComponentGBit | vk.ColorComponentBBit | vk.ColorComponentABit,
+}
+
+// AlphaBlend is regular, non-premultiplied alpha blending.
+var AlphaBlend = BlendState{
+	Enable:    true,
+	SrcColor:  vk.BlendFactorSrcAlpha,
+	DstColor:  vk.BlendFactorOneMinusSrcAlpha,
+	ColorOp:   vk.BlendOpAdd,
+	SrcAlpha:  vk.BlendFactorOne,
+	DstAlpha:  vk.BlendFactorOneMinusSrcAlpha,
+	AlphaOp:   vk.BlendOpAdd,
+	WriteMask: Opaque.WriteMask,
+}
+
+// SpecConstant sets the value of a specialization constant of a stage.
+type SpecConstant struct {
+	Stage vk.ShaderStageFlagBits
+	ID    uint32
+	Value uint32
+}
+
+type PushConstantRange struct {
+	Stages vk.ShaderStageFlagBits
+	Offset uint32
+	Size   uint32
+}
+
+// PipelineDesc is the complete fixed function and shader state of a
+// graphics pipeline as plain data, two pipelines built from equal
+// descriptions are interchangeable.
+type PipelineDesc struct {
+	Shaders          []ShaderStage
+	VertexBindings   []VertexBinding
+	VertexAttributes []VertexAttribute
+	Topology         vk.PrimitiveTopology
+	PrimitiveRestart bool
+	Raster           RasterState
+	Samples          vk.SampleCountFlagBits
+	Depth            DepthState
+	// Blend has one entry per color attachment of the subpass.
+	Blend []BlendState
+	// DynamicViewport leaves viewport and scissor to vk.CmdSetViewport
+	// and vk.CmdSetScissor, otherwise they cover Viewport.
+	DynamicViewport bool
+	Viewport        vk.Extent2D
+	PushConstants   []PushConstantRange
+	Specialization  []SpecConstant
+}
+
+// Key is a textual form of the description, equal descriptions have
+// equal keys.
+func (d *PipelineDesc) Key() string {
+	return fmt.Sprintf("%+v", *d)
+}
+
+func (d *PipelineDesc) Hash() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(d.Key()))
+	return h.Sum64()
+}
+
+// Diff returns the names of the fields that differ between d and o.
+func (d *PipelineDesc) Diff(o *PipelineDesc) []string {
+	var fields []string
+	a, b := reflect.ValueOf(d).Elem(), reflect.ValueOf(o).Elem()
+	for i := 0; i < a.NumField(); i++ {
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			fields = append(fields, a.Type().Field(i).Name)
+		}
+	}
+	return fields
+}
+
+// Validate catches combinations Vulkan doesn't allow.
+func (d *PipelineDesc) Validate() error {
+	hasVertex := false
+	for _, s := range d.Shaders {
+		if s.Stage == vk.ShaderStageVertexBit {
+			hasVertex = true
+		}
+	}
+	if !hasVertex {
+		return fmt.Errorf("pipeline has no vertex shader")
+	}
+	if d.PrimitiveRestart {
+		switch d.Topology {
+		case vk.PrimitiveTopologyPointList, vk.PrimitiveTopologyLineList, vk.PrimitiveTopologyTriangleList:
+			return fmt.Errorf("primitive restart is not allowed with list topology %d", d.Topology)
+		}
+	}
+	if !d.DynamicViewport && (d.Viewport.Width == 0 || d.Viewport.Height == 0) {
+		return fmt.Errorf("static viewport of %dx%d", d.Viewport.Width, d.Viewport.Height)
+	}
+	for _, a := range d.VertexAttributes {
+		found := false
+		for _, b := range d.VertexBindings {
+			found = found || b.Binding == a.Binding
+		}
+		if !found {
+			return fmt.Errorf("vertex attribute %d uses the undeclared binding %d", a.Location, a.Binding)
+		}
+	}
+	return nil
+}
+
+// PipelineBuilder builds a graphics pipeline from defaults that are
+// overridden per state: triangle lists, back faces culled with counter
+// clockwise front faces, no depth test, one opaque color attachment,
+// a single sample and a dynamic viewport.
+type PipelineBuilder struct {
+	desc       PipelineDesc
+	setLayouts []vk.DescriptorSetLayout
+	cache      vk.PipelineCache
+}
+
+func NewPipelineBuilder() *PipelineBuilder {
+	return &PipelineBuilder{
+		desc: PipelineDesc{
+			Topology: vk.PrimitiveTopologyTriangleList,
+			Raster: RasterState{
+				PolygonMode: vk.PolygonModeFill,
+				CullMode:    vk.CullModeBackBit,
+				FrontFace:   vk.FrontFaceCounterClockwise,
+				LineWidth:   1,
+			},
+			Samples: vk.SampleCount1Bit,
+			Depth: DepthState{
+				CompareOp: vk.CompareOpLessOrEqual,
+			},
+			Blend:           []BlendState{Opaque},
+			DynamicViewport: true,
+		},
+	}
+}
+
+// Desc returns a copy of the description built so far.
+func (b *PipelineBuilder) Desc() PipelineDesc {
+	d := b.desc
+	d.Shaders = append([]ShaderStage(nil), d.Shaders...)
+	d.VertexBindings = append([]VertexBinding(nil), d.VertexBindings...)
+	d.VertexAttributes = append([]VertexAttribute(nil), d.VertexAttributes...)
+	d.Blend = append([]BlendState(nil), d.Blend...)
+	d.PushConstants = append([]PushConstantRange(nil), d.PushConstants...)
+	d.Specialization = append([]SpecConstant(nil), d.Specialization...)
+	return d
+}
+
+// Shader sets the shader of a stage, with "main" as entry point.
+func (b *PipelineBuilder) Shader(stage vk.ShaderStageFlagBits, name string) *PipelineBuilder {
+	for i := range b.desc.Shaders {
+		if b.desc.Shaders[i].Stage == stage {
+			b.desc.Shaders[i].Name = name
+			return b
+		}
+	}
+	b.desc.Shaders = append(b.desc.Shaders, ShaderStage{Stage: stage, Name: name, Entry: "main"})
+	return b
+}
+
+// Vertex adds a per vertex binding of tightly packed attributes, their
+// locations follow the attributes added before.
+func (b *PipelineBuilder) Vertex(binding uint32, formats ...vk.Format) *PipelineBuilder {
+	return b.vertexInput(binding, vk.VertexInputRateVertex, formats)
+}
+
+// Instance is like Vertex with a per instance binding.
+func (b *PipelineBuilder) Instance(binding uint32, formats ...vk.Format) *PipelineBuilder {
+	return b.vertexInput(binding, vk.VertexInputRateInstance, formats)
+}
+
+func (b *PipelineBuilder) vertexInput(binding uint32, rate vk.VertexInputRate, formats []vk.Format) *PipelineBuilder {
+	offset := uint32(0)
+	for _, format := range formats {
+		b.desc.VertexAttributes = append(b.desc.VertexAttributes, VertexAttribute{
+			Location: uint32(len(b.desc.VertexAttributes)),
+			Binding:  binding,
+			Format:   format,
+			Offset:   offset,
+		})
+		offset += FormatSize(format)
+	}
+	return b.VertexBinding(VertexBinding{Binding: binding, Stride: offset, InputRate: rate})
+}
+
+// VertexBinding adds or replaces a binding, for layouts Vertex can't express.
+func (b *PipelineBuilder) VertexBinding(binding VertexBinding) *PipelineBuilder {
+	for i := range b.desc.VertexBindings {
+		if b.desc.VertexBindings[i].Binding == binding.Binding {
+			b.desc.VertexBindings[i] = binding
+			return b
+		}
+	}
+	b.desc.VertexBindings = append(b.desc.VertexBindings, binding)
+	return b
+}
+
+func (b *PipelineBuilder) VertexAttribute(attribute VertexAttribute) *PipelineBuilder {
+	b.desc.VertexAttributes = append(b.desc.VertexAttributes, attribute)
+	return b
+}
+
+func (b *PipelineBuilder) Topology(topology vk.PrimitiveTopology, primitiveRestart bool) *PipelineBuilder {
+	b.desc.Topology = topology
+	b.desc.PrimitiveRestart = primitiveRestart
+	return b
+}
+
+func (b *PipelineBuilder) Raster(raster RasterState) *PipelineBuilder {
+	b.desc.Raster = raster
+	return b
+}
+
+func (b *PipelineBuilder) Cull(mode vk.CullModeFlagBits, frontFace vk.FrontFace) *PipelineBuilder {
+	b.desc.Raster.CullMode = mode
+	b.desc.Raster.FrontFace = frontFace
+	return b
+}
+
+func (b *PipelineBuilder) Samples(samples vk.SampleCountFlagBits) *PipelineBuilder {
+	b.desc.Samples = samples
+	return b
+}
+
+// DepthTest enables the depth test with vk.CompareOpLessOrEqual.
+func (b *PipelineBuilder) DepthTest(write bool) *PipelineBuilder {
+	b.desc.Depth.Test = true
+	b.desc.Depth.Write = write
+	return b
+}
+
+func (b *PipelineBuilder) Depth(depth DepthState) *PipelineBuilder {
+	b.desc.Depth = depth
+	return b
+}
+
+// Blend sets the blending of color attachment i, adding opaque
+// attachments up to it.
+func (b *PipelineBuilder) Blend(i int, blend BlendState) *PipelineBuilder {
+	for len(b.desc.Blend) <= i {
+		b.desc.Blend = append(b.desc.Blend, Opaque)
+	}
+	b.desc.Blend[i] = blend
+	return b
+}
+
+// ColorAttachments sets the number of color attachments, new ones are opaque.
+func (b *PipelineBuilder) ColorAttachments(n int) *PipelineBuilder {
+	if n < len(b.desc.Blend) {
+		b.desc.Blend = b.desc.Blend[:n]
+		return b
+	}
+	if n > 0 {
+		b.Blend(n-1, Opaque)
+	}
+	return b
+}
+
+// StaticViewport bakes a viewport and scissor covering extent into the pipeline.
+func (b *PipelineBuilder) StaticViewport(extent vk.Extent2D) *PipelineBuilder {
+	b.desc.DynamicViewport = false
+	b.desc.Viewport = extent
+	return b
+}
+
+func (b *PipelineBuilder) DynamicViewport() *PipelineBuilder {
+	b.desc.DynamicViewport = true
+	b.desc.Viewport = vk.Extent2D{}
+	return b
+}
+
+func (b *PipelineBuilder) PushConstants(stages vk.ShaderStageFlagBits, offset, size uint32) *PipelineBuilder {
+	b.desc.PushConstants = append(b.desc.PushConstants, PushConstantRange{
+		Stages: stages,
+		Offset: offset,
+		Size:   size,
+	})
+	return b
+}
+
+// Specialize sets the 32 bit specialization constant id of a shader stage.
+func (b *PipelineBuilder) Specialize(stage vk.ShaderStageFlagBits, id, value uint32) *PipelineBuilder {
+	for i := range b.desc.Specialization {
+		c := &b.desc.Specialization[i]
+		if c.Stage == stage && c.ID == id {
+			c.Value = value
+			return b
+		}
+	}
+	b.desc.Specialization = append(b.desc.Specialization, SpecConstant{
+		Stage: stage,
+		ID:    id,
+		Value: value,
+	})
+	return b
+}
+
+// SetLayouts sets the descriptor set layouts of the pipeline layout.
+func (b *PipelineBuilder) SetLayouts(layouts ...vk.DescriptorSetLayout) *PipelineBuilder {
+	b.setLayouts = layouts
+	return b
+}
+
+// Cache sets the pipeline cache used by Build.
+func (b *PipelineBuilder) Cache(cache vk.PipelineCache) *PipelineBuilder {
+	b.cache = cache
+	return b
+}
+
+// Pipeline is a graphics pipeline with its own pipeline layout.
+type Pipeline struct {
+	device vk.Device
+
+	Desc     PipelineDesc
+	Layout   vk.PipelineLayout
+	Pipeline vk.Pipeline
+}
+
+// Build creates the pipeline for subpass 0 of renderPass, loading the
+// shaders with load.
+func (b *PipelineBuilder) Build(device vk.Device, renderPass vk.RenderPass,
+	load func(name string) ([]byte, error)) (*Pipeline, error) {
+
+	desc := b.Desc()
+	if err := desc.Validate(); err != nil {
+		return nil, err
+	}
+	p := &Pipeline{
+		device: device,
+		Desc:   desc,
+	}
+
+	// Phase 1: vk.CreatePipelineLayout
+
+	pushConstants := make([]vk.PushConstantRange, len(desc.PushConstants))
+	for i, r := range desc.PushConstants {
+		pushConstants[i] = vk.PushConstantRange{
+			StageFlags: vk.ShaderStageFlags(r.Stages),
+			Offset:     r.Offset,
+			Size:       r.Size,
+		}
+	}
+	pipelineLayoutCreateInfo := vk.PipelineLayoutCreateInfo{
+		SType:                  vk.StructureTypePipelineLayoutCreateInfo,
+		SetLayoutCount:         uint32(len(b.setLayouts)),
+		PSetLayouts:            b.setLayouts,
+		PushConstantRangeCount: uint32(len(pushConstants)),
+		PPushConstantRanges:    pushConstants,
+	}
+	err := vk.Error(vk.CreatePipelineLayout(device, &pipelineLayoutCreateInfo, nil, &p.Layout))
+	if err != nil {
+		err = fmt.Errorf("vk.CreatePipelineLayout failed with %s", err)
+		return nil, err
+	}
+
+	// Phase 2: load shaders and specify shader stages
+
+	shaderStages := make([]vk.PipelineShaderStageCreateInfo, 0, len(desc.Shaders))
+	for _, stage := range desc.Shaders {
+		module, err := loadShaderModule(device, stage.Name, load)
+		if err != nil {
+			p.Destroy()
+			return nil, err
+		}
+		defer vk.DestroyShaderModule(device, module, nil)
+		shaderStages = append(shaderStages, vk.PipelineShaderStageCreateInfo{
+			SType:               vk.StructureTypePipelineShaderStageCreateInfo,
+			Stage:               stage.Stage,
+			Module:              module,
+			PName:               stage.Entry + "\x00",
+			PSpecializationInfo: specializationInfo(desc.Specialization, stage.Stage),
+		})
+	}
+
+	// Phase 3: specify vertex input and input assembly state
+
+	vertexInputBindings := make([]vk.VertexInputBindingDescription, len(desc.VertexBindings))
+	for i, binding := range desc.VertexBindings {
+		vertexInputBindings[i] = vk.VertexInputBindingDescription{
+			Binding:   binding.Binding,
+			Stride:    binding.Stride,
+			InputRate: binding.InputRate,
+		}
+	}
+	vertexInputAttributes := make([]vk.VertexInputAttributeDescription, len(desc.VertexAttributes))
+	for i, attribute := range desc.VertexAttributes {
+		vertexInputAttributes[i] = vk.VertexInputAttributeDescription{
+			Location: attribute.Location,
+			Binding:  attribute.Binding,
+			Format:   attribute.Format,
+			Offset:   attribute.Offset,
+		}
+	}
+	vertexInputState := vk.PipelineVertexInputStateCreateInfo{
+		SType:                           vk.StructureTypePipelineVertexInputStateCreateInfo,
+		VertexBindingDescriptionCount:   uint32(len(vertexInputBindings)),
+		PVertexBindingDescriptions:      vertexInputBindings,
+		VertexAttributeDescriptionCount: uint32(len(vertexInputAttributes)),
+		PVertexAttributeDescriptions:    vertexInputAttributes,
+	}
+	inputAssemblyState := vk.PipelineInputAssemblyStateCreateInfo{
+		SType:                  vk.StructureTypePipelineInputAssemblyStateCreateInfo,
+		Topology:               desc.Topology,
+		PrimitiveRestartEnable: vkBool(desc.PrimitiveRestart),
+	}
+
+	// Phase 4: specify viewport state
+	//			dynamic viewports only need the counts
+
+	viewportState := vk.PipelineViewportStateCreateInfo{
+		SType:         vk.StructureTypePipelineViewportStateCreateInfo,
+		ViewportCount: 1,
+		ScissorCount:  1,
+	}
+	dynamicState := vk.PipelineDynamicStateCreateInfo{
+		SType: vk.StructureTypePipelineDynamicStateCreateInfo,
+	}
+	if desc.DynamicViewport {
+		dynamicStates := []vk.DynamicState{vk.DynamicStateViewport, vk.DynamicStateScissor}
+		dynamicState.DynamicStateCount = uint32(len(dynamicStates))
+		dynamicState.PDynamicStates = dynamicStates
+	} else {
+		viewportState.PViewports = []vk.Viewport{Viewport(desc.Viewport)}
+		viewportState.PScissors = []vk.Rect2D{{Extent: desc.Viewport}}
+	}
+
+	// Phase 5: specify rasterizer, multisample, depth stencil
+	//			and color blend state
+
+	rasterState := vk.PipelineRasterizationStateCreateInfo{
+		SType:                   vk.StructureTypePipelineRasterizationStateCreateInfo,
+		DepthClampEnable:        vkBool(desc.Raster.DepthClamp),
+		RasterizerDiscardEnable: vk.False,
+		PolygonMode:             desc.Raster.PolygonMode,
+		CullMode:                vk.CullModeFlags(desc.Raster.CullMode),
+		FrontFace:               desc.Raster.FrontFace,
+		DepthBiasEnable:         vkBool(desc.Raster.DepthBias),
+		DepthBiasConstantFactor: desc.Raster.DepthBiasConstant,
+		DepthBiasSlopeFactor:    desc.Raster.DepthBiasSlope,
+		LineWidth:               desc.Raster.LineWidth,
+	}
+	multisampleState := MultisampleState(desc.Samples)
+	depthStencilState := vk.PipelineDepthStencilStateCreateInfo{
+		SType:             vk.StructureTypePipelineDepthStencilStateCreateInfo,
+		DepthTestEnable:   vkBool(desc.Depth.Test),
+		DepthWriteEnable:  vkBool(desc.Depth.Write),
+		DepthCompareOp:    desc.Depth.CompareOp,
+		StencilTestEnable: vkBool(desc.Depth.Stencil),
+		Front:             desc.Depth.Front.state(),
+		Back:              desc.Depth.Back.state(),
+		MaxDepthBounds:    1,
+	}
+	attachmentStates := make([]vk.PipelineColorBlendAttachmentState, len(desc.Blend))
+	for i, blend := range desc.Blend {
+		attachmentStates[i] = vk.PipelineColorBlendAttachmentState{
+			BlendEnable:         vkBool(blend.Enable),
+			SrcColorBlendFactor: blend.SrcColor,
+			DstColorBlendFactor: blend.DstColor,
+			ColorBlendOp:        blend.ColorOp,
+			SrcAlphaBlendFactor: blend.SrcAlpha,
+			DstAlphaBlendFactor: blend.DstAlpha,
+			AlphaBlendOp:        blend.AlphaOp,
+			ColorWriteMask:      vk.ColorComponentFlags(blend.WriteMask),
+		}
+	}
+	colorBlendState := vk.PipelineColorBlendStateCreateInfo{
+		SType:           vk.StructureTypePipelineColorBlendStateCreateInfo,
+		LogicOpEnable:   vk.False,
+		LogicOp:         vk.LogicOpCopy,
+		AttachmentCount: uint32(len(attachmentStates)),
+		PAttachments:    attachmentStates,
+	}
+
+	// Phase 6: vk.CreateGraphicsPipelines
+
+	pipelineCreateInfos := []vk.GraphicsPipelineCreateInfo{{
+		SType:               vk.StructureTypeGraphicsPipelineCreateInfo,
+		StageCount:          uint32(len(shaderStages)),
+		PStages:             shaderStages,
+		PVertexInputState:   &vertexInputState,
+		PInputAssemblyState: &inputAssemblyState,
+		PViewportState:      &viewportState,
+		PRasterizationState: &rasterState,
+		PMultisampleState:   &multisampleState,
+		PDepthStencilState:  &depthStencilState,
+		PColorBlendState:    &colorBlendState,
+		PDynamicState:       &dynamicState,
+		Layout:              p.Layout,
+		RenderPass:          renderPass,
+	}}
+	pipelines := make([]vk.Pipeline, 1)
+	err = vk.Error(vk.CreateGraphicsPipelines(device, b.cache, 1, pipelineCreateInfos, nil, pipelines))
+	if err != nil {
+		p.Destroy()
+		err = fmt.Errorf("vk.CreateGraphicsPipelines failed with %s", err)
+		return nil, err
+	}
+	p.Pipeline = pipelines[0]
+	return p, nil
+}
+
+func (p *Pipeline) Destroy() {
+	if p == nil {
+		return
+	}
+	vk.DestroyPipeline(p.device, p.Pipeline, nil)
+	vk.DestroyPipelineLayout(p.device, p.Layout, nil)
+	p.Pipeline = vk.NullPipeline
+	p.Layout = vk.NullPipelineLayout
+}
+
+func loadShaderModule(device vk.Device, name string, load func(string) ([]byte, error)) (vk.ShaderModule, error) {
+	var module vk.ShaderModule
+	data, err := load(name)
+	if err != nil {
+		err := fmt.Errorf("asset %s not found: %s", name, err)
+		return module, err
+	}
+	shaderModuleCreateInfo := vk.ShaderModuleCreateInfo{
+		SType:    vk.StructureTypeShaderModuleCreateInfo,
+		CodeSize: uint(len(data)),
+		PCode:    util.RepackUint32(data),
+	}
+	err = vk.Error(vk.CreateShaderModule(device, &shaderModuleCreateInfo, nil, &module))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateShaderModule failed with %s", err)
+		return module, err
+	}
+	return module, nil
+}
+
+// specializationInfo packs the constants of stage, nil if it has none.
+func specializationInfo(constants []SpecConstant, stage vk.ShaderStageFlagBits) []vk.SpecializationInfo {
+	var entries []vk.SpecializationMapEntry
+	var data []uint32
+	for _, c := range constants {
+		if c.Stage != stage {
+			continue
+		}
+		entries = append(entries, vk.SpecializationMapEntry{
+			ConstantID: c.ID,
+			Offset:     uint32(4 * len(data)),
+			Size:       4,
+		})
+		data = append(data, c.Value)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return []vk.SpecializationInfo{{
+		MapEntryCount: uint32(len(entries)),
+		PMapEntries:   entries,
+		DataSize:      uint(4 * len(data)),
+		PData:         unsafe.Pointer(&data[0]),
+	}}
+}
+
+func (s StencilState) state() vk.StencilOpState {
+	return vk.StencilOpState{
+		FailOp:      s.FailOp,
+		PassOp:      s.PassOp,
+		DepthFailOp: s.DepthFailOp,
+		CompareOp:   s.CompareOp,
+		CompareMask: s.CompareMask,
+		WriteMask:   s.WriteMask,
+		Reference:   s.Reference,
+	}
+}
+
+func vkBool(b bool) vk.Bool32 {
+	if b {
+		return vk.True
+	}
+	return vk.False
+}
+
+// Viewport covers extent with the full depth range.
+func Viewport(extent vk.Extent2D) vk.Viewport {
+	return vk.Viewport{
+		Width:    float32(extent.Width),
+		Height:   float32(extent.Height),
+		MinDepth: 0,
+		MaxDepth: 1,
+	}
+}
+
+// FormatSize returns the size in bytes of a vertex attribute format, zero
+// for formats it doesn't know.
+func FormatSize(format vk.Format) uint32 {
+	switch format {
+	case vk.FormatR8g8b8a8Unorm, vk.FormatR8g8b8a8Snorm, vk.FormatR8g8b8a8Uint,
+		vk.FormatR8g8b8a8Srgb, vk.FormatB8g8r8a8Unorm, vk.FormatB8g8r8a8Srgb,
+		vk.FormatR16g16Sfloat, vk.FormatR16g16Unorm, vk.FormatR16g16Snorm,
+		vk.FormatR32Sfloat, vk.FormatR32Uint, vk.FormatR32Sint:
+		return 4
+	case vk.FormatR16g16b16a16Sfloat, vk.FormatR16g16b16a16Unorm, vk.FormatR16g16b16a16Snorm,
+		vk.FormatR16g16b16a16Uint,
+		vk.FormatR32g32Sfloat, vk.FormatR32g32Uint, vk.FormatR32g32Sint:
+		return 8
+	case vk.FormatR32g32b32Sfloat, vk.FormatR32g32b32Uint, vk.FormatR32g32b32Sint:
+		return 12
+	case vk.FormatR32g32b32a32Sfloat, vk.FormatR32g32b32a32Uint, vk.FormatR32g32b32a32Sint:
+		return 16
+	}
+	return 0
+}
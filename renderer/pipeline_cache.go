@@ -0,0 +1,242 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// PipelineCacheHeader is the header Vulkan puts in front of pipeline cache
+// data, VkPipelineCacheHeaderVersionOne.
+type PipelineCacheHeader struct {
+	HeaderSize    uint32
+	HeaderVersion uint32
+	VendorID      uint32
+	DeviceID      uint32
+	UUID          [vk.UuidSize]byte
+}
+
+const (
+	pipelineCacheHeaderSize     = 16 + vk.UuidSize
+	pipelineCacheHeaderVersion1 = 1
+)
+
+var errPipelineCacheMismatch = errors.New("pipeline cache was created by another device or driver")
+
+// ParsePipelineCacheHeader reads the header of pipeline cache data.
+func ParsePipelineCacheHeader(data []byte) (PipelineCacheHeader, error) {
+	var h PipelineCacheHeader
+	if len(data) < pipelineCacheHeaderSize {
+		return h, fmt.Errorf("pipeline cache of %d bytes is shorter than its header", len(data))
+	}
+	h.HeaderSize = binary.LittleEndian.Uint32(data[0:])
+	h.HeaderVersion = binary.LittleEndian.Uint32(data[4:])
+	h.VendorID = binary.LittleEndian.Uint32(data[8:])
+	h.DeviceID = binary.LittleEndian.Uint32(data[12:])
+	copy(h.UUID[:], data[16:])
+	if h.HeaderVersion != pipelineCacheHeaderVersion1 {
+		return h, fmt.Errorf("unknown pipeline cache header version %d", h.HeaderVersion)
+	}
+	if h.HeaderSize < pipelineCacheHeaderSize || int(h.HeaderSize) > len(data) {
+		return h, fmt.Errorf("invalid pipeline cache header size %d", h.HeaderSize)
+	}
+	return h, nil
+}
+
+// pipelineCacheFile is what we store on disk: the driver version isn't
+// part of the Vulkan header, a checksum catches truncated or corrupt files.
+//
+//	magic "VKPC", format version, driver version, data size, crc32, data
+type pipelineCacheFile struct {
+	DriverVersion uint32
+	Data          []byte
+}
+
+var pipelineCacheMagic = []byte("VKPC")
+
+const (
+	pipelineCacheFileVersion    = 1
+	pipelineCacheFileHeaderSize = 20
+)
+
+func encodePipelineCacheFile(f pipelineCacheFile) []byte {
+	buf := make([]byte, pipelineCacheFileHeaderSize, pipelineCacheFileHeaderSize+len(f.Data))
+	copy(buf, pipelineCacheMagic)
+	binary.LittleEndian.PutUint32(buf[4:], pipelineCacheFileVersion)
+	binary.LittleEndian.PutUint32(buf[8:], f.DriverVersion)
+	binary.LittleEndian.PutUint32(buf[12:], uint32(len(f.Data)))
+	binary.LittleEndian.PutUint32(buf[16:], crc32.ChecksumIEEE(f.Data))
+	return append(buf, f.Data...)
+}
+
+func decodePipelineCacheFile(buf []byte) (pipelineCacheFile, error) {
+	var f pipelineCacheFile
+	if len(buf) < pipelineCacheFileHeaderSize || !bytes.Equal(buf[:4], pipelineCacheMagic) {
+		return f, errors.New("not a pipeline cache file")
+	}
+	if version := binary.LittleEndian.Uint32(buf[4:]); version != pipelineCacheFileVersion {
+		return f, fmt.Errorf("unknown pipeline cache file version %d", version)
+	}
+	f.DriverVersion = binary.LittleEndian.Uint32(buf[8:])
+	size := binary.LittleEndian.Uint32(buf[12:])
+	if uint64(size) != uint64(len(buf)-pipelineCacheFileHeaderSize) {
+		return f, fmt.Errorf("pipeline cache file holds %d bytes instead of %d",
+			len(buf)-pipelineCacheFileHeaderSize, size)
+	}
+	f.Data = buf[pipelineCacheFileHeaderSize:]
+	if crc32.ChecksumIEEE(f.Data) != binary.LittleEndian.Uint32(buf[16:]) {
+		return f, errors.New("pipeline cache file checksum mismatch")
+	}
+	return f, nil
+}
+
+// ValidatePipelineCache checks that a stored cache file was written for
+// the device described by props and returns the Vulkan cache data in it.
+func ValidatePipelineCache(buf []byte, props vk.PhysicalDeviceProperties) ([]byte, error) {
+	f, err := decodePipelineCacheFile(buf)
+	if err != nil {
+		return nil, err
+	}
+	h, err := ParsePipelineCacheHeader(f.Data)
+	if err != nil {
+		return nil, err
+	}
+	if f.DriverVersion != props.DriverVersion || h.VendorID != props.VendorID ||
+		h.DeviceID != props.DeviceID || h.UUID != props.PipelineCacheUUID {
+		return nil, errPipelineCacheMismatch
+	}
+	return f.Data, nil
+}
+
+// PipelineCacheFileName names the cache file of a device, so devices and
+// drivers sharing a directory don't overwrite each other's caches.
+func PipelineCacheFileName(props vk.PhysicalDeviceProperties) string {
+	return fmt.Sprintf("pipelines-%04x-%04x-%08x-%x.bin", props.VendorID, props.DeviceID,
+		props.DriverVersion, props.PipelineCacheUUID[:])
+}
+
+// PipelineCache is a vk.PipelineCache that is loaded from and saved to a
+// file, so pipelines compiled by an earlier run are reused.
+type PipelineCache struct {
+	device vk.Device
+	props  vk.PhysicalDeviceProperties
+	path   string
+	cache  vk.PipelineCache
+}
+
+// LoadPipelineCache creates the pipeline cache of the device from the file
+// in dir. A missing, mismatched or corrupt file gives an empty cache.
+func (v *VulkanDeviceInfo) LoadPipelineCache(dir string) (*PipelineCache, error) {
+	var props vk.PhysicalDeviceProperties
+	vk.GetPhysicalDeviceProperties(v.gpuDevices[0], &props)
+	props.Deref()
+	c := &PipelineCache{
+		device: v.Device,
+		props:  props,
+		path:   filepath.Join(dir, PipelineCacheFileName(props)),
+	}
+
+	// Phase 1: read and validate the file
+
+	var data []byte
+	buf, err := ioutil.ReadFile(c.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		log.Println("[WARN] failed to read pipeline cache:", err)
+	default:
+		data, err = ValidatePipelineCache(buf, props)
+		if err != nil {
+			log.Println("[WARN] ignoring pipeline cache", c.path+":", err)
+		}
+	}
+
+	// Phase 2: vk.CreatePipelineCache
+
+	pipelineCacheInfo := vk.PipelineCacheCreateInfo{
+		SType: vk.StructureTypePipelineCacheCreateInfo,
+	}
+	if len(data) > 0 {
+		pipelineCacheInfo.InitialDataSize = uint(len(data))
+		pipelineCacheInfo.PInitialData = unsafe.Pointer(&data[0])
+	}
+	err = vk.Error(vk.CreatePipelineCache(v.Device, &pipelineCacheInfo, nil, &c.cache))
+	if err != nil {
+		err = fmt.Errorf("vk.CreatePipelineCache failed with %s", err)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *PipelineCache) Handle() vk.PipelineCache {
+	if c == nil {
+		return vk.NullPipelineCache
+	}
+	return c.cache
+}
+
+// Save writes the cache data to the file. It writes a temporary file and
+// renames it, a crash never leaves a partial cache behind.
+func (c *PipelineCache) Save() error {
+	var size uint
+	err := vk.Error(vk.GetPipelineCacheData(c.device, c.cache, &size, nil))
+	if err != nil {
+		return fmt.Errorf("vk.GetPipelineCacheData failed with %s", err)
+	}
+	if size == 0 {
+		return nil
+	}
+	data := make([]byte, size)
+	err = vk.Error(vk.GetPipelineCacheData(c.device, c.cache, &size, unsafe.Pointer(&data[0])))
+	if err != nil {
+		return fmt.Errorf("vk.GetPipelineCacheData failed with %s", err)
+	}
+	data = data[:size]
+	buf := encodePipelineCacheFile(pipelineCacheFile{
+		DriverVersion: c.props.DriverVersion,
+		Data:          data,
+	})
+	return writeFileAtomic(c.path, buf)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (c *PipelineCache) Destroy() {
+	if c == nil {
+		return
+	}
+	vk.DestroyPipelineCache(c.device, c.cache, nil)
+	c.cache = vk.NullPipelineCache
+}
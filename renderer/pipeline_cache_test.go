@@ -0,0 +1,144 @@
+package renderer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+var testCacheProps = vk.PhysicalDeviceProperties{
+	DriverVersion:     0x00400123,
+	VendorID:          0x10de,
+	DeviceID:          0x2204,
+	PipelineCacheUUID: [vk.UuidSize]byte{0: 0xaa, 15: 0x55},
+}
+
+// testCacheData is Vulkan pipeline cache data of testCacheProps: a version
+// one header followed by 4 bytes of driver data.
+var testCacheData = []byte{
+	0x20, 0, 0, 0, // header size
+	0x01, 0, 0, 0, // header version
+	0xde, 0x10, 0, 0, // vendor ID
+	0x04, 0x22, 0, 0, // device ID
+	0xaa, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x55, // UUID
+	1, 2, 3, 4,
+}
+
+func TestParsePipelineCacheHeader(t *testing.T) {
+	h, err := ParsePipelineCacheHeader(testCacheData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PipelineCacheHeader{
+		HeaderSize:    32,
+		HeaderVersion: 1,
+		VendorID:      testCacheProps.VendorID,
+		DeviceID:      testCacheProps.DeviceID,
+		UUID:          testCacheProps.PipelineCacheUUID,
+	}
+	if h != want {
+		t.Errorf("ParsePipelineCacheHeader = %+v, want %+v", h, want)
+	}
+
+	modified := func(offset int, b byte) []byte {
+		data := append([]byte(nil), testCacheData...)
+		data[offset] = b
+		return data
+	}
+	bad := map[string][]byte{
+		"truncated":        testCacheData[:31],
+		"unknown version":  modified(4, 2),
+		"header too small": modified(0, 16),
+		"header past data": modified(0, 64),
+	}
+	for name, data := range bad {
+		if _, err := ParsePipelineCacheHeader(data); err == nil {
+			t.Errorf("%s: ParsePipelineCacheHeader succeeded", name)
+		}
+	}
+}
+
+func TestValidatePipelineCache(t *testing.T) {
+	file := encodePipelineCacheFile(pipelineCacheFile{
+		DriverVersion: testCacheProps.DriverVersion,
+		Data:          testCacheData,
+	})
+	if !bytes.Equal(file[:4], []byte("VKPC")) || len(file) != 20+len(testCacheData) {
+		t.Fatalf("unexpected file layout % x", file[:20])
+	}
+	data, err := ValidatePipelineCache(file, testCacheProps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, testCacheData) {
+		t.Errorf("ValidatePipelineCache = % x, want % x", data, testCacheData)
+	}
+
+	withProps := func(f func(*vk.PhysicalDeviceProperties)) vk.PhysicalDeviceProperties {
+		props := testCacheProps
+		f(&props)
+		return props
+	}
+	withByte := func(offset int, b byte) []byte {
+		buf := append([]byte(nil), file...)
+		buf[offset] ^= b
+		return buf
+	}
+	tests := []struct {
+		name  string
+		file  []byte
+		props vk.PhysicalDeviceProperties
+	}{
+		{"wrong vendor", file, withProps(func(p *vk.PhysicalDeviceProperties) { p.VendorID = 0x1002 })},
+		{"wrong device", file, withProps(func(p *vk.PhysicalDeviceProperties) { p.DeviceID++ })},
+		{"wrong UUID", file, withProps(func(p *vk.PhysicalDeviceProperties) { p.PipelineCacheUUID[3] = 1 })},
+		{"wrong driver version", file, withProps(func(p *vk.PhysicalDeviceProperties) { p.DriverVersion++ })},
+		{"truncated", file[:len(file)-1], testCacheProps},
+		{"truncated file header", file[:12], testCacheProps},
+		{"bad CRC", withByte(len(file)-1, 0xff), testCacheProps},
+		{"bad magic", withByte(0, 0x20), testCacheProps},
+		{"unknown file version", withByte(4, 0x02), testCacheProps},
+	}
+	for _, test := range tests {
+		if _, err := ValidatePipelineCache(test.file, test.props); err == nil {
+			t.Errorf("%s: ValidatePipelineCache succeeded", test.name)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache", PipelineCacheFileName(testCacheProps))
+	for _, driverVersion := range []uint32{1, testCacheProps.DriverVersion} {
+		file := encodePipelineCacheFile(pipelineCacheFile{
+			DriverVersion: driverVersion,
+			Data:          testCacheData,
+		})
+		if err := writeFileAtomic(path, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ValidatePipelineCache(buf, testCacheProps)
+	if err != nil {
+		t.Fatalf("the second write wasn't kept: %s", err)
+	}
+	if !bytes.Equal(data, testCacheData) {
+		t.Errorf("read back % x, want % x", data, testCacheData)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files in the cache directory, temporary files are left behind", len(entries))
+	}
+}
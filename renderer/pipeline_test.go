@@ -0,0 +1,98 @@
+package renderer
+
+import (
+	"reflect"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func testPipelineBuilder() *PipelineBuilder {
+	return NewPipelineBuilder().
+		Shader(vk.ShaderStageVertexBit, "shaders/cube.vert.spv").
+		Shader(vk.ShaderStageFragmentBit, "shaders/cube.frag.spv").
+		Vertex(0, vk.FormatR32g32b32Sfloat, vk.FormatR32g32Sfloat)
+}
+
+func TestPipelineDescKey(t *testing.T) {
+	a, b := testPipelineBuilder().Desc(), testPipelineBuilder().Desc()
+	if a.Key() != b.Key() || a.Hash() != b.Hash() || len(a.Diff(&b)) != 0 {
+		t.Fatalf("equal descriptions differ: %q and %q", a.Key(), b.Key())
+	}
+
+	// Every field changed on its own changes the key, and only that
+	// field differs.
+	tests := []struct {
+		field  string
+		change func(*PipelineDesc)
+	}{
+		{"Shaders", func(d *PipelineDesc) { d.Shaders[1].Name = "shaders/other.frag.spv" }},
+		{"VertexBindings", func(d *PipelineDesc) { d.VertexBindings[0].Stride += 4 }},
+		{"VertexAttributes", func(d *PipelineDesc) { d.VertexAttributes[1].Format = vk.FormatR16g16Sfloat }},
+		{"Topology", func(d *PipelineDesc) { d.Topology = vk.PrimitiveTopologyTriangleStrip }},
+		{"PrimitiveRestart", func(d *PipelineDesc) { d.PrimitiveRestart = true }},
+		{"Raster", func(d *PipelineDesc) { d.Raster.CullMode = vk.CullModeNone }},
+		{"Samples", func(d *PipelineDesc) { d.Samples = vk.SampleCount4Bit }},
+		{"Depth", func(d *PipelineDesc) { d.Depth.Test = true }},
+		{"Blend", func(d *PipelineDesc) { d.Blend[0] = AlphaBlend }},
+		{"DynamicViewport", func(d *PipelineDesc) { d.DynamicViewport = false }},
+		{"Viewport", func(d *PipelineDesc) { d.Viewport.Width = 640 }},
+		{"PushConstants", func(d *PipelineDesc) {
+			d.PushConstants = append(d.PushConstants, PushConstantRange{vk.ShaderStageVertexBit, 0, 64})
+		}},
+		{"Specialization", func(d *PipelineDesc) {
+			d.Specialization = append(d.Specialization, SpecConstant{vk.ShaderStageFragmentBit, 0, 1})
+		}},
+	}
+	if n := reflect.TypeOf(PipelineDesc{}).NumField(); len(tests) != n {
+		t.Errorf("%d fields changed, PipelineDesc has %d", len(tests), n)
+	}
+	base := testPipelineBuilder().Desc()
+	for _, test := range tests {
+		changed := testPipelineBuilder().Desc()
+		test.change(&changed)
+		if changed.Key() == base.Key() || changed.Hash() == base.Hash() {
+			t.Errorf("%s: key unchanged", test.field)
+		}
+		if diff := base.Diff(&changed); !reflect.DeepEqual(diff, []string{test.field}) {
+			t.Errorf("%s: Diff = %v", test.field, diff)
+		}
+	}
+}
+
+func TestPipelineBuilderDesc(t *testing.T) {
+	builder := testPipelineBuilder().Specialize(vk.ShaderStageFragmentBit, 0, 1)
+	d := builder.Desc()
+	// Desc is a copy, changing it doesn't change the builder.
+	d.Shaders[0].Name, d.Blend[0], d.Specialization[0].Value = "", AlphaBlend, 2
+	if again := builder.Desc(); len(again.Diff(&d)) != 3 {
+		t.Errorf("Desc shares slices with the builder: %v", again.Diff(&d))
+	}
+
+	// Builder calls set the fields of the same state.
+	a := testPipelineBuilder().Specialize(vk.ShaderStageFragmentBit, 0, 1).Specialize(vk.ShaderStageFragmentBit, 0, 2).Desc()
+	b := testPipelineBuilder().Specialize(vk.ShaderStageFragmentBit, 0, 2).Desc()
+	if a.Key() != b.Key() {
+		t.Errorf("respecialized constant: %v, want %v", a.Specialization, b.Specialization)
+	}
+	if got := testPipelineBuilder().DepthTest(true).Desc(); !got.Depth.Test || !got.Depth.Write {
+		t.Errorf("DepthTest(true) = %+v", got.Depth)
+	}
+}
+
+func TestPipelineDescValidate(t *testing.T) {
+	if d := testPipelineBuilder().Desc(); d.Validate() != nil {
+		t.Errorf("valid description: %s", d.Validate())
+	}
+	bad := map[string]*PipelineBuilder{
+		"no vertex shader":        NewPipelineBuilder().Shader(vk.ShaderStageFragmentBit, "frag"),
+		"restart of a list":       testPipelineBuilder().Topology(vk.PrimitiveTopologyTriangleList, true),
+		"empty static viewport":   testPipelineBuilder().StaticViewport(vk.Extent2D{}),
+		"undeclared vertex input": testPipelineBuilder().VertexAttribute(VertexAttribute{Location: 5, Binding: 3}),
+	}
+	for name, builder := range bad {
+		if d := builder.Desc(); d.Validate() == nil {
+			t.Errorf("%s: Validate succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// Attributes is a set of the glTF vertex attributes of a primitive.
+type Attributes uint32
+
+const (
+	AttributePosition Attributes = 1 << iota
+	AttributeNormal
+	AttributeTangent
+	AttributeTexCoord0
+	AttributeTexCoord1
+	AttributeColor0
+	AttributeJoints0
+	AttributeWeights0
+)
+
+// attributeNames are the glTF names of the attributes, in the order of
+// the Attributes bits.
+var attributeNames = []string{
+	"POSITION", "NORMAL", "TANGENT", "TEXCOORD_0", "TEXCOORD_1", "COLOR_0", "JOINTS_0", "WEIGHTS_0",
+}
+
+// attributeFormats are the formats the attributes are uploaded in, in the
+// order of the Attributes bits. The shader location of an attribute is
+// its bit index, so locations don't move when attributes are missing.
+var attributeFormats = []vk.Format{
+	vk.FormatR32g32b32Sfloat,
+	vk.FormatR32g32b32Sfloat,
+	vk.FormatR32g32b32a32Sfloat,
+	vk.FormatR32g32Sfloat,
+	vk.FormatR32g32Sfloat,
+	vk.FormatR32g32b32a32Sfloat,
+	vk.FormatR16g16b16a16Uint,
+	vk.FormatR32g32b32a32Sfloat,
+}
+
+// ParseAttributes returns the set of the attributes of a glTF primitive
+// from their names. Attributes the variants don't use, like TEXCOORD_2
+// or custom ones starting with an underscore, are ignored.
+func ParseAttributes(names ...string) Attributes {
+	var attributes Attributes
+	for _, name := range names {
+		for i, n := range attributeNames {
+			if n == name {
+				attributes |= 1 << uint(i)
+			}
+		}
+	}
+	return attributes
+}
+
+// AlphaMode is the alphaMode of a glTF material.
+type AlphaMode uint32
+
+const (
+	AlphaOpaque AlphaMode = iota
+	AlphaMask
+	AlphaBlended
+)
+
+// ParseAlphaMode returns the alpha mode of a glTF material, an empty one
+// is opaque.
+func ParseAlphaMode(mode string) (AlphaMode, error) {
+	switch mode {
+	case "", "OPAQUE":
+		return AlphaOpaque, nil
+	case "MASK":
+		return AlphaMask, nil
+	case "BLEND":
+		return AlphaBlended, nil
+	}
+	return AlphaOpaque, fmt.Errorf("unknown alpha mode %q", mode)
+}
+
+// Specialization constant ids of the variant shaders.
+const (
+	SpecAttributes uint32 = iota
+	SpecTextures
+	SpecAlphaMode
+)
+
+// VariantKey is the material and primitive state that selects a pipeline.
+type VariantKey struct {
+	Attributes  Attributes
+	Textures    TextureSlots
+	AlphaMode   AlphaMode
+	DoubleSided bool
+	Topology    vk.PrimitiveTopology
+}
+
+// VariantConfig is the state shared by all variants.
+type VariantConfig struct {
+	VertexShader   string
+	FragmentShader string
+	Samples        vk.SampleCountFlagBits
+	SetLayouts     []vk.DescriptorSetLayout
+	PushConstants  []PushConstantRange
+	// Load reads the SPIR-V code of a shader.
+	Load func(name string) ([]byte, error)
+}
+
+// pipelineFactory builds and destroys the pipelines of a variant cache.
+type pipelineFactory interface {
+	build(b *PipelineBuilder, renderPass vk.RenderPass) (*Pipeline, error)
+	destroy(p *Pipeline)
+}
+
+type vulkanPipelineFactory struct {
+	device vk.Device
+	load   func(name string) ([]byte, error)
+}
+
+func (f vulkanPipelineFactory) build(b *PipelineBuilder, renderPass vk.RenderPass) (*Pipeline, error) {
+	return b.Build(f.device, renderPass, f.load)
+}
+
+func (f vulkanPipelineFactory) destroy(p *Pipeline) {
+	p.Destroy()
+}
+
+// VariantCache creates a pipeline per VariantKey on first use. Keys that
+// end up with the same PipelineDesc share one pipeline.
+type VariantCache struct {
+	factory    pipelineFactory
+	renderPass vk.RenderPass
+	cache      *PipelineCache
+	config     VariantConfig
+
+	variants map[VariantKey]*Pipeline
+	// pipelines holds every distinct pipeline by its description key.
+	pipelines map[string]*Pipeline
+}
+
+// VariantCaches owns the variant caches of a device, their pipelines
+// are destroyed with it.
+type VariantCaches struct {
+	device vk.Device
+	caches []*VariantCache
+}
+
+func NewVariantCaches(device vk.Device) *VariantCaches {
+	return &VariantCaches{device: device}
+}
+
+// New returns a variant cache for the pipelines of a render pass.
+func (c *VariantCaches) New(renderPass vk.RenderPass, cache *PipelineCache, config VariantConfig) *VariantCache {
+	v := newVariantCache(vulkanPipelineFactory{c.device, config.Load}, renderPass, cache, config)
+	c.caches = append(c.caches, v)
+	return v
+}
+
+// Destroy destroys the pipelines of all caches, it has to be called
+// before the device is destroyed.
+func (c *VariantCaches) Destroy() {
+	if c == nil {
+		return
+	}
+	for _, v := range c.caches {
+		v.Destroy()
+	}
+	c.caches = nil
+}
+
+func newVariantCache(factory pipelineFactory, renderPass vk.RenderPass, cache *PipelineCache,
+	config VariantConfig) *VariantCache {
+
+	return &VariantCache{
+		factory:    factory,
+		renderPass: renderPass,
+		cache:      cache,
+		config:     config,
+		variants:   make(map[VariantKey]*Pipeline),
+		pipelines:  make(map[string]*Pipeline),
+	}
+}
+
+// Builder returns the builder of the pipeline for key: every present
+// attribute gets a binding of its own, blended materials don't write
+// depth and double sided ones aren't culled. Points and lines aren't
+// culled anyway, so their single and double sided keys share a pipeline.
+func (c *VariantCache) Builder(key VariantKey) *PipelineBuilder {
+	b := NewPipelineBuilder().
+		Shader(vk.ShaderStageVertexBit, c.config.VertexShader).
+		Shader(vk.ShaderStageFragmentBit, c.config.FragmentShader).
+		Samples(c.config.Samples).
+		SetLayouts(c.config.SetLayouts...).
+		Cache(c.cache.Handle())
+	if key.Topology != vk.PrimitiveTopologyTriangleList {
+		b.Topology(key.Topology, false)
+	}
+	for i, format := range attributeFormats {
+		if key.Attributes&(1<<uint(i)) == 0 {
+			continue
+		}
+		b.VertexBinding(VertexBinding{
+			Binding:   uint32(i),
+			Stride:    FormatSize(format),
+			InputRate: vk.VertexInputRateVertex,
+		})
+		b.VertexAttribute(VertexAttribute{
+			Location: uint32(i),
+			Binding:  uint32(i),
+			Format:   format,
+		})
+	}
+	if key.DoubleSided && isTriangles(key.Topology) {
+		b.Cull(vk.CullModeNone, vk.FrontFaceCounterClockwise)
+	}
+	if key.AlphaMode == AlphaBlended {
+		b.DepthTest(false).Blend(0, AlphaBlend)
+	} else {
+		b.DepthTest(true)
+	}
+	for _, r := range c.config.PushConstants {
+		b.PushConstants(r.Stages, r.Offset, r.Size)
+	}
+	for _, stage := range []vk.ShaderStageFlagBits{vk.ShaderStageVertexBit, vk.ShaderStageFragmentBit} {
+		b.Specialize(stage, SpecAttributes, uint32(key.Attributes)).
+			Specialize(stage, SpecTextures, uint32(key.Textures)).
+			Specialize(stage, SpecAlphaMode, uint32(key.AlphaMode))
+	}
+	return b
+}
+
+func isTriangles(topology vk.PrimitiveTopology) bool {
+	switch topology {
+	case vk.PrimitiveTopologyPointList, vk.PrimitiveTopologyLineList, vk.PrimitiveTopologyLineStrip,
+		vk.PrimitiveTopologyLineListWithAdjacency, vk.PrimitiveTopologyLineStripWithAdjacency:
+		return false
+	}
+	return true
+}
+
+// Get returns the pipeline for key, creating it if needed.
+func (c *VariantCache) Get(key VariantKey) (*Pipeline, error) {
+	if p, ok := c.variants[key]; ok {
+		return p, nil
+	}
+	if key.Attributes&AttributePosition == 0 {
+		return nil, fmt.Errorf("primitive without positions")
+	}
+	b := c.Builder(key)
+	desc := b.Desc()
+	descKey := desc.Key()
+	if p, ok := c.pipelines[descKey]; ok {
+		c.variants[key] = p
+		return p, nil
+	}
+	p, err := c.factory.build(b, c.renderPass)
+	if err != nil {
+		return nil, err
+	}
+	c.pipelines[descKey] = p
+	c.variants[key] = p
+	return p, nil
+}
+
+// Len returns the number of distinct pipelines.
+func (c *VariantCache) Len() int {
+	return len(c.pipelines)
+}
+
+// Reset destroys all pipelines, they are created again for renderPass on
+// demand. The GPU must not use them anymore.
+func (c *VariantCache) Reset(renderPass vk.RenderPass) {
+	c.Destroy()
+	c.renderPass = renderPass
+}
+
+// Destroy destroys all pipelines.
+func (c *VariantCache) Destroy() {
+	if c == nil {
+		return
+	}
+	for _, p := range c.pipelines {
+		c.factory.destroy(p)
+	}
+	c.variants = make(map[VariantKey]*Pipeline)
+	c.pipelines = make(map[string]*Pipeline)
+}
@@ -0,0 +1,192 @@
+package renderer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// fakePipelineFactory builds pipelines holding only their description and
+// counts the builds and destroys.
+type fakePipelineFactory struct {
+	built     int
+	destroyed map[*Pipeline]int
+	err       error
+}
+
+func (f *fakePipelineFactory) build(b *PipelineBuilder, renderPass vk.RenderPass) (*Pipeline, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.built++
+	return &Pipeline{Desc: b.Desc()}, nil
+}
+
+func (f *fakePipelineFactory) destroy(p *Pipeline) {
+	f.destroyed[p]++
+}
+
+func newTestVariantCache() (*VariantCache, *fakePipelineFactory) {
+	f := &fakePipelineFactory{destroyed: map[*Pipeline]int{}}
+	config := VariantConfig{
+		VertexShader:   "shaders/pbr.vert.spv",
+		FragmentShader: "shaders/pbr.frag.spv",
+		Samples:        vk.SampleCount1Bit,
+		PushConstants:  []PushConstantRange{{vk.ShaderStageVertexBit, 0, 64}},
+	}
+	return newVariantCache(f, vk.NullRenderPass, nil, config), f
+}
+
+func TestParseVariantKey(t *testing.T) {
+	attributes := ParseAttributes("POSITION", "TEXCOORD_0", "_CUSTOM", "TEXCOORD_2", "NORMAL")
+	if want := AttributePosition | AttributeNormal | AttributeTexCoord0; attributes != want {
+		t.Errorf("ParseAttributes = %b, want %b", attributes, want)
+	}
+	modes := map[string]AlphaMode{"": AlphaOpaque, "OPAQUE": AlphaOpaque, "MASK": AlphaMask, "BLEND": AlphaBlended}
+	for name, want := range modes {
+		if mode, err := ParseAlphaMode(name); err != nil || mode != want {
+			t.Errorf("ParseAlphaMode(%q) = %d, %v, want %d", name, mode, err, want)
+		}
+	}
+	if _, err := ParseAlphaMode("blend"); err == nil {
+		t.Errorf("ParseAlphaMode of a lowercase mode succeeded")
+	}
+}
+
+func TestVariantBuilder(t *testing.T) {
+	c, _ := newTestVariantCache()
+	key := VariantKey{
+		Attributes: AttributePosition | AttributeTexCoord0 | AttributeColor0,
+		Textures:   TextureBaseColor | TextureNormal,
+		AlphaMode:  AlphaMask,
+		Topology:   vk.PrimitiveTopologyTriangleList,
+	}
+	d := c.Builder(key).Desc()
+	if err := d.Validate(); err != nil {
+		t.Fatalf("invalid description: %s", err)
+	}
+	// Locations are the attribute bits, missing attributes leave gaps.
+	wantBindings := []VertexBinding{
+		{0, 12, vk.VertexInputRateVertex},
+		{3, 8, vk.VertexInputRateVertex},
+		{5, 16, vk.VertexInputRateVertex},
+	}
+	wantAttributes := []VertexAttribute{
+		{0, 0, vk.FormatR32g32b32Sfloat, 0},
+		{3, 3, vk.FormatR32g32Sfloat, 0},
+		{5, 5, vk.FormatR32g32b32a32Sfloat, 0},
+	}
+	if !reflect.DeepEqual(d.VertexBindings, wantBindings) {
+		t.Errorf("bindings %+v, want %+v", d.VertexBindings, wantBindings)
+	}
+	if !reflect.DeepEqual(d.VertexAttributes, wantAttributes) {
+		t.Errorf("attributes %+v, want %+v", d.VertexAttributes, wantAttributes)
+	}
+	wantSpec := []SpecConstant{
+		{vk.ShaderStageVertexBit, SpecAttributes, 0x29},
+		{vk.ShaderStageVertexBit, SpecTextures, 0x5},
+		{vk.ShaderStageVertexBit, SpecAlphaMode, uint32(AlphaMask)},
+		{vk.ShaderStageFragmentBit, SpecAttributes, 0x29},
+		{vk.ShaderStageFragmentBit, SpecTextures, 0x5},
+		{vk.ShaderStageFragmentBit, SpecAlphaMode, uint32(AlphaMask)},
+	}
+	if !reflect.DeepEqual(d.Specialization, wantSpec) {
+		t.Errorf("specialization %+v, want %+v", d.Specialization, wantSpec)
+	}
+	if !d.Depth.Test || !d.Depth.Write || d.Blend[0] == AlphaBlend || d.Raster.CullMode == vk.CullModeNone {
+		t.Errorf("masked single sided variant: depth %+v, blend %+v, cull %d", d.Depth, d.Blend[0], d.Raster.CullMode)
+	}
+	if want := c.config.PushConstants; !reflect.DeepEqual(d.PushConstants, want) {
+		t.Errorf("push constants %+v, want %+v", d.PushConstants, want)
+	}
+
+	// Blended materials test depth without writing it, double sided ones
+	// aren't culled.
+	key.AlphaMode, key.DoubleSided = AlphaBlended, true
+	key.Topology = vk.PrimitiveTopologyTriangleStrip
+	d = c.Builder(key).Desc()
+	if !d.Depth.Test || d.Depth.Write || d.Blend[0] != AlphaBlend {
+		t.Errorf("blended variant: depth %+v, blend %+v", d.Depth, d.Blend[0])
+	}
+	if d.Raster.CullMode != vk.CullModeNone || d.Topology != vk.PrimitiveTopologyTriangleStrip {
+		t.Errorf("double sided strip: cull %d, topology %d", d.Raster.CullMode, d.Topology)
+	}
+}
+
+func TestVariantCacheGet(t *testing.T) {
+	c, f := newTestVariantCache()
+	key := VariantKey{Attributes: AttributePosition | AttributeNormal, Topology: vk.PrimitiveTopologyTriangleList}
+	a, err := c.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.Get(key); again != a {
+		t.Errorf("Get of the same key returned another pipeline")
+	}
+	// Points aren't culled, single and double sided ones are the same.
+	points := VariantKey{Attributes: AttributePosition, Topology: vk.PrimitiveTopologyPointList}
+	p, err := c.Get(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points.DoubleSided = true
+	if same, err := c.Get(points); err != nil || same != p {
+		t.Errorf("Get of a key with an equal description = %p, %v, want %p", same, err, p)
+	}
+	other := key
+	other.Textures = TextureBaseColor
+	b, err := c.Get(other)
+	if err != nil || b == a {
+		t.Errorf("Get of another material = %p, %v, want a new pipeline", b, err)
+	}
+	if f.built != 3 || c.Len() != 3 {
+		t.Errorf("%d pipelines built, %d cached, want 3", f.built, c.Len())
+	}
+
+	if _, err := c.Get(VariantKey{Attributes: AttributeNormal}); err == nil {
+		t.Errorf("Get of a key without positions succeeded")
+	}
+	f.err = errors.New("build failed")
+	if _, err := c.Get(VariantKey{Attributes: AttributePosition | AttributeColor0}); err == nil || c.Len() != 3 {
+		t.Errorf("failed build: %v, %d cached", err, c.Len())
+	}
+	f.err = nil
+
+	// Every pipeline is destroyed once, Reset builds them again.
+	c.Reset(vk.NullRenderPass)
+	if len(f.destroyed) != 3 || f.destroyed[a] != 1 || f.destroyed[b] != 1 || f.destroyed[p] != 1 || c.Len() != 0 {
+		t.Errorf("Reset destroyed %v, %d left", f.destroyed, c.Len())
+	}
+	if again, err := c.Get(key); err != nil || again == a {
+		t.Errorf("Get after Reset = %p, %v, want a new pipeline", again, err)
+	}
+	c.Destroy()
+	c.Destroy()
+	if len(f.destroyed) != 4 {
+		t.Errorf("%d pipelines destroyed, want 4", len(f.destroyed))
+	}
+	for p, n := range f.destroyed {
+		if n != 1 {
+			t.Errorf("pipeline %p destroyed %d times", p, n)
+		}
+	}
+}
+
+func TestVariantCachesDestroy(t *testing.T) {
+	var nilCaches *VariantCaches
+	nilCaches.Destroy()
+
+	caches := NewVariantCaches(vk.NullDevice)
+	c, f := newTestVariantCache()
+	caches.caches = append(caches.caches, c)
+	p, err := c.Get(VariantKey{Attributes: AttributePosition})
+	if err != nil {
+		t.Fatal(err)
+	}
+	caches.Destroy()
+	if f.destroyed[p] != 1 || c.Len() != 0 {
+		t.Errorf("device teardown destroyed %v", f.destroyed)
+	}
+}
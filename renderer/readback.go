@@ -0,0 +1,279 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/pixels"
+)
+
+// PixelFormat returns the channel order of the 8 bit color formats that
+// can be read back.
+func PixelFormat(format vk.Format) (pixels.Format, bool) {
+	switch format {
+	case vk.FormatR8g8b8a8Unorm, vk.FormatR8g8b8a8Srgb:
+		return pixels.RGBA8, true
+	case vk.FormatB8g8r8a8Unorm, vk.FormatB8g8r8a8Srgb:
+		return pixels.BGRA8, true
+	}
+	return 0, false
+}
+
+// readbackPitch is the row pitch of a copy of width texels, aligned for
+// fast copies.
+func readbackPitch(width, texelSize uint32, alignment uint64) uint64 {
+	align := lcm(uint64(texelSize), alignment)
+	return (uint64(width)*uint64(texelSize) + align - 1) / align * align
+}
+
+// runOnce records a command buffer from cmdPool, submits it to the
+// graphics queue and waits for it to complete.
+func (v *VulkanDeviceInfo) runOnce(cmdPool vk.CommandPool, record func(cmd vk.CommandBuffer)) error {
+	cmd, err := allocateCommandBuffer(v.Device, cmdPool)
+	if err != nil {
+		return err
+	}
+	defer vk.FreeCommandBuffers(v.Device, cmdPool, 1, []vk.CommandBuffer{cmd})
+
+	err = vk.Error(vk.BeginCommandBuffer(cmd, &vk.CommandBufferBeginInfo{
+		SType: vk.StructureTypeCommandBufferBeginInfo,
+		Flags: vk.CommandBufferUsageFlags(vk.CommandBufferUsageOneTimeSubmitBit),
+	}))
+	if err != nil {
+		return fmt.Errorf("vk.BeginCommandBuffer failed with %s", err)
+	}
+	record(cmd)
+	err = vk.Error(vk.EndCommandBuffer(cmd))
+	if err != nil {
+		return fmt.Errorf("vk.EndCommandBuffer failed with %s", err)
+	}
+	var fence vk.Fence
+	err = vk.Error(vk.CreateFence(v.Device, &vk.FenceCreateInfo{
+		SType: vk.StructureTypeFenceCreateInfo,
+	}, nil, &fence))
+	if err != nil {
+		return fmt.Errorf("vk.CreateFence failed with %s", err)
+	}
+	defer vk.DestroyFence(v.Device, fence, nil)
+	err = vk.Error(vk.QueueSubmit(v.Queue, 1, []vk.SubmitInfo{{
+		SType:              vk.StructureTypeSubmitInfo,
+		CommandBufferCount: 1,
+		PCommandBuffers:    []vk.CommandBuffer{cmd},
+	}}, fence))
+	if err != nil {
+		return fmt.Errorf("vk.QueueSubmit failed with %s", err)
+	}
+	err = vk.Error(vk.WaitForFences(v.Device, 1, []vk.Fence{fence}, vk.True, vk.MaxUint64))
+	if err != nil {
+		return fmt.Errorf("vk.WaitForFences failed with %s", err)
+	}
+	return nil
+}
+
+func imageBarrier(image vk.Image, oldLayout, newLayout vk.ImageLayout,
+	srcAccess, dstAccess vk.AccessFlags) vk.ImageMemoryBarrier {
+
+	return vk.ImageMemoryBarrier{
+		SType:               vk.StructureTypeImageMemoryBarrier,
+		SrcAccessMask:       srcAccess,
+		DstAccessMask:       dstAccess,
+		OldLayout:           oldLayout,
+		NewLayout:           newLayout,
+		SrcQueueFamilyIndex: vk.QueueFamilyIgnored,
+		DstQueueFamilyIndex: vk.QueueFamilyIgnored,
+		Image:               image,
+		SubresourceRange: vk.ImageSubresourceRange{
+			AspectMask: vk.ImageAspectFlags(vk.ImageAspectColorBit),
+			LevelCount: 1,
+			LayerCount: 1,
+		},
+	}
+}
+
+// ReadImage copies the first level of a color image in layout to host
+// memory and transitions it back to layout. The image must not be in use,
+// wait for the frames rendering to it first.
+func (v *VulkanDeviceInfo) ReadImage(cmdPool vk.CommandPool, image vk.Image, format vk.Format,
+	extent vk.Extent2D, layout vk.ImageLayout) (pixels.Buffer, error) {
+
+	pixelFormat, ok := PixelFormat(format)
+	if !ok {
+		return pixels.Buffer{}, fmt.Errorf("format %d can't be read back", format)
+	}
+	if layout == vk.ImageLayoutUndefined {
+		return pixels.Buffer{}, fmt.Errorf("an image in the undefined layout has no content to read")
+	}
+	texelSize := FormatSize(format)
+	pitch := readbackPitch(extent.Width, texelSize, uint64(v.Limits().OptimalBufferCopyRowPitchAlignment))
+	size := pitch * uint64(extent.Height)
+
+	// Phase 1: create a host visible buffer to copy to
+
+	var buffer vk.Buffer
+	err := vk.Error(vk.CreateBuffer(v.Device, &vk.BufferCreateInfo{
+		SType:       vk.StructureTypeBufferCreateInfo,
+		Size:        vk.DeviceSize(size),
+		Usage:       vk.BufferUsageFlags(vk.BufferUsageTransferDstBit),
+		SharingMode: vk.SharingModeExclusive,
+	}, nil, &buffer))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateBuffer failed with %s", err)
+		return pixels.Buffer{}, err
+	}
+	defer vk.DestroyBuffer(v.Device, buffer, nil)
+	alloc, err := v.Memory.AllocateBuffer(buffer,
+		vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit),
+		vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit|vk.MemoryPropertyHostCachedBit))
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	defer v.Memory.Free(alloc)
+
+	// Phase 2: transition the image for the copy, copy it and return it
+	//			to its layout
+
+	err = v.runOnce(cmdPool, func(cmd vk.CommandBuffer) {
+		vk.CmdPipelineBarrier(cmd,
+			vk.PipelineStageFlags(vk.PipelineStageAllCommandsBit),
+			vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+			0, 0, nil, 0, nil, 1, []vk.ImageMemoryBarrier{
+				imageBarrier(image, layout, vk.ImageLayoutTransferSrcOptimal,
+					vk.AccessFlags(vk.AccessColorAttachmentWriteBit|vk.AccessTransferWriteBit),
+					vk.AccessFlags(vk.AccessTransferReadBit)),
+			})
+		vk.CmdCopyImageToBuffer(cmd, image, vk.ImageLayoutTransferSrcOptimal, buffer, 1, []vk.BufferImageCopy{{
+			BufferRowLength: uint32(pitch / uint64(texelSize)),
+			ImageSubresource: vk.ImageSubresourceLayers{
+				AspectMask: vk.ImageAspectFlags(vk.ImageAspectColorBit),
+				LayerCount: 1,
+			},
+			ImageExtent: vk.Extent3D{
+				Width:  extent.Width,
+				Height: extent.Height,
+				Depth:  1,
+			},
+		}})
+		vk.CmdPipelineBarrier(cmd,
+			vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+			vk.PipelineStageFlags(vk.PipelineStageHostBit|vk.PipelineStageBottomOfPipeBit),
+			0, 0, nil, 1, []vk.BufferMemoryBarrier{{
+				SType:               vk.StructureTypeBufferMemoryBarrier,
+				SrcAccessMask:       vk.AccessFlags(vk.AccessTransferWriteBit),
+				DstAccessMask:       vk.AccessFlags(vk.AccessHostReadBit),
+				SrcQueueFamilyIndex: vk.QueueFamilyIgnored,
+				DstQueueFamilyIndex: vk.QueueFamilyIgnored,
+				Buffer:              buffer,
+				Size:                vk.DeviceSize(vk.WholeSize),
+			}}, 1, []vk.ImageMemoryBarrier{
+				imageBarrier(image, vk.ImageLayoutTransferSrcOptimal, layout,
+					vk.AccessFlags(vk.AccessTransferReadBit), 0),
+			})
+	})
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+
+	// Phase 3: copy the buffer out of mapped memory
+
+	ptr, err := v.Memory.Map(alloc)
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	err = v.Memory.Invalidate(alloc, 0, size)
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	data := make([]byte, size)
+	copy(data, (*[1 << 30]byte)(ptr)[:size:size])
+	return pixels.Buffer{
+		Data:     data,
+		Width:    int(extent.Width),
+		Height:   int(extent.Height),
+		RowPitch: int(pitch),
+		Format:   pixelFormat,
+	}, nil
+}
+
+// SwapchainCapture is a host visible buffer a frame copies its swapchain
+// image to. The copy has to be recorded into the frame's command buffer,
+// once a frame is presented its image belongs to the presentation engine.
+type SwapchainCapture struct {
+	device   vk.Device
+	memory   *MemoryAllocator
+	format   pixels.Format
+	extent   vk.Extent2D
+	size     uint64
+	readback readbackBuffer
+}
+
+// CreateSwapchainCapture creates a capture for the images of s, which
+// must be Readable. Recreating the swapchain needs a new capture.
+func (v *VulkanDeviceInfo) CreateSwapchainCapture(s *VulkanSwapchainInfo) (*SwapchainCapture, error) {
+	if !s.Readable {
+		return nil, fmt.Errorf("the surface doesn't support copies from swapchain images")
+	}
+	pixelFormat, ok := PixelFormat(s.DisplayFormat)
+	if !ok {
+		return nil, fmt.Errorf("format %d can't be read back", s.DisplayFormat)
+	}
+	size := uint64(s.DisplaySize.Width) * uint64(s.DisplaySize.Height) * uint64(FormatSize(s.DisplayFormat))
+	rb, err := v.createReadbackBuffer(size)
+	if err != nil {
+		return nil, err
+	}
+	return &SwapchainCapture{
+		device:   v.Device,
+		memory:   v.Memory,
+		format:   pixelFormat,
+		extent:   s.DisplaySize,
+		size:     size,
+		readback: rb,
+	}, nil
+}
+
+// Buffer returns the buffer to copy the swapchain image to, record the
+// copy after the render pass and before the frame is presented.
+func (c *SwapchainCapture) Buffer() vk.Buffer {
+	return c.readback.buffer
+}
+
+// Read returns what the last copy captured. The frame that recorded the
+// copy must have completed.
+func (c *SwapchainCapture) Read() (pixels.Buffer, error) {
+	data, err := c.readback.read(c.memory, c.size)
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	return pixels.Buffer{
+		Data:   data,
+		Width:  int(c.extent.Width),
+		Height: int(c.extent.Height),
+		Format: c.format,
+		Opaque: true,
+	}, nil
+}
+
+func (c *SwapchainCapture) Destroy() {
+	if c == nil {
+		return
+	}
+	c.readback.destroy(c.device, c.memory)
+}
+
+// Readback returns the pixels of Pixels as a pixels.Buffer.
+func (t *OffscreenTarget) Readback(image uint32) (pixels.Buffer, error) {
+	pixelFormat, ok := PixelFormat(t.format)
+	if !ok {
+		return pixels.Buffer{}, fmt.Errorf("format %d can't be converted to pixels", t.format)
+	}
+	data, err := t.Pixels(image)
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	return pixels.Buffer{
+		Data:   data,
+		Width:  int(t.extent.Width),
+		Height: int(t.extent.Height),
+		Format: pixelFormat,
+	}, nil
+}
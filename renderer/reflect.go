@@ -0,0 +1,175 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+
+	"github.com/vulkan-samples/spirv"
+)
+
+// ReflectShader loads a shader like PipelineBuilder.Build does and
+// reflects its interface.
+func ReflectShader(name string, load func(string) ([]byte, error)) (*spirv.Module, error) {
+	data, err := load(name)
+	if err != nil {
+		err := fmt.Errorf("asset %s not found: %s", name, err)
+		return nil, err
+	}
+	m, err := spirv.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return m, nil
+}
+
+var descriptorTypes = map[spirv.DescriptorType]vk.DescriptorType{
+	spirv.UniformBuffer:          vk.DescriptorTypeUniformBuffer,
+	spirv.StorageBuffer:          vk.DescriptorTypeStorageBuffer,
+	spirv.CombinedImageSampler:   vk.DescriptorTypeCombinedImageSampler,
+	spirv.SampledImageDescriptor: vk.DescriptorTypeSampledImage,
+	spirv.StorageImage:           vk.DescriptorTypeStorageImage,
+	spirv.SamplerDescriptor:      vk.DescriptorTypeSampler,
+	spirv.UniformTexelBuffer:     vk.DescriptorTypeUniformTexelBuffer,
+	spirv.StorageTexelBuffer:     vk.DescriptorTypeStorageTexelBuffer,
+	spirv.InputAttachment:        vk.DescriptorTypeInputAttachment,
+}
+
+// ReflectLayout collects the bindings of descriptor set set used by the
+// modules, a binding used by several stages is visible to all of them.
+// Shaders can't tell whether a buffer is bound with a dynamic offset, use
+// LayoutBuilder.Dynamic for that.
+func ReflectLayout(set uint32, modules ...*spirv.Module) (*LayoutBuilder, error) {
+	b := NewLayoutBuilder()
+	seen := make(map[uint32]DescriptorBinding)
+	for _, m := range modules {
+		for _, binding := range m.Bindings {
+			if binding.Set != set {
+				continue
+			}
+			typ, ok := descriptorTypes[binding.Type]
+			if !ok {
+				return nil, fmt.Errorf("binding %q: unsupported descriptor type %d", binding.Name, binding.Type)
+			}
+			if binding.Count == 0 {
+				return nil, fmt.Errorf("binding %q: runtime arrays are not supported", binding.Name)
+			}
+			d := DescriptorBinding{
+				Binding: binding.Binding,
+				Type:    typ,
+				Count:   binding.Count,
+				Stages:  vk.ShaderStageFlags(m.Stages()),
+			}
+			if prev, ok := seen[d.Binding]; ok {
+				if prev.Type != d.Type || prev.Count != d.Count {
+					return nil, fmt.Errorf("binding %d of set %d is declared differently by the stages",
+						d.Binding, set)
+				}
+				d.Stages |= prev.Stages
+			}
+			seen[d.Binding] = d
+			b.Binding(d)
+		}
+	}
+	return b, nil
+}
+
+// Dynamic turns uniform and storage buffer bindings into their dynamic
+// offset variants.
+func (b *LayoutBuilder) Dynamic(bindings ...uint32) *LayoutBuilder {
+	for _, binding := range bindings {
+		for i := range b.bindings {
+			if b.bindings[i].Binding != binding {
+				continue
+			}
+			switch b.bindings[i].Type {
+			case vk.DescriptorTypeUniformBuffer:
+				b.bindings[i].Type = vk.DescriptorTypeUniformBufferDynamic
+			case vk.DescriptorTypeStorageBuffer:
+				b.bindings[i].Type = vk.DescriptorTypeStorageBufferDynamic
+			}
+		}
+	}
+	return b
+}
+
+// ReflectPushConstants returns one range per push constant block, ranges
+// of blocks shared by several modules are merged.
+func ReflectPushConstants(modules ...*spirv.Module) []PushConstantRange {
+	var ranges []PushConstantRange
+	for _, m := range modules {
+		for _, pc := range m.PushConstants {
+			r := PushConstantRange{
+				Stages: vk.ShaderStageFlagBits(m.Stages()),
+				Size:   pc.Block.Size,
+			}
+			for i, member := range pc.Block.Members {
+				if i == 0 || member.Offset < r.Offset {
+					r.Offset = member.Offset
+				}
+			}
+			r.Size -= r.Offset
+			merged := false
+			for i := range ranges {
+				if ranges[i].Offset == r.Offset && ranges[i].Size == r.Size {
+					ranges[i].Stages |= r.Stages
+					merged = true
+				}
+			}
+			if !merged {
+				ranges = append(ranges, r)
+			}
+		}
+	}
+	return ranges
+}
+
+// VertexFormats returns the formats of the inputs of a vertex shader by
+// location, ready for PipelineBuilder.Vertex. Matrices take a location
+// per column.
+func VertexFormats(m *spirv.Module) ([]vk.Format, error) {
+	var formats []vk.Format
+	for _, input := range m.Inputs {
+		if input.Location != uint32(len(formats)) {
+			return nil, fmt.Errorf("vertex input %q at location %d leaves a gap", input.Name, input.Location)
+		}
+		typ, columns := input.Type, uint32(1)
+		if typ.Kind == spirv.Matrix {
+			typ, columns = typ.Elem, typ.Len
+		}
+		format, err := vertexFormat(typ)
+		if err != nil {
+			return nil, fmt.Errorf("vertex input %q: %s", input.Name, err)
+		}
+		for i := uint32(0); i < columns; i++ {
+			formats = append(formats, format)
+		}
+	}
+	return formats, nil
+}
+
+var vertexFormats = map[spirv.Kind][4]vk.Format{
+	spirv.Float: {vk.FormatR32Sfloat, vk.FormatR32g32Sfloat, vk.FormatR32g32b32Sfloat, vk.FormatR32g32b32a32Sfloat},
+	spirv.Int:   {vk.FormatR32Sint, vk.FormatR32g32Sint, vk.FormatR32g32b32Sint, vk.FormatR32g32b32a32Sint},
+}
+
+var vertexFormatsUnsigned = [4]vk.Format{
+	vk.FormatR32Uint, vk.FormatR32g32Uint, vk.FormatR32g32b32Uint, vk.FormatR32g32b32a32Uint,
+}
+
+// vertexFormat picks the 32 bit format matching a scalar or vector input.
+func vertexFormat(t *spirv.Type) (vk.Format, error) {
+	n := uint32(1)
+	scalar := t
+	if t.Kind == spirv.Vector {
+		n, scalar = t.Len, t.Elem
+	}
+	formats, ok := vertexFormats[scalar.Kind]
+	if !ok || scalar.Width != 32 || n < 1 || n > 4 {
+		return vk.FormatUndefined, fmt.Errorf("no vertex format for %s", t)
+	}
+	if scalar.Kind == spirv.Int && !scalar.Signed {
+		formats = vertexFormatsUnsigned
+	}
+	return formats[n-1], nil
+}
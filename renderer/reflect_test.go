@@ -0,0 +1,187 @@
+package renderer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+
+	"github.com/vulkan-samples/spirv"
+)
+
+func loadTestShader(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join("../uniformBuffer/uniform/shaders", name))
+}
+
+func reflectTriShaders(t *testing.T) (vert, frag *spirv.Module) {
+	t.Helper()
+	vert, err := ReflectShader("tri-vert.spv", loadTestShader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frag, err = ReflectShader("tri-frag.spv", loadTestShader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vert, frag
+}
+
+var (
+	testFloat = &spirv.Type{Kind: spirv.Float, Width: 32}
+	testUint  = &spirv.Type{Kind: spirv.Int, Width: 32}
+	testVec4  = &spirv.Type{Kind: spirv.Vector, Len: 4, Elem: testFloat}
+)
+
+func TestReflectLayout(t *testing.T) {
+	vert, frag := reflectTriShaders(t)
+	b, err := ReflectLayout(0, vert, frag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DescriptorBinding{{
+		Binding: 0,
+		Type:    vk.DescriptorTypeUniformBuffer,
+		Count:   1,
+		Stages:  vk.ShaderStageFlags(vk.ShaderStageVertexBit),
+	}}
+	if got := b.Bindings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReflectLayout = %+v, want %+v", got, want)
+	}
+	want[0].Type = vk.DescriptorTypeUniformBufferDynamic
+	if got := b.Dynamic(0).Bindings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Dynamic(0) = %+v, want %+v", got, want)
+	}
+	if b, err := ReflectLayout(1, vert, frag); err != nil || len(b.Bindings()) != 0 {
+		t.Errorf("ReflectLayout of an unused set = %+v, %v", b, err)
+	}
+
+	module := func(stage spirv.Stage, bindings ...spirv.Binding) *spirv.Module {
+		return &spirv.Module{
+			EntryPoints: []spirv.EntryPoint{{Name: "main", Stage: stage}},
+			Bindings:    bindings,
+		}
+	}
+	textures := spirv.Binding{Name: "textures", Binding: 1, Type: spirv.CombinedImageSampler, Count: 4}
+	b, err = ReflectLayout(0, module(spirv.StageVertex, textures), module(spirv.StageFragment, textures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages := vk.ShaderStageFlags(vk.ShaderStageVertexBit | vk.ShaderStageFragmentBit)
+	if got := b.Bindings(); len(got) != 1 || got[0].Stages != stages || got[0].Count != 4 {
+		t.Errorf("binding shared by two stages = %+v, want one for both stages", got)
+	}
+
+	buffer := spirv.Binding{Name: "textures", Binding: 1, Type: spirv.StorageBuffer, Count: 1}
+	runtime := spirv.Binding{Name: "all", Binding: 2, Type: spirv.CombinedImageSampler}
+	bad := map[string][]*spirv.Module{
+		"conflicting types": {module(spirv.StageVertex, textures), module(spirv.StageFragment, buffer)},
+		"runtime array":     {module(spirv.StageFragment, runtime)},
+	}
+	for name, modules := range bad {
+		if _, err := ReflectLayout(0, modules...); err == nil {
+			t.Errorf("%s: ReflectLayout succeeded", name)
+		}
+	}
+}
+
+func TestVertexFormats(t *testing.T) {
+	vert, _ := reflectTriShaders(t)
+	formats, err := VertexFormats(vert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []vk.Format{vk.FormatR32g32b32Sfloat, vk.FormatR32g32b32Sfloat}
+	if !reflect.DeepEqual(formats, want) {
+		t.Errorf("VertexFormats = %v, want %v", formats, want)
+	}
+
+	inputs := func(vars ...spirv.Variable) *spirv.Module {
+		return &spirv.Module{Inputs: vars}
+	}
+	mat4 := &spirv.Type{Kind: spirv.Matrix, Len: 4, Elem: testVec4}
+	uvec2 := &spirv.Type{Kind: spirv.Vector, Len: 2, Elem: testUint}
+	formats, err = VertexFormats(inputs(
+		spirv.Variable{Name: "model", Location: 0, Type: mat4},
+		spirv.Variable{Name: "ids", Location: 4, Type: uvec2},
+		spirv.Variable{Name: "weight", Location: 5, Type: testFloat},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []vk.Format{vk.FormatR32g32b32a32Sfloat, vk.FormatR32g32b32a32Sfloat, vk.FormatR32g32b32a32Sfloat,
+		vk.FormatR32g32b32a32Sfloat, vk.FormatR32g32Uint, vk.FormatR32Sfloat}
+	if !reflect.DeepEqual(formats, want) {
+		t.Errorf("VertexFormats = %v, want %v", formats, want)
+	}
+
+	double := &spirv.Type{Kind: spirv.Float, Width: 64}
+	bad := map[string]*spirv.Module{
+		"gap":    inputs(spirv.Variable{Name: "pos", Location: 1, Type: testVec4}),
+		"double": inputs(spirv.Variable{Name: "pos", Location: 0, Type: double}),
+	}
+	for name, m := range bad {
+		if _, err := VertexFormats(m); err == nil {
+			t.Errorf("%s: VertexFormats succeeded", name)
+		}
+	}
+}
+
+func TestReflectPushConstants(t *testing.T) {
+	vert, frag := reflectTriShaders(t)
+	if ranges := ReflectPushConstants(vert, frag); len(ranges) != 0 {
+		t.Errorf("ReflectPushConstants = %+v, the shaders have no push constants", ranges)
+	}
+
+	// block has the members of a push constant block at offsets, the block
+	// size ends after the last one.
+	block := func(size uint32, offsets ...uint32) *spirv.Type {
+		t := &spirv.Type{Kind: spirv.Struct, Block: true, Size: size}
+		for _, offset := range offsets {
+			t.Members = append(t.Members, spirv.Member{Offset: offset, Type: testVec4, Size: 16})
+		}
+		return t
+	}
+	module := func(stage spirv.Stage, b *spirv.Type) *spirv.Module {
+		return &spirv.Module{
+			EntryPoints:   []spirv.EntryPoint{{Name: "main", Stage: stage}},
+			PushConstants: []spirv.PushConstant{{Name: "pc", Block: b}},
+		}
+	}
+	tests := []struct {
+		name    string
+		modules []*spirv.Module
+		want    []PushConstantRange
+	}{
+		{
+			name:    "single stage",
+			modules: []*spirv.Module{module(spirv.StageVertex, block(32, 0, 16))},
+			want:    []PushConstantRange{{vk.ShaderStageVertexBit, 0, 32}},
+		},
+		{
+			name: "shared block",
+			modules: []*spirv.Module{
+				module(spirv.StageVertex, block(32, 0, 16)),
+				module(spirv.StageFragment, block(32, 0, 16)),
+			},
+			want: []PushConstantRange{{vk.ShaderStageVertexBit | vk.ShaderStageFragmentBit, 0, 32}},
+		},
+		{
+			name: "disjoint ranges",
+			modules: []*spirv.Module{
+				module(spirv.StageVertex, block(64, 0, 48)),
+				module(spirv.StageFragment, block(80, 64)),
+			},
+			want: []PushConstantRange{
+				{vk.ShaderStageVertexBit, 0, 64},
+				{vk.ShaderStageFragmentBit, 64, 16},
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := ReflectPushConstants(test.modules...); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: ReflectPushConstants = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
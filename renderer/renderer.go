@@ -1,17 +1,13 @@
 package renderer
 
 import (
-	"bytes"
 	"fmt"
 	"log"
 	"unsafe"
 	"errors"
-	"image"
-	"image/draw"
-	"image/jpeg"
 
-	as "github.com/vulkan-go/asche"
 	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/memalloc"
 	"github.com/vulkan-samples/util"
 )
 
@@ -27,18 +23,22 @@ type Texture struct {
 	image       vk.Image
 	imageLayout vk.ImageLayout
 
-	memAlloc *vk.MemoryAllocateInfo
-	mem      vk.DeviceMemory
-	view     vk.ImageView
+	memory *MemoryAllocator
+	alloc  *memalloc.Allocation
+	view   vk.ImageView
 
+	format    vk.Format
 	texWidth  int32
 	texHeight int32
+	levels    uint32
+	layers    uint32
+	cube      bool
 }
 
 func (t *Texture) Destroy(dev vk.Device) {
 	vk.DestroyImageView(dev, t.view, nil)
-	vk.FreeMemory(dev, t.mem, nil)
 	vk.DestroyImage(dev, t.image, nil)
+	t.memory.Free(t.alloc)
 	vk.DestroySampler(dev, t.sampler, nil)
 }
 
@@ -52,6 +52,11 @@ func NewVulkanDevice(appInfo *vk.ApplicationInfo, window uintptr, instanceExtens
 		instanceExtensions = append(instanceExtensions,
 			"VK_EXT_debug_report\x00")
 	}
+	// HDR10 and scRGB surface formats need the extended colorspaces.
+	if createSurfaceFunc != nil && hasExtension(existingExtensions, "VK_EXT_swapchain_colorspace") {
+		instanceExtensions = append(instanceExtensions,
+			"VK_EXT_swapchain_colorspace\x00")
+	}
 
 	// ANDROID:
 	// these layers must be included in APK,
@@ -78,6 +83,7 @@ func NewVulkanDevice(appInfo *vk.ApplicationInfo, window uintptr, instanceExtens
 	}
 
 	var v VulkanDeviceInfo
+	v.SwapchainPolicy = DefaultSwapchainPolicy()
 	err := vk.Error(vk.CreateInstance(&instanceCreateInfo, nil, &v.Instance))
 	if err != nil {
 		err = fmt.Errorf("vk.CreateInstance failed with %s", err)
@@ -87,8 +93,12 @@ func NewVulkanDevice(appInfo *vk.ApplicationInfo, window uintptr, instanceExtens
 	}
 
 	// Phase 2: vk.CreateAndroidSurface with vk.AndroidSurfaceCreateInfo
+	//			skipped by headless devices
 
-	v.Surface = vk.SurfaceFromPointer(createSurfaceFunc(v.Instance))
+	v.Headless = createSurfaceFunc == nil
+	if !v.Headless {
+		v.Surface = vk.SurfaceFromPointer(createSurfaceFunc(v.Instance))
+	}
 	if err != nil {
 		vk.DestroyInstance(v.Instance, nil)
 		err = fmt.Errorf("vkCreateWindowSurface failed with %s", err)
@@ -125,9 +135,22 @@ func NewVulkanDevice(appInfo *vk.ApplicationInfo, window uintptr, instanceExtens
 		QueueCount:       1,
 		PQueuePriorities: []float32{1.0},
 	}}
-	deviceExtensions := []string{
-		"VK_KHR_swapchain\x00",
+	// Uploads go through a dedicated transfer queue when the GPU has one,
+	// they run next to rendering on the DMA engines of discrete GPUs.
+	transferFamily, dedicatedTransfer := selectTransferFamily(getQueueFamilies(v.gpuDevices[0]), 0)
+	if dedicatedTransfer {
+		queueCreateInfos = append(queueCreateInfos, vk.DeviceQueueCreateInfo{
+			SType:            vk.StructureTypeDeviceQueueCreateInfo,
+			QueueFamilyIndex: transferFamily,
+			QueueCount:       1,
+			PQueuePriorities: []float32{1.0},
+		})
+	}
+	var deviceExtensions []string
+	if !v.Headless {
+		deviceExtensions = append(deviceExtensions, "VK_KHR_swapchain\x00")
 	}
+	v.Features = textureFeatures(v.gpuDevices[0])
 	deviceCreateInfo := vk.DeviceCreateInfo{
 		SType:                   vk.StructureTypeDeviceCreateInfo,
 		QueueCreateInfoCount:    uint32(len(queueCreateInfos)),
@@ -136,6 +159,7 @@ func NewVulkanDevice(appInfo *vk.ApplicationInfo, window uintptr, instanceExtens
 		PpEnabledExtensionNames: deviceExtensions,
 		EnabledLayerCount:       uint32(len(deviceLayers)),
 		PpEnabledLayerNames:     deviceLayers,
+		PEnabledFeatures:        []vk.PhysicalDeviceFeatures{v.Features},
 	}
 	var device vk.Device // we choose the first GPU available for this device
 	err = vk.Error(vk.CreateDevice(v.gpuDevices[0], &deviceCreateInfo, nil, &device))
@@ -150,6 +174,21 @@ func NewVulkanDevice(appInfo *vk.ApplicationInfo, window uintptr, instanceExtens
 		var queue vk.Queue
 		vk.GetDeviceQueue(device, 0, 0, &queue)
 		v.Queue = queue
+		v.TransferQueue = queue
+		if dedicatedTransfer {
+			var transferQueue vk.Queue
+			vk.GetDeviceQueue(device, transferFamily, 0, &transferQueue)
+			v.TransferQueue = transferQueue
+			v.TransferFamily = transferFamily
+		}
+		v.Memory = v.NewMemoryAllocator()
+		v.Layouts = NewLayoutCache(device)
+		v.Variants = NewVariantCaches(device)
+		v.Uploads, err = v.NewUploadManager(DefaultStagingSize)
+		if err != nil {
+			err = fmt.Errorf("renderer.NewUploadManager failed with %s", err)
+			return v, err
+		}
 	}
 
 	if enableDebug {
@@ -172,6 +211,13 @@ func NewVulkanDevice(appInfo *vk.ApplicationInfo, window uintptr, instanceExtens
 	return v, nil
 }
 
+// NewHeadlessDevice creates a device without a surface and without
+// VK_KHR_swapchain, it renders to an OffscreenTarget only. It also runs on
+// software implementations that have no presentation support.
+func NewHeadlessDevice(appInfo *vk.ApplicationInfo, instanceExtensions []string) (VulkanDeviceInfo, error) {
+	return NewVulkanDevice(appInfo, 0, instanceExtensions, nil)
+}
+
 func dbgCallbackFunc(flags vk.DebugReportFlags, objectType vk.DebugReportObjectType,
 	object uint64, location uint, messageCode int32, pLayerPrefix string,
 	pMessage string, pUserData unsafe.Pointer) vk.Bool32 {
@@ -222,6 +268,41 @@ func getPhysicalDevices(instance vk.Instance) ([]vk.PhysicalDevice, error) {
 	return gpuList, nil
 }
 
+func getQueueFamilies(gpu vk.PhysicalDevice) []vk.QueueFamilyProperties {
+	var count uint32
+	vk.GetPhysicalDeviceQueueFamilyProperties(gpu, &count, nil)
+	families := make([]vk.QueueFamilyProperties, count)
+	vk.GetPhysicalDeviceQueueFamilyProperties(gpu, &count, families)
+	for i := range families {
+		families[i].Deref()
+	}
+	return families
+}
+
+// selectTransferFamily returns a queue family that supports transfers but
+// not graphics, false means uploads should use the graphics queue.
+func selectTransferFamily(families []vk.QueueFamilyProperties, graphicsFamily uint32) (uint32, bool) {
+	for i := range families {
+		flags := families[i].QueueFlags
+		if uint32(i) == graphicsFamily || families[i].QueueCount == 0 {
+			continue
+		}
+		if flags&vk.QueueFlags(vk.QueueTransferBit) != 0 && flags&vk.QueueFlags(vk.QueueGraphicsBit) == 0 {
+			return uint32(i), true
+		}
+	}
+	return graphicsFamily, false
+}
+
+func hasExtension(extensions []string, name string) bool {
+	for _, ext := range extensions {
+		if ext == name {
+			return true
+		}
+	}
+	return false
+}
+
 func getInstanceExtensions() (extNames []string) {
 	var instanceExtLen uint32
 	ret := vk.EnumerateInstanceExtensionProperties("", &instanceExtLen, nil)
@@ -243,8 +324,34 @@ type VulkanDeviceInfo struct {
 	Dbg      vk.DebugReportCallback
 	Instance vk.Instance
 	Surface  vk.Surface
+	// Headless devices have no Surface and can't create swapchains.
+	Headless bool
 	Queue    vk.Queue
 	Device   vk.Device
+
+	// WindowExtent is the framebuffer size of the window, it is used
+	// when the surface lets the application pick the swapchain extent.
+	WindowExtent vk.Extent2D
+	// SwapchainPolicy selects present mode, format and image count
+	// of the swapchains created on this device.
+	SwapchainPolicy SwapchainPolicy
+	// Memory sub-allocates the memory of buffers and images.
+	Memory *MemoryAllocator
+
+	// QueueFamily is the family of Queue, used for graphics and present.
+	QueueFamily uint32
+	// TransferQueue is a dedicated transfer queue if the GPU has one,
+	// otherwise the same as Queue.
+	TransferQueue  vk.Queue
+	TransferFamily uint32
+	// Uploads copies data into device local buffers and images.
+	Uploads *UploadManager
+	// Layouts shares descriptor set layouts with equal bindings.
+	Layouts *LayoutCache
+	// Variants owns the material pipeline variants, destroyed with the device.
+	Variants *VariantCaches
+	// Features are the enabled device features.
+	Features vk.PhysicalDeviceFeatures
 }
 
 type VulkanSwapchainInfo struct {
@@ -253,15 +360,24 @@ type VulkanSwapchainInfo struct {
 	Swapchains   []vk.Swapchain
 	SwapchainLen []uint32
 
-	UniformBuffer []UniformBuffer
-	DisplaySize   vk.Extent2D
-	DisplayFormat vk.Format
+	DisplaySize       vk.Extent2D
+	DisplayFormat     vk.Format
+	DisplayColorSpace vk.ColorSpace
+	PresentMode       vk.PresentMode
+	// FormatFallback is set when the display format isn't one of the
+	// swapchain policy's, see SwapchainConfig.
+	FormatFallback bool
 
 	Framebuffers []vk.Framebuffer
 	DisplayViews []vk.ImageView
+	// DisplayImages are the swapchain images, frames can copy Readable
+	// ones to a SwapchainCapture before presenting them.
+	DisplayImages []vk.Image
+	Readable      bool
 
+	// DescLayout is owned by the layout cache of the device.
 	DescLayout 		vk.DescriptorSetLayout
-	DescPool 			vk.DescriptorPool
+	Descriptors 	*DescriptorAllocator
 	DescriptorSet	[]vk.DescriptorSet
 }
 
@@ -273,93 +389,31 @@ func (v *VulkanSwapchainInfo) DefaultSwapchainLen() uint32 {
 	return v.SwapchainLen[0]
 }
 
-func (s *VulkanSwapchainInfo) CreateDescriptorPool(textures []*Texture) error {
-	dev := s.Device
-
-	var poolCount = uint32(1)
-	if (len(textures) > 0) {
-		poolCount += 1
+// CreateDescriptorSet allocates the descriptor set binding the uniform ring,
+// all frames share it and select their uniform data with a dynamic offset.
+// Sets allocated by an earlier call are released.
+func (s *VulkanSwapchainInfo) CreateDescriptorSet(uniforms *UniformRing, textures []*Texture) error {
+	if s.Descriptors == nil {
+		s.Descriptors = NewDescriptorAllocator(s.Device, 0, nil)
 	}
-
-	var descPool vk.DescriptorPool
-	ret := vk.CreateDescriptorPool(dev, &vk.DescriptorPoolCreateInfo{
-		SType:         vk.StructureTypeDescriptorPoolCreateInfo,
-		MaxSets:       uint32(s.SwapchainLen[0]),
-		PoolSizeCount: poolCount,
-		PPoolSizes: 	 []vk.DescriptorPoolSize{{
-			Type:            vk.DescriptorTypeUniformBuffer,
-			DescriptorCount: uint32(s.SwapchainLen[0]),
-		}, {
-			Type:            vk.DescriptorTypeCombinedImageSampler,
-			DescriptorCount: uint32(s.SwapchainLen[0]) * uint32(len(textures)),
-		}},
-	}, nil, &descPool)
-	err := vk.Error(ret)
-	if (err != nil) {
-		return fmt.Errorf("vk.CreateDescriptorPool failed with %s", err)
+	s.Descriptors.Reset()
+	set, err := s.Descriptors.Allocate(s.DescLayout)
+	if err != nil {
+		return err
 	}
+	s.DescriptorSet = []vk.DescriptorSet{set}
 
-	s.DescPool = descPool
+	NewDescriptorWriter().
+		Buffer(set, 0, vk.DescriptorTypeUniformBufferDynamic, uniforms.Buffer(), 0, uniforms.Range()).
+		Textures(set, 1, textures).
+		Update(s.Device)
 	return nil
 }
 
-func (s *VulkanSwapchainInfo) CreateDescriptorSet(uniformSize vk.DeviceSize, textures []*Texture) error{
-	dev := s.Device
-
-	// Create image info
-	texInfos := make([]vk.DescriptorImageInfo, 0, len(textures))
-	for _, tex := range textures {
-		texInfos = append(texInfos, vk.DescriptorImageInfo{
-			Sampler: tex.sampler,
-			ImageView: tex.view,
-			ImageLayout: vk.ImageLayoutGeneral,
-		})
-	}
-
-	s.DescriptorSet = make([]vk.DescriptorSet, s.SwapchainLen[0])
-	for i := uint32(0); i < s.SwapchainLen[0]; i++ {
-		var set vk.DescriptorSet
-		ret := vk.AllocateDescriptorSets(dev, &vk.DescriptorSetAllocateInfo{
-			SType:              vk.StructureTypeDescriptorSetAllocateInfo,
-			DescriptorPool:     s.DescPool,
-			DescriptorSetCount: 1,
-			PSetLayouts:        []vk.DescriptorSetLayout{s.DescLayout},
-		}, &set)
-		err := vk.Error(ret)
-		if (err != nil) {
-			return fmt.Errorf("vk.AllocateDescriptorSets failed with %s", err)
-		}
-
-		s.DescriptorSet[i] = set
-
-		var setCount = uint32(1)
-		if (len(textures) > 0) {
-			setCount += 1
-		}
-
-		vk.UpdateDescriptorSets(dev, setCount, []vk.WriteDescriptorSet{{
-			SType:           vk.StructureTypeWriteDescriptorSet,
-			DstSet:          set,
-			DescriptorCount: 1,
-			DescriptorType:  vk.DescriptorTypeUniformBuffer,
-			PBufferInfo: []vk.DescriptorBufferInfo{{
-				Offset: 0,
-				Range:  uniformSize,
-				Buffer: s.UniformBuffer[i].buffer,
-			}},
-			}, {
-				SType:           vk.StructureTypeWriteDescriptorSet,
-				DstBinding:      1,
-				DstSet:          set,
-				DescriptorCount: uint32(len(textures)),
-				DescriptorType:  vk.DescriptorTypeCombinedImageSampler,
-				PImageInfo:      texInfos,
-		}}, 0, nil)
-	}
-	return nil
-}
-
-func (s *VulkanSwapchainInfo) CreateFramebuffers(renderPass vk.RenderPass, depthView vk.ImageView) error {
+// CreateFramebuffers creates a framebuffer for every swapchain image with
+// the attachments of targets, targets may be nil to render only to the
+// swapchain images.
+func (s *VulkanSwapchainInfo) CreateFramebuffers(renderPass vk.RenderPass, targets *RenderTargets) error {
 	// Phase 1: vk.GetSwapchainImages
 
 	var swapchainImagesCount uint32
@@ -398,28 +452,23 @@ func (s *VulkanSwapchainInfo) CreateFramebuffers(renderPass vk.RenderPass, depth
 			return err // bail out
 		}
 	}
-	swapchainImages = nil
+	s.DisplayImages = swapchainImages
 
 	// Phase 3: vk.CreateFramebuffer
 	//			create a framebuffer from each swapchain image
 
 	s.Framebuffers = make([]vk.Framebuffer, s.DefaultSwapchainLen())
 	for i := range s.Framebuffers {
-		attachments := []vk.ImageView{
-			s.DisplayViews[i], depthView,
-		}
+		attachments := targets.Attachments(s.DisplayViews[i])
 		fbCreateInfo := vk.FramebufferCreateInfo{
 			SType:           vk.StructureTypeFramebufferCreateInfo,
 			RenderPass:      renderPass,
 			Layers:          1,
-			AttachmentCount: 1, // 2 if has depthView
+			AttachmentCount: uint32(len(attachments)),
 			PAttachments:    attachments,
 			Width:           s.DisplaySize.Width,
 			Height:          s.DisplaySize.Height,
 		}
-		if depthView != vk.NullImageView {
-			fbCreateInfo.AttachmentCount = 2
-		}
 		err := vk.Error(vk.CreateFramebuffer(s.Device, &fbCreateInfo, nil, &s.Framebuffers[i]))
 		if err != nil {
 			err = fmt.Errorf("vk.CreateFramebuffer failed with %s", err)
@@ -431,18 +480,17 @@ func (s *VulkanSwapchainInfo) CreateFramebuffers(renderPass vk.RenderPass, depth
 
 func (s *VulkanSwapchainInfo) Destroy() {
 	for i := uint32(0); i < s.DefaultSwapchainLen(); i++ {
-		vk.DestroyBuffer(s.Device, s.UniformBuffer[i].buffer, nil)
-		vk.FreeMemory(s.Device, s.UniformBuffer[i].memory, nil)
 		vk.DestroyFramebuffer(s.Device, s.Framebuffers[i], nil)
 		vk.DestroyImageView(s.Device, s.DisplayViews[i], nil)
-		vk.FreeDescriptorSets(s.Device, s.DescPool, i, &s.DescriptorSet[i])
 	}
-
-	vk.DestroyDescriptorSetLayout(s.Device, s.DescLayout, nil)
-	vk.DestroyDescriptorPool(s.Device, s.DescPool, nil)
+	// Destroying the pools frees the sets allocated from them.
+	s.Descriptors.Destroy()
+	s.Descriptors = nil
+	s.DescriptorSet = nil
 
 	s.Framebuffers = nil
 	s.DisplayViews = nil
+	s.DisplayImages = nil
 	for i := range s.Swapchains {
 		vk.DestroySwapchain(s.Device, s.Swapchains[i], nil)
 	}
@@ -451,6 +499,9 @@ func (s *VulkanSwapchainInfo) Destroy() {
 type VulkanBufferInfo struct {
 	device    vk.Device
 	buffers		[]vk.Buffer
+
+	memory *MemoryAllocator
+	allocs []*memalloc.Allocation
 }
 
 func (v *VulkanBufferInfo) GetDevice() vk.Device {
@@ -473,95 +524,122 @@ func (buf *VulkanBufferInfo) Destroy() {
 	for i := range buf.buffers {
 		vk.DestroyBuffer(buf.device, buf.buffers[i], nil)
 	}
+	for i := range buf.allocs {
+		buf.memory.Free(buf.allocs[i])
+	}
+	buf.buffers = nil
+	buf.allocs = nil
 }
 
 type UniformBuffer struct {
-	// device for destroy purposes.
 	// Buffer is the buffer object.
 	buffer vk.Buffer
-	// Memory is the device memory backing buffer object.
-	memory vk.DeviceMemory
+	// alloc is the memory backing buffer object.
+	memory *MemoryAllocator
+	alloc  *memalloc.Allocation
 }
 
-func (buf *UniformBuffer) GetMemory() vk.DeviceMemory {
-	return buf.memory;
+// Update copies data to the start of the uniform buffer.
+func (buf *UniformBuffer) Update(data []byte) error {
+	return buf.memory.Write(buf.alloc, data)
 }
 
-func (v VulkanDeviceInfo) CreateUniformBuffers(uniformData []byte) (*UniformBuffer, error) {
-	gpu := v.gpuDevices[0]
+func (buf *UniformBuffer) Destroy(dev vk.Device) {
+	vk.DestroyBuffer(dev, buf.buffer, nil)
+	buf.memory.Free(buf.alloc)
+	buf.buffer = vk.NullBuffer
+	buf.alloc = nil
+}
 
+// createHostBuffer creates a host visible and coherent buffer of usage
+// holding a copy of data.
+func (v *VulkanDeviceInfo) createHostBuffer(data []byte, usage vk.BufferUsageFlagBits) (vk.Buffer, *memalloc.Allocation, error) {
 	// Phase 1: vk.CreateBuffer
-	//			create the triangle vertex buffer
-	dataRaw := uniformData;
 
-	uniformBufferCreateInfo := vk.BufferCreateInfo{
+	queueFamilyIdx := []uint32{0}
+	bufferCreateInfo := vk.BufferCreateInfo{
 		SType:                 vk.StructureTypeBufferCreateInfo,
-		Size:                  vk.DeviceSize(len(dataRaw)),
-		Usage:                 vk.BufferUsageFlags(vk.BufferUsageUniformBufferBit),
-	//	SharingMode:           vk.SharingModeExclusive,
-	//	QueueFamilyIndexCount: 1,
-	//	PQueueFamilyIndices:   queueFamilyIdx,
-	}
-
-	uniformBuffer := VulkanBufferInfo{
-		buffers: make([]vk.Buffer, 1),
+		Size:                  vk.DeviceSize(len(data)),
+		Usage:                 vk.BufferUsageFlags(usage),
+		SharingMode:           vk.SharingModeExclusive,
+		QueueFamilyIndexCount: 1,
+		PQueueFamilyIndices:   queueFamilyIdx,
 	}
-	var uniformDeviceMemory vk.DeviceMemory
-	err := vk.Error(vk.CreateBuffer(v.Device, &uniformBufferCreateInfo, nil, &uniformBuffer.buffers[0]))
+	var buffer vk.Buffer
+	err := vk.Error(vk.CreateBuffer(v.Device, &bufferCreateInfo, nil, &buffer))
 	if err != nil {
 		err = fmt.Errorf("vk.CreateBuffer failed with %s", err)
-		return nil, err
+		return buffer, nil, err
 	}
 
-	// Phase 2: vk.GetBufferMemoryRequirements
-	//			vk.FindMemoryTypeIndex
-	// 			assign a proper memory type for that buffer
-
-	var memReq vk.MemoryRequirements
-	vk.GetBufferMemoryRequirements(v.Device, uniformBuffer.DefaultBuffer(), &memReq)
-	memReq.Deref()
-	allocInfo := vk.MemoryAllocateInfo{
-		SType:           vk.StructureTypeMemoryAllocateInfo,
-		AllocationSize:  memReq.Size,
-		MemoryTypeIndex: 0, // see below
-	}
-	allocInfo.MemoryTypeIndex, _ = vk.FindMemoryTypeIndex(gpu, memReq.MemoryTypeBits,
-		vk.MemoryPropertyHostVisibleBit)
-
-	// Phase 3: vk.AllocateMemory
-	//			vk.MapMemory
-	//			vk.MemCopyFloat32
-	//			vk.UnmapMemory
-	// 			allocate and map memory for that buffer
+	// Phase 2: sub-allocate host visible memory and bind the buffer
+	//			the memory stays mapped, copy the data to it
 
-	err = vk.Error(vk.AllocateMemory(v.Device, &allocInfo, nil, &uniformDeviceMemory))
+	hostMemory := vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit | vk.MemoryPropertyHostCoherentBit)
+	alloc, err := v.Memory.AllocateBuffer(buffer, hostMemory, hostMemory)
 	if err != nil {
-		err = fmt.Errorf("vk.AllocateMemory failed with %s", err)
-		return nil, err
+		vk.DestroyBuffer(v.Device, buffer, nil)
+		return vk.NullBuffer, nil, err
 	}
-	var uniformDataPtr unsafe.Pointer
-	vk.MapMemory(v.Device, uniformDeviceMemory, 0, vk.DeviceSize(len(dataRaw)), 0, &uniformDataPtr)
-	n := vk.Memcopy(uniformDataPtr, dataRaw)
-	if n != len(dataRaw) {
-		log.Println("[WARN] failed to copy uniform buffer data")
+	err = v.Memory.Write(alloc, data)
+	if err != nil {
+		log.Println("[WARN] failed to copy buffer data:", err)
 	}
-	vk.UnmapMemory(v.Device, uniformDeviceMemory)
+	return buffer, alloc, nil
+}
+
+// createDeviceBuffer creates a device local buffer of usage and queues the
+// upload of data into it with v.Uploads.
+func (v *VulkanDeviceInfo) createDeviceBuffer(data []byte, usage vk.BufferUsageFlagBits,
+	dstAccess vk.AccessFlagBits, dstStage vk.PipelineStageFlagBits) (vk.Buffer, *memalloc.Allocation, error) {
 
-	// Phase 4: vk.BindBufferMemory
-	//			copy vertex data and bind buffer
+	// Phase 1: vk.CreateBuffer
 
-	err = vk.Error(vk.BindBufferMemory(v.Device, uniformBuffer.DefaultBuffer(), uniformDeviceMemory, 0))
+	queueFamilyIdx := []uint32{0}
+	bufferCreateInfo := vk.BufferCreateInfo{
+		SType:                 vk.StructureTypeBufferCreateInfo,
+		Size:                  vk.DeviceSize(len(data)),
+		Usage:                 vk.BufferUsageFlags(usage | vk.BufferUsageTransferDstBit),
+		SharingMode:           vk.SharingModeExclusive,
+		QueueFamilyIndexCount: 1,
+		PQueueFamilyIndices:   queueFamilyIdx,
+	}
+	var buffer vk.Buffer
+	err := vk.Error(vk.CreateBuffer(v.Device, &bufferCreateInfo, nil, &buffer))
 	if err != nil {
-		err = fmt.Errorf("vk.BindBufferMemory failed with %s", err)
-		return nil, err
+		err = fmt.Errorf("vk.CreateBuffer failed with %s", err)
+		return buffer, nil, err
 	}
 
-	buffer := &UniformBuffer{
-		buffer: uniformBuffer.DefaultBuffer(),
-		memory: uniformDeviceMemory,
+	// Phase 2: sub-allocate device local memory and bind the buffer
+	//			queue the copy from the staging ring
+
+	deviceMemory := vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit)
+	alloc, err := v.Memory.AllocateBuffer(buffer, deviceMemory, deviceMemory)
+	if err != nil {
+		vk.DestroyBuffer(v.Device, buffer, nil)
+		return vk.NullBuffer, nil, err
 	}
+	_, err = v.Uploads.UploadBuffer(buffer, 0, data,
+		vk.AccessFlags(dstAccess), vk.PipelineStageFlags(dstStage))
+	if err != nil {
+		vk.DestroyBuffer(v.Device, buffer, nil)
+		v.Memory.Free(alloc)
+		return vk.NullBuffer, nil, err
+	}
+	return buffer, alloc, nil
+}
 
-	return buffer, err
+func (v VulkanDeviceInfo) CreateUniformBuffers(uniformData []byte) (*UniformBuffer, error) {
+	buffer, alloc, err := v.createHostBuffer(uniformData, vk.BufferUsageUniformBufferBit)
+	if err != nil {
+		return nil, err
+	}
+	return &UniformBuffer{
+		buffer: buffer,
+		memory: v.Memory,
+		alloc:  alloc,
+	}, nil
 }
 
 func (v *VulkanDeviceInfo) CreateCommandBuffers(n uint32, cmdPool vk.CommandPool) ([]vk.CommandBuffer, error) {
@@ -580,92 +658,132 @@ func (v *VulkanDeviceInfo) CreateCommandBuffers(n uint32, cmdPool vk.CommandPool
 	return cmdBuffers, nil
 }
 
-func (v *VulkanDeviceInfo) CreateSwapchain(uniformData []byte, textures []*Texture) (VulkanSwapchainInfo, error) {
+// Limits returns the limits of the physical device in use.
+func (v *VulkanDeviceInfo) Limits() vk.PhysicalDeviceLimits {
+	var props vk.PhysicalDeviceProperties
+	vk.GetPhysicalDeviceProperties(v.gpuDevices[0], &props)
+	props.Deref()
+	props.Limits.Deref()
+	return props.Limits
+}
+
+func (v *VulkanDeviceInfo) CreateSwapchain(textures []*Texture) (VulkanSwapchainInfo, error) {
+	return v.createSwapchain(textures, vk.NullSwapchain)
+}
+
+// RecreateSwapchain builds a new swapchain for the current surface extent,
+// handing the old one over as OldSwapchain, and then releases everything
+// that belonged to the old swapchain. The caller has to rebuild framebuffers,
+// descriptor sets and pipelines for the returned swapchain.
+func (v *VulkanDeviceInfo) RecreateSwapchain(old *VulkanSwapchainInfo, textures []*Texture) (VulkanSwapchainInfo, error) {
+	err := vk.Error(vk.DeviceWaitIdle(v.Device))
+	if err != nil {
+		err = fmt.Errorf("vk.DeviceWaitIdle failed with %s", err)
+		return *old, err
+	}
+	s, err := v.createSwapchain(textures, old.DefaultSwapchain())
+	if err != nil {
+		// keep the old swapchain, the caller may try again later.
+		return *old, err
+	}
+	// The old swapchain is retired now, it is safe to destroy it
+	// together with its image views and framebuffers.
+	old.Destroy()
+	return s, nil
+}
+
+func (v *VulkanDeviceInfo) createSwapchain(textures []*Texture, oldSwapchain vk.Swapchain) (VulkanSwapchainInfo, error) {
 	gpu := v.gpuDevices[0]
 
 	var s VulkanSwapchainInfo
-	var descLayout vk.DescriptorSetLayout
-	var bindCount = uint32(1)
-	if (len(textures) > 0) {
-		bindCount += 1
+	if v.Headless {
+		return s, fmt.Errorf("headless devices have no swapchain, render to an OffscreenTarget")
 	}
-
-	ret := vk.CreateDescriptorSetLayout(v.Device, &vk.DescriptorSetLayoutCreateInfo{
-		SType:		vk.StructureTypeDescriptorSetLayoutCreateInfo,
-		BindingCount: bindCount,
-		PBindings: []vk.DescriptorSetLayoutBinding{
-		{
-			Binding: 0,
-			DescriptorType: vk.DescriptorTypeUniformBuffer,
-			DescriptorCount: 1,
-			StageFlags: vk.ShaderStageFlags(vk.ShaderStageVertexBit),
-		}, {
-			Binding:         1,
-			DescriptorType:  vk.DescriptorTypeCombinedImageSampler,
-			DescriptorCount: uint32(len(textures)),
-			StageFlags:      vk.ShaderStageFlags(vk.ShaderStageFragmentBit),
-		}},
-	}, nil, &descLayout)
-	s.DescLayout = descLayout
-	err := vk.Error(ret)
+	descLayout, err := v.Layouts.Get(NewLayoutBuilder().
+		UniformBufferDynamic(0, vk.ShaderStageVertexBit).
+		CombinedImageSamplers(1, uint32(len(textures)), vk.ShaderStageFragmentBit))
 	if err != nil {
-		err = fmt.Errorf("vk.CreateDescriptorSetLayout failed with %s", err)
 		return s, err
 	}
+	s.DescLayout = descLayout
 
 	// Phase 1: vk.GetPhysicalDeviceSurfaceCapabilities
 	//			vk.GetPhysicalDeviceSurfaceFormats
-	var surfaceCapabilities vk.SurfaceCapabilities
-	err = vk.Error(vk.GetPhysicalDeviceSurfaceCapabilities(gpu, v.Surface, &surfaceCapabilities))
+	//			vk.GetPhysicalDeviceSurfacePresentModes
+	var support SurfaceSupport
+	err = vk.Error(vk.GetPhysicalDeviceSurfaceCapabilities(gpu, v.Surface, &support.Capabilities))
 	if err != nil {
 		err = fmt.Errorf("vk.GetPhysicalDeviceSurfaceCapabilities failed with %s", err)
 		return s, err
 	}
+	support.Capabilities.Deref()
 	var formatCount uint32
 	vk.GetPhysicalDeviceSurfaceFormats(gpu, v.Surface, &formatCount, nil)
 	formats := make([]vk.SurfaceFormat, formatCount)
 	vk.GetPhysicalDeviceSurfaceFormats(gpu, v.Surface, &formatCount, formats)
-
-	log.Println("[INFO] got", formatCount, "physical device surface formats")
-
-	chosenFormat := -1
-	for i := 0; i < int(formatCount); i++ {
+	support.Formats = make([]vk.SurfaceFormat, formatCount)
+	for i := range formats {
 		formats[i].Deref()
-		if formats[i].Format == vk.FormatB8g8r8a8Unorm ||
-			formats[i].Format == vk.FormatR8g8b8a8Unorm {
-			chosenFormat = i
-			break
+		support.Formats[i] = vk.SurfaceFormat{
+			Format:     formats[i].Format,
+			ColorSpace: formats[i].ColorSpace,
 		}
+		formats[i].Free()
 	}
-	if chosenFormat < 0 {
-		err := fmt.Errorf("vk.GetPhysicalDeviceSurfaceFormats not found suitable format")
+	var presentModeCount uint32
+	vk.GetPhysicalDeviceSurfacePresentModes(gpu, v.Surface, &presentModeCount, nil)
+	support.PresentModes = make([]vk.PresentMode, presentModeCount)
+	vk.GetPhysicalDeviceSurfacePresentModes(gpu, v.Surface, &presentModeCount, support.PresentModes)
+
+	log.Println("[INFO] got", formatCount, "physical device surface formats")
+
+	cfg, err := SelectSwapchainConfig(v.SwapchainPolicy, support)
+	if err != nil {
+		err = fmt.Errorf("vk.GetPhysicalDeviceSurfaceFormats not found suitable format: %s", err)
 		return s, err
 	}
+	if cfg.FormatFallback {
+		log.Println("[WARN] no surface format of the swapchain policy is supported, falling back to",
+			cfg.SurfaceFormat.Format, "in colorspace", cfg.SurfaceFormat.ColorSpace)
+	}
 
 	// Phase 2: vk.CreateSwapchain
 	//			create a swapchain with supported capabilities and format
 
-	surfaceCapabilities.Deref()
-	s.DisplaySize = surfaceCapabilities.CurrentExtent
-	s.DisplaySize.Deref()
-	s.DisplayFormat = formats[chosenFormat].Format
+	s.DisplaySize = chooseSwapchainExtent(support.Capabilities, v.WindowExtent)
+	if s.DisplaySize.Width == 0 || s.DisplaySize.Height == 0 {
+		err := fmt.Errorf("vk.CreateSwapchain skipped for a minimized surface")
+		return s, err
+	}
+	s.DisplayFormat = cfg.SurfaceFormat.Format
+	s.DisplayColorSpace = cfg.SurfaceFormat.ColorSpace
+	s.FormatFallback = cfg.FormatFallback
+	s.PresentMode = cfg.PresentMode
 	queueFamily := []uint32{0}
+	// Copies from the images make screenshots possible.
+	usage := vk.ImageUsageFlags(vk.ImageUsageColorAttachmentBit)
+	transferSrc := vk.ImageUsageFlags(vk.ImageUsageTransferSrcBit)
+	if support.Capabilities.SupportedUsageFlags&transferSrc != 0 {
+		usage |= transferSrc
+		s.Readable = true
+	}
 	swapchainCreateInfo := vk.SwapchainCreateInfo{
 		SType:           vk.StructureTypeSwapchainCreateInfo,
 		Surface:         v.Surface,
-		MinImageCount:   surfaceCapabilities.MinImageCount,
-		ImageFormat:     formats[chosenFormat].Format,
-		ImageColorSpace: formats[chosenFormat].ColorSpace,
-		ImageExtent:     surfaceCapabilities.CurrentExtent,
-		ImageUsage:      vk.ImageUsageFlags(vk.ImageUsageColorAttachmentBit),
-		PreTransform:    vk.SurfaceTransformIdentityBit,
+		MinImageCount:   cfg.ImageCount,
+		ImageFormat:     cfg.SurfaceFormat.Format,
+		ImageColorSpace: cfg.SurfaceFormat.ColorSpace,
+		ImageExtent:     s.DisplaySize,
+		ImageUsage:      usage,
+		PreTransform:    cfg.PreTransform,
+		CompositeAlpha:  cfg.CompositeAlpha,
 
 		ImageArrayLayers:      1,
 		ImageSharingMode:      vk.SharingModeExclusive,
 		QueueFamilyIndexCount: 1,
 		PQueueFamilyIndices:   queueFamily,
-		PresentMode:           vk.PresentModeFifo,
-		OldSwapchain:          vk.NullSwapchain,
+		PresentMode:           cfg.PresentMode,
+		OldSwapchain:          oldSwapchain,
 		Clipped:               vk.False,
 	}
 	s.Swapchains = make([]vk.Swapchain, 1)
@@ -680,287 +798,44 @@ func (v *VulkanDeviceInfo) CreateSwapchain(uniformData []byte, textures []*Textu
 		err = fmt.Errorf("vk.GetSwapchainImages failed with %s", err)
 		return s, err
 	}
-	var imageCount uint32 = s.SwapchainLen[0];
-	s.UniformBuffer = make([]UniformBuffer, imageCount)
-
-	// create uniform buffer.
-	for i := uint32(0); i < imageCount; i++ {
-		buffer, err := v.CreateUniformBuffers(uniformData);
-		s.UniformBuffer[i].buffer = buffer.buffer;
-		s.UniformBuffer[i].memory = buffer.memory;
-		util.OrPanic(err)
-	}
-
-	for i := range formats {
-		formats[i].Free()
-	}
 	s.Device = v.Device
 	return s, nil
 }
 
+// CreateVertexBuffers creates a device local vertex buffer, the data is
+// uploaded with v.Uploads which has to be flushed before drawing.
 func (v VulkanDeviceInfo) CreateVertexBuffers(data []byte, size uint32) (VulkanBufferInfo, error) {
-	gpu := v.gpuDevices[0]
-
-	// Phase 1: vk.CreateBuffer
-	//			create the triangle vertex buffer
-	queueFamilyIdx := []uint32{0}
-	vertexBufferCreateInfo := vk.BufferCreateInfo{
-		SType:                 vk.StructureTypeBufferCreateInfo,
-		Size:                  vk.DeviceSize(size),
-		Usage:                 vk.BufferUsageFlags(vk.BufferUsageVertexBufferBit),
-		SharingMode:           vk.SharingModeExclusive,
-		QueueFamilyIndexCount: 1,
-		PQueueFamilyIndices:   queueFamilyIdx,
-	}
 	vertexBuffer := VulkanBufferInfo{
-		buffers: make([]vk.Buffer, 1),
+		device: v.Device,
+		memory: v.Memory,
 	}
-	err := vk.Error(vk.CreateBuffer(v.Device, &vertexBufferCreateInfo, nil, &vertexBuffer.buffers[0]))
+	buffer, alloc, err := v.createDeviceBuffer(data[:size], vk.BufferUsageVertexBufferBit,
+		vk.AccessVertexAttributeReadBit, vk.PipelineStageVertexInputBit)
 	if err != nil {
-		err = fmt.Errorf("vk.CreateBuffer failed with %s", err)
 		return vertexBuffer, err
 	}
-
-	// Phase 2: vk.GetBufferMemoryRequirements
-	//			vk.FindMemoryTypeIndex
-	// 			assign a proper memory type for that buffer
-
-	var memReq vk.MemoryRequirements
-	vk.GetBufferMemoryRequirements(v.Device, vertexBuffer.DefaultBuffer(), &memReq)
-	memReq.Deref()
-	allocInfo := vk.MemoryAllocateInfo{
-		SType:           vk.StructureTypeMemoryAllocateInfo,
-		AllocationSize:  memReq.Size,
-		MemoryTypeIndex: 0, // see below
-	}
-	allocInfo.MemoryTypeIndex, _ = vk.FindMemoryTypeIndex(gpu, memReq.MemoryTypeBits,
-		vk.MemoryPropertyHostVisibleBit)
-
-	// Phase 3: vk.AllocateMemory
-	//			vk.MapMemory
-	//			vk.MemCopyFloat32
-	//			vk.UnmapMemory
-	// 			allocate and map memory for that buffer
-
-	var vertexDeviceMemory vk.DeviceMemory
-	err = vk.Error(vk.AllocateMemory(v.Device, &allocInfo, nil, &vertexDeviceMemory))
-	if err != nil {
-		err = fmt.Errorf("vk.AllocateMemory failed with %s", err)
-		return vertexBuffer, err
-	}
-	var vertexDataPtr unsafe.Pointer
-	vk.MapMemory(v.Device, vertexDeviceMemory, 0, vk.DeviceSize(size), 0, &vertexDataPtr)
-	n := vk.Memcopy(vertexDataPtr, data)
-	if n != int(size) {
-		log.Println("[WARN] failed to copy vertex buffer data")
-	}
-	vk.UnmapMemory(v.Device, vertexDeviceMemory)
-
-	// Phase 4: vk.BindBufferMemory
-	//			copy vertex data and bind buffer
-
-	err = vk.Error(vk.BindBufferMemory(v.Device, vertexBuffer.DefaultBuffer(), vertexDeviceMemory, 0))
-	if err != nil {
-		err = fmt.Errorf("vk.BindBufferMemory failed with %s", err)
-		return vertexBuffer, err
-	}
-	vertexBuffer.device = v.Device
-	return vertexBuffer, err
+	vertexBuffer.buffers = []vk.Buffer{buffer}
+	vertexBuffer.allocs = []*memalloc.Allocation{alloc}
+	return vertexBuffer, nil
 }
 
+// CreateIndexBuffers creates a device local index buffer, the data is
+// uploaded with v.Uploads which has to be flushed before drawing.
 func (v VulkanDeviceInfo) CreateIndexBuffers(data []byte, size uint32) (VulkanBufferInfo, error) {
-	gpu := v.gpuDevices[0]
-
-	// Phase 1: vk.CreateBuffer
-	//			create the triangle vertex buffer
-	queueFamilyIdx := []uint32{0}
-	indexBufferCreateInfo := vk.BufferCreateInfo{
-		SType:                 vk.StructureTypeBufferCreateInfo,
-		Size:                  vk.DeviceSize(size),
-		Usage:                 vk.BufferUsageFlags(vk.BufferUsageVertexBufferBit),
-		SharingMode:           vk.SharingModeExclusive,
-		QueueFamilyIndexCount: 1,
-		PQueueFamilyIndices:   queueFamilyIdx,
-	}
 	indexBuffer := VulkanBufferInfo{
-		buffers: make([]vk.Buffer, 1),
+		device: v.Device,
+		memory: v.Memory,
 	}
-	err := vk.Error(vk.CreateBuffer(v.Device, &indexBufferCreateInfo, nil, &indexBuffer.buffers[0]))
+	buffer, alloc, err := v.createDeviceBuffer(data[:size], vk.BufferUsageIndexBufferBit,
+		vk.AccessIndexReadBit, vk.PipelineStageVertexInputBit)
 	if err != nil {
-		err = fmt.Errorf("vk.CreateBuffer failed with %s", err)
 		return indexBuffer, err
 	}
-
-	// Phase 2: vk.GetBufferMemoryRequirements
-	//			vk.FindMemoryTypeIndex
-	// 			assign a proper memory type for that buffer
-
-	var memReq vk.MemoryRequirements
-	vk.GetBufferMemoryRequirements(v.Device, indexBuffer.DefaultBuffer(), &memReq)
-	memReq.Deref()
-	allocInfo := vk.MemoryAllocateInfo{
-		SType:           vk.StructureTypeMemoryAllocateInfo,
-		AllocationSize:  memReq.Size,
-		MemoryTypeIndex: 0, // see below
-	}
-	allocInfo.MemoryTypeIndex, _ = vk.FindMemoryTypeIndex(gpu, memReq.MemoryTypeBits,
-		vk.MemoryPropertyHostVisibleBit)
-
-	// Phase 3: vk.AllocateMemory
-	//			vk.MapMemory
-	//			vk.MemCopyFloat32
-	//			vk.UnmapMemory
-	// 			allocate and map memory for that buffer
-
-	var indexDeviceMemory vk.DeviceMemory
-	err = vk.Error(vk.AllocateMemory(v.Device, &allocInfo, nil, &indexDeviceMemory))
-	if err != nil {
-		err = fmt.Errorf("vk.AllocateMemory failed with %s", err)
-		return indexBuffer, err
-	}
-	var indexDataPtr unsafe.Pointer
-	vk.MapMemory(v.Device, indexDeviceMemory, 0, vk.DeviceSize(size), 0, &indexDataPtr)
-	n := vk.Memcopy(indexDataPtr, data)
-	if n != int(size) {
-		log.Println("[WARN] failed to copy index buffer data")
-	}
-	vk.UnmapMemory(v.Device, indexDeviceMemory)
-
-	// Phase 4: vk.BindBufferMemory
-	//			copy vertex data and bind buffer
-
-	err = vk.Error(vk.BindBufferMemory(v.Device, indexBuffer.DefaultBuffer(), indexDeviceMemory, 0))
-	if err != nil {
-		err = fmt.Errorf("vk.BindBufferMemory failed with %s", err)
-		return indexBuffer, err
-	}
-	indexBuffer.device = v.Device
-	return indexBuffer, err
+	indexBuffer.buffers = []vk.Buffer{buffer}
+	indexBuffer.allocs = []*memalloc.Allocation{alloc}
+	return indexBuffer, nil
 }
 
-func (v VulkanDeviceInfo) CreateTexture(rawData []byte) *Texture {
-	texFormat := vk.FormatR8g8b8a8Unorm
-	_, width, height, err := loadTextureData(rawData, 0)
-	if err != nil {
-		util.OrPanic(err)
-	}
-	tex := &Texture{
-		texWidth:    int32(width),
-		texHeight:   int32(height),
-		imageLayout: vk.ImageLayoutShaderReadOnlyOptimal,
-	}
-
-	var image vk.Image
-	ret := vk.CreateImage(v.Device, &vk.ImageCreateInfo{
-		SType:     vk.StructureTypeImageCreateInfo,
-		ImageType: vk.ImageType2d,
-		Format:    texFormat,
-		Extent: vk.Extent3D{
-			Width:  uint32(width),
-			Height: uint32(height),
-			Depth:  1,
-		},
-		MipLevels:   1,
-		ArrayLayers: 1,
-		Samples:     vk.SampleCount1Bit,
-		Tiling:      vk.ImageTilingLinear,
-		Usage:       vk.ImageUsageFlags(vk.ImageUsageSampledBit),
-		InitialLayout: vk.ImageLayoutPreinitialized,
-	}, nil, &image)
-	util.OrPanic(as.NewError(ret))
-	tex.image = image
-
-	var memReqs vk.MemoryRequirements
-	vk.GetImageMemoryRequirements(v.Device, tex.image, &memReqs)
-	memReqs.Deref()
-
-	var memProps vk.PhysicalDeviceMemoryProperties
-	vk.GetPhysicalDeviceMemoryProperties(v.gpuDevices[0], &memProps)
-	memProps.Deref()
-  memoryProps := vk.MemoryPropertyHostVisibleBit|vk.MemoryPropertyHostCoherentBit
-
-	memTypeIndex, _ := as.FindRequiredMemoryTypeFallback(memProps,
-		vk.MemoryPropertyFlagBits(memReqs.MemoryTypeBits), memoryProps)
-	tex.memAlloc = &vk.MemoryAllocateInfo{
-		SType:           vk.StructureTypeMemoryAllocateInfo,
-		AllocationSize:  memReqs.Size,
-		MemoryTypeIndex: memTypeIndex,
-	}
-	var mem vk.DeviceMemory
-	ret = vk.AllocateMemory(v.Device, tex.memAlloc, nil, &mem)
-	util.OrPanic(as.NewError(ret))
-	tex.mem = mem
-	ret = vk.BindImageMemory(v.Device, tex.image, tex.mem, 0)
-	util.OrPanic(as.NewError(ret))
-
-	hostVisible := memoryProps&vk.MemoryPropertyHostVisibleBit != 0
-	if hostVisible {
-		var layout vk.SubresourceLayout
-		vk.GetImageSubresourceLayout(v.Device, tex.image, &vk.ImageSubresource{
-			AspectMask: vk.ImageAspectFlags(vk.ImageAspectColorBit),
-		}, &layout)
-		layout.Deref()
-
-		data, _, _, err := loadTextureData(rawData, int(layout.RowPitch))
-		util.OrPanic(err)
-		if len(data) > 0 {
-			var pData unsafe.Pointer
-			ret = vk.MapMemory(v.Device, tex.mem, 0, vk.DeviceSize(len(data)), 0, &pData)
-			if util.IsError(ret) {
-				log.Printf("vulkan warning: failed to map device memory for data (len=%d)", len(data))
-				return tex
-			}
-			n := vk.Memcopy(pData, data)
-			if n != len(data) {
-				log.Printf("vulkan warning: failed to copy data, %d != %d", n, len(data))
-			}
-			vk.UnmapMemory(v.Device, tex.mem)
-		}
-	}
-
-	// Create sampler
-	var sampler vk.Sampler
-	ret = vk.CreateSampler(v.Device, &vk.SamplerCreateInfo{
-		SType:					vk.StructureTypeSamplerCreateInfo,
-		MagFilter:			vk.FilterNearest,
-		MinFilter:			vk.FilterNearest,
-		MipmapMode:			vk.SamplerMipmapModeNearest,
-		AddressModeU:		vk.SamplerAddressModeClampToEdge,
-		AddressModeV:		vk.SamplerAddressModeClampToEdge,
-		AddressModeW:		vk.SamplerAddressModeClampToEdge,
-		AnisotropyEnable: vk.False,
-		MaxAnisotropy:	1,
-		CompareOp:			vk.CompareOpNever,
-		BorderColor:		vk.BorderColorFloatOpaqueWhite,
-		UnnormalizedCoordinates: vk.False,
-	}, nil, &sampler)
-	util.OrPanic(as.NewError(ret))
-	tex.sampler = sampler
-
-	// Create image view
-	var view vk.ImageView
-	ret = vk.CreateImageView(v.Device, &vk.ImageViewCreateInfo{
-		SType:    vk.StructureTypeImageViewCreateInfo,
-		Image:    tex.image,
-		ViewType: vk.ImageViewType2d,
-		Format:   texFormat,
-		Components: vk.ComponentMapping{
-			R: vk.ComponentSwizzleR,
-			G: vk.ComponentSwizzleG,
-			B: vk.ComponentSwizzleB,
-			A: vk.ComponentSwizzleA,
-		},
-		SubresourceRange: vk.ImageSubresourceRange{
-			AspectMask: vk.ImageAspectFlags(vk.ImageAspectColorBit),
-			LevelCount: 1,
-			LayerCount: 1,
-		},
-	}, nil, &view)
-
-	return tex
-}
-
-
 // s.setImageLayout(tex.image, vk.ImageAspectColorBit,
 // 	vk.ImageLayoutPreinitialized, tex.imageLayout,
 // 	vk.AccessHostWriteBit,
@@ -1008,22 +883,6 @@ func (v VulkanDeviceInfo) SetImageLayout(tex *Texture, cmdBuffer vk.CommandBuffe
 		0, 0, nil, 0, nil, 1, []vk.ImageMemoryBarrier{imageMemoryBarrier})
 }
 
-func loadTextureData(data []byte, rowPitch int) ([]byte, int, int, error) {
-//	data := MustAsset(name)
-	img, err := jpeg.Decode(bytes.NewReader(data))
-	if err != nil {
-	}
-	newImg := image.NewRGBA(img.Bounds())
-	if rowPitch <= 4*img.Bounds().Dy() {
-		// apply the proposed row pitch only if supported,
-		// as we're using only optimal textures.
-		newImg.Stride = rowPitch
-	}
-	draw.Draw(newImg, newImg.Bounds(), img, image.ZP, draw.Src)
-	size := newImg.Bounds().Size()
-	return []byte(newImg.Pix), size.X, size.Y, nil
-}
-
 // func setImageLayout(image vk.Image, aspectMask vk.ImageAspectFlagBits,
 // 	oldImageLayout, newImageLayout vk.ImageLayout,
 // 	srcAccessMask vk.AccessFlagBits,
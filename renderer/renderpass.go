@@ -0,0 +1,147 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// CreateRenderPass creates a single subpass render pass that clears and
+// presents a swapchain color attachment. If depthFormat is not
+// vk.FormatUndefined a depth attachment is added, with more than one
+// sample the subpass renders to a multisampled color attachment that is
+// resolved into the swapchain image. The attachments are in the order of
+// RenderTargets.Attachments.
+func CreateRenderPass(device vk.Device, colorFormat, depthFormat vk.Format,
+	samples vk.SampleCountFlagBits) (vk.RenderPass, error) {
+
+	return createRenderPass(device, colorFormat, depthFormat, samples, vk.ImageLayoutPresentSrc)
+}
+
+// CreateOffscreenRenderPass is CreateRenderPass for an OffscreenTarget,
+// attachment 0 is left ready to be copied to a buffer.
+func CreateOffscreenRenderPass(device vk.Device, colorFormat, depthFormat vk.Format,
+	samples vk.SampleCountFlagBits) (vk.RenderPass, error) {
+
+	return createRenderPass(device, colorFormat, depthFormat, samples, vk.ImageLayoutTransferSrcOptimal)
+}
+
+func createRenderPass(device vk.Device, colorFormat, depthFormat vk.Format,
+	samples vk.SampleCountFlagBits, finalLayout vk.ImageLayout) (vk.RenderPass, error) {
+
+	if samples == 0 {
+		samples = vk.SampleCount1Bit
+	}
+	multisampled := samples != vk.SampleCount1Bit
+
+	// Attachment 0 is the swapchain image, it is only written by the
+	// resolve when multisampled.
+	displayAttachment := vk.AttachmentDescription{
+		Format:         colorFormat,
+		Samples:        vk.SampleCount1Bit,
+		LoadOp:         vk.AttachmentLoadOpClear,
+		StoreOp:        vk.AttachmentStoreOpStore,
+		StencilLoadOp:  vk.AttachmentLoadOpDontCare,
+		StencilStoreOp: vk.AttachmentStoreOpDontCare,
+		InitialLayout:  vk.ImageLayoutUndefined,
+		FinalLayout:    finalLayout,
+	}
+	if multisampled {
+		displayAttachment.LoadOp = vk.AttachmentLoadOpDontCare
+	}
+	attachmentDescriptions := []vk.AttachmentDescription{displayAttachment}
+	colorAttachments := []vk.AttachmentReference{{
+		Attachment: 0,
+		Layout:     vk.ImageLayoutColorAttachmentOptimal,
+	}}
+	subpassDescriptions := []vk.SubpassDescription{{
+		PipelineBindPoint:    vk.PipelineBindPointGraphics,
+		ColorAttachmentCount: 1,
+		PColorAttachments:    colorAttachments,
+	}}
+
+	// The image acquire semaphore is waited on at the color attachment
+	// stage, the layout transition must not happen before it.
+	dependency := vk.SubpassDependency{
+		SrcSubpass:    vk.SubpassExternal,
+		DstSubpass:    0,
+		SrcStageMask:  vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit),
+		DstStageMask:  vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit),
+		DstAccessMask: vk.AccessFlags(vk.AccessColorAttachmentWriteBit),
+	}
+
+	if depthFormat != vk.FormatUndefined {
+		subpassDescriptions[0].PDepthStencilAttachment = &vk.AttachmentReference{
+			Attachment: uint32(len(attachmentDescriptions)),
+			Layout:     vk.ImageLayoutDepthStencilAttachmentOptimal,
+		}
+		attachmentDescriptions = append(attachmentDescriptions, vk.AttachmentDescription{
+			Format:         depthFormat,
+			Samples:        samples,
+			LoadOp:         vk.AttachmentLoadOpClear,
+			StoreOp:        vk.AttachmentStoreOpDontCare,
+			StencilLoadOp:  vk.AttachmentLoadOpClear,
+			StencilStoreOp: vk.AttachmentStoreOpDontCare,
+			InitialLayout:  vk.ImageLayoutUndefined,
+			FinalLayout:    vk.ImageLayoutDepthStencilAttachmentOptimal,
+		})
+		// A single depth image is shared by all frames in flight, the
+		// clear of this frame has to wait for the previous depth writes.
+		dependency.SrcStageMask |= vk.PipelineStageFlags(vk.PipelineStageLateFragmentTestsBit)
+		dependency.DstStageMask |= vk.PipelineStageFlags(vk.PipelineStageEarlyFragmentTestsBit)
+		dependency.SrcAccessMask |= vk.AccessFlags(vk.AccessDepthStencilAttachmentWriteBit)
+		dependency.DstAccessMask |= vk.AccessFlags(vk.AccessDepthStencilAttachmentWriteBit)
+	}
+
+	if multisampled {
+		// Render to the multisampled target and resolve into attachment 0.
+		subpassDescriptions[0].PResolveAttachments = colorAttachments
+		subpassDescriptions[0].PColorAttachments = []vk.AttachmentReference{{
+			Attachment: uint32(len(attachmentDescriptions)),
+			Layout:     vk.ImageLayoutColorAttachmentOptimal,
+		}}
+		attachmentDescriptions = append(attachmentDescriptions, vk.AttachmentDescription{
+			Format:         colorFormat,
+			Samples:        samples,
+			LoadOp:         vk.AttachmentLoadOpClear,
+			StoreOp:        vk.AttachmentStoreOpDontCare,
+			StencilLoadOp:  vk.AttachmentLoadOpDontCare,
+			StencilStoreOp: vk.AttachmentStoreOpDontCare,
+			InitialLayout:  vk.ImageLayoutUndefined,
+			FinalLayout:    vk.ImageLayoutColorAttachmentOptimal,
+		})
+		// The multisampled target is shared by all frames in flight too,
+		// its clear has to wait for the color writes of the previous frame.
+		dependency.SrcAccessMask |= vk.AccessFlags(vk.AccessColorAttachmentWriteBit)
+	}
+
+	dependencies := []vk.SubpassDependency{dependency}
+	if finalLayout == vk.ImageLayoutTransferSrcOptimal {
+		// Copies recorded after the render pass read the color writes.
+		dependencies = append(dependencies, vk.SubpassDependency{
+			SrcSubpass:    0,
+			DstSubpass:    vk.SubpassExternal,
+			SrcStageMask:  vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit),
+			DstStageMask:  vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+			SrcAccessMask: vk.AccessFlags(vk.AccessColorAttachmentWriteBit),
+			DstAccessMask: vk.AccessFlags(vk.AccessTransferReadBit),
+		})
+	}
+
+	renderPassCreateInfo := vk.RenderPassCreateInfo{
+		SType:           vk.StructureTypeRenderPassCreateInfo,
+		AttachmentCount: uint32(len(attachmentDescriptions)),
+		PAttachments:    attachmentDescriptions,
+		SubpassCount:    1,
+		PSubpasses:      subpassDescriptions,
+		DependencyCount: uint32(len(dependencies)),
+		PDependencies:   dependencies,
+	}
+	var renderPass vk.RenderPass
+	err := vk.Error(vk.CreateRenderPass(device, &renderPassCreateInfo, nil, &renderPass))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateRenderPass failed with %s", err)
+		return renderPass, err
+	}
+	return renderPass, nil
+}
@@ -0,0 +1,170 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// SwapchainSurface reports the extent a swapchain has to be created with.
+// VulkanDeviceInfo implements it on top of the window surface, tests can
+// use a fake one to drive SwapchainLifecycle.
+type SwapchainSurface interface {
+	CurrentExtent() (vk.Extent2D, error)
+}
+
+type SwapchainState int
+
+const (
+	// SwapchainReady means the swapchain matches the surface.
+	SwapchainReady SwapchainState = iota
+	// SwapchainOutOfDate means the swapchain has to be recreated
+	// before the next frame is drawn.
+	SwapchainOutOfDate
+	// SwapchainMinimized means the surface has a zero extent and
+	// nothing can be presented until it grows again.
+	SwapchainMinimized
+)
+
+func (s SwapchainState) String() string {
+	switch s {
+	case SwapchainReady:
+		return "ready"
+	case SwapchainOutOfDate:
+		return "out of date"
+	case SwapchainMinimized:
+		return "minimized"
+	}
+	return fmt.Sprintf("SwapchainState(%d)", int(s))
+}
+
+type FrameAction int
+
+const (
+	// FrameDraw means the current swapchain can be used as is.
+	FrameDraw FrameAction = iota
+	// FrameRecreate means the swapchain and everything depending on
+	// its extent has to be rebuilt before drawing.
+	FrameRecreate
+	// FrameSkip means no frame should be drawn this time.
+	FrameSkip
+)
+
+// SwapchainLifecycle tracks whether the swapchain still matches its surface.
+// Feed it resize notifications and the results of vk.AcquireNextImage and
+// vk.QueuePresent, and ask it before every frame what has to happen.
+type SwapchainLifecycle struct {
+	surface SwapchainSurface
+	state   SwapchainState
+	extent  vk.Extent2D
+}
+
+func NewSwapchainLifecycle(surface SwapchainSurface, extent vk.Extent2D) *SwapchainLifecycle {
+	return &SwapchainLifecycle{
+		surface: surface,
+		state:   SwapchainReady,
+		extent:  extent,
+	}
+}
+
+func (l *SwapchainLifecycle) State() SwapchainState {
+	return l.state
+}
+
+// Extent returns the extent of the swapchain currently in use.
+func (l *SwapchainLifecycle) Extent() vk.Extent2D {
+	return l.extent
+}
+
+// Invalidate marks the swapchain as out of date, e.g. from a window
+// resize callback.
+func (l *SwapchainLifecycle) Invalidate() {
+	l.state = SwapchainOutOfDate
+}
+
+// Observe inspects the result of vk.AcquireNextImage or vk.QueuePresent.
+// It returns false if the current frame has to be abandoned, the error is
+// non-nil only for results that are not related to the surface.
+func (l *SwapchainLifecycle) Observe(ret vk.Result) (bool, error) {
+	switch ret {
+	case vk.Success:
+		return true, nil
+	case vk.Suboptimal:
+		// The image is still usable, finish this frame and
+		// recreate before the next one.
+		l.state = SwapchainOutOfDate
+		return true, nil
+	case vk.ErrorOutOfDate:
+		l.state = SwapchainOutOfDate
+		return false, nil
+	}
+	return false, vk.Error(ret)
+}
+
+// Prepare tells what has to happen before drawing the next frame.
+// On FrameRecreate the caller rebuilds the swapchain and reports back
+// with Recreated.
+func (l *SwapchainLifecycle) Prepare() (FrameAction, error) {
+	if l.state == SwapchainReady {
+		return FrameDraw, nil
+	}
+	extent, err := l.surface.CurrentExtent()
+	if err != nil {
+		return FrameSkip, err
+	}
+	if extent.Width == 0 || extent.Height == 0 {
+		l.state = SwapchainMinimized
+		return FrameSkip, nil
+	}
+	l.state = SwapchainOutOfDate
+	return FrameRecreate, nil
+}
+
+// Recreated records that a swapchain with the given extent is in use now.
+func (l *SwapchainLifecycle) Recreated(extent vk.Extent2D) {
+	l.extent = extent
+	l.state = SwapchainReady
+}
+
+// CurrentExtent returns the extent a swapchain created right now would have.
+func (v *VulkanDeviceInfo) CurrentExtent() (vk.Extent2D, error) {
+	var surfaceCapabilities vk.SurfaceCapabilities
+	err := vk.Error(vk.GetPhysicalDeviceSurfaceCapabilities(v.gpuDevices[0], v.Surface, &surfaceCapabilities))
+	if err != nil {
+		err = fmt.Errorf("vk.GetPhysicalDeviceSurfaceCapabilities failed with %s", err)
+		return vk.Extent2D{}, err
+	}
+	surfaceCapabilities.Deref()
+	return chooseSwapchainExtent(surfaceCapabilities, v.WindowExtent), nil
+}
+
+// chooseSwapchainExtent uses the surface extent if the surface defines one,
+// otherwise the window extent clamped to the supported range. A window
+// with a zero side is minimized and keeps a zero extent.
+func chooseSwapchainExtent(caps vk.SurfaceCapabilities, window vk.Extent2D) vk.Extent2D {
+	extent := caps.CurrentExtent
+	extent.Deref()
+	if extent.Width != vk.MaxUint32 {
+		return extent
+	}
+	if window.Width == 0 || window.Height == 0 {
+		return vk.Extent2D{}
+	}
+	minExtent, maxExtent := caps.MinImageExtent, caps.MaxImageExtent
+	minExtent.Deref()
+	maxExtent.Deref()
+	return vk.Extent2D{
+		Width:  clampUint32(window.Width, minExtent.Width, maxExtent.Width),
+		Height: clampUint32(window.Height, minExtent.Height, maxExtent.Height),
+	}
+}
+
+func clampUint32(v, min, max uint32) uint32 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,192 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+var (
+	// SurfaceFormatsUnorm are the 8-bit linear formats the samples used so far.
+	SurfaceFormatsUnorm = []vk.SurfaceFormat{
+		{Format: vk.FormatB8g8r8a8Unorm, ColorSpace: vk.ColorSpaceSrgbNonlinear},
+		{Format: vk.FormatR8g8b8a8Unorm, ColorSpace: vk.ColorSpaceSrgbNonlinear},
+	}
+	// SurfaceFormatsSrgb let the hardware encode the shader output to sRGB.
+	SurfaceFormatsSrgb = []vk.SurfaceFormat{
+		{Format: vk.FormatB8g8r8a8Srgb, ColorSpace: vk.ColorSpaceSrgbNonlinear},
+		{Format: vk.FormatR8g8b8a8Srgb, ColorSpace: vk.ColorSpaceSrgbNonlinear},
+	}
+	// SurfaceFormatsHDR10 are 10-bit formats with the ST.2084 (PQ) transfer function.
+	// Surfaces only offer it and scRGB with VK_EXT_swapchain_colorspace,
+	// which NewVulkanDevice enables when the instance supports it.
+	SurfaceFormatsHDR10 = []vk.SurfaceFormat{
+		{Format: vk.FormatA2b10g10r10UnormPack32, ColorSpace: vk.ColorSpaceHdr10St2084},
+		{Format: vk.FormatA2r10g10b10UnormPack32, ColorSpace: vk.ColorSpaceHdr10St2084},
+	}
+	// SurfaceFormatsScRGB is the linear extended sRGB half float format.
+	SurfaceFormatsScRGB = []vk.SurfaceFormat{
+		{Format: vk.FormatR16g16b16a16Sfloat, ColorSpace: vk.ColorSpaceExtendedSrgbLinear},
+	}
+)
+
+// SwapchainPolicy lists the swapchain properties an application prefers.
+// Every list is ordered by preference and the zero value of any field
+// falls back to what DefaultSwapchainPolicy picks.
+type SwapchainPolicy struct {
+	// PresentModes are tried in order, vk.PresentModeFifo is used
+	// when none of them is supported since it is always available.
+	PresentModes []vk.PresentMode
+	// SurfaceFormats are matched on both format and colorspace.
+	SurfaceFormats []vk.SurfaceFormat
+	// ImageCount is the number of images to ask for, it is clamped
+	// to the surface limits. Zero asks for one more than the minimum.
+	ImageCount uint32
+	// CompositeAlpha modes are tried in order, the first supported
+	// one of the surface is used otherwise.
+	CompositeAlpha []vk.CompositeAlphaFlagBits
+	// PreTransform is used when supported, otherwise identity or
+	// the current transform of the surface.
+	PreTransform vk.SurfaceTransformFlagBits
+}
+
+// DefaultSwapchainPolicy is vsync'ed FIFO presentation of an 8-bit UNORM format.
+func DefaultSwapchainPolicy() SwapchainPolicy {
+	return SwapchainPolicy{
+		PresentModes:   []vk.PresentMode{vk.PresentModeFifo},
+		SurfaceFormats: SurfaceFormatsUnorm,
+		CompositeAlpha: []vk.CompositeAlphaFlagBits{vk.CompositeAlphaOpaqueBit},
+		PreTransform:   vk.SurfaceTransformIdentityBit,
+	}
+}
+
+// SurfaceSupport is what a surface supports for a physical device.
+type SurfaceSupport struct {
+	Capabilities vk.SurfaceCapabilities
+	Formats      []vk.SurfaceFormat
+	PresentModes []vk.PresentMode
+}
+
+// SwapchainConfig is the outcome of SelectSwapchainConfig.
+type SwapchainConfig struct {
+	SurfaceFormat  vk.SurfaceFormat
+	PresentMode    vk.PresentMode
+	ImageCount     uint32
+	CompositeAlpha vk.CompositeAlphaFlagBits
+	PreTransform   vk.SurfaceTransformFlagBits
+	// FormatFallback is set when the surface supports none of the
+	// policy's formats and SurfaceFormat is a plain UNORM or sRGB one,
+	// so that the application can change the encoding of its output.
+	FormatFallback bool
+}
+
+// SelectSwapchainConfig picks the swapchain configuration for a policy
+// from what the surface supports. It doesn't touch Vulkan, capabilities
+// are expected to be dereferenced already.
+func SelectSwapchainConfig(policy SwapchainPolicy, support SurfaceSupport) (SwapchainConfig, error) {
+	var cfg SwapchainConfig
+	defaults := DefaultSwapchainPolicy()
+	if len(policy.SurfaceFormats) == 0 {
+		policy.SurfaceFormats = defaults.SurfaceFormats
+	}
+
+	format, fallback, ok := selectSurfaceFormat(policy.SurfaceFormats, support.Formats)
+	if !ok {
+		err := fmt.Errorf("no supported surface format among %d formats of the surface", len(support.Formats))
+		return cfg, err
+	}
+	cfg.SurfaceFormat, cfg.FormatFallback = format, fallback
+	cfg.PresentMode = selectPresentMode(policy.PresentModes, support.PresentModes)
+	cfg.ImageCount = selectImageCount(policy.ImageCount, support.Capabilities)
+	cfg.CompositeAlpha = selectCompositeAlpha(policy.CompositeAlpha, support.Capabilities.SupportedCompositeAlpha)
+	cfg.PreTransform = selectPreTransform(policy.PreTransform, support.Capabilities)
+	return cfg, nil
+}
+
+func selectSurfaceFormat(preferred, supported []vk.SurfaceFormat) (format vk.SurfaceFormat, fallback, ok bool) {
+	if len(supported) == 0 {
+		return vk.SurfaceFormat{}, false, false
+	}
+	// A single undefined format means the surface has no preference at all.
+	if len(supported) == 1 && supported[0].Format == vk.FormatUndefined {
+		return preferred[0], false, true
+	}
+	for _, want := range preferred {
+		for _, have := range supported {
+			if want.Format == have.Format && want.ColorSpace == have.ColorSpace {
+				return have, false, true
+			}
+		}
+	}
+	// Fall back to the plain UNORM formats, then to whatever sRGB
+	// nonlinear format the surface offers first.
+	for _, want := range SurfaceFormatsUnorm {
+		for _, have := range supported {
+			if want.Format == have.Format && want.ColorSpace == have.ColorSpace {
+				return have, true, true
+			}
+		}
+	}
+	for _, have := range supported {
+		if have.ColorSpace == vk.ColorSpaceSrgbNonlinear && have.Format != vk.FormatUndefined {
+			return have, true, true
+		}
+	}
+	return vk.SurfaceFormat{}, false, false
+}
+
+func selectPresentMode(preferred, supported []vk.PresentMode) vk.PresentMode {
+	for _, want := range preferred {
+		for _, have := range supported {
+			if want == have {
+				return have
+			}
+		}
+	}
+	return vk.PresentModeFifo
+}
+
+func selectImageCount(requested uint32, caps vk.SurfaceCapabilities) uint32 {
+	count := requested
+	if count == 0 {
+		count = caps.MinImageCount + 1
+	}
+	if count < caps.MinImageCount {
+		count = caps.MinImageCount
+	}
+	// MaxImageCount of zero means there is no limit.
+	if caps.MaxImageCount > 0 && count > caps.MaxImageCount {
+		count = caps.MaxImageCount
+	}
+	return count
+}
+
+func selectCompositeAlpha(preferred []vk.CompositeAlphaFlagBits, supported vk.CompositeAlphaFlags) vk.CompositeAlphaFlagBits {
+	for _, want := range preferred {
+		if supported&vk.CompositeAlphaFlags(want) != 0 {
+			return want
+		}
+	}
+	for _, bit := range []vk.CompositeAlphaFlagBits{
+		vk.CompositeAlphaOpaqueBit,
+		vk.CompositeAlphaPreMultipliedBit,
+		vk.CompositeAlphaPostMultipliedBit,
+		vk.CompositeAlphaInheritBit,
+	} {
+		if supported&vk.CompositeAlphaFlags(bit) != 0 {
+			return bit
+		}
+	}
+	return vk.CompositeAlphaOpaqueBit
+}
+
+func selectPreTransform(preferred vk.SurfaceTransformFlagBits, caps vk.SurfaceCapabilities) vk.SurfaceTransformFlagBits {
+	supported := caps.SupportedTransforms
+	if preferred != 0 && supported&vk.SurfaceTransformFlags(preferred) != 0 {
+		return preferred
+	}
+	if supported&vk.SurfaceTransformFlags(vk.SurfaceTransformIdentityBit) != 0 {
+		return vk.SurfaceTransformIdentityBit
+	}
+	return caps.CurrentTransform
+}
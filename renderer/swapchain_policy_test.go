@@ -0,0 +1,125 @@
+package renderer
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestSelectSwapchainConfig(t *testing.T) {
+	bgraUnorm := vk.SurfaceFormat{Format: vk.FormatB8g8r8a8Unorm, ColorSpace: vk.ColorSpaceSrgbNonlinear}
+	bgraSrgb := vk.SurfaceFormat{Format: vk.FormatB8g8r8a8Srgb, ColorSpace: vk.ColorSpaceSrgbNonlinear}
+	hdr10 := SurfaceFormatsHDR10[0]
+	rgb10 := vk.SurfaceFormat{Format: vk.FormatA2b10g10r10UnormPack32, ColorSpace: vk.ColorSpaceSrgbNonlinear}
+	caps := vk.SurfaceCapabilities{
+		MinImageCount:           2,
+		MaxImageCount:           3,
+		SupportedTransforms:     vk.SurfaceTransformFlags(vk.SurfaceTransformIdentityBit),
+		CurrentTransform:        vk.SurfaceTransformIdentityBit,
+		SupportedCompositeAlpha: vk.CompositeAlphaFlags(vk.CompositeAlphaOpaqueBit),
+	}
+	support := SurfaceSupport{
+		Capabilities: caps,
+		Formats:      []vk.SurfaceFormat{bgraSrgb, bgraUnorm},
+		PresentModes: []vk.PresentMode{vk.PresentModeFifo, vk.PresentModeMailbox},
+	}
+	withPolicy := func(f func(*SwapchainPolicy)) SwapchainPolicy {
+		p := DefaultSwapchainPolicy()
+		f(&p)
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		policy  SwapchainPolicy
+		support SurfaceSupport
+		want    SwapchainConfig
+	}{{
+		name:    "default",
+		policy:  DefaultSwapchainPolicy(),
+		support: support,
+		want:    SwapchainConfig{bgraUnorm, vk.PresentModeFifo, 3, vk.CompositeAlphaOpaqueBit, vk.SurfaceTransformIdentityBit, false},
+	}, {
+		name: "sRGB and mailbox",
+		policy: withPolicy(func(p *SwapchainPolicy) {
+			p.SurfaceFormats, p.PresentModes = SurfaceFormatsSrgb, []vk.PresentMode{vk.PresentModeMailbox}
+		}),
+		support: support,
+		want:    SwapchainConfig{bgraSrgb, vk.PresentModeMailbox, 3, vk.CompositeAlphaOpaqueBit, vk.SurfaceTransformIdentityBit, false},
+	}, {
+		name:    "unsupported present mode falls back to FIFO",
+		policy:  withPolicy(func(p *SwapchainPolicy) { p.PresentModes = []vk.PresentMode{vk.PresentModeImmediate} }),
+		support: support,
+		want:    SwapchainConfig{bgraUnorm, vk.PresentModeFifo, 3, vk.CompositeAlphaOpaqueBit, vk.SurfaceTransformIdentityBit, false},
+	}, {
+		name:    "HDR10 without extended colorspaces falls back to UNORM",
+		policy:  withPolicy(func(p *SwapchainPolicy) { p.SurfaceFormats = SurfaceFormatsHDR10 }),
+		support: support,
+		want:    SwapchainConfig{bgraUnorm, vk.PresentModeFifo, 3, vk.CompositeAlphaOpaqueBit, vk.SurfaceTransformIdentityBit, true},
+	}, {
+		name:   "sRGB falls back to UNORM",
+		policy: withPolicy(func(p *SwapchainPolicy) { p.SurfaceFormats = SurfaceFormatsSrgb }),
+		support: SurfaceSupport{
+			Capabilities: caps,
+			Formats:      []vk.SurfaceFormat{hdr10, bgraUnorm},
+		},
+		want: SwapchainConfig{bgraUnorm, vk.PresentModeFifo, 3, vk.CompositeAlphaOpaqueBit, vk.SurfaceTransformIdentityBit, true},
+	}, {
+		name:   "sRGB falls back to the first nonlinear format",
+		policy: withPolicy(func(p *SwapchainPolicy) { p.SurfaceFormats = SurfaceFormatsSrgb }),
+		support: SurfaceSupport{
+			Capabilities: caps,
+			Formats:      []vk.SurfaceFormat{hdr10, rgb10},
+		},
+		want: SwapchainConfig{rgb10, vk.PresentModeFifo, 3, vk.CompositeAlphaOpaqueBit, vk.SurfaceTransformIdentityBit, true},
+	}, {
+		name:   "HDR10",
+		policy: withPolicy(func(p *SwapchainPolicy) { p.SurfaceFormats = SurfaceFormatsHDR10 }),
+		support: SurfaceSupport{
+			Capabilities: caps,
+			Formats:      []vk.SurfaceFormat{bgraUnorm, hdr10},
+		},
+		want: SwapchainConfig{hdr10, vk.PresentModeFifo, 3, vk.CompositeAlphaOpaqueBit, vk.SurfaceTransformIdentityBit, false},
+	}, {
+		name:   "undefined format means any",
+		policy: withPolicy(func(p *SwapchainPolicy) { p.SurfaceFormats = SurfaceFormatsSrgb }),
+		support: SurfaceSupport{
+			Capabilities: caps,
+			Formats:      []vk.SurfaceFormat{{Format: vk.FormatUndefined}},
+		},
+		want: SwapchainConfig{bgraSrgb, vk.PresentModeFifo, 3, vk.CompositeAlphaOpaqueBit, vk.SurfaceTransformIdentityBit, false},
+	}, {
+		name:    "image count clamped to the maximum",
+		policy:  withPolicy(func(p *SwapchainPolicy) { p.ImageCount = 8 }),
+		support: support,
+		want:    SwapchainConfig{bgraUnorm, vk.PresentModeFifo, 3, vk.CompositeAlphaOpaqueBit, vk.SurfaceTransformIdentityBit, false},
+	}, {
+		name:   "no maximum image count",
+		policy: withPolicy(func(p *SwapchainPolicy) { p.ImageCount = 8 }),
+		support: SurfaceSupport{
+			Capabilities: vk.SurfaceCapabilities{
+				MinImageCount:           2,
+				SupportedTransforms:     vk.SurfaceTransformFlags(vk.SurfaceTransformRotate90Bit),
+				CurrentTransform:        vk.SurfaceTransformRotate90Bit,
+				SupportedCompositeAlpha: vk.CompositeAlphaFlags(vk.CompositeAlphaInheritBit),
+			},
+			Formats: []vk.SurfaceFormat{bgraUnorm},
+		},
+		want: SwapchainConfig{bgraUnorm, vk.PresentModeFifo, 8, vk.CompositeAlphaInheritBit, vk.SurfaceTransformRotate90Bit, false},
+	}}
+	for _, test := range tests {
+		got, err := SelectSwapchainConfig(test.policy, test.support)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: SelectSwapchainConfig = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	_, err := SelectSwapchainConfig(DefaultSwapchainPolicy(), SurfaceSupport{Capabilities: caps})
+	if err == nil {
+		t.Errorf("surface without formats: SelectSwapchainConfig succeeded")
+	}
+}
@@ -0,0 +1,98 @@
+package renderer
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+type fakeSurface struct {
+	extent vk.Extent2D
+}
+
+func (s *fakeSurface) CurrentExtent() (vk.Extent2D, error) {
+	return s.extent, nil
+}
+
+func TestSwapchainLifecycleObserve(t *testing.T) {
+	tests := []struct {
+		ret      vk.Result
+		keep     bool
+		state    SwapchainState
+		hasError bool
+	}{
+		{vk.Success, true, SwapchainReady, false},
+		{vk.Suboptimal, true, SwapchainOutOfDate, false},
+		{vk.ErrorOutOfDate, false, SwapchainOutOfDate, false},
+		{vk.ErrorDeviceLost, false, SwapchainReady, true},
+	}
+	for _, test := range tests {
+		l := NewSwapchainLifecycle(&fakeSurface{vk.Extent2D{Width: 640, Height: 480}}, vk.Extent2D{Width: 640, Height: 480})
+		keep, err := l.Observe(test.ret)
+		if keep != test.keep || l.State() != test.state || (err != nil) != test.hasError {
+			t.Errorf("Observe(%d) = %v, %v with state %s, want %v, error %v with state %s",
+				test.ret, keep, err, l.State(), test.keep, test.hasError, test.state)
+		}
+	}
+}
+
+func TestSwapchainLifecyclePrepare(t *testing.T) {
+	surface := &fakeSurface{vk.Extent2D{Width: 640, Height: 480}}
+	l := NewSwapchainLifecycle(surface, surface.extent)
+	if action, _ := l.Prepare(); action != FrameDraw {
+		t.Fatalf("ready swapchain: Prepare() = %d, want FrameDraw", action)
+	}
+
+	// Minimizing gives a 0x0 surface: skip frames until it grows again.
+	surface.extent = vk.Extent2D{}
+	l.Invalidate()
+	for i := 0; i < 2; i++ {
+		if action, _ := l.Prepare(); action != FrameSkip || l.State() != SwapchainMinimized {
+			t.Fatalf("minimized: Prepare() = %d with state %s, want FrameSkip while minimized", action, l.State())
+		}
+	}
+	surface.extent = vk.Extent2D{Width: 800, Height: 0}
+	if action, _ := l.Prepare(); action != FrameSkip {
+		t.Fatalf("zero height: Prepare() = %d, want FrameSkip", action)
+	}
+
+	surface.extent = vk.Extent2D{Width: 800, Height: 600}
+	if action, _ := l.Prepare(); action != FrameRecreate {
+		t.Fatalf("restored: Prepare() = %d, want FrameRecreate", action)
+	}
+	l.Recreated(surface.extent)
+	if action, _ := l.Prepare(); action != FrameDraw || l.Extent() != surface.extent {
+		t.Fatalf("recreated: Prepare() = %d with extent %v, want FrameDraw with %v", action, l.Extent(), surface.extent)
+	}
+}
+
+func TestChooseSwapchainExtent(t *testing.T) {
+	caps := func(current vk.Extent2D) vk.SurfaceCapabilities {
+		return vk.SurfaceCapabilities{
+			CurrentExtent:  current,
+			MinImageExtent: vk.Extent2D{Width: 16, Height: 16},
+			MaxImageExtent: vk.Extent2D{Width: 4096, Height: 2048},
+		}
+	}
+	undefined := vk.Extent2D{Width: vk.MaxUint32, Height: vk.MaxUint32}
+	tests := []struct {
+		name    string
+		current vk.Extent2D
+		window  vk.Extent2D
+		want    vk.Extent2D
+	}{
+		{"surface extent", vk.Extent2D{Width: 1280, Height: 720}, vk.Extent2D{Width: 640, Height: 480}, vk.Extent2D{Width: 1280, Height: 720}},
+		{"minimized surface", vk.Extent2D{}, vk.Extent2D{Width: 640, Height: 480}, vk.Extent2D{}},
+		{"window extent", undefined, vk.Extent2D{Width: 640, Height: 480}, vk.Extent2D{Width: 640, Height: 480}},
+		{"minimized window", undefined, vk.Extent2D{Width: 0, Height: 480}, vk.Extent2D{}},
+		{"minimized window height", undefined, vk.Extent2D{Width: 640, Height: 0}, vk.Extent2D{}},
+		{"clamped to min", undefined, vk.Extent2D{Width: 4, Height: 8}, vk.Extent2D{Width: 16, Height: 16}},
+		{"clamped to max", undefined, vk.Extent2D{Width: 8192, Height: 8192}, vk.Extent2D{Width: 4096, Height: 2048}},
+	}
+	for _, test := range tests {
+		got := chooseSwapchainExtent(caps(test.current), test.window)
+		if got != test.want {
+			t.Errorf("%s: chooseSwapchainExtent = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
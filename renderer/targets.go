@@ -0,0 +1,296 @@
+package renderer
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/gpu"
+	"github.com/vulkan-samples/memalloc"
+)
+
+// attachmentImage is an optimally tiled image with its own memory and a
+// view on it, used as a framebuffer attachment.
+type attachmentImage struct {
+	device  vk.Device
+	format  vk.Format
+	extent  vk.Extent2D
+	samples vk.SampleCountFlagBits
+
+	image  vk.Image
+	memory *MemoryAllocator
+	alloc  *memalloc.Allocation
+	view   vk.ImageView
+}
+
+func (a *attachmentImage) Format() vk.Format {
+	return a.format
+}
+
+func (a *attachmentImage) Extent() vk.Extent2D {
+	return a.extent
+}
+
+func (a *attachmentImage) Samples() vk.SampleCountFlagBits {
+	return a.samples
+}
+
+func (v *VulkanDeviceInfo) createAttachmentImage(format vk.Format, extent vk.Extent2D,
+	samples vk.SampleCountFlagBits, usage vk.ImageUsageFlags, aspect vk.ImageAspectFlags) (attachmentImage, error) {
+
+	if samples == 0 {
+		samples = vk.SampleCount1Bit
+	}
+	a := attachmentImage{
+		device:  v.Device,
+		format:  format,
+		extent:  extent,
+		samples: samples,
+	}
+
+	// Phase 1: vk.CreateImage
+	//			create an optimally tiled image
+
+	imageCreateInfo := vk.ImageCreateInfo{
+		SType:     vk.StructureTypeImageCreateInfo,
+		ImageType: vk.ImageType2d,
+		Format:    format,
+		Extent: vk.Extent3D{
+			Width:  extent.Width,
+			Height: extent.Height,
+			Depth:  1,
+		},
+		MipLevels:     1,
+		ArrayLayers:   1,
+		Samples:       samples,
+		Tiling:        vk.ImageTilingOptimal,
+		Usage:         usage,
+		SharingMode:   vk.SharingModeExclusive,
+		InitialLayout: vk.ImageLayoutUndefined,
+	}
+	err := vk.Error(vk.CreateImage(v.Device, &imageCreateInfo, nil, &a.image))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateImage failed with %s", err)
+		return a, err
+	}
+
+	// Phase 2: sub-allocate device local memory and bind the image
+	//			transient attachments prefer lazily allocated memory
+
+	preferred := vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit)
+	if usage&vk.ImageUsageFlags(vk.ImageUsageTransientAttachmentBit) != 0 {
+		preferred |= vk.MemoryPropertyFlags(vk.MemoryPropertyLazilyAllocatedBit)
+	}
+	a.memory = v.Memory
+	a.alloc, err = v.Memory.AllocateImage(a.image, vk.ImageTilingOptimal,
+		vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit), preferred)
+	if err != nil {
+		a.Destroy()
+		return a, err
+	}
+
+	// Phase 3: vk.CreateImageView
+
+	viewCreateInfo := vk.ImageViewCreateInfo{
+		SType:    vk.StructureTypeImageViewCreateInfo,
+		Image:    a.image,
+		ViewType: vk.ImageViewType2d,
+		Format:   format,
+		SubresourceRange: vk.ImageSubresourceRange{
+			AspectMask: aspect,
+			LevelCount: 1,
+			LayerCount: 1,
+		},
+	}
+	err = vk.Error(vk.CreateImageView(v.Device, &viewCreateInfo, nil, &a.view))
+	if err != nil {
+		a.Destroy()
+		err = fmt.Errorf("vk.CreateImageView failed with %s", err)
+		return a, err
+	}
+	return a, nil
+}
+
+func (a *attachmentImage) Destroy() {
+	vk.DestroyImageView(a.device, a.view, nil)
+	vk.DestroyImage(a.device, a.image, nil)
+	a.memory.Free(a.alloc)
+	a.view = vk.NullImageView
+	a.image = vk.NullImage
+	a.alloc = nil
+}
+
+// ColorTarget is a multisampled color attachment that is resolved into
+// the swapchain image at the end of the render pass.
+type ColorTarget struct {
+	attachmentImage
+}
+
+func (c *ColorTarget) View() vk.ImageView {
+	if c == nil {
+		return vk.NullImageView
+	}
+	return c.view
+}
+
+// CreateColorTarget creates a transient color attachment, its content
+// never leaves the GPU tile memory on hardware that supports it.
+func (v *VulkanDeviceInfo) CreateColorTarget(format vk.Format, extent vk.Extent2D,
+	samples vk.SampleCountFlagBits) (*ColorTarget, error) {
+
+	usage := vk.ImageUsageFlags(vk.ImageUsageColorAttachmentBit) |
+		vk.ImageUsageFlags(vk.ImageUsageTransientAttachmentBit)
+	img, err := v.createAttachmentImage(format, extent, samples, usage,
+		vk.ImageAspectFlags(vk.ImageAspectColorBit))
+	if err != nil {
+		return nil, err
+	}
+	return &ColorTarget{img}, nil
+}
+
+func (c *ColorTarget) Destroy() {
+	if c == nil {
+		return
+	}
+	c.attachmentImage.Destroy()
+}
+
+// RenderTargets are the attachments rendered to besides the swapchain image.
+// The attachment order matches CreateRenderPass: the swapchain image,
+// then depth if any, then the multisampled color target if any.
+type RenderTargets struct {
+	Samples vk.SampleCountFlagBits
+	// Color is nil when rendering directly to the swapchain image.
+	Color *ColorTarget
+	// Depth is nil without a depth attachment.
+	Depth *DepthBuffer
+}
+
+// CreateRenderTargets creates the attachments for a swapchain of the given
+// format and extent. depthFormat may be vk.FormatUndefined for no depth,
+// a sample count above one adds a multisampled color target.
+func (v *VulkanDeviceInfo) CreateRenderTargets(colorFormat, depthFormat vk.Format, extent vk.Extent2D,
+	samples vk.SampleCountFlagBits) (*RenderTargets, error) {
+
+	if samples == 0 {
+		samples = vk.SampleCount1Bit
+	}
+	t := &RenderTargets{
+		Samples: samples,
+	}
+	var err error
+	if depthFormat != vk.FormatUndefined {
+		t.Depth, err = v.CreateDepthBuffer(depthFormat, extent, samples)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if samples != vk.SampleCount1Bit {
+		t.Color, err = v.CreateColorTarget(colorFormat, extent, samples)
+		if err != nil {
+			t.Destroy()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// RecreateRenderTargets creates the targets again for a new swapchain.
+// On error the old targets are kept.
+func (v *VulkanDeviceInfo) RecreateRenderTargets(old *RenderTargets, colorFormat vk.Format,
+	extent vk.Extent2D) (*RenderTargets, error) {
+
+	t, err := v.CreateRenderTargets(colorFormat, old.DepthFormat(), extent, old.Samples)
+	if err != nil {
+		return old, err
+	}
+	old.Destroy()
+	return t, nil
+}
+
+// DepthFormat returns vk.FormatUndefined if there is no depth attachment.
+func (t *RenderTargets) DepthFormat() vk.Format {
+	if t == nil || t.Depth == nil {
+		return vk.FormatUndefined
+	}
+	return t.Depth.Format()
+}
+
+func (t *RenderTargets) SampleCount() vk.SampleCountFlagBits {
+	if t == nil || t.Samples == 0 {
+		return vk.SampleCount1Bit
+	}
+	return t.Samples
+}
+
+// Attachments returns the framebuffer attachments for a swapchain image view.
+func (t *RenderTargets) Attachments(displayView vk.ImageView) []vk.ImageView {
+	attachments := []vk.ImageView{displayView}
+	if t == nil {
+		return attachments
+	}
+	if t.Depth != nil {
+		attachments = append(attachments, t.Depth.View())
+	}
+	if t.Color != nil {
+		attachments = append(attachments, t.Color.View())
+	}
+	return attachments
+}
+
+// ClearValues returns one clear value per attachment, with depth cleared
+// to the far plane.
+func (t *RenderTargets) ClearValues(color gpu.ClearValue) []gpu.ClearValue {
+	clear := []gpu.ClearValue{color}
+	if t == nil {
+		return clear
+	}
+	if t.Depth != nil {
+		clear = append(clear, gpu.ClearDepth(1.0, 0))
+	}
+	if t.Color != nil {
+		clear = append(clear, color)
+	}
+	return clear
+}
+
+func (t *RenderTargets) Destroy() {
+	if t == nil {
+		return
+	}
+	t.Color.Destroy()
+	t.Depth.Destroy()
+	t.Color = nil
+	t.Depth = nil
+}
+
+// FrameTarget is what frames render into: the images of a swapchain or of
+// an OffscreenTarget. Draw code that takes a FrameTarget works with both.
+type FrameTarget interface {
+	Extent() vk.Extent2D
+	Format() vk.Format
+	ImageCount() uint32
+	// Framebuffer returns the framebuffer of the image a frame renders to,
+	// Frame.ImageIndex.
+	Framebuffer(image uint32) vk.Framebuffer
+}
+
+var (
+	_ FrameTarget = (*VulkanSwapchainInfo)(nil)
+	_ FrameTarget = (*OffscreenTarget)(nil)
+)
+
+func (s *VulkanSwapchainInfo) Extent() vk.Extent2D {
+	return s.DisplaySize
+}
+
+func (s *VulkanSwapchainInfo) Format() vk.Format {
+	return s.DisplayFormat
+}
+
+func (s *VulkanSwapchainInfo) ImageCount() uint32 {
+	return s.DefaultSwapchainLen()
+}
+
+func (s *VulkanSwapchainInfo) Framebuffer(image uint32) vk.Framebuffer {
+	return s.Framebuffers[image]
+}
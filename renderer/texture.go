@@ -0,0 +1,273 @@
+package renderer
+
+import (
+	"fmt"
+	"log"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/texture"
+)
+
+// TextureSlots is a set of the textures a glTF material binds.
+type TextureSlots uint32
+
+const (
+	TextureBaseColor TextureSlots = 1 << iota
+	TextureMetallicRoughness
+	TextureNormal
+	TextureOcclusion
+	TextureEmissive
+)
+
+// SlotColorSpace is the color space glTF stores the textures of a slot in:
+// sRGB for baseColor and emissive, linear for the others.
+func SlotColorSpace(slot TextureSlots) texture.ColorSpace {
+	if slot == TextureBaseColor || slot == TextureEmissive {
+		return texture.SRGB
+	}
+	return texture.Linear
+}
+
+// optimalFeatures returns the features of format with optimal tiling.
+func (v VulkanDeviceInfo) optimalFeatures(format vk.Format) vk.FormatFeatureFlags {
+	var props vk.FormatProperties
+	vk.GetPhysicalDeviceFormatProperties(v.gpuDevices[0], format, &props)
+	props.Deref()
+	return props.OptimalTilingFeatures
+}
+
+// CanSample reports whether images of format with optimal tiling can be
+// sampled.
+func (v VulkanDeviceInfo) CanSample(format vk.Format) bool {
+	return v.optimalFeatures(format)&vk.FormatFeatureFlags(vk.FormatFeatureSampledImageBit) != 0
+}
+
+// CreateTexture decodes a PNG, JPEG, Radiance HDR, KTX2 or DDS image and
+// creates a texture of it for a material slot. mimeType is the one of the
+// glTF image, it may be empty. Basis Universal textures are transcoded to
+// the best format the device samples, BCn textures are decoded on the CPU
+// when the device doesn't sample them.
+func (v VulkanDeviceInfo) CreateTexture(rawData []byte, mimeType string, slot TextureSlots) (*Texture, error) {
+	img, err := texture.Decode(rawData, mimeType, texture.Options{
+		ColorSpace: SlotColorSpace(slot),
+		Supported:  v.CanSample,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.CreateTextureFromImage(img)
+}
+
+// CreateTextureFromImage creates a texture with the levels and layers of
+// img, viewed as a 2D texture, an array, a cube or a cube array. Images
+// with a single level get a full mip chain, blitted on the GPU when the
+// format allows linear filtered blits and downsampled on the CPU otherwise.
+// The texels are uploaded with v.Uploads which has to be flushed before the
+// texture is sampled.
+func (v VulkanDeviceInfo) CreateTextureFromImage(img *texture.Image) (*Texture, error) {
+	blockWidth, blockHeight, texelSize, compressed := texture.CompressedBlock(img.Format)
+	if !compressed {
+		blockWidth, blockHeight, texelSize = 1, 1, FormatSize(img.Format)
+	}
+	if texelSize == 0 {
+		return nil, fmt.Errorf("texture format %d has no known texel size", img.Format)
+	}
+	if !v.CanSample(img.Format) {
+		return nil, fmt.Errorf("texture format %d can't be sampled on this device", img.Format)
+	}
+	width, height := img.Width(), img.Height()
+	blit := false
+	if len(img.Levels) == 1 && !compressed && texture.MipLevelCount(width, height) > 1 {
+		if v.CanBlitMipmaps(img.Format) {
+			blit = true
+		} else if texture.CanGenerateMipmaps(img.Format) {
+			var err error
+			img, err = texture.GenerateMipmaps(img, texture.FilterBox)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	levels, layers := uint32(len(img.Levels)), max(img.Layers, 1)
+	if blit {
+		levels = uint32(texture.MipLevelCount(width, height))
+	}
+	usage := vk.ImageUsageFlags(vk.ImageUsageTransferDstBit | vk.ImageUsageSampledBit)
+	if blit {
+		usage |= vk.ImageUsageFlags(vk.ImageUsageTransferSrcBit)
+	}
+	viewType := vk.ImageViewType2d
+	var flags vk.ImageCreateFlags
+	switch {
+	case img.Cube && layers%6 != 0:
+		return nil, fmt.Errorf("cubemap with %d layers", layers)
+	case img.Cube && layers > 6 && v.Features.ImageCubeArray == vk.False:
+		return nil, fmt.Errorf("cube arrays aren't supported by this device")
+	case img.Cube && layers > 6:
+		viewType, flags = vk.ImageViewTypeCubeArray, vk.ImageCreateFlags(vk.ImageCreateCubeCompatibleBit)
+	case img.Cube:
+		viewType, flags = vk.ImageViewTypeCube, vk.ImageCreateFlags(vk.ImageCreateCubeCompatibleBit)
+	case layers > 1:
+		viewType = vk.ImageViewType2dArray
+	}
+	tex := &Texture{
+		format:      img.Format,
+		texWidth:    int32(width),
+		texHeight:   int32(height),
+		levels:      levels,
+		layers:      layers,
+		cube:        img.Cube,
+		imageLayout: vk.ImageLayoutShaderReadOnlyOptimal,
+		memory:      v.Memory,
+	}
+
+	// Phase 1: create the image in device local memory
+
+	err := vk.Error(vk.CreateImage(v.Device, &vk.ImageCreateInfo{
+		SType:     vk.StructureTypeImageCreateInfo,
+		Flags:     flags,
+		ImageType: vk.ImageType2d,
+		Format:    img.Format,
+		Extent: vk.Extent3D{
+			Width:  width,
+			Height: height,
+			Depth:  1,
+		},
+		MipLevels:     levels,
+		ArrayLayers:   layers,
+		Samples:       vk.SampleCount1Bit,
+		Tiling:        vk.ImageTilingOptimal,
+		Usage:         usage,
+		InitialLayout: vk.ImageLayoutUndefined,
+	}, nil, &tex.image))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateImage failed with %s", err)
+		return nil, err
+	}
+	deviceMemory := vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit)
+	tex.alloc, err = v.Memory.AllocateImage(tex.image, vk.ImageTilingOptimal, deviceMemory, deviceMemory)
+	if err != nil {
+		tex.Destroy(v.Device)
+		return nil, err
+	}
+
+	// Phase 2: create the sampler and the image view, filtering linearly
+	//			between texels and levels unless the format can't,
+	//			before any upload is recorded on the image
+
+	filter, mipmapMode := vk.FilterNearest, vk.SamplerMipmapModeNearest
+	if v.optimalFeatures(img.Format)&vk.FormatFeatureFlags(vk.FormatFeatureSampledImageFilterLinearBit) != 0 {
+		filter, mipmapMode = vk.FilterLinear, vk.SamplerMipmapModeLinear
+	}
+	err = vk.Error(vk.CreateSampler(v.Device, &vk.SamplerCreateInfo{
+		SType:                   vk.StructureTypeSamplerCreateInfo,
+		MagFilter:               filter,
+		MinFilter:               filter,
+		MipmapMode:              mipmapMode,
+		AddressModeU:            vk.SamplerAddressModeClampToEdge,
+		AddressModeV:            vk.SamplerAddressModeClampToEdge,
+		AddressModeW:            vk.SamplerAddressModeClampToEdge,
+		AnisotropyEnable:        vk.False,
+		MaxAnisotropy:           1,
+		MaxLod:                  float32(levels),
+		CompareOp:               vk.CompareOpNever,
+		BorderColor:             vk.BorderColorFloatOpaqueWhite,
+		UnnormalizedCoordinates: vk.False,
+	}, nil, &tex.sampler))
+	if err != nil {
+		tex.Destroy(v.Device)
+		err = fmt.Errorf("vk.CreateSampler failed with %s", err)
+		return nil, err
+	}
+	err = vk.Error(vk.CreateImageView(v.Device, &vk.ImageViewCreateInfo{
+		SType:    vk.StructureTypeImageViewCreateInfo,
+		Image:    tex.image,
+		ViewType: viewType,
+		Format:   img.Format,
+		Components: vk.ComponentMapping{
+			R: vk.ComponentSwizzleR,
+			G: vk.ComponentSwizzleG,
+			B: vk.ComponentSwizzleB,
+			A: vk.ComponentSwizzleA,
+		},
+		SubresourceRange: vk.ImageSubresourceRange{
+			AspectMask: vk.ImageAspectFlags(vk.ImageAspectColorBit),
+			LevelCount: levels,
+			LayerCount: layers,
+		},
+	}, nil, &tex.view))
+	if err != nil {
+		tex.Destroy(v.Device)
+		err = fmt.Errorf("vk.CreateImageView failed with %s", err)
+		return nil, err
+	}
+
+	// Phase 3: copy each layer of the levels through the staging ring,
+	//			the image ends up in tex.imageLayout once v.Uploads is
+	//			flushed. Blitted mipmaps are read from the first level.
+	//			A failure flushes and waits for what was recorded on the
+	//			image before destroying it.
+
+	fail := func(err error) (*Texture, error) {
+		if werr := v.Uploads.WaitIdle(); werr != nil {
+			log.Println("[WARN] texture upload:", werr)
+		}
+		tex.Destroy(v.Device)
+		return nil, err
+	}
+	upload := ImageUpload{
+		Image:       tex.image,
+		TexelSize:   texelSize,
+		BlockWidth:  blockWidth,
+		BlockHeight: blockHeight,
+		FinalLayout: tex.imageLayout,
+	}
+	if blit {
+		upload.FinalLayout = vk.ImageLayoutTransferSrcOptimal
+		upload.DstAccess = vk.AccessFlags(vk.AccessTransferReadBit)
+		upload.DstStage = vk.PipelineStageFlags(vk.PipelineStageTransferBit)
+	}
+	for i, level := range img.Levels {
+		layerSize := len(level.Data) / int(layers)
+		for layer := uint32(0); layer < layers; layer++ {
+			upload.Extent = vk.Extent3D{
+				Width:  level.Width,
+				Height: level.Height,
+				Depth:  1,
+			}
+			upload.MipLevel, upload.Layer = uint32(i), layer
+			_, err = v.Uploads.UploadImage(upload, level.Data[int(layer)*layerSize:][:layerSize])
+			if err != nil {
+				return fail(fmt.Errorf("level %d layer %d: %s", i, layer, err))
+			}
+		}
+	}
+	if blit {
+		_, err = v.Uploads.GenerateMipmaps(MipmapBlit{
+			Image:       tex.image,
+			Width:       width,
+			Height:      height,
+			Levels:      levels,
+			Layers:      layers,
+			FinalLayout: tex.imageLayout,
+		})
+		if err != nil {
+			return fail(err)
+		}
+	}
+	return tex, nil
+}
+
+// textureFeatures returns the texture compression and cube array features
+// of gpu, the device enables them when they are supported.
+func textureFeatures(gpu vk.PhysicalDevice) vk.PhysicalDeviceFeatures {
+	var supported vk.PhysicalDeviceFeatures
+	vk.GetPhysicalDeviceFeatures(gpu, &supported)
+	supported.Deref()
+	return vk.PhysicalDeviceFeatures{
+		TextureCompressionBC:       supported.TextureCompressionBC,
+		TextureCompressionETC2:     supported.TextureCompressionETC2,
+		TextureCompressionASTC_LDR: supported.TextureCompressionASTC_LDR,
+		ImageCubeArray:             supported.ImageCubeArray,
+	}
+}
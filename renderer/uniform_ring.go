@@ -0,0 +1,171 @@
+package renderer
+
+import (
+	"fmt"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/memalloc"
+)
+
+// DefaultUniformRingSize is enough for a few thousand transforms per frame.
+const DefaultUniformRingSize = 4 << 20
+
+// UniformRing is a persistently mapped uniform buffer shared by all frames
+// in flight. Every draw pushes its uniform data into the ring and binds it
+// with a dynamic offset, so one buffer and one descriptor set serve any
+// number of objects.
+type UniformRing struct {
+	device vk.Device
+	memory *MemoryAllocator
+
+	buffer vk.Buffer
+	alloc  *memalloc.Allocation
+	ptr    unsafe.Pointer
+	ring   *memalloc.Ring
+	// alignment is minUniformBufferOffsetAlignment, maxRange the range
+	// of the descriptor bound at every offset.
+	alignment uint64
+	maxRange  uint64
+
+	frame uint64
+	// slotTickets holds the last frame that used each frame slot.
+	slotTickets []uint64
+	// dirty are the ranges written since the last Flush, only tracked
+	// for memory that isn't host coherent.
+	coherent bool
+	dirty    [][2]uint64
+}
+
+// NewUniformRing creates a ring of size bytes for framesInFlight frames.
+// maxRange is the largest block of uniform data pushed at once.
+func (v *VulkanDeviceInfo) NewUniformRing(size, maxRange uint64, framesInFlight int) (*UniformRing, error) {
+	if framesInFlight < 1 {
+		framesInFlight = DefaultFramesInFlight
+	}
+	limits := v.Limits()
+	u := &UniformRing{
+		device:      v.Device,
+		memory:      v.Memory,
+		ring:        memalloc.NewRing(size),
+		alignment:   uint64(limits.MinUniformBufferOffsetAlignment),
+		maxRange:    maxRange,
+		slotTickets: make([]uint64, framesInFlight),
+	}
+	if maxRange == 0 || maxRange > uint64(limits.MaxUniformBufferRange) {
+		return nil, fmt.Errorf("uniform range of %d bytes exceeds the limit of %d",
+			maxRange, limits.MaxUniformBufferRange)
+	}
+
+	// Phase 1: vk.CreateBuffer
+	//			the descriptor range must stay inside the buffer at
+	//			every offset, so maxRange bytes are added at the end
+
+	bufferCreateInfo := vk.BufferCreateInfo{
+		SType:       vk.StructureTypeBufferCreateInfo,
+		Size:        vk.DeviceSize(size + maxRange),
+		Usage:       vk.BufferUsageFlags(vk.BufferUsageUniformBufferBit),
+		SharingMode: vk.SharingModeExclusive,
+	}
+	err := vk.Error(vk.CreateBuffer(v.Device, &bufferCreateInfo, nil, &u.buffer))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateBuffer failed with %s", err)
+		return nil, err
+	}
+
+	// Phase 2: sub-allocate host visible memory and bind the buffer
+	//			coherent memory is preferred but not required
+
+	u.alloc, err = v.Memory.AllocateBuffer(u.buffer,
+		vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit),
+		vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit|vk.MemoryPropertyHostCoherentBit))
+	if err != nil {
+		u.Destroy()
+		return nil, err
+	}
+	u.ptr, err = v.Memory.Map(u.alloc)
+	if err != nil {
+		u.Destroy()
+		return nil, err
+	}
+	u.coherent = v.Memory.Coherent(u.alloc)
+	return u, nil
+}
+
+func (u *UniformRing) Buffer() vk.Buffer {
+	return u.buffer
+}
+
+// Range is the range of the dynamic uniform buffer descriptor.
+func (u *UniformRing) Range() vk.DeviceSize {
+	return vk.DeviceSize(u.maxRange)
+}
+
+// BeginFrame starts a frame in frame slot, after the fence of the slot
+// was waited on. The ranges pushed by the previous frame in that slot,
+// and by every frame before it, are reused from then on.
+func (u *UniformRing) BeginFrame(slot int) {
+	u.ring.Commit(u.frame)
+	if done := u.slotTickets[slot]; done > 0 {
+		u.ring.Release(done)
+	}
+	u.frame++
+	u.slotTickets[slot] = u.frame
+}
+
+// Push copies data into the ring and returns the dynamic offset to bind
+// it with. It fails when the frames in flight have used up the ring.
+func (u *UniformRing) Push(data []byte) (uint32, error) {
+	size := uint64(len(data))
+	if size > u.maxRange {
+		return 0, fmt.Errorf("%d bytes of uniform data exceed the range of %d", size, u.maxRange)
+	}
+	offset, ok := u.ring.Alloc(size, u.alignment)
+	if !ok {
+		return 0, fmt.Errorf("uniform ring of %d bytes is full", u.ring.Size())
+	}
+	vk.Memcopy(unsafe.Pointer(uintptr(u.ptr)+uintptr(offset)), data)
+	if !u.coherent {
+		u.markDirty(offset, offset+size)
+	}
+	return uint32(offset), nil
+}
+
+func (u *UniformRing) markDirty(start, end uint64) {
+	if n := len(u.dirty); n > 0 && u.dirty[n-1][1] <= start && start-u.dirty[n-1][1] < u.alignment {
+		u.dirty[n-1][1] = end
+		return
+	}
+	u.dirty = append(u.dirty, [2]uint64{start, end})
+}
+
+// Flush makes the data pushed since the last Flush visible to the device,
+// it has to be called before the frame is submitted. It does nothing on
+// host coherent memory.
+func (u *UniformRing) Flush() error {
+	if len(u.dirty) == 0 {
+		return nil
+	}
+	ranges := make([]vk.MappedMemoryRange, len(u.dirty))
+	for i, r := range u.dirty {
+		ranges[i] = u.memory.mappedRange(u.alloc, r[0], r[1]-r[0])
+	}
+	u.dirty = u.dirty[:0]
+	err := vk.Error(vk.FlushMappedMemoryRanges(u.device, uint32(len(ranges)), ranges))
+	if err != nil {
+		return fmt.Errorf("vk.FlushMappedMemoryRanges failed with %s", err)
+	}
+	return nil
+}
+
+func (u *UniformRing) Destroy() {
+	if u == nil {
+		return
+	}
+	vk.DestroyBuffer(u.device, u.buffer, nil)
+	u.memory.Free(u.alloc)
+	u.buffer = vk.NullBuffer
+	u.alloc = nil
+	u.ptr = nil
+	u.ring.Reset()
+}
@@ -0,0 +1,776 @@
+package renderer
+
+import (
+	"fmt"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/memalloc"
+)
+
+// DefaultStagingSize is the size of the staging ring of the upload manager.
+const DefaultStagingSize = 16 << 20
+
+// UploadTicket identifies a batch of uploads, tickets increase with
+// every batch submitted.
+type UploadTicket uint64
+
+// ImageUpload describes the copy of tightly packed texel data into a mip
+// level and array layer of an optimally tiled image.
+type ImageUpload struct {
+	Image  vk.Image
+	Extent vk.Extent3D
+	// TexelSize is the size of a texel in bytes, or of a block of
+	// BlockWidth by BlockHeight texels for block compressed formats.
+	TexelSize               uint32
+	BlockWidth, BlockHeight uint32
+	// MipLevel and Layer are the level and array layer of the image the
+	// texels are copied to.
+	MipLevel uint32
+	Layer    uint32
+	// Aspect defaults to the color aspect.
+	Aspect vk.ImageAspectFlags
+	// FinalLayout defaults to vk.ImageLayoutShaderReadOnlyOptimal.
+	FinalLayout vk.ImageLayout
+	// DstAccess and DstStage describe the first use of the image
+	// and default to a fragment shader read.
+	DstAccess vk.AccessFlags
+	DstStage  vk.PipelineStageFlags
+}
+
+type uploadBatch struct {
+	ticket UploadTicket
+
+	transferCmd vk.CommandBuffer
+	// acquireCmd takes ownership on the graphics queue, it is only
+	// used with a dedicated transfer queue.
+	acquireCmd  vk.CommandBuffer
+	transferred vk.Semaphore
+	fence       vk.Fence
+
+	bufferBarriers []vk.BufferMemoryBarrier
+	imageBarriers  []vk.ImageMemoryBarrier
+	dstStages      vk.PipelineStageFlags
+	// mipmaps are blitted after the barriers, on the graphics queue.
+	mipmaps []MipmapBlit
+}
+
+// UploadManager copies data into device local buffers and optimally tiled
+// images through a persistently mapped staging ring. Copies are batched
+// until Flush, the returned tickets tell when they have completed.
+type UploadManager struct {
+	queue uploadQueue
+	ring  *memalloc.Ring
+	// copyAlignment is the alignment of every range of the ring.
+	copyAlignment uint64
+
+	current   *uploadBatch
+	inflight  []*uploadBatch
+	free      []*uploadBatch
+	last      UploadTicket
+	completed UploadTicket
+}
+
+// uploadQueue records batches, submits them and waits for them. The
+// upload manager only keeps track of tickets and of the staging ring,
+// vulkanUploadQueue does the Vulkan side.
+type uploadQueue interface {
+	newBatch() (*uploadBatch, error)
+	begin(b *uploadBatch) error
+	// stage writes data to the staging buffer at offset.
+	stage(offset uint64, data []byte)
+	copyBuffer(b *uploadBatch, dst vk.Buffer, region vk.BufferCopy)
+	copyBufferToImage(b *uploadBatch, dst vk.Image, region vk.BufferImageCopy)
+	// prepareImage records the transition of an image before its copy.
+	prepareImage(b *uploadBatch, barrier vk.ImageMemoryBarrier)
+	// submit records the barriers and blits ending a batch and submits
+	// it, its fence signals once it is done.
+	submit(b *uploadBatch) error
+	signaled(b *uploadBatch) bool
+	wait(b *uploadBatch) error
+	// reset makes a batch that is done, or was never submitted, ready
+	// to record again.
+	reset(b *uploadBatch)
+	destroyBatch(b *uploadBatch)
+	destroy()
+}
+
+// vulkanUploadQueue records and submits batches on the transfer queue,
+// and on the graphics queue to acquire the resources if it is a
+// different family.
+type vulkanUploadQueue struct {
+	device vk.Device
+	memory *MemoryAllocator
+
+	transferQueue  vk.Queue
+	transferFamily uint32
+	graphicsQueue  vk.Queue
+	graphicsFamily uint32
+	transferPool   vk.CommandPool
+	graphicsPool   vk.CommandPool
+
+	staging      vk.Buffer
+	stagingAlloc *memalloc.Allocation
+	stagingPtr   unsafe.Pointer
+}
+
+// NewUploadManager creates the staging ring of stagingSize bytes and the
+// command pools for the transfer and graphics queues of the device.
+func (v *VulkanDeviceInfo) NewUploadManager(stagingSize uint64) (*UploadManager, error) {
+	q := &vulkanUploadQueue{
+		device:         v.Device,
+		memory:         v.Memory,
+		transferQueue:  v.TransferQueue,
+		transferFamily: v.TransferFamily,
+		graphicsQueue:  v.Queue,
+		graphicsFamily: v.QueueFamily,
+	}
+
+	// Phase 1: vk.CreateCommandPool
+	//			one pool per queue family used
+
+	var err error
+	q.transferPool, err = createCommandPool(v.Device, q.transferFamily)
+	if err != nil {
+		return nil, err
+	}
+	if q.dedicatedTransfer() {
+		q.graphicsPool, err = createCommandPool(v.Device, q.graphicsFamily)
+		if err != nil {
+			q.destroy()
+			return nil, err
+		}
+	}
+
+	// Phase 2: vk.CreateBuffer
+	//			the staging buffer stays mapped for its whole lifetime
+
+	bufferCreateInfo := vk.BufferCreateInfo{
+		SType:       vk.StructureTypeBufferCreateInfo,
+		Size:        vk.DeviceSize(stagingSize),
+		Usage:       vk.BufferUsageFlags(vk.BufferUsageTransferSrcBit),
+		SharingMode: vk.SharingModeExclusive,
+	}
+	err = vk.Error(vk.CreateBuffer(v.Device, &bufferCreateInfo, nil, &q.staging))
+	if err != nil {
+		q.destroy()
+		err = fmt.Errorf("vk.CreateBuffer failed with %s", err)
+		return nil, err
+	}
+	hostMemory := vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit | vk.MemoryPropertyHostCoherentBit)
+	q.stagingAlloc, err = v.Memory.AllocateBuffer(q.staging, hostMemory, hostMemory)
+	if err != nil {
+		q.destroy()
+		return nil, err
+	}
+	q.stagingPtr, err = v.Memory.Map(q.stagingAlloc)
+	if err != nil {
+		q.destroy()
+		return nil, err
+	}
+	return newUploadManager(q, stagingSize, uint64(v.Limits().OptimalBufferCopyOffsetAlignment)), nil
+}
+
+func newUploadManager(q uploadQueue, stagingSize, copyAlignment uint64) *UploadManager {
+	return &UploadManager{
+		queue:         q,
+		ring:          memalloc.NewRing(stagingSize),
+		copyAlignment: max(copyAlignment, 4),
+	}
+}
+
+func createCommandPool(device vk.Device, family uint32) (vk.CommandPool, error) {
+	cmdPoolCreateInfo := vk.CommandPoolCreateInfo{
+		SType:            vk.StructureTypeCommandPoolCreateInfo,
+		Flags:            vk.CommandPoolCreateFlags(vk.CommandPoolCreateResetCommandBufferBit),
+		QueueFamilyIndex: family,
+	}
+	var cmdPool vk.CommandPool
+	err := vk.Error(vk.CreateCommandPool(device, &cmdPoolCreateInfo, nil, &cmdPool))
+	if err != nil {
+		err = fmt.Errorf("vk.CreateCommandPool failed with %s", err)
+		return vk.NullCommandPool, err
+	}
+	return cmdPool, nil
+}
+
+func (q *vulkanUploadQueue) dedicatedTransfer() bool {
+	return q.transferFamily != q.graphicsFamily
+}
+
+// begin returns the batch uploads are recorded into, starting a new one
+// if needed.
+func (u *UploadManager) begin() (*uploadBatch, error) {
+	if u.current != nil {
+		return u.current, nil
+	}
+	var b *uploadBatch
+	if n := len(u.free); n > 0 {
+		b = u.free[n-1]
+		u.free = u.free[:n-1]
+	} else {
+		var err error
+		b, err = u.queue.newBatch()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := u.queue.begin(b); err != nil {
+		u.free = append(u.free, b)
+		return nil, err
+	}
+	b.ticket = u.last + 1
+	u.current = b
+	return b, nil
+}
+
+func (q *vulkanUploadQueue) begin(b *uploadBatch) error {
+	beginInfo := vk.CommandBufferBeginInfo{
+		SType: vk.StructureTypeCommandBufferBeginInfo,
+		Flags: vk.CommandBufferUsageFlags(vk.CommandBufferUsageOneTimeSubmitBit),
+	}
+	err := vk.Error(vk.BeginCommandBuffer(b.transferCmd, &beginInfo))
+	if err != nil {
+		return fmt.Errorf("vk.BeginCommandBuffer failed with %s", err)
+	}
+	return nil
+}
+
+func (q *vulkanUploadQueue) newBatch() (*uploadBatch, error) {
+	b := &uploadBatch{}
+	cmd, err := allocateCommandBuffer(q.device, q.transferPool)
+	if err != nil {
+		return nil, err
+	}
+	b.transferCmd = cmd
+	fenceCreateInfo := vk.FenceCreateInfo{
+		SType: vk.StructureTypeFenceCreateInfo,
+	}
+	err = vk.Error(vk.CreateFence(q.device, &fenceCreateInfo, nil, &b.fence))
+	if err != nil {
+		q.destroyBatch(b)
+		return nil, fmt.Errorf("vk.CreateFence failed with %s", err)
+	}
+	if q.dedicatedTransfer() {
+		b.acquireCmd, err = allocateCommandBuffer(q.device, q.graphicsPool)
+		if err != nil {
+			q.destroyBatch(b)
+			return nil, err
+		}
+		semaphoreCreateInfo := vk.SemaphoreCreateInfo{
+			SType: vk.StructureTypeSemaphoreCreateInfo,
+		}
+		err = vk.Error(vk.CreateSemaphore(q.device, &semaphoreCreateInfo, nil, &b.transferred))
+		if err != nil {
+			q.destroyBatch(b)
+			return nil, fmt.Errorf("vk.CreateSemaphore failed with %s", err)
+		}
+	}
+	return b, nil
+}
+
+func allocateCommandBuffer(device vk.Device, cmdPool vk.CommandPool) (vk.CommandBuffer, error) {
+	cmdBuffers := make([]vk.CommandBuffer, 1)
+	cmdBufferAllocateInfo := vk.CommandBufferAllocateInfo{
+		SType:              vk.StructureTypeCommandBufferAllocateInfo,
+		CommandPool:        cmdPool,
+		Level:              vk.CommandBufferLevelPrimary,
+		CommandBufferCount: 1,
+	}
+	err := vk.Error(vk.AllocateCommandBuffers(device, &cmdBufferAllocateInfo, cmdBuffers))
+	if err != nil {
+		err = fmt.Errorf("vk.AllocateCommandBuffers failed with %s", err)
+		return nil, err
+	}
+	return cmdBuffers[0], nil
+}
+
+// stage copies data into the staging ring, flushing and waiting for older
+// batches while the ring is full.
+func (u *UploadManager) stage(data []byte, alignment uint64) (uint64, error) {
+	if uint64(len(data)) > u.ring.Size() {
+		return 0, fmt.Errorf("%d bytes don't fit a staging ring of %d", len(data), u.ring.Size())
+	}
+	alignment = lcm(alignment, u.copyAlignment)
+	for {
+		offset, ok := u.ring.Alloc(uint64(len(data)), alignment)
+		if ok {
+			u.queue.stage(offset, data)
+			return offset, nil
+		}
+		if len(u.inflight) == 0 {
+			if u.current == nil {
+				return 0, fmt.Errorf("staging ring of %d bytes can't fit %d", u.ring.Size(), len(data))
+			}
+			if _, err := u.Flush(); err != nil {
+				return 0, err
+			}
+		}
+		if err := u.waitBatch(u.inflight[0]); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (q *vulkanUploadQueue) stage(offset uint64, data []byte) {
+	vk.Memcopy(unsafe.Pointer(uintptr(q.stagingPtr)+uintptr(offset)), data)
+}
+
+func lcm(a, b uint64) uint64 {
+	if a == 0 || b == 0 {
+		return a + b
+	}
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+// UploadBuffer copies data to dst at offset. dstAccess and dstStage are
+// the first use of the buffer after the upload. The copy is executed
+// once the returned ticket is flushed. Empty data records nothing and
+// returns the last ticket submitted.
+func (u *UploadManager) UploadBuffer(dst vk.Buffer, offset uint64, data []byte,
+	dstAccess vk.AccessFlags, dstStage vk.PipelineStageFlags) (UploadTicket, error) {
+
+	if len(data) == 0 {
+		return u.last, nil
+	}
+	chunkSize := u.ring.Size() / 2
+	for start := uint64(0); start < uint64(len(data)); start += chunkSize {
+		end := start + chunkSize
+		if end > uint64(len(data)) {
+			end = uint64(len(data))
+		}
+		srcOffset, err := u.stage(data[start:end], 4)
+		if err != nil {
+			return 0, err
+		}
+		// stage may have flushed, record into the current batch.
+		b, err := u.begin()
+		if err != nil {
+			return 0, err
+		}
+		u.queue.copyBuffer(b, dst, vk.BufferCopy{
+			SrcOffset: vk.DeviceSize(srcOffset),
+			DstOffset: vk.DeviceSize(offset + start),
+			Size:      vk.DeviceSize(end - start),
+		})
+	}
+	b, err := u.begin()
+	if err != nil {
+		return 0, err
+	}
+	b.bufferBarriers = append(b.bufferBarriers, vk.BufferMemoryBarrier{
+		SType:               vk.StructureTypeBufferMemoryBarrier,
+		SrcAccessMask:       vk.AccessFlags(vk.AccessTransferWriteBit),
+		DstAccessMask:       dstAccess,
+		SrcQueueFamilyIndex: vk.QueueFamilyIgnored,
+		DstQueueFamilyIndex: vk.QueueFamilyIgnored,
+		Buffer:              dst,
+		Offset:              vk.DeviceSize(offset),
+		Size:                vk.DeviceSize(len(data)),
+	})
+	b.dstStages |= dstStage
+	return b.ticket, nil
+}
+
+// UploadImage copies tightly packed texels to the first mip level of an
+// image in the undefined layout and leaves it in up.FinalLayout. Images
+// larger than half the staging ring are copied in bands of rows.
+func (u *UploadManager) UploadImage(up ImageUpload, data []byte) (UploadTicket, error) {
+	if up.Aspect == 0 {
+		up.Aspect = vk.ImageAspectFlags(vk.ImageAspectColorBit)
+	}
+	if up.FinalLayout == vk.ImageLayoutUndefined {
+		up.FinalLayout = vk.ImageLayoutShaderReadOnlyOptimal
+	}
+	if up.DstAccess == 0 {
+		up.DstAccess = vk.AccessFlags(vk.AccessShaderReadBit)
+		up.DstStage = vk.PipelineStageFlags(vk.PipelineStageFragmentShaderBit)
+	}
+	if up.Extent.Depth == 0 {
+		up.Extent.Depth = 1
+	}
+	if up.BlockWidth == 0 || up.BlockHeight == 0 {
+		up.BlockWidth, up.BlockHeight = 1, 1
+	}
+	// Rows are rows of blocks, a block being a texel for uncompressed
+	// formats.
+	blockRows := uint64((up.Extent.Height + up.BlockHeight - 1) / up.BlockHeight)
+	rowSize := uint64((up.Extent.Width+up.BlockWidth-1)/up.BlockWidth) * uint64(up.TexelSize)
+	sliceSize := rowSize * blockRows
+	if sliceSize == 0 {
+		return 0, fmt.Errorf("empty image upload of %dx%d texels", up.Extent.Width, up.Extent.Height)
+	}
+	if uint64(len(data)) < sliceSize*uint64(up.Extent.Depth) {
+		return 0, fmt.Errorf("%d bytes of texel data, %dx%dx%d texels of %d bytes need %d",
+			len(data), up.Extent.Width, up.Extent.Height, up.Extent.Depth, up.TexelSize,
+			sliceSize*uint64(up.Extent.Depth))
+	}
+	subresource := vk.ImageSubresourceRange{
+		AspectMask:     up.Aspect,
+		BaseMipLevel:   up.MipLevel,
+		BaseArrayLayer: up.Layer,
+		LevelCount:     1,
+		LayerCount:     1,
+	}
+
+	// Phase 1: transition the image for the copy
+
+	b, err := u.begin()
+	if err != nil {
+		return 0, err
+	}
+	u.queue.prepareImage(b, vk.ImageMemoryBarrier{
+		SType:               vk.StructureTypeImageMemoryBarrier,
+		DstAccessMask:       vk.AccessFlags(vk.AccessTransferWriteBit),
+		OldLayout:           vk.ImageLayoutUndefined,
+		NewLayout:           vk.ImageLayoutTransferDstOptimal,
+		SrcQueueFamilyIndex: vk.QueueFamilyIgnored,
+		DstQueueFamilyIndex: vk.QueueFamilyIgnored,
+		Image:               up.Image,
+		SubresourceRange:    subresource,
+	})
+
+	// Phase 2: vk.CmdCopyBufferToImage
+	//			a band of rows at a time
+
+	rowsPerCopy := blockRows
+	if up.Extent.Depth > 1 {
+		if size := sliceSize * uint64(up.Extent.Depth); size > u.ring.Size()/2 {
+			return 0, fmt.Errorf("3D image of %d bytes doesn't fit the staging ring", size)
+		}
+	} else if sliceSize > u.ring.Size()/2 {
+		rowsPerCopy = (u.ring.Size() / 2) / rowSize
+		if rowsPerCopy == 0 {
+			return 0, fmt.Errorf("a row of %d bytes doesn't fit the staging ring", rowSize)
+		}
+	}
+	for y := uint64(0); y < blockRows; y += rowsPerCopy {
+		rows := rowsPerCopy
+		if y+rows > blockRows {
+			rows = blockRows - y
+		}
+		size := rows * rowSize * uint64(up.Extent.Depth)
+		// vkCmdCopyBufferToImage needs offsets that are a multiple
+		// of both 4 and the texel size.
+		srcOffset, err := u.stage(data[y*rowSize:y*rowSize+size], lcm(4, uint64(up.TexelSize)))
+		if err != nil {
+			return 0, err
+		}
+		b, err = u.begin()
+		if err != nil {
+			return 0, err
+		}
+		u.queue.copyBufferToImage(b, up.Image, vk.BufferImageCopy{
+			BufferOffset: vk.DeviceSize(srcOffset),
+			ImageSubresource: vk.ImageSubresourceLayers{
+				AspectMask:     up.Aspect,
+				MipLevel:       up.MipLevel,
+				BaseArrayLayer: up.Layer,
+				LayerCount:     1,
+			},
+			ImageOffset: vk.Offset3D{
+				Y: int32(y) * int32(up.BlockHeight),
+			},
+			// The last band may end in a partial block.
+			ImageExtent: vk.Extent3D{
+				Width:  up.Extent.Width,
+				Height: min(uint32(rows)*up.BlockHeight, up.Extent.Height-uint32(y)*up.BlockHeight),
+				Depth:  up.Extent.Depth,
+			},
+		})
+	}
+
+	// Phase 3: transition to the final layout after the batch
+
+	b.imageBarriers = append(b.imageBarriers, vk.ImageMemoryBarrier{
+		SType:               vk.StructureTypeImageMemoryBarrier,
+		SrcAccessMask:       vk.AccessFlags(vk.AccessTransferWriteBit),
+		DstAccessMask:       up.DstAccess,
+		OldLayout:           vk.ImageLayoutTransferDstOptimal,
+		NewLayout:           up.FinalLayout,
+		SrcQueueFamilyIndex: vk.QueueFamilyIgnored,
+		DstQueueFamilyIndex: vk.QueueFamilyIgnored,
+		Image:               up.Image,
+		SubresourceRange:    subresource,
+	})
+	b.dstStages |= up.DstStage
+	return b.ticket, nil
+}
+
+// Flush submits the uploads recorded so far and returns their ticket.
+// With a dedicated transfer queue the resources are released there and
+// acquired by the graphics queue, otherwise a barrier makes the copies
+// visible to their first use.
+func (u *UploadManager) Flush() (UploadTicket, error) {
+	b := u.current
+	if b == nil {
+		return u.last, nil
+	}
+	u.current = nil
+	if err := u.queue.submit(b); err != nil {
+		u.recycle(b)
+		return u.last, err
+	}
+	u.last = b.ticket
+	u.ring.Commit(uint64(b.ticket))
+	u.inflight = append(u.inflight, b)
+	return b.ticket, nil
+}
+
+func (q *vulkanUploadQueue) copyBuffer(b *uploadBatch, dst vk.Buffer, region vk.BufferCopy) {
+	vk.CmdCopyBuffer(b.transferCmd, q.staging, dst, 1, []vk.BufferCopy{region})
+}
+
+func (q *vulkanUploadQueue) copyBufferToImage(b *uploadBatch, dst vk.Image, region vk.BufferImageCopy) {
+	vk.CmdCopyBufferToImage(b.transferCmd, q.staging, dst, vk.ImageLayoutTransferDstOptimal,
+		1, []vk.BufferImageCopy{region})
+}
+
+func (q *vulkanUploadQueue) prepareImage(b *uploadBatch, barrier vk.ImageMemoryBarrier) {
+	vk.CmdPipelineBarrier(b.transferCmd,
+		vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit), vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+		0, 0, nil, 0, nil, 1, []vk.ImageMemoryBarrier{barrier})
+}
+
+func (q *vulkanUploadQueue) submit(b *uploadBatch) error {
+	// Phase 1: record the barriers ending the batch
+
+	if !q.dedicatedTransfer() {
+		if len(b.bufferBarriers)+len(b.imageBarriers) > 0 {
+			vk.CmdPipelineBarrier(b.transferCmd,
+				vk.PipelineStageFlags(vk.PipelineStageTransferBit), b.dstStages, 0, 0, nil,
+				uint32(len(b.bufferBarriers)), b.bufferBarriers,
+				uint32(len(b.imageBarriers)), b.imageBarriers)
+		}
+		for _, m := range b.mipmaps {
+			recordMipmapBlits(b.transferCmd, m)
+		}
+	} else {
+		release, acquire := q.ownershipBarriers(b)
+		releaseImages, acquireImages := q.ownershipImageBarriers(b)
+		vk.CmdPipelineBarrier(b.transferCmd,
+			vk.PipelineStageFlags(vk.PipelineStageTransferBit), vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit),
+			0, 0, nil, uint32(len(release)), release, uint32(len(releaseImages)), releaseImages)
+
+		beginInfo := vk.CommandBufferBeginInfo{
+			SType: vk.StructureTypeCommandBufferBeginInfo,
+			Flags: vk.CommandBufferUsageFlags(vk.CommandBufferUsageOneTimeSubmitBit),
+		}
+		err := vk.Error(vk.BeginCommandBuffer(b.acquireCmd, &beginInfo))
+		if err != nil {
+			vk.EndCommandBuffer(b.transferCmd)
+			return fmt.Errorf("vk.BeginCommandBuffer failed with %s", err)
+		}
+		dstStages := b.dstStages
+		if dstStages == 0 {
+			dstStages = vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit)
+		}
+		vk.CmdPipelineBarrier(b.acquireCmd,
+			vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit), dstStages,
+			0, 0, nil, uint32(len(acquire)), acquire, uint32(len(acquireImages)), acquireImages)
+		for _, m := range b.mipmaps {
+			recordMipmapBlits(b.acquireCmd, m)
+		}
+		err = vk.Error(vk.EndCommandBuffer(b.acquireCmd))
+		if err != nil {
+			vk.EndCommandBuffer(b.transferCmd)
+			return fmt.Errorf("vk.EndCommandBuffer failed with %s", err)
+		}
+	}
+	err := vk.Error(vk.EndCommandBuffer(b.transferCmd))
+	if err != nil {
+		return fmt.Errorf("vk.EndCommandBuffer failed with %s", err)
+	}
+
+	// Phase 2: vk.QueueSubmit
+	//			the fence signals once the last submit of the batch is done
+
+	transferSubmit := vk.SubmitInfo{
+		SType:              vk.StructureTypeSubmitInfo,
+		CommandBufferCount: 1,
+		PCommandBuffers:    []vk.CommandBuffer{b.transferCmd},
+	}
+	if !q.dedicatedTransfer() {
+		err = vk.Error(vk.QueueSubmit(q.transferQueue, 1, []vk.SubmitInfo{transferSubmit}, b.fence))
+	} else {
+		transferSubmit.SignalSemaphoreCount = 1
+		transferSubmit.PSignalSemaphores = []vk.Semaphore{b.transferred}
+		err = vk.Error(vk.QueueSubmit(q.transferQueue, 1, []vk.SubmitInfo{transferSubmit}, vk.NullFence))
+		if err == nil {
+			acquireSubmit := vk.SubmitInfo{
+				SType:              vk.StructureTypeSubmitInfo,
+				WaitSemaphoreCount: 1,
+				PWaitSemaphores:    []vk.Semaphore{b.transferred},
+				PWaitDstStageMask:  []vk.PipelineStageFlags{vk.PipelineStageFlags(vk.PipelineStageAllCommandsBit)},
+				CommandBufferCount: 1,
+				PCommandBuffers:    []vk.CommandBuffer{b.acquireCmd},
+			}
+			err = vk.Error(vk.QueueSubmit(q.graphicsQueue, 1, []vk.SubmitInfo{acquireSubmit}, b.fence))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("vk.QueueSubmit failed with %s", err)
+	}
+	return nil
+}
+
+func (q *vulkanUploadQueue) ownershipBarriers(b *uploadBatch) (release, acquire []vk.BufferMemoryBarrier) {
+	for _, barrier := range b.bufferBarriers {
+		barrier.SrcQueueFamilyIndex = q.transferFamily
+		barrier.DstQueueFamilyIndex = q.graphicsFamily
+		dstAccess := barrier.DstAccessMask
+		barrier.DstAccessMask = 0
+		release = append(release, barrier)
+		barrier.SrcAccessMask = 0
+		barrier.DstAccessMask = dstAccess
+		acquire = append(acquire, barrier)
+	}
+	return release, acquire
+}
+
+func (q *vulkanUploadQueue) ownershipImageBarriers(b *uploadBatch) (release, acquire []vk.ImageMemoryBarrier) {
+	for _, barrier := range b.imageBarriers {
+		barrier.SrcQueueFamilyIndex = q.transferFamily
+		barrier.DstQueueFamilyIndex = q.graphicsFamily
+		dstAccess := barrier.DstAccessMask
+		barrier.DstAccessMask = 0
+		release = append(release, barrier)
+		barrier.SrcAccessMask = 0
+		barrier.DstAccessMask = dstAccess
+		acquire = append(acquire, barrier)
+	}
+	return release, acquire
+}
+
+// recycle resets a batch that is done or was never submitted.
+func (u *UploadManager) recycle(b *uploadBatch) {
+	u.queue.reset(b)
+	b.bufferBarriers = b.bufferBarriers[:0]
+	b.imageBarriers = b.imageBarriers[:0]
+	b.dstStages = 0
+	b.mipmaps = b.mipmaps[:0]
+	u.free = append(u.free, b)
+}
+
+func (q *vulkanUploadQueue) reset(b *uploadBatch) {
+	vk.ResetFences(q.device, 1, []vk.Fence{b.fence})
+	vk.ResetCommandBuffer(b.transferCmd, 0)
+	if b.acquireCmd != nil {
+		vk.ResetCommandBuffer(b.acquireCmd, 0)
+	}
+}
+
+// retire releases the staging ranges of a completed batch.
+func (u *UploadManager) retire(b *uploadBatch) {
+	u.ring.Release(uint64(b.ticket))
+	u.completed = b.ticket
+	u.inflight = u.inflight[1:]
+	u.recycle(b)
+}
+
+func (u *UploadManager) waitBatch(b *uploadBatch) error {
+	if err := u.queue.wait(b); err != nil {
+		return err
+	}
+	u.retire(b)
+	return nil
+}
+
+func (q *vulkanUploadQueue) wait(b *uploadBatch) error {
+	err := vk.Error(vk.WaitForFences(q.device, 1, []vk.Fence{b.fence}, vk.True, vk.MaxUint64))
+	if err != nil {
+		return fmt.Errorf("vk.WaitForFences failed with %s", err)
+	}
+	return nil
+}
+
+func (q *vulkanUploadQueue) signaled(b *uploadBatch) bool {
+	return vk.GetFenceStatus(q.device, b.fence) == vk.Success
+}
+
+// Poll retires the batches that have completed on the GPU.
+func (u *UploadManager) Poll() {
+	for len(u.inflight) > 0 {
+		b := u.inflight[0]
+		if !u.queue.signaled(b) {
+			return
+		}
+		u.retire(b)
+	}
+}
+
+// Completed reports whether the uploads of ticket have finished.
+func (u *UploadManager) Completed(ticket UploadTicket) bool {
+	u.Poll()
+	return ticket <= u.completed
+}
+
+// Wait blocks until the uploads of ticket have finished, flushing them
+// first if needed.
+func (u *UploadManager) Wait(ticket UploadTicket) error {
+	if u.current != nil && ticket >= u.current.ticket {
+		if _, err := u.Flush(); err != nil {
+			return err
+		}
+	}
+	for len(u.inflight) > 0 && u.inflight[0].ticket <= ticket {
+		if err := u.waitBatch(u.inflight[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitIdle flushes and waits for all uploads.
+func (u *UploadManager) WaitIdle() error {
+	ticket, err := u.Flush()
+	if err != nil {
+		return err
+	}
+	return u.Wait(ticket)
+}
+
+func (q *vulkanUploadQueue) destroyBatch(b *uploadBatch) {
+	if b.transferCmd != nil {
+		vk.FreeCommandBuffers(q.device, q.transferPool, 1, []vk.CommandBuffer{b.transferCmd})
+	}
+	if b.acquireCmd != nil {
+		vk.FreeCommandBuffers(q.device, q.graphicsPool, 1, []vk.CommandBuffer{b.acquireCmd})
+	}
+	vk.DestroyFence(q.device, b.fence, nil)
+	vk.DestroySemaphore(q.device, b.transferred, nil)
+}
+
+func (q *vulkanUploadQueue) destroy() {
+	vk.DestroyBuffer(q.device, q.staging, nil)
+	q.memory.Free(q.stagingAlloc)
+	vk.DestroyCommandPool(q.device, q.transferPool, nil)
+	vk.DestroyCommandPool(q.device, q.graphicsPool, nil)
+	q.staging = vk.NullBuffer
+	q.stagingAlloc = nil
+}
+
+func (u *UploadManager) Destroy() {
+	if u == nil {
+		return
+	}
+	if u.current != nil {
+		u.recycle(u.current)
+		u.current = nil
+	}
+	for len(u.inflight) > 0 {
+		if err := u.waitBatch(u.inflight[0]); err != nil {
+			break
+		}
+	}
+	for _, b := range append(u.free, u.inflight...) {
+		u.queue.destroyBatch(b)
+	}
+	u.free = nil
+	u.inflight = nil
+	u.queue.destroy()
+}
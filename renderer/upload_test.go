@@ -0,0 +1,195 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// fakeUploadQueue stages into memory and logs what it records, submits
+// and waits for. Batches complete when the test signals them or when
+// they are waited for.
+type fakeUploadQueue struct {
+	staging []byte
+	batches int
+	copies  []fakeCopy
+	log     []string
+	done    map[UploadTicket]bool
+}
+
+// fakeCopy is a buffer copy with the staged bytes it copies.
+type fakeCopy struct {
+	ticket UploadTicket
+	region vk.BufferCopy
+	data   []byte
+}
+
+func newFakeUploadQueue(stagingSize int) *fakeUploadQueue {
+	return &fakeUploadQueue{staging: make([]byte, stagingSize), done: map[UploadTicket]bool{}}
+}
+
+func (q *fakeUploadQueue) newBatch() (*uploadBatch, error) {
+	q.batches++
+	return &uploadBatch{}, nil
+}
+
+func (q *fakeUploadQueue) begin(b *uploadBatch) error { return nil }
+
+func (q *fakeUploadQueue) stage(offset uint64, data []byte) {
+	copy(q.staging[offset:], data)
+}
+
+func (q *fakeUploadQueue) copyBuffer(b *uploadBatch, dst vk.Buffer, region vk.BufferCopy) {
+	data := append([]byte(nil), q.staging[region.SrcOffset:region.SrcOffset+region.Size]...)
+	q.copies = append(q.copies, fakeCopy{b.ticket, region, data})
+}
+
+func (q *fakeUploadQueue) copyBufferToImage(b *uploadBatch, dst vk.Image, region vk.BufferImageCopy) {
+}
+
+func (q *fakeUploadQueue) prepareImage(b *uploadBatch, barrier vk.ImageMemoryBarrier) {}
+
+func (q *fakeUploadQueue) submit(b *uploadBatch) error {
+	q.log = append(q.log, fmt.Sprintf("submit %d", b.ticket))
+	return nil
+}
+
+func (q *fakeUploadQueue) signaled(b *uploadBatch) bool { return q.done[b.ticket] }
+
+func (q *fakeUploadQueue) wait(b *uploadBatch) error {
+	q.log = append(q.log, fmt.Sprintf("wait %d", b.ticket))
+	q.done[b.ticket] = true
+	return nil
+}
+
+func (q *fakeUploadQueue) reset(b *uploadBatch)        {}
+func (q *fakeUploadQueue) destroyBatch(b *uploadBatch) {}
+func (q *fakeUploadQueue) destroy()                    {}
+
+func TestUploadBufferChunks(t *testing.T) {
+	// Chunks are half the ring: the third one only fits once the batch of
+	// the first two is flushed and done.
+	q := newFakeUploadQueue(64)
+	u := newUploadManager(q, 64, 0)
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	ticket, err := u.UploadBuffer(vk.NullBuffer, 1000, data, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket != 2 {
+		t.Errorf("ticket %d, want 2", ticket)
+	}
+	want := []struct {
+		ticket                UploadTicket
+		src, dst, size, first uint64
+	}{
+		{1, 0, 1000, 32, 0},
+		{1, 32, 1032, 32, 32},
+		{2, 0, 1064, 32, 64},
+		{2, 32, 1096, 4, 96},
+	}
+	if len(q.copies) != len(want) {
+		t.Fatalf("%d copies, want %d", len(q.copies), len(want))
+	}
+	for i, w := range want {
+		c := q.copies[i]
+		region := vk.BufferCopy{SrcOffset: vk.DeviceSize(w.src), DstOffset: vk.DeviceSize(w.dst), Size: vk.DeviceSize(w.size)}
+		if c.ticket != w.ticket || c.region != region || !bytes.Equal(c.data, data[w.first:w.first+w.size]) {
+			t.Errorf("copy %d of ticket %d %+v, want ticket %d %+v", i, c.ticket, c.region, w.ticket, region)
+		}
+	}
+	if log := []string{"submit 1", "wait 1"}; !reflect.DeepEqual(q.log, log) {
+		t.Errorf("log %q, want %q", q.log, log)
+	}
+	// The barrier covers the whole upload and ends the last batch.
+	if b := u.current; len(b.bufferBarriers) != 1 || b.bufferBarriers[0].Offset != 1000 || b.bufferBarriers[0].Size != 100 {
+		t.Errorf("barriers %+v, want one of 100 bytes at 1000", b.bufferBarriers)
+	}
+	// The batch of ticket 1 was recycled for ticket 2.
+	if q.batches != 1 {
+		t.Errorf("%d batches created, want 1", q.batches)
+	}
+}
+
+func TestUploadBufferEmpty(t *testing.T) {
+	q := newFakeUploadQueue(64)
+	u := newUploadManager(q, 64, 0)
+	ticket, err := u.UploadBuffer(vk.NullBuffer, 0, nil, 0, 0)
+	if err != nil || ticket != 0 {
+		t.Errorf("UploadBuffer of nothing = %d, %v, want ticket 0", ticket, err)
+	}
+	if u.current != nil || len(q.copies) != 0 {
+		t.Errorf("UploadBuffer of nothing recorded %d copies and batch %v", len(q.copies), u.current)
+	}
+}
+
+func TestUploadTickets(t *testing.T) {
+	q := newFakeUploadQueue(1024)
+	u := newUploadManager(q, 1024, 0)
+	upload := func() UploadTicket {
+		t.Helper()
+		ticket, err := u.UploadBuffer(vk.NullBuffer, 0, make([]byte, 16), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ticket
+	}
+	for want := UploadTicket(1); want <= 3; want++ {
+		// Uploads before a flush share its ticket.
+		if ticket, again := upload(), upload(); ticket != want || again != want {
+			t.Fatalf("tickets %d and %d, want %d", ticket, again, want)
+		}
+		if ticket, err := u.Flush(); err != nil || ticket != want {
+			t.Fatalf("Flush = %d, %v, want %d", ticket, err, want)
+		}
+	}
+	if ticket, err := u.Flush(); err != nil || ticket != 3 {
+		t.Errorf("Flush of nothing = %d, %v, want the last ticket 3", ticket, err)
+	}
+
+	// Batches retire in order: ticket 2 doesn't complete before 1 does.
+	q.done[2] = true
+	if u.Completed(1) || u.Completed(2) {
+		t.Errorf("tickets 1 or 2 completed before ticket 1 signaled")
+	}
+	q.done[1] = true
+	if !u.Completed(1) || !u.Completed(2) || u.Completed(3) {
+		t.Errorf("Completed of 1, 2 and 3 = %v, %v, %v, want true, true, false",
+			u.Completed(1), u.Completed(2), u.Completed(3))
+	}
+	if len(u.inflight) != 1 {
+		t.Errorf("%d batches in flight, want 1", len(u.inflight))
+	}
+
+	// Waiting for the current ticket flushes it, and waits for the older
+	// batches first.
+	ticket := upload()
+	if ticket != 4 {
+		t.Fatalf("ticket %d, want 4", ticket)
+	}
+	q.log = nil
+	if err := u.Wait(ticket); err != nil {
+		t.Fatal(err)
+	}
+	if log := []string{"submit 4", "wait 3", "wait 4"}; !reflect.DeepEqual(q.log, log) {
+		t.Errorf("log %q, want %q", q.log, log)
+	}
+	if !u.Completed(4) || len(u.inflight) != 0 {
+		t.Errorf("ticket 4 incomplete after Wait")
+	}
+	q.log = nil
+	if err := u.Wait(2); err != nil || len(q.log) != 0 {
+		t.Errorf("Wait of a completed ticket: %v, log %q", err, q.log)
+	}
+	// Ticket 4 reused the batch of a retired ticket.
+	if q.batches != 3 {
+		t.Errorf("%d batches created for 4 tickets, want 3", q.batches)
+	}
+	u.Destroy()
+}
@@ -0,0 +1,330 @@
+package softraster
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// attributes interpolated across a triangle: color and UV.
+const numAttributes = 6
+
+type clipVertex struct {
+	pos   [4]float32
+	attrs [numAttributes]float32
+}
+
+// screenVertex is a vertex in framebuffer coordinates. Attributes are
+// divided by w so they interpolate linearly in screen space.
+type screenVertex struct {
+	x, y, z float32
+	invW    float32
+	attrs   [numAttributes]float32
+}
+
+func transform(m *[4][4]float32, p [3]float32) [4]float32 {
+	var out [4]float32
+	for r := 0; r < 4; r++ {
+		out[r] = m[0][r]*p[0] + m[1][r]*p[1] + m[2][r]*p[2] + m[3][r]
+	}
+	return out
+}
+
+func (t *Target) draw(d *Draw, stats *Stats) {
+	if d.Mesh == nil {
+		return
+	}
+	mvp := [4][4]float32{d.MVP[0], d.MVP[1], d.MVP[2], d.MVP[3]}
+	verts := make([]clipVertex, len(d.Mesh.Vertices))
+	for i, v := range d.Mesh.Vertices {
+		verts[i] = clipVertex{
+			pos:   transform(&mvp, v.Position),
+			attrs: [numAttributes]float32{v.Color[0], v.Color[1], v.Color[2], v.Color[3], v.UV[0], v.UV[1]},
+		}
+	}
+	tex := newTexture(d.Texture)
+
+	count := len(d.Mesh.Indices)
+	if d.Mesh.Indices == nil {
+		count = len(verts)
+	}
+	for i := 0; i+2 < count; i += 3 {
+		var tri [3]clipVertex
+		valid := true
+		for k := range tri {
+			index := uint32(i + k)
+			if d.Mesh.Indices != nil {
+				index = d.Mesh.Indices[i+k]
+			}
+			if int(index) >= len(verts) {
+				valid = false
+				break
+			}
+			tri[k] = verts[index]
+		}
+		if !valid {
+			continue
+		}
+		stats.Triangles++
+		poly := clip(tri[:])
+		if len(poly) < 3 {
+			stats.Clipped++
+			continue
+		}
+		if len(poly) != 3 || poly[0] != tri[0] || poly[1] != tri[1] || poly[2] != tri[2] {
+			stats.Clipped++
+		}
+		screen := make([]screenVertex, len(poly))
+		for k, v := range poly {
+			screen[k] = t.toScreen(v)
+		}
+		if culled(screen, d.Cull, d.FrontFace) {
+			stats.Culled++
+			continue
+		}
+		for k := 1; k+1 < len(screen); k++ {
+			stats.Fragments += t.triangle(d, tex, screen[0], screen[k], screen[k+1])
+		}
+	}
+}
+
+// clipPlanes are the Vulkan view volume: -w <= x, y <= w and 0 <= z <= w.
+var clipPlanes = [][4]float32{
+	{1, 0, 0, 1},
+	{-1, 0, 0, 1},
+	{0, 1, 0, 1},
+	{0, -1, 0, 1},
+	{0, 0, 1, 0},
+	{0, 0, -1, 1},
+}
+
+func planeDistance(plane [4]float32, p [4]float32) float32 {
+	return plane[0]*p[0] + plane[1]*p[1] + plane[2]*p[2] + plane[3]*p[3]
+}
+
+// clip clips a polygon against the view volume, Sutherland-Hodgman style.
+func clip(poly []clipVertex) []clipVertex {
+	for _, plane := range clipPlanes {
+		if len(poly) == 0 {
+			break
+		}
+		var out []clipVertex
+		for i, cur := range poly {
+			next := poly[(i+1)%len(poly)]
+			dc := planeDistance(plane, cur.pos)
+			dn := planeDistance(plane, next.pos)
+			if dc >= 0 {
+				out = append(out, cur)
+			}
+			if (dc >= 0) != (dn >= 0) {
+				out = append(out, lerpVertex(cur, next, dc/(dc-dn)))
+			}
+		}
+		poly = out
+	}
+	return poly
+}
+
+func lerpVertex(a, b clipVertex, t float32) clipVertex {
+	var v clipVertex
+	for i := range v.pos {
+		v.pos[i] = a.pos[i] + (b.pos[i]-a.pos[i])*t
+	}
+	for i := range v.attrs {
+		v.attrs[i] = a.attrs[i] + (b.attrs[i]-a.attrs[i])*t
+	}
+	return v
+}
+
+// toScreen divides by w and applies a viewport covering the target with a
+// depth range of [0, 1].
+func (t *Target) toScreen(v clipVertex) screenVertex {
+	invW := 1 / v.pos[3]
+	s := screenVertex{
+		x:    (v.pos[0]*invW + 1) * 0.5 * float32(t.Width),
+		y:    (v.pos[1]*invW + 1) * 0.5 * float32(t.Height),
+		z:    v.pos[2] * invW,
+		invW: invW,
+	}
+	for i, a := range v.attrs {
+		s.attrs[i] = a * invW
+	}
+	return s
+}
+
+// signedArea is the polygon area the specification defines, negated
+// shoelace formula in framebuffer coordinates.
+func signedArea(poly []screenVertex) float32 {
+	var sum float32
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		sum += a.x*b.y - b.x*a.y
+	}
+	return -sum / 2
+}
+
+func culled(poly []screenVertex, cull CullMode, front FrontFace) bool {
+	area := signedArea(poly)
+	if area == 0 {
+		return true
+	}
+	isFront := area > 0
+	if front == Clockwise {
+		isFront = !isFront
+	}
+	switch cull {
+	case CullBack:
+		return !isFront
+	case CullFront:
+		return isFront
+	}
+	return false
+}
+
+func edge(a, b screenVertex, x, y float32) float32 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+// topLeft tells whether an edge owns the pixels exactly on it, for
+// triangles wound so that edge is positive inside.
+func topLeft(a, b screenVertex) bool {
+	dx, dy := b.x-a.x, b.y-a.y
+	return dy < 0 || (dy == 0 && dx > 0)
+}
+
+func covers(e float32, owns bool) bool {
+	return e > 0 || (e == 0 && owns)
+}
+
+// triangle rasterizes at pixel centers and returns how many fragments
+// were written.
+func (t *Target) triangle(d *Draw, tex *texture, v0, v1, v2 screenVertex) int {
+	area := edge(v0, v1, v2.x, v2.y)
+	if area == 0 {
+		return 0
+	}
+	if area < 0 {
+		v1, v2 = v2, v1
+		area = -area
+	}
+	minX := clampInt(int(math.Floor(float64(min3(v0.x, v1.x, v2.x)))), 0, t.Width)
+	maxX := clampInt(int(math.Ceil(float64(max3(v0.x, v1.x, v2.x)))), 0, t.Width)
+	minY := clampInt(int(math.Floor(float64(min3(v0.y, v1.y, v2.y)))), 0, t.Height)
+	maxY := clampInt(int(math.Ceil(float64(max3(v0.y, v1.y, v2.y)))), 0, t.Height)
+	owns0, owns1, owns2 := topLeft(v1, v2), topLeft(v2, v0), topLeft(v0, v1)
+
+	written := 0
+	for y := minY; y < maxY; y++ {
+		py := float32(y) + 0.5
+		for x := minX; x < maxX; x++ {
+			px := float32(x) + 0.5
+			e0 := edge(v1, v2, px, py)
+			e1 := edge(v2, v0, px, py)
+			e2 := edge(v0, v1, px, py)
+			if !covers(e0, owns0) || !covers(e1, owns1) || !covers(e2, owns2) {
+				continue
+			}
+			l0, l1, l2 := e0/area, e1/area, e2/area
+			i := y*t.Width + x
+			z := l0*v0.z + l1*v1.z + l2*v2.z
+			if d.DepthTest {
+				if z >= t.Depth[i] {
+					continue
+				}
+				t.Depth[i] = z
+			}
+			w := 1 / (l0*v0.invW + l1*v1.invW + l2*v2.invW)
+			var attrs [numAttributes]float32
+			for k := range attrs {
+				attrs[k] = (l0*v0.attrs[k] + l1*v1.attrs[k] + l2*v2.attrs[k]) * w
+			}
+			c := [4]float32{attrs[0], attrs[1], attrs[2], attrs[3]}
+			if tex != nil {
+				s := tex.sample(attrs[4], attrs[5])
+				for k := range c {
+					c[k] *= s[k]
+				}
+			}
+			t.Color[i] = c
+			written++
+		}
+	}
+	return written
+}
+
+func min3(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// texture is an image converted to float texels, sampled bilinearly with
+// repeat wrapping.
+type texture struct {
+	width, height int
+	texels        [][4]float32
+}
+
+func newTexture(img image.Image) *texture {
+	if img == nil {
+		return nil
+	}
+	b := img.Bounds()
+	tex := &texture{
+		width:  b.Dx(),
+		height: b.Dy(),
+		texels: make([][4]float32, b.Dx()*b.Dy()),
+	}
+	if tex.width == 0 || tex.height == 0 {
+		return nil
+	}
+	for y := 0; y < tex.height; y++ {
+		for x := 0; x < tex.width; x++ {
+			c := color.NRGBA64Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA64)
+			tex.texels[y*tex.width+x] = [4]float32{
+				float32(c.R) / 0xffff, float32(c.G) / 0xffff, float32(c.B) / 0xffff, float32(c.A) / 0xffff,
+			}
+		}
+	}
+	return tex
+}
+
+func wrap(i, n int) int {
+	i %= n
+	if i < 0 {
+		i += n
+	}
+	return i
+}
+
+func (t *texture) sample(u, v float32) [4]float32 {
+	fx := u*float32(t.width) - 0.5
+	fy := v*float32(t.height) - 0.5
+	x0 := int(math.Floor(float64(fx)))
+	y0 := int(math.Floor(float64(fy)))
+	ax, ay := fx-float32(x0), fy-float32(y0)
+	at := func(x, y int) [4]float32 {
+		return t.texels[wrap(y, t.height)*t.width+wrap(x, t.width)]
+	}
+	c00, c10 := at(x0, y0), at(x0+1, y0)
+	c01, c11 := at(x0, y0+1), at(x0+1, y0+1)
+	var c [4]float32
+	for k := range c {
+		top := c00[k] + (c10[k]-c00[k])*ax
+		bottom := c01[k] + (c11[k]-c01[k])*ax
+		c[k] = top + (bottom-top)*ay
+	}
+	return c
+}
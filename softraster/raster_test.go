@@ -0,0 +1,259 @@
+package softraster
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/xlab/linmath"
+)
+
+var identity = linmath.Mat4x4{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+
+// quad covers the view volume at depth z with UVs from 0 to 1. Its
+// triangles are clockwise in framebuffer coordinates, where y points down.
+func quad(z float32, c [4]float32) *Mesh {
+	return &Mesh{
+		Vertices: []Vertex{
+			{[3]float32{-1, -1, z}, c, [2]float32{0, 0}},
+			{[3]float32{1, -1, z}, c, [2]float32{1, 0}},
+			{[3]float32{1, 1, z}, c, [2]float32{1, 1}},
+			{[3]float32{-1, 1, z}, c, [2]float32{0, 1}},
+		},
+		Indices: []uint32{0, 1, 2, 0, 2, 3},
+	}
+}
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-5
+}
+
+func nearColor(a, b [4]float32) bool {
+	for k := range a {
+		if !near(a[k], b[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestToScreen(t *testing.T) {
+	target := NewTarget(4, 2)
+	v := clipVertex{pos: [4]float32{0, -2, 1, 2}, attrs: [numAttributes]float32{1, 0.5, 0, 1, 2, 4}}
+	s := target.toScreen(v)
+	want := screenVertex{x: 2, y: 0, z: 0.5, invW: 0.5, attrs: [numAttributes]float32{0.5, 0.25, 0, 0.5, 1, 2}}
+	if s != want {
+		t.Errorf("toScreen = %+v, want %+v", s, want)
+	}
+}
+
+func TestCulling(t *testing.T) {
+	red := [4]float32{1, 0, 0, 1}
+	clockwise := quad(0.5, red)
+	counterClockwise := quad(0.5, red)
+	counterClockwise.Indices = []uint32{0, 2, 1, 0, 3, 2}
+
+	tests := []struct {
+		name  string
+		mesh  *Mesh
+		cull  CullMode
+		front FrontFace
+		drawn bool
+	}{
+		{"clockwise, no culling", clockwise, CullNone, CounterClockwise, true},
+		{"clockwise back face", clockwise, CullBack, CounterClockwise, false},
+		{"clockwise front face", clockwise, CullBack, Clockwise, true},
+		{"clockwise back face, front culled", clockwise, CullFront, CounterClockwise, true},
+		{"counterclockwise front face", counterClockwise, CullBack, CounterClockwise, true},
+		{"counterclockwise front face, front culled", counterClockwise, CullFront, CounterClockwise, false},
+		{"counterclockwise back face", counterClockwise, CullBack, Clockwise, false},
+	}
+	for _, test := range tests {
+		target := NewTarget(4, 4)
+		stats := target.Render(Draw{Mesh: test.mesh, MVP: identity, Cull: test.cull, FrontFace: test.front})
+		// The diagonal the triangles share is drawn once.
+		want := Stats{Triangles: 2, Fragments: 16}
+		if !test.drawn {
+			want = Stats{Triangles: 2, Culled: 2}
+		}
+		if stats != want {
+			t.Errorf("%s: stats %+v, want %+v", test.name, stats, want)
+		}
+		if drawn := nearColor(target.Color[5], red); drawn != test.drawn {
+			t.Errorf("%s: drawn %v, want %v", test.name, drawn, test.drawn)
+		}
+	}
+
+	// Degenerate triangles have no area and are culled.
+	line := &Mesh{Vertices: []Vertex{{Position: [3]float32{-1, -1, 0}}, {Position: [3]float32{1, 1, 0}},
+		{Position: [3]float32{0, 0, 0}}}}
+	if stats := NewTarget(4, 4).Render(Draw{Mesh: line, MVP: identity}); stats.Culled != 1 || stats.Fragments != 0 {
+		t.Errorf("degenerate triangle: stats %+v", stats)
+	}
+}
+
+func TestDepthTest(t *testing.T) {
+	red, green := [4]float32{1, 0, 0, 1}, [4]float32{0, 1, 0, 1}
+	tests := []struct {
+		name      string
+		first     *Mesh
+		second    *Mesh
+		depthTest bool
+		want      [4]float32
+		depth     float32
+	}{
+		{"far then near", quad(0.5, red), quad(0.25, green), true, green, 0.25},
+		{"near then far", quad(0.25, green), quad(0.5, red), true, green, 0.25},
+		// Fragments pass when closer, equal ones fail.
+		{"equal depth", quad(0.5, red), quad(0.5, green), true, red, 0.5},
+		{"no depth test", quad(0.25, green), quad(0.5, red), false, red, 1},
+	}
+	for _, test := range tests {
+		target := NewTarget(4, 4)
+		target.Clear([4]float32{0, 0, 0, 1}, 1)
+		target.Render(
+			Draw{Mesh: test.first, MVP: identity, DepthTest: test.depthTest},
+			Draw{Mesh: test.second, MVP: identity, DepthTest: test.depthTest},
+		)
+		for i := range target.Color {
+			if !nearColor(target.Color[i], test.want) || !near(target.Depth[i], test.depth) {
+				t.Errorf("%s: pixel %d is %v at depth %g, want %v at %g", test.name, i,
+					target.Color[i], target.Depth[i], test.want, test.depth)
+				break
+			}
+		}
+	}
+}
+
+func TestNearPlaneClipping(t *testing.T) {
+	// The apex is behind the near plane, the edges to it cross z = 0 at
+	// y = 0, the middle of the target.
+	tri := func(apex float32) *Mesh {
+		return &Mesh{Vertices: []Vertex{
+			{[3]float32{-1, -1, 0.5}, [4]float32{1, 0, 0, 1}, [2]float32{}},
+			{[3]float32{1, -1, 0.5}, [4]float32{1, 0, 0, 1}, [2]float32{}},
+			{[3]float32{0, 1, apex}, [4]float32{0, 0, 1, 1}, [2]float32{}},
+		}}
+	}
+	m := [4][4]float32{identity[0], identity[1], identity[2], identity[3]}
+	verts := make([]clipVertex, 3)
+	for i, v := range tri(-0.5).Vertices {
+		verts[i] = clipVertex{pos: transform(&m, v.Position),
+			attrs: [numAttributes]float32{v.Color[0], v.Color[1], v.Color[2], v.Color[3]}}
+	}
+	poly := clip(verts)
+	if len(poly) != 4 {
+		t.Fatalf("clipped triangle has %d vertices, want 4", len(poly))
+	}
+	for _, v := range poly {
+		if v.pos[2] < 0 {
+			t.Errorf("vertex %v in front of the near plane", v.pos)
+		}
+		// The new vertices are halfway, with half of each color.
+		if v.pos[2] == 0 && (v.pos[1] != 0 || !near(v.attrs[0], 0.5) || !near(v.attrs[2], 0.5)) {
+			t.Errorf("vertex on the near plane %+v, want y 0 and half red and blue", v)
+		}
+	}
+
+	target := NewTarget(8, 8)
+	stats := target.Render(Draw{Mesh: tri(-0.5), MVP: identity})
+	if stats.Clipped != 1 || stats.Culled != 0 || stats.Fragments == 0 {
+		t.Errorf("stats %+v, want one clipped triangle", stats)
+	}
+	for y := 0; y < target.Height; y++ {
+		for x := 0; x < target.Width; x++ {
+			// The top half is covered, between the edges to the apex.
+			px, py := float32(x)+0.5, float32(y)+0.5
+			want := y < 4 && px > py/2 && px < float32(target.Width)-py/2
+			if covered := target.Color[y*target.Width+x][3] != 0; covered != want {
+				t.Errorf("pixel %d, %d covered %v, want %v", x, y, covered, want)
+			}
+		}
+	}
+
+	// A triangle entirely behind the near plane is clipped away.
+	behind := tri(-0.5)
+	for i := range behind.Vertices {
+		behind.Vertices[i].Position[2] = -0.5
+	}
+	if stats := NewTarget(8, 8).Render(Draw{Mesh: behind, MVP: identity}); stats.Clipped != 1 || stats.Fragments != 0 {
+		t.Errorf("triangle behind the near plane: stats %+v", stats)
+	}
+}
+
+func TestPerspectiveInterpolation(t *testing.T) {
+	// Positions are x and y in clip space and w in z, clip space z is 0.
+	mvp := linmath.Mat4x4{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 0, 1}, {0, 0, 0, 0}}
+	// In framebuffer coordinates the vertices are at 0, 0, at 4, 0 with a
+	// w of 3 and at 0, 4. Red is 1 at the far vertex only.
+	mesh := &Mesh{Vertices: []Vertex{
+		{[3]float32{-1, -1, 1}, [4]float32{0, 0, 0, 1}, [2]float32{}},
+		{[3]float32{3, -3, 3}, [4]float32{1, 0, 0, 1}, [2]float32{}},
+		{[3]float32{-1, 1, 1}, [4]float32{0, 0, 0, 1}, [2]float32{}},
+	}}
+	target := NewTarget(4, 4)
+	target.Render(Draw{Mesh: mesh, MVP: mvp})
+
+	// With screen space weights l, red is l1/3 / (l0 + l1/3 + l2) rather
+	// than l1.
+	tests := []struct {
+		x, y int
+		want float32
+	}{
+		{0, 0, 1. / 22}, // l = 3/4, 1/8, 1/8
+		{1, 0, 1. / 6},  // l = 1/2, 3/8, 1/8
+		{2, 0, 5. / 14}, // l = 1/4, 5/8, 1/8
+		{0, 1, 1. / 22}, // l = 1/2, 1/8, 3/8
+	}
+	for _, test := range tests {
+		c := target.Color[test.y*target.Width+test.x]
+		if !near(c[0], test.want) || !near(c[3], 1) {
+			t.Errorf("pixel %d, %d is %v, want red %g", test.x, test.y, c, test.want)
+		}
+	}
+}
+
+func TestTextureSampling(t *testing.T) {
+	// 16 bit, so that the transparent white keeps its color.
+	img := image.NewNRGBA64(image.Rect(0, 0, 2, 2))
+	img.SetNRGBA64(0, 0, color.NRGBA64{0xffff, 0, 0, 0xffff})
+	img.SetNRGBA64(1, 0, color.NRGBA64{0, 0xffff, 0, 0xffff})
+	img.SetNRGBA64(0, 1, color.NRGBA64{0, 0, 0xffff, 0xffff})
+	img.SetNRGBA64(1, 1, color.NRGBA64{0xffff, 0xffff, 0xffff, 0})
+	tex := newTexture(img)
+
+	tests := []struct {
+		u, v float32
+		want [4]float32
+	}{
+		// Texel centers sample one texel.
+		{0.25, 0.25, [4]float32{1, 0, 0, 1}},
+		{0.75, 0.75, [4]float32{1, 1, 1, 0}},
+		// Halfway between two texels.
+		{0.5, 0.25, [4]float32{0.5, 0.5, 0, 1}},
+		{0.5, 0.5, [4]float32{0.5, 0.5, 0.5, 0.75}},
+		// Coordinates repeat, the edges blend with the opposite side.
+		{1.25, -0.75, [4]float32{1, 0, 0, 1}},
+		{0, 0.25, [4]float32{0.5, 0.5, 0, 1}},
+	}
+	for _, test := range tests {
+		if c := tex.sample(test.u, test.v); !nearColor(c, test.want) {
+			t.Errorf("sample(%g, %g) = %v, want %v", test.u, test.v, c, test.want)
+		}
+	}
+
+	// Each pixel of a 2x2 target is at the center of a texel, modulated by
+	// the vertex color.
+	target := NewTarget(2, 2)
+	target.Render(Draw{Mesh: quad(0.5, [4]float32{1, 0.5, 1, 1}), MVP: identity, Texture: img})
+	want := [][4]float32{{1, 0, 0, 1}, {0, 0.5, 0, 1}, {0, 0, 1, 1}, {1, 0.5, 1, 0}}
+	for i, c := range target.Color {
+		if !nearColor(c, want[i]) {
+			t.Errorf("pixel %d is %v, want %v", i, c, want[i])
+		}
+	}
+	if newTexture(nil) != nil || newTexture(image.NewNRGBA(image.Rectangle{})) != nil {
+		t.Errorf("newTexture of no image isn't nil")
+	}
+}
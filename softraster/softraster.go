@@ -0,0 +1,139 @@
+// Package softraster is a small software rasterizer that draws the scenes
+// the samples submit to Vulkan, so their images can be compared with
+// golden images on machines without a GPU.
+//
+// It follows the Vulkan conventions: clip space z runs from 0 to w, NDC y
+// points down and a triangle's facing is decided from its area in
+// framebuffer coordinates, as in the "Basic Polygon Rasterization" section
+// of the specification.
+package softraster
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/xlab/linmath"
+)
+
+type Vertex struct {
+	Position [3]float32
+	Color    [4]float32
+	UV       [2]float32
+}
+
+type Mesh struct {
+	Vertices []Vertex
+	// Indices form a triangle list, nil draws the vertices in order.
+	Indices []uint32
+}
+
+type CullMode int
+
+const (
+	CullNone CullMode = iota
+	CullBack
+	CullFront
+)
+
+type FrontFace int
+
+const (
+	CounterClockwise FrontFace = iota
+	Clockwise
+)
+
+// Draw is one draw call. The color of a fragment is the interpolated
+// vertex color, multiplied by the texture sampled at the interpolated UV
+// when there is one.
+type Draw struct {
+	Label     string
+	Mesh      *Mesh
+	MVP       linmath.Mat4x4
+	Cull      CullMode
+	FrontFace FrontFace
+	// DepthTest passes fragments closer than the stored depth and writes
+	// their depth.
+	DepthTest bool
+	Texture   image.Image
+}
+
+// Stats counts what a Render did.
+type Stats struct {
+	Triangles int
+	Culled    int
+	Clipped   int
+	Fragments int
+}
+
+// Target is a color and a depth buffer.
+type Target struct {
+	Width, Height int
+	Color         [][4]float32
+	Depth         []float32
+}
+
+func NewTarget(width, height int) *Target {
+	return &Target{
+		Width:  width,
+		Height: height,
+		Color:  make([][4]float32, width*height),
+		Depth:  make([]float32, width*height),
+	}
+}
+
+func (t *Target) Clear(c [4]float32, depth float32) {
+	for i := range t.Color {
+		t.Color[i] = c
+		t.Depth[i] = depth
+	}
+}
+
+// Render rasterizes the draws in order.
+func (t *Target) Render(draws ...Draw) Stats {
+	var stats Stats
+	for i := range draws {
+		t.draw(&draws[i], &stats)
+	}
+	return stats
+}
+
+// Image converts the color buffer to 8 bit, values are clamped to [0, 1].
+func (t *Target) Image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, t.Width, t.Height))
+	for y := 0; y < t.Height; y++ {
+		for x := 0; x < t.Width; x++ {
+			c := t.Color[y*t.Width+x]
+			img.SetNRGBA(x, y, color.NRGBA{unorm8(c[0]), unorm8(c[1]), unorm8(c[2]), unorm8(c[3])})
+		}
+	}
+	return img
+}
+
+func unorm8(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 255
+	}
+	return uint8(v*255 + 0.5)
+}
+
+func (t *Target) WritePNG(w io.Writer) error {
+	return png.Encode(w, t.Image())
+}
+
+func (t *Target) SavePNG(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = t.WritePNG(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
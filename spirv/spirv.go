@@ -0,0 +1,623 @@
+// Package spirv reflects the interface of SPIR-V shader modules: entry
+// points, descriptor bindings, push constant and uniform block layouts,
+// specialization constants and the input and output locations.
+package spirv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const magic uint32 = 0x07230203
+
+// MaxVersion is the newest SPIR-V version, 1.6.
+const MaxVersion = 0x00010600
+
+// Check validates the header of a module handed to Vulkan, which reads
+// it in little endian words, and returns its version.
+func Check(code []byte) (uint32, error) {
+	if len(code) < 20 {
+		return 0, errors.New("spirv: module is shorter than its header")
+	}
+	if len(code)%4 != 0 {
+		return 0, fmt.Errorf("spirv: size %d is not a multiple of 4", len(code))
+	}
+	switch magic {
+	case binary.LittleEndian.Uint32(code):
+	case binary.BigEndian.Uint32(code):
+		return 0, errors.New("spirv: module is big endian")
+	default:
+		return 0, errors.New("spirv: bad magic number")
+	}
+	version := binary.LittleEndian.Uint32(code[4:])
+	if version < 0x00010000 || version > MaxVersion || version&0xff0000ff != 0 {
+		return version, fmt.Errorf("spirv: unsupported version %d.%d", version>>16, version>>8&0xff)
+	}
+	return version, nil
+}
+
+// Stage is a shader stage, the bits match VkShaderStageFlagBits.
+type Stage uint32
+
+const (
+	StageVertex Stage = 1 << iota
+	StageTessellationControl
+	StageTessellationEvaluation
+	StageGeometry
+	StageFragment
+	StageCompute
+)
+
+// executionModels maps SPIR-V execution models to stages.
+var executionModels = map[uint32]Stage{
+	0: StageVertex,
+	1: StageTessellationControl,
+	2: StageTessellationEvaluation,
+	3: StageGeometry,
+	4: StageFragment,
+	5: StageCompute,
+}
+
+// DescriptorType is the kind of descriptor a binding expects.
+type DescriptorType int
+
+const (
+	UniformBuffer DescriptorType = iota
+	StorageBuffer
+	CombinedImageSampler
+	SampledImageDescriptor
+	StorageImage
+	SamplerDescriptor
+	UniformTexelBuffer
+	StorageTexelBuffer
+	InputAttachment
+	AccelerationStructureDescriptor
+)
+
+type EntryPoint struct {
+	Name  string
+	Stage Stage
+}
+
+// Binding is a resource variable bound through a descriptor set.
+type Binding struct {
+	Name    string
+	Set     uint32
+	Binding uint32
+	Type    DescriptorType
+	// Count is the array size, 0 for runtime arrays.
+	Count uint32
+	// Block is the block struct of buffers, nil for images and samplers.
+	Block *Type
+	// InputAttachmentIndex is set for input attachments.
+	InputAttachmentIndex uint32
+}
+
+// PushConstant is a push constant block.
+type PushConstant struct {
+	Name  string
+	Block *Type
+}
+
+// Variable is an input or output of a stage.
+type Variable struct {
+	Name      string
+	Location  uint32
+	Component uint32
+	Type      *Type
+}
+
+// SpecConstant is a specialization constant with its default value, bool
+// constants default to 0 or 1 and floats to their bits.
+type SpecConstant struct {
+	Name    string
+	ID      uint32
+	Type    *Type
+	Default uint64
+}
+
+// Float returns the default value of a float constant.
+func (c SpecConstant) Float() float64 {
+	if c.Type.Width == 64 {
+		return math.Float64frombits(c.Default)
+	}
+	return float64(math.Float32frombits(uint32(c.Default)))
+}
+
+// Module is the reflected interface of a shader module.
+type Module struct {
+	// Version is the SPIR-V version, 0x00010000 for 1.0.
+	Version       uint32
+	Generator     uint32
+	EntryPoints   []EntryPoint
+	Bindings      []Binding
+	PushConstants []PushConstant
+	Inputs        []Variable
+	Outputs       []Variable
+	SpecConstants []SpecConstant
+}
+
+// Stages returns the stages of all entry points.
+func (m *Module) Stages() Stage {
+	var stages Stage
+	for _, e := range m.EntryPoints {
+		stages |= e.Stage
+	}
+	return stages
+}
+
+// Binding looks up the binding of a set.
+func (m *Module) Binding(set, binding uint32) (Binding, bool) {
+	for _, b := range m.Bindings {
+		if b.Set == set && b.Binding == binding {
+			return b, true
+		}
+	}
+	return Binding{}, false
+}
+
+// Opcodes, decorations and storage classes from the SPIR-V specification
+// that reflection needs.
+const (
+	opName                       = 5
+	opMemberName                 = 6
+	opEntryPoint                 = 15
+	opTypeVoid                   = 19
+	opTypeBool                   = 20
+	opTypeInt                    = 21
+	opTypeFloat                  = 22
+	opTypeVector                 = 23
+	opTypeMatrix                 = 24
+	opTypeImage                  = 25
+	opTypeSampler                = 26
+	opTypeSampledImage           = 27
+	opTypeArray                  = 28
+	opTypeRuntimeArray           = 29
+	opTypeStruct                 = 30
+	opTypePointer                = 32
+	opConstantTrue               = 41
+	opConstantFalse              = 42
+	opConstant                   = 43
+	opSpecConstantTrue           = 48
+	opSpecConstantFalse          = 49
+	opSpecConstant               = 50
+	opVariable                   = 59
+	opDecorate                   = 71
+	opMemberDecorate             = 72
+	opTypeAccelerationStructure  = 5341
+	decorationSpecID             = 1
+	decorationBlock              = 2
+	decorationBufferBlock        = 3
+	decorationRowMajor           = 4
+	decorationArrayStride        = 6
+	decorationMatrixStride       = 7
+	decorationBuiltIn            = 11
+	decorationLocation           = 30
+	decorationComponent          = 31
+	decorationBinding            = 33
+	decorationDescriptorSet      = 34
+	decorationOffset             = 35
+	decorationInputAttachmentIdx = 43
+	storageUniformConstant       = 0
+	storageInput                 = 1
+	storageUniform               = 2
+	storageOutput                = 3
+	storagePushConstant          = 9
+	storageStorageBuffer         = 12
+)
+
+// decorations of an id or a struct member.
+type decorations map[uint32][]uint32
+
+func (d decorations) has(decoration uint32) bool {
+	_, ok := d[decoration]
+	return ok
+}
+
+func (d decorations) value(decoration uint32) uint32 {
+	if v := d[decoration]; len(v) > 0 {
+		return v[0]
+	}
+	return 0
+}
+
+type instruction struct {
+	op       uint32
+	operands []uint32
+}
+
+type variable struct {
+	id, typ, storage uint32
+}
+
+type parser struct {
+	names         map[uint32]string
+	memberNames   map[uint32]map[uint32]string
+	decorations   map[uint32]decorations
+	members       map[uint32]map[uint32]decorations
+	types         map[uint32]instruction
+	constants     map[uint32]instruction
+	specConstants []uint32
+	variables     []variable
+	resolved      map[uint32]*Type
+}
+
+// Parse reflects a SPIR-V binary. Both byte orders are accepted.
+func Parse(code []byte) (*Module, error) {
+	if len(code)%4 != 0 {
+		return nil, fmt.Errorf("spirv: size %d is not a multiple of 4", len(code))
+	}
+	if len(code) < 20 {
+		return nil, errors.New("spirv: module is shorter than its header")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(code) != magic {
+		order = binary.BigEndian
+		if order.Uint32(code) != magic {
+			return nil, errors.New("spirv: bad magic number")
+		}
+	}
+	words := make([]uint32, len(code)/4)
+	for i := range words {
+		words[i] = order.Uint32(code[4*i:])
+	}
+
+	m := &Module{
+		Version:   words[1],
+		Generator: words[2],
+	}
+	p := &parser{
+		names:       make(map[uint32]string),
+		memberNames: make(map[uint32]map[uint32]string),
+		decorations: make(map[uint32]decorations),
+		members:     make(map[uint32]map[uint32]decorations),
+		types:       make(map[uint32]instruction),
+		constants:   make(map[uint32]instruction),
+		resolved:    make(map[uint32]*Type),
+	}
+
+	// Phase 1: collect the instructions, types may be decorated before
+	// they are declared so they are resolved afterwards
+
+	for i := 5; i < len(words); {
+		count := int(words[i] >> 16)
+		op := words[i] & 0xffff
+		if count == 0 || i+count > len(words) {
+			return nil, fmt.Errorf("spirv: invalid instruction length %d at word %d", count, i)
+		}
+		operands := words[i+1 : i+count]
+		i += count
+		if err := p.instruction(m, op, operands); err != nil {
+			return nil, err
+		}
+	}
+
+	// Phase 2: resolve the variables
+
+	for _, v := range p.variables {
+		if err := p.variable(m, v); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range p.specConstants {
+		c := p.constants[id]
+		typ, err := p.resolve(c.operands[0])
+		if err != nil {
+			return nil, err
+		}
+		sc := SpecConstant{
+			Name: p.names[id],
+			ID:   p.decorations[id].value(decorationSpecID),
+			Type: typ,
+		}
+		switch c.op {
+		case opSpecConstantTrue:
+			sc.Default = 1
+		case opSpecConstant:
+			sc.Default = literal(c.operands[2:])
+		}
+		m.SpecConstants = append(m.SpecConstants, sc)
+	}
+
+	sort.Slice(m.Bindings, func(i, j int) bool {
+		a, b := m.Bindings[i], m.Bindings[j]
+		return a.Set < b.Set || a.Set == b.Set && a.Binding < b.Binding
+	})
+	sort.Slice(m.Inputs, func(i, j int) bool { return m.Inputs[i].Location < m.Inputs[j].Location })
+	sort.Slice(m.Outputs, func(i, j int) bool { return m.Outputs[i].Location < m.Outputs[j].Location })
+	sort.Slice(m.SpecConstants, func(i, j int) bool { return m.SpecConstants[i].ID < m.SpecConstants[j].ID })
+	return m, nil
+}
+
+// minOperands guards the instructions whose operands are read unchecked.
+var minOperands = map[uint32]int{
+	opName: 1, opMemberName: 2, opEntryPoint: 2, opDecorate: 2, opMemberDecorate: 3,
+	opVariable: 3, opConstant: 3, opSpecConstant: 3, opConstantTrue: 2, opConstantFalse: 2,
+	opSpecConstantTrue: 2, opSpecConstantFalse: 2,
+}
+
+func (p *parser) instruction(m *Module, op uint32, operands []uint32) error {
+	if n, ok := minOperands[op]; ok && len(operands) < n {
+		return fmt.Errorf("spirv: opcode %d with %d operands", op, len(operands))
+	}
+	switch op {
+	case opName:
+		p.names[operands[0]] = str(operands[1:])
+	case opMemberName:
+		if p.memberNames[operands[0]] == nil {
+			p.memberNames[operands[0]] = make(map[uint32]string)
+		}
+		p.memberNames[operands[0]][operands[1]] = str(operands[2:])
+	case opEntryPoint:
+		stage, ok := executionModels[operands[0]]
+		if !ok {
+			return fmt.Errorf("spirv: unsupported execution model %d", operands[0])
+		}
+		m.EntryPoints = append(m.EntryPoints, EntryPoint{Name: str(operands[2:]), Stage: stage})
+	case opDecorate:
+		if p.decorations[operands[0]] == nil {
+			p.decorations[operands[0]] = make(decorations)
+		}
+		p.decorations[operands[0]][operands[1]] = operands[2:]
+	case opMemberDecorate:
+		if p.members[operands[0]] == nil {
+			p.members[operands[0]] = make(map[uint32]decorations)
+		}
+		if p.members[operands[0]][operands[1]] == nil {
+			p.members[operands[0]][operands[1]] = make(decorations)
+		}
+		p.members[operands[0]][operands[1]][operands[2]] = operands[3:]
+	case opTypeVoid, opTypeBool, opTypeInt, opTypeFloat, opTypeVector, opTypeMatrix,
+		opTypeImage, opTypeSampler, opTypeSampledImage, opTypeArray, opTypeRuntimeArray,
+		opTypeStruct, opTypePointer, opTypeAccelerationStructure:
+		if len(operands) < 1 {
+			return fmt.Errorf("spirv: type opcode %d without result", op)
+		}
+		p.types[operands[0]] = instruction{op, operands}
+	case opConstant, opConstantTrue, opConstantFalse:
+		p.constants[operands[1]] = instruction{op, operands}
+	case opSpecConstant, opSpecConstantTrue, opSpecConstantFalse:
+		p.constants[operands[1]] = instruction{op, operands}
+		p.specConstants = append(p.specConstants, operands[1])
+	case opVariable:
+		p.variables = append(p.variables, variable{id: operands[1], typ: operands[0], storage: operands[2]})
+	}
+	return nil
+}
+
+// variable sorts a global variable into the module interface.
+func (p *parser) variable(m *Module, v variable) error {
+	ptr, ok := p.types[v.typ]
+	if !ok || ptr.op != opTypePointer || len(ptr.operands) < 3 {
+		return fmt.Errorf("spirv: variable %%%d is not a pointer", v.id)
+	}
+	typ, err := p.resolve(ptr.operands[2])
+	if err != nil {
+		return err
+	}
+	d := p.decorations[v.id]
+	name := p.names[v.id]
+
+	switch v.storage {
+	case storageInput, storageOutput:
+		if d.has(decorationBuiltIn) || typ.BuiltIn || !d.has(decorationLocation) {
+			return nil
+		}
+		variable := Variable{
+			Name:      name,
+			Location:  d.value(decorationLocation),
+			Component: d.value(decorationComponent),
+			Type:      typ,
+		}
+		if v.storage == storageInput {
+			m.Inputs = append(m.Inputs, variable)
+		} else {
+			m.Outputs = append(m.Outputs, variable)
+		}
+	case storagePushConstant:
+		m.PushConstants = append(m.PushConstants, PushConstant{Name: name, Block: typ})
+	case storageUniformConstant, storageUniform, storageStorageBuffer:
+		b := Binding{
+			Name:                 name,
+			Set:                  d.value(decorationDescriptorSet),
+			Binding:              d.value(decorationBinding),
+			Count:                1,
+			InputAttachmentIndex: d.value(decorationInputAttachmentIdx),
+		}
+		for typ.Kind == Array || typ.Kind == RuntimeArray {
+			if typ.Kind == RuntimeArray {
+				b.Count = 0
+			} else {
+				b.Count *= typ.Len
+			}
+			typ = typ.Elem
+		}
+		b.Type, err = descriptorType(v.storage, typ)
+		if err != nil {
+			return fmt.Errorf("spirv: binding %q: %s", name, err)
+		}
+		if typ.Kind == Struct {
+			b.Block = typ
+		}
+		m.Bindings = append(m.Bindings, b)
+	}
+	return nil
+}
+
+func descriptorType(storage uint32, typ *Type) (DescriptorType, error) {
+	switch typ.Kind {
+	case Struct:
+		if storage == storageStorageBuffer || typ.BufferBlock {
+			return StorageBuffer, nil
+		}
+		if typ.Block {
+			return UniformBuffer, nil
+		}
+	case SampledImage:
+		if typ.Elem.Image.Dim == DimBuffer {
+			return UniformTexelBuffer, nil
+		}
+		return CombinedImageSampler, nil
+	case Image:
+		switch {
+		case typ.Image.Dim == DimSubpassData:
+			return InputAttachment, nil
+		case typ.Image.Dim == DimBuffer && typ.Image.Sampled == 2:
+			return StorageTexelBuffer, nil
+		case typ.Image.Dim == DimBuffer:
+			return UniformTexelBuffer, nil
+		case typ.Image.Sampled == 2:
+			return StorageImage, nil
+		}
+		return SampledImageDescriptor, nil
+	case Sampler:
+		return SamplerDescriptor, nil
+	case AccelerationStructure:
+		return AccelerationStructureDescriptor, nil
+	}
+	return 0, fmt.Errorf("%s is not a resource", typ)
+}
+
+// resolve builds the type of id with its decorations applied.
+func (p *parser) resolve(id uint32) (*Type, error) {
+	if t, ok := p.resolved[id]; ok {
+		if t == nil {
+			return nil, fmt.Errorf("spirv: type %%%d refers to itself", id)
+		}
+		return t, nil
+	}
+	inst, ok := p.types[id]
+	if !ok {
+		return nil, fmt.Errorf("spirv: undefined type %%%d", id)
+	}
+	p.resolved[id] = nil
+	ops := inst.operands
+	operand := func(i int) uint32 {
+		if i < len(ops) {
+			return ops[i]
+		}
+		return 0
+	}
+	elem := func() (*Type, error) { return p.resolve(operand(1)) }
+
+	t := &Type{Name: p.names[id]}
+	d := p.decorations[id]
+	var err error
+	switch inst.op {
+	case opTypeVoid:
+		t.Kind = Void
+	case opTypeBool:
+		t.Kind = Bool
+	case opTypeInt:
+		t.Kind = Int
+		t.Width = operand(1)
+		t.Signed = operand(2) != 0
+	case opTypeFloat:
+		t.Kind = Float
+		t.Width = operand(1)
+	case opTypeVector, opTypeMatrix:
+		t.Kind = Vector
+		if inst.op == opTypeMatrix {
+			t.Kind = Matrix
+		}
+		t.Elem, err = elem()
+		t.Len = operand(2)
+	case opTypeImage:
+		t.Kind = Image
+		t.Elem, err = elem()
+		t.Image = ImageInfo{
+			Dim:     Dim(operand(2)),
+			Depth:   operand(3),
+			Arrayed: operand(4) != 0,
+			MS:      operand(5) != 0,
+			Sampled: operand(6),
+			Format:  operand(7),
+		}
+	case opTypeSampler:
+		t.Kind = Sampler
+	case opTypeSampledImage:
+		t.Kind = SampledImage
+		t.Elem, err = elem()
+	case opTypeArray:
+		t.Kind = Array
+		t.Elem, err = elem()
+		length, ok := p.constants[operand(2)]
+		if !ok || len(length.operands) < 3 {
+			return nil, fmt.Errorf("spirv: array %%%d has no constant length", id)
+		}
+		t.Len = uint32(literal(length.operands[2:]))
+		t.Stride = d.value(decorationArrayStride)
+	case opTypeRuntimeArray:
+		t.Kind = RuntimeArray
+		t.Elem, err = elem()
+		t.Stride = d.value(decorationArrayStride)
+	case opTypeStruct:
+		t.Kind = Struct
+		t.Block = d.has(decorationBlock)
+		t.BufferBlock = d.has(decorationBufferBlock)
+		for i, typeID := range ops[1:] {
+			md := p.members[id][uint32(i)]
+			mt, err := p.resolve(typeID)
+			if err != nil {
+				return nil, err
+			}
+			member := Member{
+				Name:         p.memberNames[id][uint32(i)],
+				Offset:       md.value(decorationOffset),
+				Type:         mt,
+				MatrixStride: md.value(decorationMatrixStride),
+				RowMajor:     md.has(decorationRowMajor),
+				BuiltIn:      md.has(decorationBuiltIn),
+			}
+			member.Size = size(mt, member.MatrixStride, member.RowMajor)
+			t.BuiltIn = t.BuiltIn || member.BuiltIn
+			t.Members = append(t.Members, member)
+		}
+	case opTypePointer:
+		// pointers only appear as members of physical storage buffers,
+		// reflect them as the type they point to.
+		t, err = p.resolve(operand(2))
+		if err == nil {
+			p.resolved[id] = t
+		}
+		return t, err
+	case opTypeAccelerationStructure:
+		t.Kind = AccelerationStructure
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Size = size(t, 0, false)
+	p.resolved[id] = t
+	return t, nil
+}
+
+// str decodes a nul terminated literal string.
+func str(words []uint32) string {
+	b := make([]byte, 0, 4*len(words))
+	for _, w := range words {
+		for i := uint(0); i < 4; i++ {
+			c := byte(w >> (8 * i))
+			if c == 0 {
+				return string(b)
+			}
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// literal decodes a number of one or two words, low order word first.
+func literal(words []uint32) uint64 {
+	var v uint64
+	if len(words) > 0 {
+		v = uint64(words[0])
+	}
+	if len(words) > 1 {
+		v |= uint64(words[1]) << 32
+	}
+	return v
+}
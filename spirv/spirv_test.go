@@ -0,0 +1,152 @@
+package spirv
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+func parseFile(t *testing.T, name string) *Module {
+	t.Helper()
+	code, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(code)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	return m
+}
+
+// checkVariables compares the name, location and type of variables.
+func checkVariables(t *testing.T, what string, vars []Variable, want []Variable) {
+	t.Helper()
+	if len(vars) != len(want) {
+		t.Fatalf("%d %s, want %d", len(vars), what, len(want))
+	}
+	for i, v := range vars {
+		if v.Name != want[i].Name || v.Location != want[i].Location || v.Type.String() != want[i].Type.String() {
+			t.Errorf("%s %d is %s at %d of %s, want %s at %d of %s", what, i,
+				v.Name, v.Location, v.Type, want[i].Name, want[i].Location, want[i].Type)
+		}
+	}
+}
+
+var (
+	float32Type = &Type{Kind: Float, Width: 32}
+	vec3Type    = &Type{Kind: Vector, Len: 3, Elem: float32Type}
+	vec4Type    = &Type{Kind: Vector, Len: 4, Elem: float32Type}
+)
+
+func TestParseVertexShader(t *testing.T) {
+	m := parseFile(t, "../uniformBuffer/uniform/shaders/tri-vert.spv")
+	if len(m.EntryPoints) != 1 || m.EntryPoints[0] != (EntryPoint{Name: "main", Stage: StageVertex}) {
+		t.Errorf("entry points %+v, want main of the vertex stage", m.EntryPoints)
+	}
+	if m.Stages() != StageVertex {
+		t.Errorf("stages %#x, want the vertex stage", m.Stages())
+	}
+
+	ubo, ok := m.Binding(0, 0)
+	if !ok {
+		t.Fatalf("no binding 0 in set 0, bindings %+v", m.Bindings)
+	}
+	if ubo.Name != "ubo" || ubo.Type != UniformBuffer || ubo.Count != 1 {
+		t.Errorf("binding 0 is %+v, want the uniform buffer ubo", ubo)
+	}
+	if ubo.Block == nil || ubo.Block.Kind != Struct || !ubo.Block.Block || ubo.Block.Size != 64 {
+		t.Fatalf("ubo block %+v, want a 64 byte uniform block", ubo.Block)
+	}
+	mvp, ok := ubo.Block.Member("mvp")
+	if !ok {
+		t.Fatalf("ubo has no member mvp")
+	}
+	if mvp.Offset != 0 || mvp.Size != 64 || mvp.MatrixStride != 16 || mvp.RowMajor {
+		t.Errorf("mvp %+v, want a column major matrix at 0 of 64 bytes with a stride of 16", mvp)
+	}
+	if mvp.Type.Kind != Matrix || mvp.Type.Len != 4 || mvp.Type.Elem.Len != 4 || mvp.Type.Scalar().Width != 32 {
+		t.Errorf("mvp is a %s, want a mat4", mvp.Type)
+	}
+	if _, ok := m.Binding(0, 1); ok {
+		t.Errorf("unexpected binding 1")
+	}
+	if len(m.PushConstants) != 0 {
+		t.Errorf("unexpected push constants %+v", m.PushConstants)
+	}
+
+	checkVariables(t, "inputs", m.Inputs, []Variable{
+		{Name: "pos", Location: 0, Type: vec3Type},
+		{Name: "color", Location: 1, Type: vec3Type},
+	})
+	// gl_Position is a built-in, it has no location.
+	checkVariables(t, "outputs", m.Outputs, []Variable{
+		{Name: "vertColor", Location: 0, Type: vec3Type},
+	})
+}
+
+func TestParseFragmentShader(t *testing.T) {
+	m := parseFile(t, "../uniformBuffer/uniform/shaders/tri-frag.spv")
+	if len(m.EntryPoints) != 1 || m.EntryPoints[0] != (EntryPoint{Name: "main", Stage: StageFragment}) {
+		t.Errorf("entry points %+v, want main of the fragment stage", m.EntryPoints)
+	}
+	if len(m.Bindings) != 0 {
+		t.Errorf("unexpected bindings %+v", m.Bindings)
+	}
+	checkVariables(t, "inputs", m.Inputs, []Variable{
+		{Name: "vertColor", Location: 0, Type: vec3Type},
+	})
+	checkVariables(t, "outputs", m.Outputs, []Variable{
+		{Name: "uFragColor", Location: 0, Type: vec4Type},
+	})
+}
+
+func TestParseBigEndian(t *testing.T) {
+	code, err := ioutil.ReadFile("../uniformBuffer/uniform/shaders/tri-vert.spv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := make([]byte, len(code))
+	for i := 0; i < len(code); i += 4 {
+		binary.BigEndian.PutUint32(swapped[i:], binary.LittleEndian.Uint32(code[i:]))
+	}
+	m, err := Parse(swapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Binding(0, 0); !ok || len(m.Inputs) != 2 {
+		t.Errorf("big endian module reflects differently: %+v", m)
+	}
+	if _, err := Check(swapped); err == nil {
+		t.Errorf("Check accepted a big endian module, Vulkan reads little endian words")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	code, err := ioutil.ReadFile("../uniformBuffer/uniform/shaders/tri-frag.spv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := Check(code)
+	if err != nil || version != 0x00010000 {
+		t.Errorf("Check = %#x, %v, want version 1.0", version, err)
+	}
+
+	withWord := func(i int, w uint32) []byte {
+		c := append([]byte(nil), code...)
+		binary.LittleEndian.PutUint32(c[i*4:], w)
+		return c
+	}
+	bad := map[string][]byte{
+		"short":             code[:16],
+		"unaligned":         code[:len(code)-1],
+		"bad magic":         withWord(0, 0x12345678),
+		"future version":    withWord(1, 0x00020000),
+		"malformed version": withWord(1, 0x00010001),
+	}
+	for name, c := range bad {
+		if _, err := Check(c); err == nil {
+			t.Errorf("%s: Check succeeded", name)
+		}
+	}
+}
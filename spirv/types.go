@@ -0,0 +1,196 @@
+package spirv
+
+import "fmt"
+
+// Kind is the kind of a SPIR-V type.
+type Kind int
+
+const (
+	Void Kind = iota
+	Bool
+	Int
+	Float
+	Vector
+	Matrix
+	Array
+	RuntimeArray
+	Struct
+	Image
+	Sampler
+	SampledImage
+	AccelerationStructure
+)
+
+var kindNames = []string{"void", "bool", "int", "float", "vector", "matrix", "array",
+	"runtime array", "struct", "image", "sampler", "sampled image", "acceleration structure"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Dim is the dimensionality of an image type.
+type Dim uint32
+
+const (
+	Dim1D Dim = iota
+	Dim2D
+	Dim3D
+	DimCube
+	DimRect
+	DimBuffer
+	DimSubpassData
+)
+
+// ImageInfo are the operands of OpTypeImage.
+type ImageInfo struct {
+	Dim     Dim
+	Depth   uint32
+	Arrayed bool
+	MS      bool
+	// Sampled is 1 for images used with a sampler, 2 for storage images.
+	Sampled uint32
+	Format  uint32
+}
+
+// Type is a resolved SPIR-V type. Size, Stride and member offsets come
+// from the Offset, ArrayStride and MatrixStride decorations and are only
+// meaningful for types used in blocks.
+type Type struct {
+	Kind Kind
+	Name string
+	// Width is the bit width of Int and Float.
+	Width  uint32
+	Signed bool
+	// Len is the component count of vectors, the column count of
+	// matrices and the length of arrays.
+	Len uint32
+	// Elem is the component type of vectors, the column type of matrices,
+	// the element type of arrays and the image type of sampled images.
+	Elem *Type
+	// Stride is the ArrayStride of arrays.
+	Stride  uint32
+	Members []Member
+	Image   ImageInfo
+	// Block and BufferBlock are set on structs used as uniform and storage
+	// blocks.
+	Block       bool
+	BufferBlock bool
+	BuiltIn     bool
+	Size        uint32
+}
+
+// Member is a member of a struct type.
+type Member struct {
+	Name   string
+	Offset uint32
+	Type   *Type
+	// MatrixStride and RowMajor apply to matrix members and arrays of them.
+	MatrixStride uint32
+	RowMajor     bool
+	BuiltIn      bool
+	Size         uint32
+}
+
+// Member looks up a member of a struct by name.
+func (t *Type) Member(name string) (Member, bool) {
+	for _, m := range t.Members {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+// Scalar returns the scalar type at the bottom of vectors and matrices.
+func (t *Type) Scalar() *Type {
+	for t.Kind == Vector || t.Kind == Matrix {
+		t = t.Elem
+	}
+	return t
+}
+
+// Locations returns the number of interface locations the type takes.
+func (t *Type) Locations() uint32 {
+	switch t.Kind {
+	case Vector:
+		if t.Elem.Width == 64 && t.Len > 2 {
+			return 2
+		}
+		return 1
+	case Matrix:
+		return t.Len * t.Elem.Locations()
+	case Array:
+		return t.Len * t.Elem.Locations()
+	case Struct:
+		var n uint32
+		for _, m := range t.Members {
+			n += m.Type.Locations()
+		}
+		return n
+	}
+	return 1
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case Int:
+		if t.Signed {
+			return fmt.Sprintf("int%d", t.Width)
+		}
+		return fmt.Sprintf("uint%d", t.Width)
+	case Float:
+		return fmt.Sprintf("float%d", t.Width)
+	case Vector:
+		return fmt.Sprintf("%svec%d", t.Elem, t.Len)
+	case Matrix:
+		return fmt.Sprintf("%smat%dx%d", t.Scalar(), t.Len, t.Elem.Len)
+	case Array:
+		return fmt.Sprintf("%s[%d]", t.Elem, t.Len)
+	case RuntimeArray:
+		return fmt.Sprintf("%s[]", t.Elem)
+	case Struct:
+		if t.Name != "" {
+			return t.Name
+		}
+	}
+	return t.Kind.String()
+}
+
+// size is the byte size of a type in a block. Matrices take their stride
+// per column, or per row if they are row major. A runtime array adds
+// nothing, its length is only known when a buffer is bound.
+func size(t *Type, matrixStride uint32, rowMajor bool) uint32 {
+	switch t.Kind {
+	case Bool:
+		return 4
+	case Int, Float:
+		return t.Width / 8
+	case Vector:
+		return t.Len * size(t.Elem, 0, false)
+	case Matrix:
+		if matrixStride == 0 {
+			return t.Len * size(t.Elem, 0, false)
+		}
+		if rowMajor {
+			return t.Elem.Len * matrixStride
+		}
+		return t.Len * matrixStride
+	case Array:
+		if t.Stride != 0 {
+			return t.Len * t.Stride
+		}
+		return t.Len * size(t.Elem, matrixStride, rowMajor)
+	case Struct:
+		var end uint32
+		for _, m := range t.Members {
+			if e := m.Offset + m.Size; e > end {
+				end = e
+			}
+		}
+		return end
+	}
+	return 0
+}
@@ -0,0 +1,254 @@
+package texture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// blockDecoder decodes a block into 4x4 texels, row by row, of the texel
+// size of its output format.
+type blockDecoder func(block, texels []byte)
+
+type bcFormat struct {
+	decode    blockDecoder
+	output    vk.Format
+	texelSize int
+}
+
+var bcFormats = map[vk.Format]bcFormat{
+	vk.FormatBc1RgbUnormBlock:  {decodeBC1Opaque, vk.FormatR8g8b8a8Unorm, 4},
+	vk.FormatBc1RgbSrgbBlock:   {decodeBC1Opaque, vk.FormatR8g8b8a8Srgb, 4},
+	vk.FormatBc1RgbaUnormBlock: {decodeBC1, vk.FormatR8g8b8a8Unorm, 4},
+	vk.FormatBc1RgbaSrgbBlock:  {decodeBC1, vk.FormatR8g8b8a8Srgb, 4},
+	vk.FormatBc2UnormBlock:     {decodeBC2, vk.FormatR8g8b8a8Unorm, 4},
+	vk.FormatBc2SrgbBlock:      {decodeBC2, vk.FormatR8g8b8a8Srgb, 4},
+	vk.FormatBc3UnormBlock:     {decodeBC3, vk.FormatR8g8b8a8Unorm, 4},
+	vk.FormatBc3SrgbBlock:      {decodeBC3, vk.FormatR8g8b8a8Srgb, 4},
+	vk.FormatBc4UnormBlock:     {decodeBC4Unorm, vk.FormatR8g8b8a8Unorm, 4},
+	vk.FormatBc4SnormBlock:     {decodeBC4Snorm, vk.FormatR8g8b8a8Snorm, 4},
+	vk.FormatBc5UnormBlock:     {decodeBC5Unorm, vk.FormatR8g8b8a8Unorm, 4},
+	vk.FormatBc5SnormBlock:     {decodeBC5Snorm, vk.FormatR8g8b8a8Snorm, 4},
+	vk.FormatBc6hUfloatBlock:   {decodeBC6HUnsigned, vk.FormatR16g16b16a16Sfloat, 8},
+	vk.FormatBc6hSfloatBlock:   {decodeBC6HSigned, vk.FormatR16g16b16a16Sfloat, 8},
+	vk.FormatBc7UnormBlock:     {decodeBC7, vk.FormatR8g8b8a8Unorm, 4},
+	vk.FormatBc7SrgbBlock:      {decodeBC7, vk.FormatR8g8b8a8Srgb, 4},
+}
+
+// IsBC reports whether format is one of the BC1 to BC7 formats.
+func IsBC(format vk.Format) bool {
+	_, ok := bcFormats[format]
+	return ok
+}
+
+// DecodeBC decodes every level and layer of a BC1 to BC7 image for devices
+// without textureCompressionBC. BC1 to BC3 and BC7 become RGBA8, sRGB ones
+// staying sRGB, BC4 and BC5 become RGBA8 with their channels in red and
+// green and BC6H becomes RGBA16 floats.
+func DecodeBC(img *Image) (*Image, error) {
+	bc, ok := bcFormats[img.Format]
+	if !ok {
+		return nil, fmt.Errorf("format %d isn't a BC format", img.Format)
+	}
+	_, _, blockSize, _ := CompressedBlock(img.Format)
+	layers := max(img.Layers, 1)
+	out := &Image{Format: bc.output, Layers: img.Layers, Cube: img.Cube}
+	texels := make([]byte, 16*bc.texelSize)
+	for i, level := range img.Levels {
+		w, h := int(level.Width), int(level.Height)
+		blocksX, blocksY := (w+3)/4, (h+3)/4
+		layerSize := blocksX * blocksY * int(blockSize)
+		if len(level.Data) < layerSize*int(layers) {
+			return nil, fmt.Errorf("level %d has %d bytes, %d layers of %dx%d texels need %d",
+				i, len(level.Data), layers, w, h, layerSize*int(layers))
+		}
+		rowSize := w * bc.texelSize
+		data := make([]byte, rowSize*h*int(layers))
+		for layer := 0; layer < int(layers); layer++ {
+			src := level.Data[layer*layerSize:]
+			dst := data[layer*rowSize*h:]
+			for by := 0; by < blocksY; by++ {
+				for bx := 0; bx < blocksX; bx++ {
+					bc.decode(src[(by*blocksX+bx)*int(blockSize):], texels)
+					// Blocks on the right and bottom edges may stick out
+					// of the image.
+					cols := min(4, w-bx*4) * bc.texelSize
+					for y := 0; y < 4 && by*4+y < h; y++ {
+						copy(dst[(by*4+y)*rowSize+bx*4*bc.texelSize:], texels[y*4*bc.texelSize:][:cols])
+					}
+				}
+			}
+		}
+		out.Levels = append(out.Levels, Level{Width: level.Width, Height: level.Height, Data: data})
+	}
+	return out, nil
+}
+
+// rgb565 expands a 5:6:5 color to 8 bit channels.
+func rgb565(c uint16) [3]int {
+	r, g, b := int(c>>11), int(c>>5&0x3f), int(c&0x1f)
+	return [3]int{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2}
+}
+
+// decodeColorBlock decodes the 8 byte color block of BC1 to BC3. BC1 blocks
+// with color0 <= color1 have three colors and transparent black, BC2 and BC3
+// blocks always have four colors.
+func decodeColorBlock(block, texels []byte, bc1 bool) {
+	c0, c1 := binary.LittleEndian.Uint16(block), binary.LittleEndian.Uint16(block[2:])
+	e0, e1 := rgb565(c0), rgb565(c1)
+	var palette [4][4]byte
+	for ch := 0; ch < 3; ch++ {
+		palette[0][ch], palette[1][ch] = byte(e0[ch]), byte(e1[ch])
+		if c0 > c1 || !bc1 {
+			palette[2][ch] = byte((2*e0[ch] + e1[ch] + 1) / 3)
+			palette[3][ch] = byte((e0[ch] + 2*e1[ch] + 1) / 3)
+		} else {
+			palette[2][ch] = byte((e0[ch] + e1[ch] + 1) / 2)
+		}
+	}
+	palette[0][3], palette[1][3], palette[2][3] = 0xff, 0xff, 0xff
+	if c0 > c1 || !bc1 {
+		palette[3][3] = 0xff
+	}
+	indices := binary.LittleEndian.Uint32(block[4:])
+	for i := 0; i < 16; i++ {
+		copy(texels[i*4:i*4+4], palette[indices>>(2*i)&3][:])
+	}
+}
+
+func decodeBC1(block, texels []byte) {
+	decodeColorBlock(block, texels, true)
+}
+
+// decodeBC1Opaque decodes BC1 blocks of the RGB formats, which have no
+// alpha: the texels transparent in RGBA formats are opaque black.
+func decodeBC1Opaque(block, texels []byte) {
+	decodeColorBlock(block, texels, true)
+	for i := 3; i < len(texels); i += 4 {
+		texels[i] = 0xff
+	}
+}
+
+func decodeBC2(block, texels []byte) {
+	decodeColorBlock(block[8:], texels, false)
+	alpha := binary.LittleEndian.Uint64(block)
+	for i := 0; i < 16; i++ {
+		a := byte(alpha >> (4 * i) & 0xf)
+		texels[i*4+3] = a<<4 | a
+	}
+}
+
+func decodeBC3(block, texels []byte) {
+	decodeColorBlock(block[8:], texels, false)
+	var alpha [16]float64
+	decodeAlphaBlock(block, &alpha, false)
+	for i, a := range alpha {
+		texels[i*4+3] = byte(math.Round(a * 255))
+	}
+}
+
+// decodeAlphaBlock decodes the 8 byte single channel block of BC3 to BC5
+// into values in [0, 1], or [-1, 1] if signed.
+func decodeAlphaBlock(block []byte, values *[16]float64, signed bool) {
+	var e0, e1 float64
+	if signed {
+		// -128 and -127 both mean -1.
+		e0 = max(float64(int8(block[0])), -127) / 127
+		e1 = max(float64(int8(block[1])), -127) / 127
+	} else {
+		e0, e1 = float64(block[0])/255, float64(block[1])/255
+	}
+	var palette [8]float64
+	palette[0], palette[1] = e0, e1
+	if e0 > e1 {
+		for i := 1; i < 7; i++ {
+			palette[i+1] = (float64(7-i)*e0 + float64(i)*e1) / 7
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			palette[i+1] = (float64(5-i)*e0 + float64(i)*e1) / 5
+		}
+		palette[6], palette[7] = 0, 1
+		if signed {
+			palette[6] = -1
+		}
+	}
+	var bits uint64
+	for i := 0; i < 6; i++ {
+		bits |= uint64(block[2+i]) << (8 * i)
+	}
+	for i := range values {
+		values[i] = palette[bits>>(3*i)&7]
+	}
+}
+
+// decodeRG decodes BC4 and BC5 blocks, channels blocks of 8 bytes, into the
+// first channels of RGBA8 texels.
+func decodeRG(block, texels []byte, channels int, signed bool) {
+	for i := 0; i < 16; i++ {
+		texels[i*4], texels[i*4+1], texels[i*4+2], texels[i*4+3] = 0, 0, 0, 0xff
+		if signed {
+			texels[i*4+3] = 127
+		}
+	}
+	var values [16]float64
+	for ch := 0; ch < channels; ch++ {
+		decodeAlphaBlock(block[8*ch:], &values, signed)
+		for i, v := range values {
+			if signed {
+				texels[i*4+ch] = byte(int8(math.Round(v * 127)))
+			} else {
+				texels[i*4+ch] = byte(math.Round(v * 255))
+			}
+		}
+	}
+}
+
+func decodeBC4Unorm(block, texels []byte) { decodeRG(block, texels, 1, false) }
+func decodeBC4Snorm(block, texels []byte) { decodeRG(block, texels, 1, true) }
+func decodeBC5Unorm(block, texels []byte) { decodeRG(block, texels, 2, false) }
+func decodeBC5Snorm(block, texels []byte) { decodeRG(block, texels, 2, true) }
+
+// bitReader reads the bits of a 128 bit block, least significant first.
+type bitReader struct {
+	lo, hi uint64
+	pos    uint
+}
+
+func newBitReader(block []byte) bitReader {
+	return bitReader{lo: binary.LittleEndian.Uint64(block), hi: binary.LittleEndian.Uint64(block[8:])}
+}
+
+func (r *bitReader) read(n uint) int {
+	var v uint64
+	for i := uint(0); i < n; i++ {
+		var bit uint64
+		if r.pos < 64 {
+			bit = r.lo >> r.pos & 1
+		} else {
+			bit = r.hi >> (r.pos - 64) & 1
+		}
+		v |= bit << i
+		r.pos++
+	}
+	return int(v)
+}
+
+// BC6H and BC7 interpolation weights for 2, 3 and 4 bit indices.
+var (
+	bcWeights2 = []int{0, 21, 43, 64}
+	bcWeights3 = []int{0, 9, 18, 27, 37, 46, 55, 64}
+	bcWeights4 = []int{0, 4, 9, 13, 17, 21, 26, 30, 34, 38, 43, 47, 51, 55, 60, 64}
+)
+
+func bcWeights(bits uint) []int {
+	switch bits {
+	case 2:
+		return bcWeights2
+	case 3:
+		return bcWeights3
+	}
+	return bcWeights4
+}
@@ -0,0 +1,226 @@
+package texture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// BC6H endpoint fields: w and x are the endpoints of the first subset, y
+// and z the ones of the second, for red, green and blue. d is the
+// partition.
+const (
+	bc6hRW = iota
+	bc6hGW
+	bc6hBW
+	bc6hRX
+	bc6hGX
+	bc6hBX
+	bc6hRY
+	bc6hGY
+	bc6hBY
+	bc6hRZ
+	bc6hGZ
+	bc6hBZ
+	bc6hD
+	bc6hFields
+)
+
+// bc6hBits is a run of bits of a field, read from bit from to bit to.
+type bc6hBits struct {
+	field    int
+	from, to int
+}
+
+type bc6hMode struct {
+	value       int
+	subsets     int
+	transformed bool
+	// endpointBits is the precision of the endpoints, deltaBits the one of
+	// the transformed endpoints of red, green and blue.
+	endpointBits uint
+	deltaBits    [3]uint
+	layout       []bc6hBits
+}
+
+var bc6hModes = []bc6hMode{
+	newBC6HMode(0x00, 2, true, 10, [3]uint{5, 5, 5}, "gy[4] by[4] bz[4] rw[9:0] gw[9:0] bw[9:0] rx[4:0] gz[4] gy[3:0] gx[4:0] bz[0] gz[3:0] bx[4:0] bz[1] by[3:0] ry[4:0] bz[2] rz[4:0] bz[3] d[4:0]"),
+	newBC6HMode(0x01, 2, true, 7, [3]uint{6, 6, 6}, "gy[5] gz[4] gz[5] rw[6:0] bz[0] bz[1] by[4] gw[6:0] by[5] bz[2] gy[4] bw[6:0] bz[3] bz[5] bz[4] rx[5:0] gy[3:0] gx[5:0] gz[3:0] bx[5:0] by[3:0] ry[5:0] rz[5:0] d[4:0]"),
+	newBC6HMode(0x02, 2, true, 11, [3]uint{5, 4, 4}, "rw[9:0] gw[9:0] bw[9:0] rx[4:0] rw[10] gy[3:0] gx[3:0] gw[10] bz[0] gz[3:0] bx[3:0] bw[10] bz[1] by[3:0] ry[4:0] bz[2] rz[4:0] bz[3] d[4:0]"),
+	newBC6HMode(0x06, 2, true, 11, [3]uint{4, 5, 4}, "rw[9:0] gw[9:0] bw[9:0] rx[3:0] rw[10] gz[4] gy[3:0] gx[4:0] gw[10] gz[3:0] bx[3:0] bw[10] bz[1] by[3:0] ry[3:0] bz[0] bz[2] rz[3:0] gy[4] bz[3] d[4:0]"),
+	newBC6HMode(0x0a, 2, true, 11, [3]uint{4, 4, 5}, "rw[9:0] gw[9:0] bw[9:0] rx[3:0] rw[10] by[4] gy[3:0] gx[3:0] gw[10] bz[0] gz[3:0] bx[4:0] bw[10] by[3:0] ry[3:0] bz[1] bz[2] rz[3:0] bz[4] bz[3] d[4:0]"),
+	newBC6HMode(0x0e, 2, true, 9, [3]uint{5, 5, 5}, "rw[8:0] by[4] gw[8:0] gy[4] bw[8:0] bz[4] rx[4:0] gz[4] gy[3:0] gx[4:0] bz[0] gz[3:0] bx[4:0] bz[1] by[3:0] ry[4:0] bz[2] rz[4:0] bz[3] d[4:0]"),
+	newBC6HMode(0x12, 2, true, 8, [3]uint{6, 5, 5}, "rw[7:0] gz[4] by[4] gw[7:0] bz[2] gy[4] bw[7:0] bz[3] bz[4] rx[5:0] gy[3:0] gx[4:0] bz[0] gz[3:0] bx[4:0] bz[1] by[3:0] ry[5:0] rz[5:0] d[4:0]"),
+	newBC6HMode(0x16, 2, true, 8, [3]uint{5, 6, 5}, "rw[7:0] bz[0] by[4] gw[7:0] gy[5] gy[4] bw[7:0] gz[5] bz[4] rx[4:0] gz[4] gy[3:0] gx[5:0] gz[3:0] bx[4:0] bz[1] by[3:0] ry[4:0] bz[2] rz[4:0] bz[3] d[4:0]"),
+	newBC6HMode(0x1a, 2, true, 8, [3]uint{5, 5, 6}, "rw[7:0] bz[1] by[4] gw[7:0] by[5] gy[4] bw[7:0] bz[5] bz[4] rx[4:0] gz[4] gy[3:0] gx[4:0] bz[0] gz[3:0] bx[5:0] by[3:0] ry[4:0] bz[2] rz[4:0] bz[3] d[4:0]"),
+	newBC6HMode(0x1e, 2, false, 6, [3]uint{6, 6, 6}, "rw[5:0] gz[4] bz[0] bz[1] by[4] gw[5:0] gy[5] by[5] bz[2] gy[4] bw[5:0] gz[5] bz[3] bz[5] bz[4] rx[5:0] gy[3:0] gx[5:0] gz[3:0] bx[5:0] by[3:0] ry[5:0] rz[5:0] d[4:0]"),
+	newBC6HMode(0x03, 1, false, 10, [3]uint{10, 10, 10}, "rw[9:0] gw[9:0] bw[9:0] rx[9:0] gx[9:0] bx[9:0]"),
+	newBC6HMode(0x07, 1, true, 11, [3]uint{9, 9, 9}, "rw[9:0] gw[9:0] bw[9:0] rx[8:0] rw[10] gx[8:0] gw[10] bx[8:0] bw[10]"),
+	newBC6HMode(0x0b, 1, true, 12, [3]uint{8, 8, 8}, "rw[9:0] gw[9:0] bw[9:0] rx[7:0] rw[10:11] gx[7:0] gw[10:11] bx[7:0] bw[10:11]"),
+	newBC6HMode(0x0f, 1, true, 16, [3]uint{4, 4, 4}, "rw[9:0] gw[9:0] bw[9:0] rx[3:0] rw[10:15] gx[3:0] gw[10:15] bx[3:0] bw[10:15]"),
+}
+
+// newBC6HMode parses the layout of a mode's header, as written in the
+// BC6H specification. f[a:b] reads the bits of f from b to a, so reversed
+// ranges such as rw[10:15] read bit 15 first.
+func newBC6HMode(value, subsets int, transformed bool, endpointBits uint, deltaBits [3]uint, layout string) bc6hMode {
+	names := [bc6hFields]string{"rw", "gw", "bw", "rx", "gx", "bx", "ry", "gy", "by", "rz", "gz", "bz", "d"}
+	m := bc6hMode{value: value, subsets: subsets, transformed: transformed, endpointBits: endpointBits, deltaBits: deltaBits}
+	for _, run := range strings.Fields(layout) {
+		name, bits, _ := strings.Cut(strings.TrimSuffix(run, "]"), "[")
+		field := -1
+		for i, n := range names {
+			if n == name {
+				field = i
+			}
+		}
+		var a, b int
+		if _, err := fmt.Sscanf(bits, "%d:%d", &a, &b); err != nil {
+			fmt.Sscanf(bits, "%d", &a)
+			b = a
+		}
+		if field < 0 {
+			panic("texture: bad BC6H layout " + run)
+		}
+		m.layout = append(m.layout, bc6hBits{field: field, from: b, to: a})
+	}
+	return m
+}
+
+func decodeBC6HUnsigned(block, texels []byte) { decodeBC6H(block, texels, false) }
+func decodeBC6HSigned(block, texels []byte)   { decodeBC6H(block, texels, true) }
+
+// decodeBC6H decodes a BC6H block into RGBA16 float texels with an alpha
+// of 1. Blocks of reserved modes decode to black.
+func decodeBC6H(block, texels []byte, signed bool) {
+	r := newBitReader(block)
+	value := r.read(2)
+	if value > 1 {
+		value |= r.read(3) << 2
+	}
+	var m *bc6hMode
+	for i := range bc6hModes {
+		if bc6hModes[i].value == value {
+			m = &bc6hModes[i]
+		}
+	}
+	for i := 0; i < 16; i++ {
+		binary.LittleEndian.PutUint64(texels[i*8:], 0x3c00<<48)
+	}
+	if m == nil {
+		return
+	}
+
+	// Phase 1: gather the scattered bits of the header fields
+
+	var fields [bc6hFields]int
+	for _, run := range m.layout {
+		step := 1
+		if run.to < run.from {
+			step = -1
+		}
+		for bit := run.from; ; bit += step {
+			fields[run.field] |= r.read(1) << bit
+			if bit == run.to {
+				break
+			}
+		}
+	}
+
+	// Phase 2: undo the delta transform and unquantize the endpoints
+
+	var endpoints [2][2][3]int
+	for ch := 0; ch < 3; ch++ {
+		w := fields[bc6hRW+ch]
+		if signed {
+			w = signExtend(w, m.endpointBits)
+		}
+		endpoints[0][0][ch] = w
+		others := []int{fields[bc6hRX+ch], fields[bc6hRY+ch], fields[bc6hRZ+ch]}[:2*m.subsets-1]
+		for i, v := range others {
+			if m.transformed {
+				v = (signExtend(v, m.deltaBits[ch]) + w) & (1<<m.endpointBits - 1)
+			}
+			if signed {
+				v = signExtend(v, m.endpointBits)
+			}
+			endpoints[(i+1)/2][(i+1)%2][ch] = v
+		}
+		for s := 0; s < m.subsets; s++ {
+			for e := 0; e < 2; e++ {
+				endpoints[s][e][ch] = bc6hUnquantize(endpoints[s][e][ch], m.endpointBits, signed)
+			}
+		}
+	}
+
+	// Phase 3: read the indices, anchors have one bit less, and
+	//			interpolate
+
+	partition := fields[bc6hD]
+	indexBits := uint(3)
+	if m.subsets == 1 {
+		indexBits = 4
+	}
+	weights := bcWeights(indexBits)
+	for i := 0; i < 16; i++ {
+		bits := indexBits
+		if bcIsAnchor(m.subsets, partition, i) {
+			bits--
+		}
+		w := weights[r.read(bits)]
+		e := endpoints[bcSubset(m.subsets, partition, i)]
+		for ch := 0; ch < 3; ch++ {
+			v := ((64-w)*e[0][ch] + w*e[1][ch] + 32) >> 6
+			binary.LittleEndian.PutUint16(texels[i*8+ch*2:], bc6hFinish(v, signed))
+		}
+	}
+}
+
+func signExtend(v int, bits uint) int {
+	if v&(1<<(bits-1)) != 0 {
+		v -= 1 << bits
+	}
+	return v
+}
+
+// bc6hUnquantize scales an endpoint of bits bits to 16 bits.
+func bc6hUnquantize(v int, bits uint, signed bool) int {
+	if !signed {
+		switch {
+		case bits >= 15, v == 0:
+			return v
+		case v == 1<<bits-1:
+			return 0xffff
+		}
+		return (v<<16 + 0x8000) >> bits
+	}
+	if bits >= 16 {
+		return v
+	}
+	negative := v < 0
+	if negative {
+		v = -v
+	}
+	switch {
+	case v == 0:
+	case v >= 1<<(bits-1)-1:
+		v = 0x7fff
+	default:
+		v = (v<<15 + 0x4000) >> (bits - 1)
+	}
+	if negative {
+		v = -v
+	}
+	return v
+}
+
+// bc6hFinish scales an interpolated value to the bits of a half float.
+func bc6hFinish(v int, signed bool) uint16 {
+	if !signed {
+		return uint16(v * 31 >> 6)
+	}
+	if v < 0 {
+		return 0x8000 | uint16(-v*31>>5)
+	}
+	return uint16(v * 31 >> 5)
+}
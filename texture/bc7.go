@@ -0,0 +1,228 @@
+package texture
+
+// Partition tables shared by BC6H and BC7. bcPartitions2 holds a bit per
+// texel, set for the texels of the second subset, bcPartitions3 two bits
+// per texel with the subset of each. Texel 0 is in the lowest bits.
+var bcPartitions2 = [64]uint16{
+	0xcccc, 0x8888, 0xeeee, 0xecc8, 0xc880, 0xfeec, 0xfec8, 0xec80,
+	0xc800, 0xffec, 0xfe80, 0xe800, 0xffe8, 0xff00, 0xfff0, 0xf000,
+	0xf710, 0x008e, 0x7100, 0x08ce, 0x008c, 0x7310, 0x3100, 0x8cce,
+	0x088c, 0x3110, 0x6666, 0x366c, 0x17e8, 0x0ff0, 0x718e, 0x399c,
+	0xaaaa, 0xf0f0, 0x5a5a, 0x33cc, 0x3c3c, 0x55aa, 0x9696, 0xa55a,
+	0x73ce, 0x13c8, 0x324c, 0x3bdc, 0x6996, 0xc33c, 0x9966, 0x0660,
+	0x0272, 0x04e4, 0x4e40, 0x2720, 0xc936, 0x936c, 0x39c6, 0x639c,
+	0x9336, 0x9cc6, 0x817e, 0xe718, 0xccf0, 0x0fcc, 0x7744, 0xee22,
+}
+
+var bcPartitions3 = [64]uint32{
+	0xaa685050, 0x6a5a5040, 0x5a5a4200, 0x5450a0a8, 0xa5a50000, 0xa0a05050, 0x5555a0a0, 0x5a5a5050,
+	0xaa550000, 0xaa555500, 0xaaaa5500, 0x90909090, 0x94949494, 0xa4a4a4a4, 0xa9a59450, 0x2a0a4250,
+	0xa5945040, 0x0a425054, 0xa5a5a500, 0x55a0a0a0, 0xa8a85454, 0x6a6a4040, 0xa4a45000, 0x1a1a0500,
+	0x0050a4a4, 0xaaa59090, 0x14696914, 0x69691400, 0xa08585a0, 0xaa821414, 0x50a4a450, 0x6a5a0200,
+	0xa9a58000, 0x5090a0a8, 0xa8a09050, 0x24242424, 0x00aa5500, 0x24924924, 0x24499224, 0x50a50a50,
+	0x500aa550, 0xaaaa4444, 0x66660000, 0xa5a0a5a0, 0x50a050a0, 0x69286928, 0x44aaaa44, 0x66666600,
+	0xaa444444, 0x54a854a8, 0x95809580, 0x96969600, 0xa85454a8, 0x80959580, 0xaa141414, 0x96960000,
+	0xaaaa1414, 0xa05050a0, 0xa0a5a5a0, 0x96000000, 0x40804080, 0xa9a8a9a8, 0xaaaaaa44, 0x2a4a5254,
+}
+
+// Anchor texels, whose index drops its top bit, of the second subset of
+// two subset partitions and of the second and third of three subset ones.
+// The first subset's anchor is always texel 0.
+var (
+	bcAnchors2 = [64]uint8{
+		15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+		15, 2, 8, 2, 2, 8, 8, 15, 2, 8, 2, 2, 8, 8, 2, 2,
+		15, 15, 6, 8, 2, 8, 15, 15, 2, 8, 2, 2, 2, 15, 15, 6,
+		6, 2, 6, 8, 15, 15, 2, 2, 15, 15, 15, 15, 15, 2, 2, 15,
+	}
+	bcAnchors3a = [64]uint8{
+		3, 3, 15, 15, 8, 3, 15, 15, 8, 8, 6, 6, 6, 5, 3, 3,
+		3, 3, 8, 15, 3, 3, 6, 10, 5, 8, 8, 6, 8, 5, 15, 15,
+		8, 15, 3, 5, 6, 10, 8, 15, 15, 3, 15, 5, 15, 15, 15, 15,
+		3, 15, 5, 5, 5, 8, 5, 10, 5, 10, 8, 13, 15, 12, 3, 3,
+	}
+	bcAnchors3b = [64]uint8{
+		15, 8, 8, 3, 15, 15, 3, 8, 15, 15, 15, 15, 15, 15, 15, 8,
+		15, 8, 15, 3, 15, 8, 15, 8, 3, 15, 6, 10, 15, 15, 10, 8,
+		15, 3, 15, 10, 10, 8, 9, 10, 6, 15, 8, 15, 3, 6, 6, 8,
+		15, 3, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 3, 15, 15, 8,
+	}
+)
+
+// bcSubset returns the subset of a texel in a partition of subsets subsets.
+func bcSubset(subsets, partition, texel int) int {
+	switch subsets {
+	case 2:
+		return int(bcPartitions2[partition] >> texel & 1)
+	case 3:
+		return int(bcPartitions3[partition] >> (2 * texel) & 3)
+	}
+	return 0
+}
+
+// bcIsAnchor reports whether a texel is the anchor of its subset.
+func bcIsAnchor(subsets, partition, texel int) bool {
+	switch {
+	case texel == 0:
+		return true
+	case subsets == 2:
+		return texel == int(bcAnchors2[partition])
+	case subsets == 3:
+		return texel == int(bcAnchors3a[partition]) || texel == int(bcAnchors3b[partition])
+	}
+	return false
+}
+
+type bc7Mode struct {
+	subsets        int
+	partitionBits  uint
+	rotationBits   uint
+	indexSelection bool
+	colorBits      uint
+	alphaBits      uint
+	// endpointPBits have a p-bit per endpoint, sharedPBits one per subset.
+	endpointPBits bool
+	sharedPBits   bool
+	indexBits     uint
+	index2Bits    uint
+}
+
+var bc7Modes = [8]bc7Mode{
+	{subsets: 3, partitionBits: 4, colorBits: 4, endpointPBits: true, indexBits: 3},
+	{subsets: 2, partitionBits: 6, colorBits: 6, sharedPBits: true, indexBits: 3},
+	{subsets: 3, partitionBits: 6, colorBits: 5, indexBits: 2},
+	{subsets: 2, partitionBits: 6, colorBits: 7, endpointPBits: true, indexBits: 2},
+	{subsets: 1, rotationBits: 2, indexSelection: true, colorBits: 5, alphaBits: 6, indexBits: 2, index2Bits: 3},
+	{subsets: 1, rotationBits: 2, colorBits: 7, alphaBits: 8, indexBits: 2, index2Bits: 2},
+	{subsets: 1, colorBits: 7, alphaBits: 7, endpointPBits: true, indexBits: 4},
+	{subsets: 2, partitionBits: 6, colorBits: 5, alphaBits: 5, endpointPBits: true, indexBits: 2},
+}
+
+// decodeBC7 decodes a BC7 block into RGBA8 texels. Blocks of the reserved
+// mode decode to transparent black.
+func decodeBC7(block, texels []byte) {
+	mode := 0
+	for mode < 8 && block[0]>>mode&1 == 0 {
+		mode++
+	}
+	if mode == 8 {
+		clear(texels[:64])
+		return
+	}
+	m := bc7Modes[mode]
+	r := newBitReader(block)
+	r.pos = uint(mode) + 1
+	partition := r.read(m.partitionBits)
+	rotation := r.read(m.rotationBits)
+	indexSelection := 0
+	if m.indexSelection {
+		indexSelection = r.read(1)
+	}
+
+	// Phase 1: read the endpoints, channel by channel, then their p-bits
+
+	var endpoints [3][2][4]int
+	channels := 3
+	if m.alphaBits > 0 {
+		channels = 4
+	}
+	for ch := 0; ch < channels; ch++ {
+		bits := m.colorBits
+		if ch == 3 {
+			bits = m.alphaBits
+		}
+		for s := 0; s < m.subsets; s++ {
+			endpoints[s][0][ch] = r.read(bits)
+			endpoints[s][1][ch] = r.read(bits)
+		}
+	}
+	colorBits, alphaBits := m.colorBits, m.alphaBits
+	if m.endpointPBits || m.sharedPBits {
+		colorBits++
+		if alphaBits > 0 {
+			alphaBits++
+		}
+		for s := 0; s < m.subsets; s++ {
+			p0 := r.read(1)
+			p1 := p0
+			if m.endpointPBits {
+				p1 = r.read(1)
+			}
+			for ch := 0; ch < channels; ch++ {
+				endpoints[s][0][ch] = endpoints[s][0][ch]<<1 | p0
+				endpoints[s][1][ch] = endpoints[s][1][ch]<<1 | p1
+			}
+		}
+	}
+	// Expand to 8 bits by repeating the top bits, alpha is opaque when
+	// the mode has none.
+	for s := 0; s < m.subsets; s++ {
+		for e := 0; e < 2; e++ {
+			for ch := 0; ch < 4; ch++ {
+				bits := colorBits
+				if ch == 3 {
+					bits = alphaBits
+				}
+				if bits == 0 {
+					endpoints[s][e][ch] = 0xff
+					continue
+				}
+				v := endpoints[s][e][ch] << (8 - bits)
+				endpoints[s][e][ch] = v | v>>bits
+			}
+		}
+	}
+
+	// Phase 2: read the indices, anchors have one bit less, and
+	//			interpolate
+
+	var indices, indices2 [16]int
+	for i := range indices {
+		bits := m.indexBits
+		if bcIsAnchor(m.subsets, partition, i) {
+			bits--
+		}
+		indices[i] = r.read(bits)
+	}
+	if m.index2Bits > 0 {
+		for i := range indices2 {
+			bits := m.index2Bits
+			if i == 0 {
+				bits--
+			}
+			indices2[i] = r.read(bits)
+		}
+	}
+	colorWeights, alphaWeights := bcWeights(m.indexBits), bcWeights(m.indexBits)
+	if m.index2Bits > 0 {
+		alphaWeights = bcWeights(m.index2Bits)
+		if indexSelection == 1 {
+			colorWeights, alphaWeights = alphaWeights, colorWeights
+		}
+	}
+	for i := 0; i < 16; i++ {
+		e := endpoints[bcSubset(m.subsets, partition, i)]
+		colorIndex, alphaIndex := indices[i], indices[i]
+		if m.index2Bits > 0 {
+			alphaIndex = indices2[i]
+			if indexSelection == 1 {
+				colorIndex, alphaIndex = alphaIndex, colorIndex
+			}
+		}
+		var texel [4]int
+		for ch := 0; ch < 4; ch++ {
+			w := colorWeights[colorIndex]
+			if ch == 3 {
+				w = alphaWeights[alphaIndex]
+			}
+			texel[ch] = ((64-w)*e[0][ch] + w*e[1][ch] + 32) >> 6
+		}
+		// Rotation swaps alpha with a color channel.
+		if rotation > 0 {
+			texel[3], texel[rotation-1] = texel[rotation-1], texel[3]
+		}
+		for ch, v := range texel {
+			texels[i*4+ch] = byte(v)
+		}
+	}
+}
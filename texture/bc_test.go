@@ -0,0 +1,303 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// bitWriter writes the bits of a 128 bit block, least significant first,
+// as bitReader reads them.
+type bitWriter struct {
+	block [16]byte
+	pos   uint
+}
+
+func (w *bitWriter) write(v int, n uint) {
+	for i := uint(0); i < n; i++ {
+		if v>>i&1 != 0 {
+			w.block[w.pos/8] |= 1 << (w.pos % 8)
+		}
+		w.pos++
+	}
+}
+
+// writeBC6HHeader writes the endpoint fields of a BC6H header in the
+// order of the mode's layout.
+func (w *bitWriter) writeBC6HHeader(m bc6hMode, fields [bc6hFields]int) {
+	for _, run := range m.layout {
+		step := 1
+		if run.to < run.from {
+			step = -1
+		}
+		for bit := run.from; ; bit += step {
+			w.write(fields[run.field]>>bit, 1)
+			if bit == run.to {
+				break
+			}
+		}
+	}
+}
+
+// decodeBlock decodes a block of format into its 16 texels.
+func decodeBlock(format vk.Format, block []byte) []byte {
+	bc := bcFormats[format]
+	texels := make([]byte, 16*bc.texelSize)
+	bc.decode(block, texels)
+	return texels
+}
+
+func TestDecodeBC1(t *testing.T) {
+	// The indices of each row are 0, 1, 2 and 3.
+	rows := []byte{0xe4, 0xe4, 0xe4, 0xe4}
+	tests := []struct {
+		name   string
+		format vk.Format
+		block  []byte
+		want   [4][4]byte
+	}{
+		{
+			// color0 > color1: two interpolated colors.
+			name:   "4 colors",
+			format: vk.FormatBc1RgbaUnormBlock,
+			block:  append([]byte{0xff, 0xff, 0, 0}, rows...),
+			want:   [4][4]byte{{255, 255, 255, 255}, {0, 0, 0, 255}, {170, 170, 170, 255}, {85, 85, 85, 255}},
+		},
+		{
+			// color0 <= color1: one interpolated color and transparent
+			// black.
+			name:   "3 colors",
+			format: vk.FormatBc1RgbaUnormBlock,
+			block:  append([]byte{0, 0, 0xff, 0xff}, rows...),
+			want:   [4][4]byte{{0, 0, 0, 255}, {255, 255, 255, 255}, {128, 128, 128, 255}, {0, 0, 0, 0}},
+		},
+		{
+			name:   "3 colors without alpha",
+			format: vk.FormatBc1RgbUnormBlock,
+			block:  append([]byte{0, 0, 0xff, 0xff}, rows...),
+			want:   [4][4]byte{{0, 0, 0, 255}, {255, 255, 255, 255}, {128, 128, 128, 255}, {0, 0, 0, 255}},
+		},
+		{
+			// BC3 color blocks always have 4 colors.
+			name:   "BC3 colors",
+			format: vk.FormatBc3UnormBlock,
+			block:  append([]byte{0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff}, rows...),
+			want:   [4][4]byte{{0, 0, 0, 255}, {255, 255, 255, 255}, {85, 85, 85, 255}, {170, 170, 170, 255}},
+		},
+	}
+	for _, test := range tests {
+		texels := decodeBlock(test.format, test.block)
+		for y := 0; y < 4; y++ {
+			for x, want := range test.want {
+				if got := texels[(y*4+x)*4:][:4]; !bytes.Equal(got, want[:]) {
+					t.Errorf("%s: texel %d,%d = %v, want %v", test.name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeBC3Alpha(t *testing.T) {
+	color := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+	tests := []struct {
+		name  string
+		alpha []byte
+		want  []byte
+	}{
+		{
+			// alpha0 > alpha1: 6 interpolated values, the indices of the
+			// first texels are 0, 1, 2 and 7.
+			name:  "8 values",
+			alpha: []byte{255, 0, 0x88, 0x0e, 0, 0, 0, 0},
+			want:  []byte{255, 0, 219, 36, 255},
+		},
+		{
+			// alpha0 <= alpha1: 4 interpolated values, 0 and 255, the
+			// indices of the first texels are 6, 7 and 2.
+			name:  "6 values",
+			alpha: []byte{0, 255, 0xbe, 0, 0, 0, 0, 0},
+			want:  []byte{0, 255, 51, 0},
+		},
+	}
+	for _, test := range tests {
+		texels := decodeBlock(vk.FormatBc3UnormBlock, append(test.alpha, color...))
+		for i, want := range test.want {
+			if got := texels[i*4+3]; got != want {
+				t.Errorf("%s: alpha of texel %d = %d, want %d", test.name, i, got, want)
+			}
+		}
+		if !bytes.Equal(texels[:3], []byte{255, 255, 255}) {
+			t.Errorf("%s: color of texel 0 = %v, want white", test.name, texels[:3])
+		}
+	}
+}
+
+func TestDecodeBC4BC5Signed(t *testing.T) {
+	// Red: 127 and -128, which means -1 like -127, so 8 values, indices 0,
+	// 1 and 2. Green: -64 and 64, so 6 values, -1 and 1, indices 6, 7
+	// and 1.
+	red := []byte{0x7f, 0x80, 0x88, 0, 0, 0, 0, 0}
+	green := []byte{0xc0, 0x40, 0x7e, 0, 0, 0, 0, 0}
+	tests := []struct {
+		format vk.Format
+		block  []byte
+		want   [4][4]byte
+	}{
+		{vk.FormatBc4SnormBlock, red, [4][4]byte{
+			{127, 0, 0, 127}, {0x81, 0, 0, 127}, {91, 0, 0, 127}, {127, 0, 0, 127},
+		}},
+		{vk.FormatBc5SnormBlock, append(red, green...), [4][4]byte{
+			{127, 0x81, 0, 127}, {0x81, 127, 0, 127}, {91, 64, 0, 127}, {127, 0xc0, 0, 127},
+		}},
+	}
+	for _, test := range tests {
+		texels := decodeBlock(test.format, test.block)
+		for i, want := range test.want {
+			if got := texels[i*4:][:4]; !bytes.Equal(got, want[:]) {
+				t.Errorf("format %d: texel %d = %v, want %v", test.format, i, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeBC6H(t *testing.T) {
+	le := binary.LittleEndian
+	// Mode 11 has one subset and 10 bit endpoints as they are: white
+	// 1023 and black, texel 0 has index 0 and texel 1 index 15.
+	var w bitWriter
+	w.write(0x03, 5)
+	for ch := 0; ch < 3; ch++ {
+		w.write(1023, 10)
+	}
+	w.write(0, 30)
+	w.write(0, 3)
+	w.write(15, 4)
+	texels := decodeBlock(vk.FormatBc6hUfloatBlock, w.block[:])
+	for ch, want := range []uint16{0x7bff, 0x7bff, 0x7bff, 0x3c00} {
+		if got := le.Uint16(texels[ch*2:]); got != want {
+			t.Errorf("mode 11: channel %d of texel 0 = %#x, want %#x", ch, got, want)
+		}
+	}
+	if got := le.Uint16(texels[8:]); got != 0 {
+		t.Errorf("mode 11: red of texel 1 = %#x, want 0", got)
+	}
+
+	// Mode 1 has two subsets and transformed endpoints: w is 512, the
+	// first endpoint of subset 1 is w - 1. Partition 13 puts texels 8
+	// to 15 in subset 1, all indices are 0.
+	w = bitWriter{}
+	w.write(0x00, 2)
+	var fields [bc6hFields]int
+	for ch := 0; ch < 3; ch++ {
+		fields[bc6hRW+ch] = 512
+		fields[bc6hRX+ch] = 3
+		fields[bc6hRY+ch] = 0x1f
+	}
+	fields[bc6hD] = 13
+	w.writeBC6HHeader(bc6hModes[0], fields)
+	texels = decodeBlock(vk.FormatBc6hUfloatBlock, w.block[:])
+	for _, test := range []struct {
+		texel int
+		want  uint16
+	}{{0, 0x3e0f}, {7, 0x3e0f}, {8, 0x3df0}, {15, 0x3df0}} {
+		if got := le.Uint16(texels[test.texel*8:]); got != test.want {
+			t.Errorf("mode 1: red of texel %d = %#x, want %#x", test.texel, got, test.want)
+		}
+	}
+}
+
+func TestDecodeBC7(t *testing.T) {
+	// Mode 6: 7 bit endpoints 127 and 0 with p-bits 1 and 0, 4 bit
+	// indices 0, 15 and 8.
+	var w bitWriter
+	w.write(1<<6, 7)
+	for ch := 0; ch < 4; ch++ {
+		w.write(127, 7)
+		w.write(0, 7)
+	}
+	w.write(1, 1)
+	w.write(0, 1)
+	w.write(0, 3)
+	w.write(15, 4)
+	w.write(8, 4)
+	texels := decodeBlock(vk.FormatBc7UnormBlock, w.block[:])
+	for i, want := range []byte{255, 0, 120} {
+		if got := texels[i*4:][:4]; !bytes.Equal(got, []byte{want, want, want, want}) {
+			t.Errorf("mode 6: texel %d = %v, want %d", i, got, want)
+		}
+	}
+
+	// Mode 4 with rotation 1, which swaps red and alpha, and the index
+	// selection bit, which interpolates color with the 2 bit indices and
+	// alpha with the 3 bit ones. Color goes from 255 to 0, alpha from 0
+	// to 255.
+	w = bitWriter{}
+	w.write(1<<4, 5)
+	w.write(1, 2)
+	w.write(1, 1)
+	for ch := 0; ch < 3; ch++ {
+		w.write(31, 5)
+		w.write(0, 5)
+	}
+	w.write(0, 6)
+	w.write(63, 6)
+	w.write(0, 1)
+	for i := 1; i < 16; i++ {
+		w.write(3, 2)
+	}
+	w.write(0, 2)
+	for i := 1; i < 16; i++ {
+		w.write(7, 3)
+	}
+	texels = decodeBlock(vk.FormatBc7UnormBlock, w.block[:])
+	if got, want := texels[:8], []byte{0, 255, 255, 255, 255, 0, 0, 0}; !bytes.Equal(got, want) {
+		t.Errorf("mode 4: texels 0 and 1 = %v, want %v", got, want)
+	}
+
+	if texels := decodeBlock(vk.FormatBc7UnormBlock, make([]byte, 16)); !bytes.Equal(texels, make([]byte, 64)) {
+		t.Errorf("reserved mode: %v, want transparent black", texels[:8])
+	}
+}
+
+func TestDecodeBCCrop(t *testing.T) {
+	// A 5x3 image of 2 layers has 2 blocks per layer, the second one
+	// only has its first column and 3 rows in the image.
+	gradient := []byte{0xff, 0xff, 0, 0, 0xe4, 0xe4, 0xe4, 0xe4}
+	red := []byte{0, 0xf8, 0, 0, 0, 0, 0, 0}
+	blue := []byte{0x1f, 0, 0, 0, 0, 0, 0, 0}
+	img := &Image{Format: vk.FormatBc1RgbaUnormBlock, Layers: 2, Levels: []Level{
+		{Width: 5, Height: 3, Data: bytes.Join([][]byte{gradient, red, gradient, blue}, nil)},
+		{Width: 2, Height: 1, Data: bytes.Join([][]byte{red, blue}, nil)},
+	}}
+	out, err := DecodeBC(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Format != vk.FormatR8g8b8a8Unorm || out.Layers != 2 || len(out.Levels) != 2 {
+		t.Fatalf("format %d with %d layers and %d levels, want RGBA8 with 2 and 2", out.Format, out.Layers, len(out.Levels))
+	}
+	row := []byte{255, 255, 255, 255, 0, 0, 0, 255, 170, 170, 170, 255, 85, 85, 85, 255}
+	var want []byte
+	for _, edge := range [][]byte{{255, 0, 0, 255}, {0, 0, 255, 255}} {
+		for y := 0; y < 3; y++ {
+			want = append(append(want, row...), edge...)
+		}
+	}
+	if level := out.Levels[0]; level.Width != 5 || level.Height != 3 || !bytes.Equal(level.Data, want) {
+		t.Errorf("level 0 is %dx%d % x, want 5x3 % x", level.Width, level.Height, level.Data, want)
+	}
+	want = []byte{255, 0, 0, 255, 255, 0, 0, 255, 0, 0, 255, 255, 0, 0, 255, 255}
+	if level := out.Levels[1]; !bytes.Equal(level.Data, want) {
+		t.Errorf("level 1 % x, want % x", level.Data, want)
+	}
+
+	img.Levels[0].Data = img.Levels[0].Data[:24]
+	if _, err := DecodeBC(img); err == nil {
+		t.Errorf("DecodeBC of a truncated level succeeded")
+	}
+	if _, err := DecodeBC(&Image{Format: vk.FormatR8g8b8a8Unorm}); err == nil {
+		t.Errorf("DecodeBC of RGBA8 succeeded")
+	}
+}
@@ -0,0 +1,268 @@
+package texture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+var ddsMagic = []byte("DDS ")
+
+// maxDDSSize and maxDDSLayers bound the sides and array size of DDS
+// textures, so that a corrupt header can't make the reader allocate
+// gigabytes.
+const (
+	maxDDSSize   = 1 << 15
+	maxDDSLayers = 2048
+)
+
+// Flags of the DDS header and of its pixel format.
+const (
+	ddsdMipMapCount   = 0x20000
+	ddpfAlphaPixels   = 0x1
+	ddpfFourCC        = 0x4
+	ddpfRGB           = 0x40
+	ddsCaps2Cubemap   = 0x200
+	ddsCaps2AllFaces  = 0xfc00
+	ddsCaps2Volume    = 0x200000
+	ddsMiscCube       = 0x4
+	ddsDimension2D    = 3
+	ddsHeaderSize     = 128
+	ddsDX10HeaderSize = 20
+)
+
+// ddsFormat is the format of a DDS texture. Typeless formats, and the ones
+// of files without a DX10 header, aren't explicit: Options.ColorSpace picks
+// their sRGB variant.
+type ddsFormat struct {
+	format   vk.Format
+	explicit bool
+}
+
+var ddsFourCCs = map[string]vk.Format{
+	"DXT1": vk.FormatBc1RgbaUnormBlock,
+	"DXT2": vk.FormatBc2UnormBlock,
+	"DXT3": vk.FormatBc2UnormBlock,
+	"DXT4": vk.FormatBc3UnormBlock,
+	"DXT5": vk.FormatBc3UnormBlock,
+	"ATI1": vk.FormatBc4UnormBlock,
+	"BC4U": vk.FormatBc4UnormBlock,
+	"BC4S": vk.FormatBc4SnormBlock,
+	"ATI2": vk.FormatBc5UnormBlock,
+	"BC5U": vk.FormatBc5UnormBlock,
+	"BC5S": vk.FormatBc5SnormBlock,
+	// D3DFMT_A16B16G16R16, D3DFMT_A16B16G16R16F and D3DFMT_A32B32G32R32F.
+	"\x24\x00\x00\x00": vk.FormatR16g16b16a16Unorm,
+	"\x71\x00\x00\x00": vk.FormatR16g16b16a16Sfloat,
+	"\x74\x00\x00\x00": vk.FormatR32g32b32a32Sfloat,
+}
+
+var dxgiFormats = map[uint32]ddsFormat{
+	2:  {vk.FormatR32g32b32a32Sfloat, true},
+	10: {vk.FormatR16g16b16a16Sfloat, true},
+	11: {vk.FormatR16g16b16a16Unorm, true},
+	27: {vk.FormatR8g8b8a8Unorm, false},
+	28: {vk.FormatR8g8b8a8Unorm, true},
+	29: {vk.FormatR8g8b8a8Srgb, true},
+	70: {vk.FormatBc1RgbaUnormBlock, false},
+	71: {vk.FormatBc1RgbaUnormBlock, true},
+	72: {vk.FormatBc1RgbaSrgbBlock, true},
+	73: {vk.FormatBc2UnormBlock, false},
+	74: {vk.FormatBc2UnormBlock, true},
+	75: {vk.FormatBc2SrgbBlock, true},
+	76: {vk.FormatBc3UnormBlock, false},
+	77: {vk.FormatBc3UnormBlock, true},
+	78: {vk.FormatBc3SrgbBlock, true},
+	79: {vk.FormatBc4UnormBlock, true},
+	80: {vk.FormatBc4UnormBlock, true},
+	81: {vk.FormatBc4SnormBlock, true},
+	82: {vk.FormatBc5UnormBlock, true},
+	83: {vk.FormatBc5UnormBlock, true},
+	84: {vk.FormatBc5SnormBlock, true},
+	87: {vk.FormatB8g8r8a8Unorm, true},
+	90: {vk.FormatB8g8r8a8Unorm, false},
+	91: {vk.FormatB8g8r8a8Srgb, true},
+	94: {vk.FormatBc6hUfloatBlock, true},
+	95: {vk.FormatBc6hUfloatBlock, true},
+	96: {vk.FormatBc6hSfloatBlock, true},
+	97: {vk.FormatBc7UnormBlock, false},
+	98: {vk.FormatBc7UnormBlock, true},
+	99: {vk.FormatBc7SrgbBlock, true},
+}
+
+var srgbFormats = map[vk.Format]vk.Format{
+	vk.FormatR8g8b8a8Unorm:     vk.FormatR8g8b8a8Srgb,
+	vk.FormatB8g8r8a8Unorm:     vk.FormatB8g8r8a8Srgb,
+	vk.FormatBc1RgbaUnormBlock: vk.FormatBc1RgbaSrgbBlock,
+	vk.FormatBc2UnormBlock:     vk.FormatBc2SrgbBlock,
+	vk.FormatBc3UnormBlock:     vk.FormatBc3SrgbBlock,
+	vk.FormatBc7UnormBlock:     vk.FormatBc7SrgbBlock,
+}
+
+// decodeDDS reads a DDS file with its mip chain, array layers and cube
+// faces. BC1 to BC7 and the RGBA8, BGRA8, RGBA16 and RGBA32 float formats
+// are kept as they are, legacy files with RGB masks become RGBA8. 1D and
+// volume textures aren't supported.
+func decodeDDS(data []byte, opts Options) (*Image, error) {
+	le := binary.LittleEndian
+	if len(data) < ddsHeaderSize {
+		return nil, fmt.Errorf("truncated header")
+	}
+	if le.Uint32(data[4:]) != 124 || le.Uint32(data[76:]) != 32 {
+		return nil, fmt.Errorf("invalid header size")
+	}
+
+	// Phase 1: read the header and the DX10 header if there is one
+
+	flags := le.Uint32(data[8:])
+	height, width := le.Uint32(data[12:]), le.Uint32(data[16:])
+	depth, mipCount := le.Uint32(data[24:]), le.Uint32(data[28:])
+	pfFlags, fourCC := le.Uint32(data[80:]), string(data[84:88])
+	caps2 := le.Uint32(data[112:])
+	if width == 0 || height == 0 || width > maxDDSSize || height > maxDDSSize {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+	if caps2&ddsCaps2Volume != 0 || depth > 1 {
+		return nil, fmt.Errorf("volume textures are not supported")
+	}
+	levels := uint32(1)
+	if flags&ddsdMipMapCount != 0 && mipCount > 0 {
+		levels = min(mipCount, uint32(bits.Len32(max(width, height))))
+	}
+	offset := ddsHeaderSize
+	layers, cube := uint32(1), false
+	var format ddsFormat
+	var masked bool
+	var bitCount uint32
+	var masks [4]uint32
+	switch {
+	case pfFlags&ddpfFourCC != 0 && fourCC == "DX10":
+		if len(data) < ddsHeaderSize+ddsDX10HeaderSize {
+			return nil, fmt.Errorf("truncated DX10 header")
+		}
+		dxgi, dimension := le.Uint32(data[128:]), le.Uint32(data[132:])
+		misc, arraySize := le.Uint32(data[136:]), le.Uint32(data[140:])
+		var ok bool
+		format, ok = dxgiFormats[dxgi]
+		if !ok {
+			return nil, fmt.Errorf("unsupported DXGI format %d", dxgi)
+		}
+		if dimension != ddsDimension2D {
+			return nil, fmt.Errorf("only 2D textures are supported, not dimension %d", dimension)
+		}
+		if arraySize > maxDDSLayers {
+			return nil, fmt.Errorf("invalid array size %d", arraySize)
+		}
+		layers = max(arraySize, 1)
+		if misc&ddsMiscCube != 0 {
+			layers, cube = layers*6, true
+		}
+		offset += ddsDX10HeaderSize
+	case pfFlags&ddpfFourCC != 0:
+		f, ok := ddsFourCCs[fourCC]
+		if !ok {
+			return nil, fmt.Errorf("unsupported FourCC %q", fourCC)
+		}
+		format = ddsFormat{format: f}
+	case pfFlags&ddpfRGB != 0:
+		bitCount = le.Uint32(data[88:])
+		if bitCount != 16 && bitCount != 24 && bitCount != 32 {
+			return nil, fmt.Errorf("unsupported %d bit RGB format", bitCount)
+		}
+		for ch := range masks {
+			masks[ch] = le.Uint32(data[92+4*ch:])
+		}
+		if pfFlags&ddpfAlphaPixels == 0 {
+			masks[3] = 0
+		}
+		format, masked = ddsFormat{format: vk.FormatR8g8b8a8Unorm}, true
+	default:
+		return nil, fmt.Errorf("unsupported pixel format flags %#x", pfFlags)
+	}
+	if caps2&ddsCaps2Cubemap != 0 && !cube {
+		if caps2&ddsCaps2AllFaces != ddsCaps2AllFaces {
+			return nil, fmt.Errorf("cubemaps without all six faces are not supported")
+		}
+		layers, cube = 6, true
+	}
+	if !format.explicit && opts.ColorSpace == SRGB {
+		if srgb, ok := srgbFormats[format.format]; ok {
+			format.format = srgb
+		}
+	}
+
+	// Phase 2: the file stores the mip chain of each layer after the other,
+	//			gather the layers of each level instead
+
+	levelSize := func(w, h uint32) int {
+		if bw, bh, size, ok := CompressedBlock(format.format); ok {
+			return int((w+bw-1)/bw) * int((h+bh-1)/bh) * int(size)
+		}
+		if masked {
+			return int(w) * int(h) * int(bitCount/8)
+		}
+		return int(w) * int(h) * int(texelSize(format.format))
+	}
+	img := &Image{Format: format.format, Layers: layers, Cube: cube}
+	for i := uint32(0); i < levels; i++ {
+		w, h := max(width>>i, 1), max(height>>i, 1)
+		img.Levels = append(img.Levels, Level{Width: w, Height: h})
+	}
+	for layer := uint32(0); layer < layers; layer++ {
+		for i := range img.Levels {
+			level := &img.Levels[i]
+			size := levelSize(level.Width, level.Height)
+			if len(data)-offset < size {
+				return nil, fmt.Errorf("truncated data in level %d of layer %d", i, layer)
+			}
+			src := data[offset : offset+size]
+			if masked {
+				src = unmaskRGBA(src, bitCount, masks)
+			}
+			level.Data = append(level.Data, src...)
+			offset += size
+		}
+	}
+	return img, nil
+}
+
+// texelSize is the size of a texel of the uncompressed formats of DDS
+// files.
+func texelSize(format vk.Format) uint32 {
+	switch format {
+	case vk.FormatR32g32b32a32Sfloat:
+		return 16
+	case vk.FormatR16g16b16a16Sfloat, vk.FormatR16g16b16a16Unorm:
+		return 8
+	}
+	return 4
+}
+
+// unmaskRGBA converts texels of a legacy RGB pixel format, described by
+// its bit count and red, green, blue and alpha masks, to RGBA8. Texels
+// without an alpha mask are opaque.
+func unmaskRGBA(src []byte, bitCount uint32, masks [4]uint32) []byte {
+	size := int(bitCount / 8)
+	out := make([]byte, len(src)/size*4)
+	for i := range len(src) / size {
+		var texel uint32
+		for b := 0; b < size; b++ {
+			texel |= uint32(src[i*size+b]) << (8 * b)
+		}
+		for ch, mask := range masks {
+			if mask == 0 {
+				if ch == 3 {
+					out[i*4+ch] = 0xff
+				}
+				continue
+			}
+			shift := bits.TrailingZeros32(mask)
+			top := uint64(mask >> shift)
+			v := uint64(texel&mask) >> shift
+			out[i*4+ch] = byte((v*255 + top/2) / top)
+		}
+	}
+	return out
+}
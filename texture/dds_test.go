@@ -0,0 +1,195 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// ddsHeader describes the fields of a DDS file the tests vary.
+type ddsHeader struct {
+	width, height, mips uint32
+	fourCC              string
+	// rgb is the bit count and red, green, blue and alpha masks of a legacy
+	// RGB pixel format, used instead of fourCC if set.
+	rgb   []uint32
+	alpha bool
+	caps2 uint32
+	// dx10 is the DXGI format, dimension, misc flags and array size.
+	dx10 []uint32
+}
+
+func (h ddsHeader) file(payload []byte) []byte {
+	le := binary.LittleEndian
+	data := make([]byte, ddsHeaderSize)
+	copy(data, ddsMagic)
+	le.PutUint32(data[4:], 124)
+	le.PutUint32(data[8:], 0x1007|ddsdMipMapCount)
+	le.PutUint32(data[12:], h.height)
+	le.PutUint32(data[16:], h.width)
+	le.PutUint32(data[28:], h.mips)
+	le.PutUint32(data[76:], 32)
+	if h.rgb != nil {
+		flags := uint32(ddpfRGB)
+		if h.alpha {
+			flags |= ddpfAlphaPixels
+		}
+		le.PutUint32(data[80:], flags)
+		for i, v := range h.rgb {
+			le.PutUint32(data[88+4*i:], v)
+		}
+	} else {
+		le.PutUint32(data[80:], ddpfFourCC)
+		copy(data[84:], h.fourCC)
+	}
+	le.PutUint32(data[112:], h.caps2)
+	for _, v := range h.dx10 {
+		data = le.AppendUint32(data, v)
+	}
+	return append(data, payload...)
+}
+
+func TestDecodeDDS(t *testing.T) {
+	tests := []struct {
+		name   string
+		header ddsHeader
+		opts   Options
+		format vk.Format
+		layers uint32
+		cube   bool
+		levels int
+	}{
+		{"DXT1", ddsHeader{width: 8, height: 4, mips: 2, fourCC: "DXT1"}, Options{},
+			vk.FormatBc1RgbaUnormBlock, 1, false, 2},
+		{"DXT1 in sRGB", ddsHeader{width: 8, height: 4, mips: 2, fourCC: "DXT1"}, Options{ColorSpace: SRGB},
+			vk.FormatBc1RgbaSrgbBlock, 1, false, 2},
+		{"explicit UNORM in sRGB", ddsHeader{width: 4, height: 4, fourCC: "DX10", dx10: []uint32{71, 3, 0, 1, 0}},
+			Options{ColorSpace: SRGB}, vk.FormatBc1RgbaUnormBlock, 1, false, 1},
+		{"mip count past the chain", ddsHeader{width: 4, height: 2, mips: 9, fourCC: "DXT5"}, Options{},
+			vk.FormatBc3UnormBlock, 1, false, 3},
+		{"legacy cubemap", ddsHeader{width: 4, height: 4, fourCC: "BC5S", caps2: ddsCaps2Cubemap | ddsCaps2AllFaces},
+			Options{}, vk.FormatBc5SnormBlock, 6, true, 1},
+	}
+	for _, test := range tests {
+		img, err := decodeDDS(test.header.file(make([]byte, 1024)), test.opts)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if img.Format != test.format || img.Layers != test.layers || img.Cube != test.cube || len(img.Levels) != test.levels {
+			t.Errorf("%s: format %d, %d layers, cube %v and %d levels, want %d, %d, %v and %d", test.name,
+				img.Format, img.Layers, img.Cube, len(img.Levels), test.format, test.layers, test.cube, test.levels)
+		}
+	}
+}
+
+func TestDecodeDDSCubeArray(t *testing.T) {
+	// 2 BC7 cubes of 2 levels, 4x4 and 2x2 with one block each. The file
+	// stores the levels of each face after the other, the first byte of
+	// each block is face*2 + level.
+	payload := make([]byte, 12*2*16)
+	for i := 0; i < 12*2; i++ {
+		payload[i*16] = byte(i)
+	}
+	h := ddsHeader{width: 4, height: 4, mips: 2, fourCC: "DX10", dx10: []uint32{98, ddsDimension2D, ddsMiscCube, 2, 0}}
+	img, err := decodeDDS(h.file(payload), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != vk.FormatBc7UnormBlock || img.Layers != 12 || !img.Cube || len(img.Levels) != 2 {
+		t.Fatalf("format %d, %d layers, cube %v and %d levels, want BC7, a cube array of 12 and 2 levels",
+			img.Format, img.Layers, img.Cube, len(img.Levels))
+	}
+	for i, level := range img.Levels {
+		if len(level.Data) != 12*16 {
+			t.Errorf("level %d has %d bytes, want %d", i, len(level.Data), 12*16)
+			continue
+		}
+		for face := 0; face < 12; face++ {
+			if got := level.Data[face*16]; got != byte(face*2+i) {
+				t.Errorf("level %d of face %d starts with %d, want %d", i, face, got, face*2+i)
+			}
+		}
+	}
+}
+
+func TestDecodeDDSMaskedRGB(t *testing.T) {
+	tests := []struct {
+		name  string
+		rgb   []uint32
+		alpha bool
+		data  []byte
+		want  []byte
+	}{
+		{"A8R8G8B8", []uint32{32, 0xff0000, 0xff00, 0xff, 0xff000000}, true,
+			[]byte{0x10, 0x20, 0x30, 0x40, 0xff, 0x00, 0x80, 0xff},
+			[]byte{0x30, 0x20, 0x10, 0x40, 0x80, 0x00, 0xff, 0xff}},
+		{"X8R8G8B8", []uint32{32, 0xff0000, 0xff00, 0xff, 0xff000000}, false,
+			[]byte{0x10, 0x20, 0x30, 0x40, 0xff, 0x00, 0x80, 0x00},
+			[]byte{0x30, 0x20, 0x10, 0xff, 0x80, 0x00, 0xff, 0xff}},
+		{"R8G8B8", []uint32{24, 0xff0000, 0xff00, 0xff, 0}, false,
+			[]byte{0x10, 0x20, 0x30, 0x40, 0x50, 0x60},
+			[]byte{0x30, 0x20, 0x10, 0xff, 0x60, 0x50, 0x40, 0xff}},
+		{"R5G6B5", []uint32{16, 0xf800, 0x7e0, 0x1f, 0}, false,
+			[]byte{0x00, 0xf8, 0xe0, 0x07},
+			[]byte{0xff, 0, 0, 0xff, 0, 0xff, 0, 0xff}},
+		{"A1R5G5B5", []uint32{16, 0x7c00, 0x3e0, 0x1f, 0x8000}, true,
+			[]byte{0x10, 0x80, 0x00, 0x7c},
+			[]byte{0, 0, 132, 0xff, 0xff, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		h := ddsHeader{width: 2, height: 1, rgb: test.rgb, alpha: test.alpha}
+		img, err := decodeDDS(h.file(test.data), Options{ColorSpace: SRGB})
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if img.Format != vk.FormatR8g8b8a8Srgb || !bytes.Equal(img.Levels[0].Data, test.want) {
+			t.Errorf("%s: format %d % x, want sRGB RGBA8 % x", test.name, img.Format, img.Levels[0].Data, test.want)
+		}
+	}
+}
+
+func TestDecodeDDSErrors(t *testing.T) {
+	dxt1 := ddsHeader{width: 8, height: 4, mips: 2, fourCC: "DXT1"}
+	// Level 0 has 2 blocks, level 1 one.
+	valid := dxt1.file(make([]byte, 3*8))
+	if _, err := decodeDDS(valid, Options{}); err != nil {
+		t.Fatalf("valid DXT1: %s", err)
+	}
+	withField := func(offset int, v uint32) []byte {
+		data := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint32(data[offset:], v)
+		return data
+	}
+	cubeArray := ddsHeader{width: 4, height: 4, fourCC: "DX10", dx10: []uint32{98, ddsDimension2D, ddsMiscCube, 2, 0}}
+	bad := map[string][]byte{
+		"truncated header":     valid[:ddsHeaderSize-1],
+		"invalid header size":  withField(4, 128),
+		"invalid pixel format": withField(76, 24),
+		"no width":             withField(16, 0),
+		"too wide":             withField(16, maxDDSSize+1),
+		"volume":               withField(24, 4),
+		"truncated level 0":    valid[:ddsHeaderSize+8],
+		"truncated level 1":    valid[:len(valid)-1],
+		"truncated DX10":       cubeArray.file(nil)[:ddsHeaderSize+ddsDX10HeaderSize-1],
+		"truncated cube array": cubeArray.file(make([]byte, 11*16)),
+		"too many layers": ddsHeader{width: 4, height: 4, fourCC: "DX10",
+			dx10: []uint32{98, ddsDimension2D, 0, maxDDSLayers + 1, 0}}.file(nil),
+		"1D texture": ddsHeader{width: 4, height: 1, fourCC: "DX10",
+			dx10: []uint32{98, 2, 0, 1, 0}}.file(make([]byte, 16)),
+		"unknown DXGI format": ddsHeader{width: 4, height: 4, fourCC: "DX10",
+			dx10: []uint32{1, ddsDimension2D, 0, 1, 0}}.file(make([]byte, 64)),
+		"unknown FourCC": ddsHeader{width: 4, height: 4, fourCC: "ETC1"}.file(make([]byte, 8)),
+		"8 bit RGB":      ddsHeader{width: 4, height: 4, rgb: []uint32{8, 0xe0, 0x1c, 0x3, 0}}.file(make([]byte, 16)),
+		"cubemap without all faces": ddsHeader{width: 4, height: 4, fourCC: "DXT5",
+			caps2: ddsCaps2Cubemap | 0x400}.file(make([]byte, 6*16)),
+	}
+	for name, data := range bad {
+		if _, err := decodeDDS(data, Options{}); err == nil {
+			t.Errorf("%s: decodeDDS succeeded", name)
+		}
+	}
+}
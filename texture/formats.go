@@ -0,0 +1,27 @@
+package texture
+
+import vk "github.com/vulkan-go/vulkan"
+
+// CompressedBlock returns the size in texels and in bytes of the blocks of
+// the block compressed formats this package produces.
+func CompressedBlock(format vk.Format) (width, height, size uint32, ok bool) {
+	switch format {
+	case vk.FormatBc1RgbUnormBlock, vk.FormatBc1RgbSrgbBlock,
+		vk.FormatBc1RgbaUnormBlock, vk.FormatBc1RgbaSrgbBlock,
+		vk.FormatBc4UnormBlock, vk.FormatBc4SnormBlock,
+		vk.FormatEtc2R8g8b8UnormBlock, vk.FormatEtc2R8g8b8SrgbBlock,
+		vk.FormatEtc2R8g8b8a1UnormBlock, vk.FormatEtc2R8g8b8a1SrgbBlock,
+		vk.FormatEacR11UnormBlock, vk.FormatEacR11SnormBlock:
+		return 4, 4, 8, true
+	case vk.FormatBc2UnormBlock, vk.FormatBc2SrgbBlock,
+		vk.FormatBc3UnormBlock, vk.FormatBc3SrgbBlock,
+		vk.FormatBc5UnormBlock, vk.FormatBc5SnormBlock,
+		vk.FormatBc6hUfloatBlock, vk.FormatBc6hSfloatBlock,
+		vk.FormatBc7UnormBlock, vk.FormatBc7SrgbBlock,
+		vk.FormatEtc2R8g8b8a8UnormBlock, vk.FormatEtc2R8g8b8a8SrgbBlock,
+		vk.FormatEacR11g11UnormBlock, vk.FormatEacR11g11SnormBlock,
+		vk.FormatAstc4x4UnormBlock, vk.FormatAstc4x4SrgbBlock:
+		return 4, 4, 16, true
+	}
+	return 0, 0, 0, false
+}
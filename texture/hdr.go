@@ -0,0 +1,186 @@
+package texture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// maxHDRSize bounds the sides of Radiance images and maxHDRTexels their
+// texels, so that a corrupt header can't make the decoder allocate
+// gigabytes: the largest image decodes to 512 MiB.
+const (
+	maxHDRSize   = 1 << 15
+	maxHDRTexels = 1 << 25
+)
+
+// decodeHDR decodes a Radiance RGBE file into linear RGBA32 floats with an
+// alpha of 1. Scanlines may be flat, run length encoded the old way or the
+// adaptive way.
+func decodeHDR(data []byte) (*Image, error) {
+	src := bytes.NewReader(data)
+	r := bufio.NewReader(src)
+
+	// Phase 1: read the header up to the empty line, then the resolution
+
+	format := ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("truncated header")
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") {
+			format = strings.TrimPrefix(line, "FORMAT=")
+		}
+	}
+	if format != "" && format != "32-bit_rle_rgbe" {
+		return nil, fmt.Errorf("unsupported pixel format %s", format)
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("missing resolution")
+	}
+	var ySign, xSign byte
+	var width, height int
+	_, err = fmt.Sscanf(line, "%cY %d %cX %d", &ySign, &height, &xSign, &width)
+	if err != nil || xSign != '+' || (ySign != '-' && ySign != '+') {
+		return nil, fmt.Errorf("unsupported resolution %q", strings.TrimSpace(line))
+	}
+	if width <= 0 || height <= 0 || width > maxHDRSize || height > maxHDRSize {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+	if width*height > maxHDRTexels {
+		return nil, fmt.Errorf("size %dx%d has more than %d texels", width, height, maxHDRTexels)
+	}
+	// Every scanline takes at least 4 bytes, however well it compresses.
+	if left := src.Len() + r.Buffered(); left < height*4 {
+		return nil, fmt.Errorf("%d bytes of scanlines are too short for %d rows", left, height)
+	}
+
+	// Phase 2: decode the scanlines, +Y files store the bottom row first
+
+	out := make([]byte, width*height*16)
+	scanline := make([]byte, width*4)
+	for y := 0; y < height; y++ {
+		err = readScanline(r, scanline)
+		if err != nil {
+			return nil, fmt.Errorf("scanline %d: %s", y, err)
+		}
+		row := y
+		if ySign == '+' {
+			row = height - 1 - y
+		}
+		dst := out[row*width*16:]
+		for x := 0; x < width; x++ {
+			rgb := rgbeToFloat(scanline[x*4 : x*4+4])
+			for ch, v := range [4]float32{rgb[0], rgb[1], rgb[2], 1} {
+				binary.LittleEndian.PutUint32(dst[x*16+ch*4:], math.Float32bits(v))
+			}
+		}
+	}
+	return &Image{
+		Format: vk.FormatR32g32b32a32Sfloat,
+		Levels: []Level{{Width: uint32(width), Height: uint32(height), Data: out}},
+		Layers: 1,
+	}, nil
+}
+
+func rgbeToFloat(rgbe []byte) [3]float32 {
+	if rgbe[3] == 0 {
+		return [3]float32{}
+	}
+	f := math.Ldexp(1, int(rgbe[3])-(128+8))
+	return [3]float32{
+		float32((float64(rgbe[0]) + 0.5) * f),
+		float32((float64(rgbe[1]) + 0.5) * f),
+		float32((float64(rgbe[2]) + 0.5) * f),
+	}
+}
+
+// readScanline reads len(line)/4 RGBE texels.
+func readScanline(r *bufio.Reader, line []byte) error {
+	width := len(line) / 4
+	head, err := r.Peek(4)
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	// Adaptive run length encoding starts with 2, 2 and the width, one
+	// channel after the other.
+	if width >= 8 && width < 0x8000 && head[0] == 2 && head[1] == 2 && head[2]&0x80 == 0 {
+		if int(head[2])<<8|int(head[3]) != width {
+			return fmt.Errorf("encoded width %d, want %d", int(head[2])<<8|int(head[3]), width)
+		}
+		r.Discard(4)
+		for ch := 0; ch < 4; ch++ {
+			for x := 0; x < width; {
+				count, err := r.ReadByte()
+				if err != nil {
+					return io.ErrUnexpectedEOF
+				}
+				run := count > 128
+				if run {
+					count -= 128
+				}
+				if count == 0 || x+int(count) > width {
+					return fmt.Errorf("bad run length %d", count)
+				}
+				var value byte
+				if run {
+					value, err = r.ReadByte()
+					if err != nil {
+						return io.ErrUnexpectedEOF
+					}
+				}
+				for end := x + int(count); x < end; x++ {
+					if !run {
+						value, err = r.ReadByte()
+						if err != nil {
+							return io.ErrUnexpectedEOF
+						}
+					}
+					line[x*4+ch] = value
+				}
+			}
+		}
+		return nil
+	}
+
+	// Flat texels, where 1, 1, 1 repeats the previous texel, shifted left
+	// by 8 bits for each run in a row.
+	shift := uint(0)
+	for x := 0; x < width; {
+		var texel [4]byte
+		if _, err := io.ReadFull(r, texel[:]); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if texel[0] == 1 && texel[1] == 1 && texel[2] == 1 {
+			if x == 0 {
+				return fmt.Errorf("run without a texel to repeat")
+			}
+			count := int(texel[3]) << shift
+			if x+count > width {
+				return fmt.Errorf("run of %d past the end of the scanline", count)
+			}
+			for ; count > 0; count-- {
+				copy(line[x*4:x*4+4], line[x*4-4:x*4])
+				x++
+			}
+			shift += 8
+			continue
+		}
+		copy(line[x*4:x*4+4], texel[:])
+		x++
+		shift = 0
+	}
+	return nil
+}
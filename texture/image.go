@@ -0,0 +1,151 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/pixels"
+)
+
+// decodeImage decodes a PNG or JPEG file. 8 bit images, paletted ones
+// included, become RGBA8. 16 bit PNGs keep their precision: linear ones
+// become RGBA16 UNORM and sRGB ones, which Vulkan has no 16 bit format for,
+// linear half floats.
+func decodeImage(data []byte, container Container, colorSpace ColorSpace) (*Image, error) {
+	var src image.Image
+	var err error
+	if container == PNG {
+		src, err = png.Decode(bytes.NewReader(data))
+	} else {
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch src.(type) {
+	case *image.Gray16, *image.RGBA64, *image.NRGBA64:
+		return from16Bit(src, colorSpace), nil
+	}
+	return from8Bit(src, colorSpace), nil
+}
+
+func from8Bit(src image.Image, colorSpace ColorSpace) *Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	data := make([]byte, w*h*4)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(src.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			i := (y*w + x) * 4
+			data[i], data[i+1], data[i+2], data[i+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	format := vk.FormatR8g8b8a8Unorm
+	if colorSpace == SRGB {
+		format = vk.FormatR8g8b8a8Srgb
+	}
+	return &Image{
+		Format: format,
+		Levels: []Level{{Width: uint32(w), Height: uint32(h), Data: data}},
+		Layers: 1,
+	}
+}
+
+func from16Bit(src image.Image, colorSpace ColorSpace) *Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	data := make([]byte, w*h*8)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA64Model.Convert(src.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA64)
+			texel := [4]uint16{c.R, c.G, c.B, c.A}
+			if colorSpace == SRGB {
+				for ch := range texel {
+					v := float64(texel[ch]) / 0xffff
+					if ch < 3 {
+						v = pixels.SRGBToLinear(v)
+					}
+					texel[ch] = Float16(float32(v))
+				}
+			}
+			i := (y*w + x) * 8
+			for ch, v := range texel {
+				binary.LittleEndian.PutUint16(data[i+2*ch:], v)
+			}
+		}
+	}
+	format := vk.FormatR16g16b16a16Unorm
+	if colorSpace == SRGB {
+		format = vk.FormatR16g16b16a16Sfloat
+	}
+	return &Image{
+		Format: format,
+		Levels: []Level{{Width: uint32(w), Height: uint32(h), Data: data}},
+		Layers: 1,
+	}
+}
+
+// Float16 converts f to an IEEE 754 half float, rounding to nearest even.
+// Values too large for a half become infinite.
+func Float16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23&0xff) - 127 + 15
+	mant := bits & 0x7fffff
+
+	switch {
+	case bits&0x7fffffff > 0x7f800000:
+		return sign | 0x7e00
+	case exp >= 0x1f:
+		return sign | 0x7c00
+	case exp <= 0:
+		if exp < -10 {
+			return sign
+		}
+		// Subnormal: shift the mantissa with its implicit bit in.
+		mant |= 0x800000
+		shift := uint(14 - exp)
+		half := mant >> shift
+		rest := mant & (1<<shift - 1)
+		mid := uint32(1) << (shift - 1)
+		if rest > mid || rest == mid && half&1 == 1 {
+			half++
+		}
+		return sign | uint16(half)
+	}
+	half := uint32(exp)<<10 | mant>>13
+	rest := mant & 0x1fff
+	if rest > 0x1000 || rest == 0x1000 && half&1 == 1 {
+		// Carries into the exponent, up to infinity, as intended.
+		half++
+	}
+	return sign | uint16(half)
+}
+
+// float16To32 converts an IEEE 754 half float to a float32.
+func float16To32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := int32(h >> 10 & 0x1f)
+	mant := uint32(h & 0x3ff)
+	switch {
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case exp == 0 && mant == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// Subnormal: normalize the mantissa.
+		exp = 1
+		for mant&0x400 == 0 {
+			mant <<= 1
+			exp--
+		}
+		mant &= 0x3ff
+	}
+	return math.Float32frombits(sign | uint32(exp+127-15)<<23 | mant<<13)
+}
@@ -0,0 +1,303 @@
+package texture
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// Supercompression is the supercompressionScheme of a KTX2 file.
+type Supercompression uint32
+
+const (
+	SupercompressionNone Supercompression = iota
+	SupercompressionBasisLZ
+	SupercompressionZstd
+	SupercompressionZlib
+)
+
+// Color models of the data format descriptor.
+const (
+	ColorModelRGBSDA = 1
+	ColorModelETC1S  = 163
+	ColorModelUASTC  = 166
+)
+
+// Transfer functions of the data format descriptor.
+const (
+	TransferLinear = 1
+	TransferSRGB   = 2
+)
+
+// Sample channel ids of Basis Universal textures meaning alpha is present.
+const (
+	channelETC1SAAA  = 15
+	channelUASTCRGBA = 3
+	channelUASTCRRRG = 5
+)
+
+// DataFormat is the basic data format descriptor block of a KTX2 file.
+type DataFormat struct {
+	ColorModel    uint8
+	Primaries     uint8
+	Transfer      uint8
+	Premultiplied bool
+	// Channels holds the channel id of each sample.
+	Channels []uint8
+}
+
+// KTX2 is a parsed KTX2 file. The data of its levels is decompressed,
+// except for BasisLZ which only a Transcoder can read.
+type KTX2 struct {
+	Format               vk.Format
+	TypeSize             uint32
+	Width, Height, Depth uint32
+	Layers, Faces        uint32
+	Supercompression     Supercompression
+	DFD                  DataFormat
+	KeyValues            map[string][]byte
+	SupercompressionData []byte
+	// Levels holds the levels of the file, the full size one first. A file
+	// asking for generated mipmaps has a single level.
+	Levels []Level
+}
+
+var ktx2Identifier = []byte{0xab, 'K', 'T', 'X', ' ', '2', '0', 0xbb, '\r', '\n', 0x1a, '\n'}
+
+const (
+	ktx2HeaderSize     = 80
+	ktx2LevelIndexSize = 24
+	// ktx2MaxLevels bounds the level count of a corrupt header.
+	ktx2MaxLevels = 32
+	// ktx2MaxLevelSize bounds the memory a corrupt supercompressed level
+	// can make decompression allocate.
+	ktx2MaxLevelSize = 1 << 30
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// decodeZstd decodes a level with a decoder shared by all files, created
+// on first use.
+func decodeZstd(level []byte, size uint64) ([]byte, error) {
+	zstdOnce.Do(func() {
+		zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0),
+			zstd.WithDecoderMaxMemory(ktx2MaxLevelSize))
+	})
+	if zstdErr != nil {
+		return nil, zstdErr
+	}
+	return zstdDecoder.DecodeAll(level, make([]byte, 0, size))
+}
+
+// decodeZlib decodes a level, reading one byte past size so that a level
+// longer than the index says is rejected without decoding all of it.
+func decodeZlib(level []byte, size uint64) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(level))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(io.LimitReader(r, int64(size)+1))
+}
+
+// IsBasis reports whether k holds Basis Universal data, which has to be
+// transcoded before it can be sampled.
+func (k *KTX2) IsBasis() bool {
+	return k.Format == vk.FormatUndefined &&
+		(k.DFD.ColorModel == ColorModelETC1S || k.DFD.ColorModel == ColorModelUASTC)
+}
+
+func (k *KTX2) SRGB() bool {
+	return k.DFD.Transfer == TransferSRGB
+}
+
+// HasAlpha reports whether a Basis Universal texture has an alpha channel.
+func (k *KTX2) HasAlpha() bool {
+	for _, ch := range k.DFD.Channels {
+		switch {
+		case k.DFD.ColorModel == ColorModelETC1S && ch == channelETC1SAAA,
+			k.DFD.ColorModel == ColorModelUASTC && (ch == channelUASTCRGBA || ch == channelUASTCRRRG):
+			return true
+		}
+	}
+	return false
+}
+
+// ParseKTX2 parses a KTX2 file and decompresses its levels.
+func ParseKTX2(data []byte) (*KTX2, error) {
+	if !bytes.HasPrefix(data, ktx2Identifier) || len(data) < ktx2HeaderSize {
+		return nil, fmt.Errorf("not a KTX2 file")
+	}
+	le := binary.LittleEndian
+
+	// Phase 1: read the header and the index
+
+	k := &KTX2{
+		Format:           vk.Format(le.Uint32(data[12:])),
+		TypeSize:         le.Uint32(data[16:]),
+		Width:            le.Uint32(data[20:]),
+		Height:           le.Uint32(data[24:]),
+		Depth:            le.Uint32(data[28:]),
+		Layers:           le.Uint32(data[32:]),
+		Faces:            le.Uint32(data[36:]),
+		Supercompression: Supercompression(le.Uint32(data[44:])),
+		KeyValues:        make(map[string][]byte),
+	}
+	levelCount := max(le.Uint32(data[40:]), 1)
+	if k.Width == 0 || levelCount > ktx2MaxLevels || k.Faces != 1 && k.Faces != 6 {
+		return nil, fmt.Errorf("invalid header: %dx%d texels, %d levels, %d faces",
+			k.Width, k.Height, levelCount, k.Faces)
+	}
+	if k.Supercompression > SupercompressionZlib {
+		return nil, fmt.Errorf("unknown supercompression scheme %d", k.Supercompression)
+	}
+	section := func(offset, length uint64, name string) ([]byte, error) {
+		if offset > uint64(len(data)) || length > uint64(len(data))-offset {
+			return nil, fmt.Errorf("%s of %d bytes at %d is past the end of the file", name, length, offset)
+		}
+		return data[offset : offset+length], nil
+	}
+	index, err := section(ktx2HeaderSize, uint64(levelCount)*ktx2LevelIndexSize, "level index")
+	if err != nil {
+		return nil, err
+	}
+	dfd, err := section(uint64(le.Uint32(data[48:])), uint64(le.Uint32(data[52:])), "data format descriptor")
+	if err != nil {
+		return nil, err
+	}
+	kvd, err := section(uint64(le.Uint32(data[56:])), uint64(le.Uint32(data[60:])), "key/value data")
+	if err != nil {
+		return nil, err
+	}
+	k.SupercompressionData, err = section(le.Uint64(data[64:]), le.Uint64(data[72:]), "supercompression data")
+	if err != nil {
+		return nil, err
+	}
+
+	// Phase 2: read the data format descriptor and the key/value pairs
+
+	k.DFD, err = parseDFD(dfd)
+	if err != nil {
+		return nil, err
+	}
+	for len(kvd) >= 4 {
+		length := le.Uint32(kvd)
+		if uint64(length) > uint64(len(kvd)-4) {
+			return nil, fmt.Errorf("key/value pair of %d bytes is past the end of its section", length)
+		}
+		pair := kvd[4 : 4+length]
+		if key, value, ok := bytes.Cut(pair, []byte{0}); ok {
+			k.KeyValues[string(key)] = value
+		}
+		// Pairs are padded to 4 bytes.
+		kvd = kvd[min(len(kvd), int(4+(length+3)/4*4)):]
+	}
+
+	// Phase 3: read and decompress the levels
+
+	for i := uint32(0); i < levelCount; i++ {
+		entry := index[i*ktx2LevelIndexSize:]
+		level, err := section(le.Uint64(entry), le.Uint64(entry[8:]), fmt.Sprintf("level %d", i))
+		if err != nil {
+			return nil, err
+		}
+		uncompressed := le.Uint64(entry[16:])
+		compressed := k.Supercompression == SupercompressionZstd || k.Supercompression == SupercompressionZlib
+		if compressed && uncompressed > ktx2MaxLevelSize {
+			return nil, fmt.Errorf("level %d of %d bytes is too large", i, uncompressed)
+		}
+		switch k.Supercompression {
+		case SupercompressionZstd:
+			level, err = decodeZstd(level, min(uncompressed, uint64(len(data))*64))
+		case SupercompressionZlib:
+			level, err = decodeZlib(level, uncompressed)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decompressing level %d failed with %s", i, err)
+		}
+		if k.Supercompression != SupercompressionBasisLZ && uint64(len(level)) != uncompressed {
+			return nil, fmt.Errorf("level %d has %d bytes, the index says %d", i, len(level), uncompressed)
+		}
+		k.Levels = append(k.Levels, Level{
+			Width:  max(k.Width>>i, 1),
+			Height: max(k.Height>>i, 1),
+			Data:   level,
+		})
+	}
+	return k, nil
+}
+
+// parseDFD reads the basic descriptor block, the first of the data format
+// descriptor.
+func parseDFD(dfd []byte) (DataFormat, error) {
+	le := binary.LittleEndian
+	const blockHeaderSize, sampleSize = 24, 16
+	if len(dfd) < 4+blockHeaderSize {
+		return DataFormat{}, fmt.Errorf("data format descriptor of %d bytes is too short", len(dfd))
+	}
+	block := dfd[4:]
+	if vendorAndType := le.Uint32(block); vendorAndType != 0 {
+		return DataFormat{}, fmt.Errorf("the first descriptor block isn't the basic Khronos one")
+	}
+	blockSize := int(le.Uint16(block[6:]))
+	if blockSize < blockHeaderSize || blockSize > len(block) {
+		return DataFormat{}, fmt.Errorf("invalid descriptor block size %d", blockSize)
+	}
+	df := DataFormat{
+		ColorModel:    block[8],
+		Primaries:     block[9],
+		Transfer:      block[10],
+		Premultiplied: block[11]&1 != 0,
+	}
+	for s := blockHeaderSize; s+sampleSize <= blockSize; s += sampleSize {
+		df.Channels = append(df.Channels, block[s+3]&0xf)
+	}
+	return df, nil
+}
+
+// decodeKTX2 turns a KTX2 file into an image, transcoding Basis Universal
+// data with the registered Transcoder. 2D textures, arrays and cubemaps are
+// supported, KTX2 stores their layers like Vulkan.
+func decodeKTX2(data []byte, opts Options) (*Image, error) {
+	k, err := ParseKTX2(data)
+	if err != nil {
+		return nil, err
+	}
+	if k.Depth > 1 || k.Height == 0 {
+		return nil, fmt.Errorf("only 2D textures are supported, not %dx%dx%d", k.Width, k.Height, k.Depth)
+	}
+	if !k.IsBasis() {
+		if k.Format == vk.FormatUndefined {
+			return nil, fmt.Errorf("no format and color model %d", k.DFD.ColorModel)
+		}
+		if k.Supercompression == SupercompressionBasisLZ {
+			return nil, fmt.Errorf("BasisLZ supercompression of format %d", k.Format)
+		}
+		return &Image{
+			Format: k.Format,
+			Levels: k.Levels,
+			Layers: max(k.Layers, 1) * k.Faces,
+			Cube:   k.Faces == 6,
+		}, nil
+	}
+
+	t := registeredTranscoder()
+	if t == nil {
+		return nil, fmt.Errorf("Basis Universal data and no transcoder registered")
+	}
+	format, err := SelectTranscodeTarget(k, opts.Supported, t)
+	if err != nil {
+		return nil, err
+	}
+	return t.Transcode(k, format)
+}
@@ -0,0 +1,137 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// The fixtures hold a 4x4 R8G8B8A8_UNORM texture with 3 levels, byte j of
+// level i being i*64 + j, as is and supercompressed. cube-zlib.ktx2 is a
+// 2x2 cubemap with 2 levels whose faces all hold that level data.
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func fixtureLevel(i int, width, height uint32) []byte {
+	data := make([]byte, width*height*4)
+	for j := range data {
+		data[j] = byte(i*64 + j)
+	}
+	return data
+}
+
+func TestParseKTX2(t *testing.T) {
+	tests := []struct {
+		file             string
+		supercompression Supercompression
+	}{
+		{"rgba8.ktx2", SupercompressionNone},
+		{"rgba8-zstd.ktx2", SupercompressionZstd},
+		{"rgba8-zlib.ktx2", SupercompressionZlib},
+	}
+	for _, test := range tests {
+		k, err := ParseKTX2(readFixture(t, test.file))
+		if err != nil {
+			t.Errorf("%s: %s", test.file, err)
+			continue
+		}
+		if k.Format != vk.FormatR8g8b8a8Unorm || k.Width != 4 || k.Height != 4 || k.Faces != 1 {
+			t.Errorf("%s: %dx%d of format %d with %d faces, want 4x4 R8G8B8A8_UNORM", test.file,
+				k.Width, k.Height, k.Format, k.Faces)
+		}
+		if k.Supercompression != test.supercompression {
+			t.Errorf("%s: supercompression %d, want %d", test.file, k.Supercompression, test.supercompression)
+		}
+		if k.DFD.ColorModel != ColorModelRGBSDA || k.SRGB() || !bytes.Equal(k.DFD.Channels, []byte{0, 1, 2, 15}) {
+			t.Errorf("%s: data format %+v, want linear RGBA", test.file, k.DFD)
+		}
+		if writer := string(k.KeyValues["KTXwriter"]); writer != "vulkan-samples fixture\x00" {
+			t.Errorf("%s: KTXwriter %q", test.file, writer)
+		}
+		if len(k.Levels) != 3 {
+			t.Fatalf("%s: %d levels, want 3", test.file, len(k.Levels))
+		}
+		for i, level := range k.Levels {
+			size := uint32(4 >> i)
+			if level.Width != size || level.Height != size || !bytes.Equal(level.Data, fixtureLevel(i, size, size)) {
+				t.Errorf("%s: level %d is %dx%d % x", test.file, i, level.Width, level.Height, level.Data)
+			}
+		}
+	}
+}
+
+func TestDecodeKTX2Cube(t *testing.T) {
+	img, err := Decode(readFixture(t, "cube-zlib.ktx2"), "image/ktx2", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !img.Cube || img.Layers != 6 || len(img.Levels) != 2 {
+		t.Fatalf("cube %v with %d layers and %d levels, want a cube of 6 and 2 levels",
+			img.Cube, img.Layers, len(img.Levels))
+	}
+	for i, level := range img.Levels {
+		size := uint32(2 >> i)
+		face := fixtureLevel(i, size, size)
+		if want := bytes.Repeat(face, 6); !bytes.Equal(level.Data, want) {
+			t.Errorf("level %d % x, want % x", i, level.Data, want)
+		}
+	}
+}
+
+func TestParseKTX2Errors(t *testing.T) {
+	le := binary.LittleEndian
+	// modified returns a fixture with a 32 or 64 bit field changed.
+	modified := func(name string, offset int, v uint64, size int) []byte {
+		data := readFixture(t, name)
+		if size == 4 {
+			le.PutUint32(data[offset:], uint32(v))
+		} else {
+			le.PutUint64(data[offset:], v)
+		}
+		return data
+	}
+	// The uncompressed size of level 0 is the third field of the index.
+	const uncompressed = ktx2HeaderSize + 16
+	level0 := uint64(4 * 4 * 4)
+	corrupted := func(name string) []byte {
+		data := readFixture(t, name)
+		offset := le.Uint64(data[ktx2HeaderSize:])
+		for i := offset + 2; i < offset+8; i++ {
+			data[i] ^= 0x5a
+		}
+		return data
+	}
+	fixture := readFixture(t, "rgba8.ktx2")
+	bad := map[string][]byte{
+		"not KTX2":                      fixture[1:],
+		"short header":                  fixture[:ktx2HeaderSize-1],
+		"truncated level":               fixture[:len(fixture)-1],
+		"no width":                      modified("rgba8.ktx2", 20, 0, 4),
+		"too many levels":               modified("rgba8.ktx2", 40, ktx2MaxLevels+1, 4),
+		"3 faces":                       modified("rgba8.ktx2", 36, 3, 4),
+		"unknown supercompression":      modified("rgba8.ktx2", 44, 4, 4),
+		"uncompressed size mismatch":    modified("rgba8.ktx2", uncompressed, level0+1, 8),
+		"zlib level longer than index":  modified("rgba8-zlib.ktx2", uncompressed, level0-1, 8),
+		"zlib level shorter than index": modified("rgba8-zlib.ktx2", uncompressed, level0+1, 8),
+		"zlib level too large":          modified("rgba8-zlib.ktx2", uncompressed, ktx2MaxLevelSize+1, 8),
+		"corrupt zlib level":            corrupted("rgba8-zlib.ktx2"),
+		"zstd level longer than index":  modified("rgba8-zstd.ktx2", uncompressed, level0-1, 8),
+		"zstd level too large":          modified("rgba8-zstd.ktx2", uncompressed, 1<<40, 8),
+		"corrupt zstd level":            corrupted("rgba8-zstd.ktx2"),
+	}
+	for name, data := range bad {
+		if _, err := ParseKTX2(data); err == nil {
+			t.Errorf("%s: ParseKTX2 succeeded", name)
+		}
+	}
+}
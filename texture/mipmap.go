@@ -0,0 +1,244 @@
+package texture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/pixels"
+)
+
+// MipFilter is the filter GenerateMipmaps downsamples with.
+type MipFilter int
+
+const (
+	// FilterBox averages the texels each texel of the smaller level
+	// covers, the texels of odd sized levels partly.
+	FilterBox MipFilter = iota
+	// FilterKaiser is a Kaiser windowed sinc, sharper than the box filter.
+	FilterKaiser
+)
+
+// Kaiser window of 3 texels of the smaller level on each side.
+const (
+	kaiserRadius = 3
+	kaiserAlpha  = 4
+)
+
+// MipLevelCount is the number of levels of a full mip chain.
+func MipLevelCount(width, height uint32) int {
+	return bits.Len32(max(width, height, 1))
+}
+
+// MipSize is the size of a side of mip level level, as Vulkan rounds it.
+func MipSize(size uint32, level int) uint32 {
+	return max(size>>level, 1)
+}
+
+// CanGenerateMipmaps reports whether GenerateMipmaps handles format.
+func CanGenerateMipmaps(format vk.Format) bool {
+	_, ok := mipTexelSize(format)
+	return ok
+}
+
+func mipTexelSize(format vk.Format) (int, bool) {
+	switch format {
+	case vk.FormatR8g8b8a8Unorm, vk.FormatR8g8b8a8Srgb, vk.FormatR8g8b8a8Snorm,
+		vk.FormatB8g8r8a8Unorm, vk.FormatB8g8r8a8Srgb:
+		return 4, true
+	case vk.FormatR16g16b16a16Unorm, vk.FormatR16g16b16a16Sfloat:
+		return 8, true
+	case vk.FormatR32g32b32a32Sfloat:
+		return 16, true
+	}
+	return 0, false
+}
+
+// GenerateMipmaps returns img with a full mip chain downsampled from its
+// first level, layer by layer. sRGB texels are filtered as linear values,
+// so that the levels don't darken, alpha and linear formats as they are.
+// Only 4 channel uncompressed formats are supported.
+func GenerateMipmaps(img *Image, filter MipFilter) (*Image, error) {
+	texelSize, ok := mipTexelSize(img.Format)
+	if !ok {
+		return nil, fmt.Errorf("can't generate mipmaps of format %d", img.Format)
+	}
+	if len(img.Levels) == 0 {
+		return nil, fmt.Errorf("image has no levels")
+	}
+	width, height := img.Width(), img.Height()
+	layers := int(max(img.Layers, 1))
+	layerSize := int(width) * int(height) * texelSize
+	if len(img.Levels[0].Data) < layerSize*layers {
+		return nil, fmt.Errorf("level 0 has %d bytes, %d layers of %dx%d texels need %d",
+			len(img.Levels[0].Data), layers, width, height, layerSize*layers)
+	}
+	count := MipLevelCount(width, height)
+	out := &Image{Format: img.Format, Layers: img.Layers, Cube: img.Cube, Levels: make([]Level, count)}
+	out.Levels[0] = Level{Width: width, Height: height, Data: img.Levels[0].Data[:layerSize*layers]}
+	for i := 1; i < count; i++ {
+		w, h := MipSize(width, i), MipSize(height, i)
+		out.Levels[i] = Level{Width: w, Height: h, Data: make([]byte, 0, int(w)*int(h)*texelSize*layers)}
+	}
+
+	// Each level is downsampled from the previous one, in linear floats.
+	for layer := 0; layer < layers; layer++ {
+		src := toLinear(img.Format, out.Levels[0].Data[layer*layerSize:][:layerSize])
+		for i := 1; i < count; i++ {
+			prev, level := out.Levels[i-1], &out.Levels[i]
+			src = downsample(src, int(prev.Width), int(prev.Height), int(level.Width), int(level.Height), filter)
+			level.Data = append(level.Data, fromLinear(img.Format, src)...)
+		}
+	}
+	return out, nil
+}
+
+// toLinear converts texels to RGBA floats, decoding sRGB color channels.
+func toLinear(format vk.Format, data []byte) []float32 {
+	le := binary.LittleEndian
+	texelSize, _ := mipTexelSize(format)
+	out := make([]float32, len(data)/texelSize*4)
+	for i := range out {
+		ch := i % 4
+		switch format {
+		case vk.FormatR8g8b8a8Unorm, vk.FormatB8g8r8a8Unorm:
+			out[i] = float32(data[i]) / 0xff
+		case vk.FormatR8g8b8a8Srgb, vk.FormatB8g8r8a8Srgb:
+			out[i] = float32(data[i]) / 0xff
+			if ch < 3 {
+				out[i] = float32(pixels.SRGBToLinear(float64(data[i]) / 0xff))
+			}
+		case vk.FormatR8g8b8a8Snorm:
+			out[i] = max(float32(int8(data[i]))/0x7f, -1)
+		case vk.FormatR16g16b16a16Unorm:
+			out[i] = float32(le.Uint16(data[i*2:])) / 0xffff
+		case vk.FormatR16g16b16a16Sfloat:
+			out[i] = float16To32(le.Uint16(data[i*2:]))
+		case vk.FormatR32g32b32a32Sfloat:
+			out[i] = math.Float32frombits(le.Uint32(data[i*4:]))
+		}
+	}
+	return out
+}
+
+// fromLinear is the inverse of toLinear. Normalized formats are clamped,
+// sharp filters overshoot.
+func fromLinear(format vk.Format, texels []float32) []byte {
+	le := binary.LittleEndian
+	texelSize, _ := mipTexelSize(format)
+	out := make([]byte, len(texels)/4*texelSize)
+	unorm := func(v float32, scale float64) float64 {
+		return math.Round(math.Min(math.Max(float64(v), 0), 1) * scale)
+	}
+	for i, v := range texels {
+		ch := i % 4
+		switch format {
+		case vk.FormatR8g8b8a8Unorm, vk.FormatB8g8r8a8Unorm:
+			out[i] = byte(unorm(v, 0xff))
+		case vk.FormatR8g8b8a8Srgb, vk.FormatB8g8r8a8Srgb:
+			if ch < 3 {
+				v = float32(pixels.LinearToSRGB(math.Min(math.Max(float64(v), 0), 1)))
+			}
+			out[i] = byte(unorm(v, 0xff))
+		case vk.FormatR8g8b8a8Snorm:
+			out[i] = byte(int8(math.Round(math.Min(math.Max(float64(v), -1), 1) * 0x7f)))
+		case vk.FormatR16g16b16a16Unorm:
+			le.PutUint16(out[i*2:], uint16(unorm(v, 0xffff)))
+		case vk.FormatR16g16b16a16Sfloat:
+			le.PutUint16(out[i*2:], Float16(v))
+		case vk.FormatR32g32b32a32Sfloat:
+			le.PutUint32(out[i*4:], math.Float32bits(v))
+		}
+	}
+	return out
+}
+
+// tap is the weight of a texel of the larger level.
+type tap struct {
+	index  int
+	weight float32
+}
+
+// filterTaps returns the taps of each of the dst texels of a row or column
+// of src texels. Taps past the edges are clamped to the edge texels.
+func filterTaps(src, dst int, filter MipFilter) [][]tap {
+	scale := float64(src) / float64(dst)
+	taps := make([][]tap, dst)
+	for i := range taps {
+		if filter == FilterBox {
+			// The texels overlapping [i*scale, (i+1)*scale).
+			start, end := float64(i)*scale, float64(i+1)*scale
+			for j := int(start); float64(j) < end; j++ {
+				overlap := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+				taps[i] = append(taps[i], tap{index: j, weight: float32(overlap / scale)})
+			}
+			continue
+		}
+		center := (float64(i) + 0.5) * scale
+		radius := kaiserRadius * scale
+		var sum float64
+		for j := int(math.Floor(center - radius)); float64(j) < center+radius; j++ {
+			x := (float64(j) + 0.5 - center) / scale
+			w := kaiser(x)
+			if w == 0 {
+				continue
+			}
+			taps[i] = append(taps[i], tap{index: min(max(j, 0), src-1), weight: float32(w)})
+			sum += w
+		}
+		for k := range taps[i] {
+			taps[i][k].weight /= float32(sum)
+		}
+	}
+	return taps
+}
+
+// kaiser is the Kaiser windowed sinc at x texels of the smaller level.
+func kaiser(x float64) float64 {
+	t := x / kaiserRadius
+	if t <= -1 || t >= 1 {
+		return 0
+	}
+	sinc := 1.0
+	if x != 0 {
+		sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+	}
+	return sinc * besselI0(kaiserAlpha*math.Sqrt(1-t*t)) / besselI0(kaiserAlpha)
+}
+
+// besselI0 is the modified Bessel function of the first kind of order 0.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > sum*1e-12; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+// downsample filters sw by sh RGBA texels to dw by dh, rows then columns.
+func downsample(src []float32, sw, sh, dw, dh int, filter MipFilter) []float32 {
+	rows := make([]float32, dw*sh*4)
+	for x, taps := range filterTaps(sw, dw, filter) {
+		for y := 0; y < sh; y++ {
+			for _, t := range taps {
+				for ch := 0; ch < 4; ch++ {
+					rows[(y*dw+x)*4+ch] += t.weight * src[(y*sw+t.index)*4+ch]
+				}
+			}
+		}
+	}
+	out := make([]float32, dw*dh*4)
+	for y, taps := range filterTaps(sh, dh, filter) {
+		for x := 0; x < dw; x++ {
+			for _, t := range taps {
+				for ch := 0; ch < 4; ch++ {
+					out[(y*dw+x)*4+ch] += t.weight * rows[(t.index*dw+x)*4+ch]
+				}
+			}
+		}
+	}
+	return out
+}
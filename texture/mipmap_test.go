@@ -0,0 +1,190 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestGenerateMipmapsSizes(t *testing.T) {
+	tests := []struct {
+		width, height uint32
+		want          [][2]uint32
+	}{
+		{1, 1, [][2]uint32{{1, 1}}},
+		{7, 3, [][2]uint32{{7, 3}, {3, 1}, {1, 1}}},
+		{5, 1, [][2]uint32{{5, 1}, {2, 1}, {1, 1}}},
+		{1, 9, [][2]uint32{{1, 9}, {1, 4}, {1, 2}, {1, 1}}},
+		{6, 6, [][2]uint32{{6, 6}, {3, 3}, {1, 1}}},
+	}
+	for _, test := range tests {
+		size := int(test.width*test.height) * 4 * 2
+		img := &Image{Format: vk.FormatR8g8b8a8Unorm, Layers: 2,
+			Levels: []Level{{Width: test.width, Height: test.height, Data: make([]byte, size)}}}
+		out, err := GenerateMipmaps(img, FilterBox)
+		if err != nil {
+			t.Errorf("%dx%d: %s", test.width, test.height, err)
+			continue
+		}
+		if len(out.Levels) != len(test.want) || MipLevelCount(test.width, test.height) != len(test.want) {
+			t.Errorf("%dx%d: %d levels, want %d", test.width, test.height, len(out.Levels), len(test.want))
+			continue
+		}
+		for i, level := range out.Levels {
+			w, h := test.want[i][0], test.want[i][1]
+			if level.Width != w || level.Height != h || len(level.Data) != int(w*h)*4*2 {
+				t.Errorf("%dx%d: level %d is %dx%d with %d bytes, want %dx%d of 2 layers", test.width, test.height,
+					i, level.Width, level.Height, len(level.Data), w, h)
+			}
+		}
+	}
+}
+
+func TestFilterTaps(t *testing.T) {
+	tests := []struct {
+		src, dst int
+		want     [][]tap
+	}{
+		{4, 2, [][]tap{{{0, 0.5}, {1, 0.5}}, {{2, 0.5}, {3, 0.5}}}},
+		{3, 1, [][]tap{{{0, 1. / 3}, {1, 1. / 3}, {2, 1. / 3}}}},
+		// The middle texel of an odd row is split between both.
+		{5, 2, [][]tap{{{0, 0.4}, {1, 0.4}, {2, 0.2}}, {{2, 0.2}, {3, 0.4}, {4, 0.4}}}},
+	}
+	for _, test := range tests {
+		taps := filterTaps(test.src, test.dst, FilterBox)
+		if len(taps) != len(test.want) {
+			t.Errorf("%d to %d: %d texels, want %d", test.src, test.dst, len(taps), len(test.want))
+			continue
+		}
+		for i := range taps {
+			if len(taps[i]) != len(test.want[i]) {
+				t.Errorf("%d to %d: taps of texel %d %v, want %v", test.src, test.dst, i, taps[i], test.want[i])
+				continue
+			}
+			for k, want := range test.want[i] {
+				if got := taps[i][k]; got.index != want.index || math.Abs(float64(got.weight-want.weight)) > 1e-6 {
+					t.Errorf("%d to %d: tap %d of texel %d %v, want %v", test.src, test.dst, k, i, got, want)
+				}
+			}
+		}
+	}
+
+	// Kaiser taps are normalized and clamped to the row.
+	for i, taps := range filterTaps(9, 4, FilterKaiser) {
+		var sum float32
+		for _, tap := range taps {
+			if tap.index < 0 || tap.index >= 9 {
+				t.Errorf("Kaiser tap of texel %d at %d, outside the row", i, tap.index)
+			}
+			sum += tap.weight
+		}
+		if math.Abs(float64(sum-1)) > 1e-5 {
+			t.Errorf("Kaiser taps of texel %d sum to %g, want 1", i, sum)
+		}
+	}
+}
+
+func TestGenerateMipmapsValues(t *testing.T) {
+	half := func(values ...float32) []byte {
+		data := make([]byte, len(values)*2)
+		for i, v := range values {
+			binary.LittleEndian.PutUint16(data[i*2:], Float16(v))
+		}
+		return data
+	}
+	// The texels of a 2x2 checkerboard of black and white.
+	checker := []byte{0, 0, 0, 255, 255, 255, 255, 255, 255, 255, 255, 255, 0, 0, 0, 255}
+	tests := []struct {
+		name          string
+		format        vk.Format
+		width, height uint32
+		data          []byte
+		want          []byte
+	}{
+		{"box of 3", vk.FormatR8g8b8a8Unorm, 3, 1,
+			[]byte{0, 0, 0, 0, 90, 90, 90, 90, 180, 180, 180, 180}, []byte{90, 90, 90, 90}},
+		// sRGB colors average to linear 0.5, alpha stays linear.
+		{"sRGB checkerboard", vk.FormatR8g8b8a8Srgb, 2, 2, checker, []byte{188, 188, 188, 255}},
+		{"UNORM checkerboard", vk.FormatR8g8b8a8Unorm, 2, 2, checker, []byte{128, 128, 128, 255}},
+		{"BGRA sRGB checkerboard", vk.FormatB8g8r8a8Srgb, 2, 2, checker, []byte{188, 188, 188, 255}},
+		{"SNORM", vk.FormatR8g8b8a8Snorm, 2, 1, []byte{0x81, 0x7f, 0x80, 0, 0x7f, 0x7f, 0x7f, 0x7f},
+			[]byte{0, 0x7f, 0, 0x40}},
+		{"half floats", vk.FormatR16g16b16a16Sfloat, 2, 1, half(1, 3, -2, 1, 3, 1, 2, 1), half(2, 2, 0, 1)},
+	}
+	for _, test := range tests {
+		img := &Image{Format: test.format, Layers: 1,
+			Levels: []Level{{Width: test.width, Height: test.height, Data: test.data}}}
+		out, err := GenerateMipmaps(img, FilterBox)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if got := out.Levels[len(out.Levels)-1].Data; !bytes.Equal(got, test.want) {
+			t.Errorf("%s: last level %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGenerateMipmapsSRGBRoundTrip(t *testing.T) {
+	// A uniform level decoded to linear and encoded again keeps its
+	// value, with both filters.
+	for _, filter := range []MipFilter{FilterBox, FilterKaiser} {
+		for v := 0; v < 256; v++ {
+			data := bytes.Repeat([]byte{byte(v), byte(v), byte(v), byte(255 - v)}, 8*4)
+			img := &Image{Format: vk.FormatR8g8b8a8Srgb, Layers: 1, Levels: []Level{{Width: 8, Height: 4, Data: data}}}
+			out, err := GenerateMipmaps(img, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, level := range out.Levels[1:] {
+				if !bytes.Equal(level.Data, data[:len(level.Data)]) {
+					t.Errorf("filter %d: level %d of %d is %v", filter, i+1, v, level.Data[:4])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateMipmapsLayers(t *testing.T) {
+	// 6 faces of a 4x4 cube, each of one value. The faces stay in order in
+	// every level and aren't filtered into each other.
+	var data []byte
+	for face := 0; face < 6; face++ {
+		data = append(data, bytes.Repeat([]byte{byte(face * 40)}, 4*4*4)...)
+	}
+	img := &Image{Format: vk.FormatR8g8b8a8Unorm, Layers: 6, Cube: true,
+		Levels: []Level{{Width: 4, Height: 4, Data: data}}}
+	out, err := GenerateMipmaps(img, FilterKaiser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Layers != 6 || !out.Cube || len(out.Levels) != 3 {
+		t.Fatalf("%d layers, cube %v and %d levels, want a cube of 6 and 3 levels", out.Layers, out.Cube, len(out.Levels))
+	}
+	for i, level := range out.Levels {
+		faceSize := int(level.Width*level.Height) * 4
+		for face := 0; face < 6; face++ {
+			want := bytes.Repeat([]byte{byte(face * 40)}, faceSize)
+			if got := level.Data[face*faceSize:][:faceSize]; !bytes.Equal(got, want) {
+				t.Errorf("level %d of face %d is %v, want %d", i, face, got, face*40)
+			}
+		}
+	}
+}
+
+func TestGenerateMipmapsErrors(t *testing.T) {
+	bad := map[string]*Image{
+		"no levels":  {Format: vk.FormatR8g8b8a8Unorm, Layers: 1},
+		"BC7":        {Format: vk.FormatBc7UnormBlock, Layers: 1, Levels: []Level{{Width: 4, Height: 4, Data: make([]byte, 16)}}},
+		"short data": {Format: vk.FormatR8g8b8a8Unorm, Layers: 2, Levels: []Level{{Width: 2, Height: 2, Data: make([]byte, 16)}}},
+	}
+	for name, img := range bad {
+		if _, err := GenerateMipmaps(img, FilterBox); err == nil {
+			t.Errorf("%s: GenerateMipmaps succeeded", name)
+		}
+	}
+}
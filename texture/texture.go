@@ -0,0 +1,176 @@
+// Package texture decodes texture files into texel data ready to upload,
+// with the vk.Format to create the image with, and parses KTX2 and DDS
+// containers. It detects the container from the magic bytes of the data and
+// returns errors for anything it can't decode.
+package texture
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// ColorSpace tells how the 8 and 16 bit channels of a texture are encoded.
+// glTF stores baseColor and emissive textures sRGB encoded and all others,
+// like normals, roughness or occlusion, as linear data.
+type ColorSpace int
+
+const (
+	Linear ColorSpace = iota
+	SRGB
+)
+
+// Container is a file format textures are stored in.
+type Container int
+
+const (
+	Unknown Container = iota
+	PNG
+	JPEG
+	HDR
+	KTX2Container
+	DDS
+)
+
+func (c Container) String() string {
+	switch c {
+	case PNG:
+		return "PNG"
+	case JPEG:
+		return "JPEG"
+	case HDR:
+		return "Radiance HDR"
+	case KTX2Container:
+		return "KTX2"
+	case DDS:
+		return "DDS"
+	}
+	return "unknown"
+}
+
+// Level is a mip level in the row order of Vulkan, top row first, with
+// tightly packed rows. It holds the level of each array layer one after the
+// other.
+type Level struct {
+	Width, Height uint32
+	Data          []byte
+}
+
+// Image is a decoded texture. Levels[0] is the full size image.
+type Image struct {
+	Format vk.Format
+	Levels []Level
+	// Layers is the number of array layers, six per cube for cubemaps.
+	Layers uint32
+	Cube   bool
+}
+
+func (img *Image) Width() uint32 {
+	return img.Levels[0].Width
+}
+
+func (img *Image) Height() uint32 {
+	return img.Levels[0].Height
+}
+
+var magics = []struct {
+	container Container
+	magic     []byte
+}{
+	{PNG, []byte("\x89PNG\r\n\x1a\n")},
+	{JPEG, []byte{0xff, 0xd8, 0xff}},
+	{HDR, []byte("#?RADIANCE")},
+	{HDR, []byte("#?RGBE")},
+	{KTX2Container, ktx2Identifier},
+	{DDS, ddsMagic},
+}
+
+// Detect tells the container of data from its magic bytes.
+func Detect(data []byte) Container {
+	for _, m := range magics {
+		if bytes.HasPrefix(data, m.magic) {
+			return m.container
+		}
+	}
+	return Unknown
+}
+
+// FromMimeType maps the mimeType of a glTF image to its container.
+func FromMimeType(mimeType string) Container {
+	switch mimeType {
+	case "image/png":
+		return PNG
+	case "image/jpeg", "image/jpg":
+		return JPEG
+	case "image/vnd.radiance", "image/x-hdr":
+		return HDR
+	case "image/ktx2":
+		return KTX2Container
+	case "image/vnd-ms.dds", "image/vnd.ms-dds":
+		return DDS
+	}
+	return Unknown
+}
+
+type Options struct {
+	// ColorSpace selects the format of 8 and 16 bit PNG and JPEG images
+	// and of DDS files that don't tell, KTX2 files tell theirs.
+	ColorSpace ColorSpace
+	// Supported reports whether the device samples a format. It picks
+	// the format Basis Universal textures are transcoded to, and BCn
+	// textures in unsupported formats are decoded to RGBA8. Nil only
+	// allows uncompressed formats.
+	Supported func(vk.Format) bool
+}
+
+func (opts Options) supports(format vk.Format) bool {
+	if opts.Supported == nil {
+		_, _, _, compressed := CompressedBlock(format)
+		return !compressed
+	}
+	return opts.Supported(format)
+}
+
+// Decode decodes data, in the container given by its magic bytes or else
+// by mimeType, which may be empty. A mimeType that disagrees with the magic
+// bytes is logged, exporters get it wrong often enough.
+func Decode(data []byte, mimeType string, opts Options) (*Image, error) {
+	container := Detect(data)
+	declared := FromMimeType(mimeType)
+	switch {
+	case container == Unknown && declared == Unknown:
+		if mimeType != "" {
+			return nil, fmt.Errorf("unsupported texture mimeType %q", mimeType)
+		}
+		return nil, fmt.Errorf("unknown texture format")
+	case container == Unknown:
+		container = declared
+	case declared != Unknown && declared != container:
+		log.Println("[WARN] texture declared as", mimeType, "is a", container, "image")
+	}
+
+	var img *Image
+	var err error
+	switch container {
+	case PNG, JPEG:
+		img, err = decodeImage(data, container, opts.ColorSpace)
+	case HDR:
+		img, err = decodeHDR(data)
+	case KTX2Container:
+		img, err = decodeKTX2(data, opts)
+	case DDS:
+		img, err = decodeDDS(data, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s texture failed with %s", container, err)
+	}
+	if !opts.supports(img.Format) && IsBC(img.Format) {
+		img, err = DecodeBC(img)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s texture failed with %s", container, err)
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,230 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// hdrFile is a Radiance file of the given resolution line and scanlines.
+func hdrFile(resolution string, scanlines ...byte) []byte {
+	header := "#?RADIANCE\n# test\nFORMAT=32-bit_rle_rgbe\n\n" + resolution + "\n"
+	return append([]byte(header), scanlines...)
+}
+
+func floats(values ...float32) []byte {
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	return data
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want Container
+	}{
+		{[]byte("\x89PNG\r\n\x1a\n...."), PNG},
+		{[]byte{0xff, 0xd8, 0xff, 0xe0}, JPEG},
+		{[]byte("#?RADIANCE\n"), HDR},
+		{[]byte("#?RGBE\n"), HDR},
+		{append(append([]byte(nil), ktx2Identifier...), 0), KTX2Container},
+		{[]byte("DDS |...."), DDS},
+		{[]byte("\x89PNG"), Unknown},
+		{[]byte("GIF89a"), Unknown},
+		{nil, Unknown},
+	}
+	for _, test := range tests {
+		if got := Detect(test.data); got != test.want {
+			t.Errorf("Detect(%q) = %s, want %s", test.data, got, test.want)
+		}
+	}
+}
+
+func TestFromMimeType(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		want     Container
+	}{
+		{"image/png", PNG},
+		{"image/jpeg", JPEG},
+		{"image/jpg", JPEG},
+		{"image/vnd.radiance", HDR},
+		{"image/x-hdr", HDR},
+		{"image/ktx2", KTX2Container},
+		{"image/vnd-ms.dds", DDS},
+		{"image/vnd.ms-dds", DDS},
+		{"image/webp", Unknown},
+		{"", Unknown},
+	}
+	for _, test := range tests {
+		if got := FromMimeType(test.mimeType); got != test.want {
+			t.Errorf("FromMimeType(%q) = %s, want %s", test.mimeType, got, test.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	rgba8 := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	rgba8.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 255})
+	rgba8.SetNRGBA(1, 0, color.NRGBA{0, 200, 100, 128})
+
+	rgba16 := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+	rgba16.SetNRGBA64(0, 0, color.NRGBA64{0xffff, 0, 0x1234, 0xffff})
+
+	paletted := image.NewPaletted(image.Rect(0, 0, 2, 1),
+		color.Palette{color.NRGBA{10, 20, 30, 255}, color.NRGBA{0, 0, 255, 255}})
+	paletted.SetColorIndex(1, 0, 1)
+
+	// One texel of 2^-7 times the channels plus a half and a black one.
+	rgbe := []byte{128, 64, 0, 129, 255, 255, 255, 0}
+	bright := floats(128.5/128, 64.5/128, 0.5/128, 1)
+	black := floats(0, 0, 0, 1)
+	// 8 texels run length encoded the adaptive way, channel by channel.
+	rle := []byte{2, 2, 0, 8, 128 + 8, 128, 128 + 8, 64, 128 + 8, 0, 128 + 8, 129}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		mimeType   string
+		colorSpace ColorSpace
+		format     vk.Format
+		width      uint32
+		height     uint32
+		want       []byte
+	}{
+		{"8 bit PNG", encodePNG(t, rgba8), "image/png", Linear, vk.FormatR8g8b8a8Unorm, 2, 1,
+			[]byte{255, 0, 0, 255, 0, 200, 100, 128}},
+		{"8 bit sRGB PNG", encodePNG(t, rgba8), "", SRGB, vk.FormatR8g8b8a8Srgb, 2, 1,
+			[]byte{255, 0, 0, 255, 0, 200, 100, 128}},
+		{"16 bit PNG", encodePNG(t, rgba16), "image/png", Linear, vk.FormatR16g16b16a16Unorm, 1, 1,
+			[]byte{0xff, 0xff, 0, 0, 0x34, 0x12, 0xff, 0xff}},
+		// sRGB 16 bit texels become linear half floats, white is checked below.
+		{"16 bit sRGB PNG", encodePNG(t, image.NewNRGBA64(image.Rect(0, 0, 1, 1))), "", SRGB,
+			vk.FormatR16g16b16a16Sfloat, 1, 1, []byte{0, 0, 0, 0, 0, 0, 0, 0}},
+		{"paletted PNG", encodePNG(t, paletted), "image/png", SRGB, vk.FormatR8g8b8a8Srgb, 2, 1,
+			[]byte{10, 20, 30, 255, 0, 0, 255, 255}},
+		{"HDR", hdrFile("-Y 1 +X 2", rgbe...), "image/vnd.radiance", Linear, vk.FormatR32g32b32a32Sfloat, 2, 1,
+			append(append([]byte(nil), bright...), black...)},
+		// +Y files store the bottom row first.
+		{"bottom up HDR", hdrFile("+Y 2 +X 1", rgbe...), "", Linear, vk.FormatR32g32b32a32Sfloat, 1, 2,
+			append(append([]byte(nil), black...), bright...)},
+		{"run length encoded HDR", hdrFile("-Y 1 +X 8", rle...), "", Linear, vk.FormatR32g32b32a32Sfloat, 8, 1,
+			bytes.Repeat(floats(128.5/128, 64.5/128, 0.5/128, 1), 8)},
+		// Flat runs of 1, 1, 1 repeat the previous texel.
+		{"flat run HDR", hdrFile("-Y 1 +X 3", 128, 64, 0, 129, 1, 1, 1, 2), "", Linear,
+			vk.FormatR32g32b32a32Sfloat, 3, 1, bytes.Repeat(bright, 3)},
+	}
+	for _, test := range tests {
+		img, err := Decode(test.data, test.mimeType, Options{ColorSpace: test.colorSpace})
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if img.Format != test.format || img.Width() != test.width || img.Height() != test.height || img.Layers != 1 {
+			t.Errorf("%s: %dx%d of format %d, want %dx%d of format %d", test.name,
+				img.Width(), img.Height(), img.Format, test.width, test.height, test.format)
+			continue
+		}
+		if got := img.Levels[0].Data; !bytes.Equal(got, test.want) {
+			t.Errorf("%s: texels %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// 16 bit sRGB white decodes to linear half float ones.
+	white := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+	white.SetNRGBA64(0, 0, color.NRGBA64{0xffff, 0xffff, 0xffff, 0xffff})
+	img, err := Decode(encodePNG(t, white), "image/png", Options{ColorSpace: SRGB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ch := 0; ch < 4; ch++ {
+		if h := binary.LittleEndian.Uint16(img.Levels[0].Data[ch*2:]); h != 0x3c00 {
+			t.Errorf("16 bit sRGB white: channel %d is %#x, want 0x3c00", ch, h)
+		}
+	}
+}
+
+func TestDecodeJPEG(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 8, 8))
+	for i := range gray.Pix {
+		gray.Pix[i] = 128
+	}
+	img, err := Decode(encodeJPEG(t, gray), "image/jpeg", Options{ColorSpace: SRGB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != vk.FormatR8g8b8a8Srgb || img.Width() != 8 || img.Height() != 8 {
+		t.Fatalf("%dx%d of format %d, want 8x8 sRGB RGBA8", img.Width(), img.Height(), img.Format)
+	}
+	// JPEG is lossy, a uniform gray may be off by one.
+	data := img.Levels[0].Data
+	for i := 0; i < len(data); i += 4 {
+		if data[i] < 127 || data[i] > 129 || data[i+1] != data[i] || data[i+2] != data[i] || data[i+3] != 255 {
+			t.Fatalf("texel %d is %v, want gray 128", i/4, data[i:i+4])
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+	corrupt := append([]byte(nil), valid...)
+	// Flip a byte of the IHDR chunk, its CRC no longer matches.
+	corrupt[20] ^= 0xff
+	jpg := encodeJPEG(t, image.NewGray(image.Rect(0, 0, 8, 8)))
+
+	bad := map[string]struct {
+		data     []byte
+		mimeType string
+	}{
+		"truncated PNG":            {valid[:len(valid)/2], ""},
+		"corrupt PNG":              {corrupt, ""},
+		"truncated JPEG":           {jpg[:len(jpg)/2], ""},
+		"PNG magic only":           {[]byte("\x89PNG\r\n\x1a\n"), ""},
+		"unknown data":             {[]byte("GIF89a"), ""},
+		"unsupported mimeType":     {[]byte("GIF89a"), "image/gif"},
+		"HDR without header end":   {[]byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n"), ""},
+		"HDR without resolution":   {[]byte("#?RADIANCE\n\n"), ""},
+		"HDR of XYZE":              {[]byte("#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n\x80\x80\x80\x80"), ""},
+		"HDR flipped X":            {hdrFile("-Y 1 -X 1", 128, 128, 128, 128), ""},
+		"HDR of zero size":         {hdrFile("-Y 0 +X 1"), ""},
+		"HDR side too large":       {hdrFile("-Y 1 +X 40000"), ""},
+		"HDR with too many texels": {hdrFile("-Y 32768 +X 32768", make([]byte, 1<<17)...), ""},
+		"HDR too short for rows":   {hdrFile("-Y 1000 +X 1000", 1, 2, 3, 4), ""},
+		"truncated HDR scanline":   {hdrFile("-Y 1 +X 2", 128, 128, 128, 128, 1), ""},
+		"HDR run without texel":    {hdrFile("-Y 1 +X 2", 1, 1, 1, 2), ""},
+		"HDR run past the end":     {hdrFile("-Y 1 +X 2", 128, 128, 128, 128, 1, 1, 1, 5), ""},
+		"HDR RLE width mismatch":   {hdrFile("-Y 1 +X 8", 2, 2, 0, 9, 0, 0, 0, 0), ""},
+		"HDR RLE bad run":          {hdrFile("-Y 1 +X 8", 2, 2, 0, 8, 128+9, 1), ""},
+		"truncated HDR RLE":        {hdrFile("-Y 1 +X 8", 2, 2, 0, 8, 128+8), ""},
+	}
+	for name, test := range bad {
+		if _, err := Decode(test.data, test.mimeType, Options{}); err == nil {
+			t.Errorf("%s: Decode succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package texture
+
+import (
+	"fmt"
+	"sync"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// Transcoder turns Basis Universal data, ETC1S with BasisLZ
+// supercompression or UASTC, into formats GPUs sample. This package doesn't
+// implement one: register a wrapper of the Basis Universal transcoder, the
+// KTX2 files of KHR_texture_basisu fail to decode without it.
+type Transcoder interface {
+	// Supports reports whether the transcoder produces format.
+	Supports(format vk.Format) bool
+	// Transcode transcodes every level and layer of k into format.
+	Transcode(k *KTX2, format vk.Format) (*Image, error)
+}
+
+var (
+	transcoderMu sync.Mutex
+	transcoder   Transcoder
+)
+
+// RegisterTranscoder makes Decode transcode Basis Universal textures with t.
+func RegisterTranscoder(t Transcoder) {
+	transcoderMu.Lock()
+	defer transcoderMu.Unlock()
+	transcoder = t
+}
+
+func registeredTranscoder() Transcoder {
+	transcoderMu.Lock()
+	defer transcoderMu.Unlock()
+	return transcoder
+}
+
+// transcodeTarget is a pair of formats with and without sRGB decoding.
+type transcodeTarget struct {
+	unorm, srgb vk.Format
+}
+
+var (
+	targetASTC     = transcodeTarget{vk.FormatAstc4x4UnormBlock, vk.FormatAstc4x4SrgbBlock}
+	targetBC7      = transcodeTarget{vk.FormatBc7UnormBlock, vk.FormatBc7SrgbBlock}
+	targetBC3      = transcodeTarget{vk.FormatBc3UnormBlock, vk.FormatBc3SrgbBlock}
+	targetBC1      = transcodeTarget{vk.FormatBc1RgbUnormBlock, vk.FormatBc1RgbSrgbBlock}
+	targetETC2RGBA = transcodeTarget{vk.FormatEtc2R8g8b8a8UnormBlock, vk.FormatEtc2R8g8b8a8SrgbBlock}
+	targetETC2RGB  = transcodeTarget{vk.FormatEtc2R8g8b8UnormBlock, vk.FormatEtc2R8g8b8SrgbBlock}
+	targetRGBA8    = transcodeTarget{vk.FormatR8g8b8a8Unorm, vk.FormatR8g8b8a8Srgb}
+	targetsETC1S   = []transcodeTarget{targetETC2RGB, targetBC7, targetBC1, targetASTC, targetRGBA8}
+	targetsETC1SA  = []transcodeTarget{targetETC2RGBA, targetBC7, targetBC3, targetASTC, targetRGBA8}
+	targetsUASTC   = []transcodeTarget{targetASTC, targetBC7, targetETC2RGB, targetRGBA8}
+	targetsUASTCA  = []transcodeTarget{targetASTC, targetBC7, targetETC2RGBA, targetRGBA8}
+)
+
+// TranscodeTargets lists the formats a Basis Universal texture transcodes
+// to, best first: the ones closest to its encoding, which transcode fastest
+// and lose the least, then the others by quality, then uncompressed RGBA8.
+func TranscodeTargets(k *KTX2) []vk.Format {
+	var targets []transcodeTarget
+	switch {
+	case k.DFD.ColorModel == ColorModelETC1S && k.HasAlpha():
+		targets = targetsETC1SA
+	case k.DFD.ColorModel == ColorModelETC1S:
+		targets = targetsETC1S
+	case k.HasAlpha():
+		targets = targetsUASTCA
+	default:
+		targets = targetsUASTC
+	}
+	formats := make([]vk.Format, len(targets))
+	for i, t := range targets {
+		formats[i] = t.unorm
+		if k.SRGB() {
+			formats[i] = t.srgb
+		}
+	}
+	return formats
+}
+
+// SelectTranscodeTarget picks the first of TranscodeTargets that the device
+// samples and t produces. A nil supported samples the RGBA8 formats only.
+func SelectTranscodeTarget(k *KTX2, supported func(vk.Format) bool, t Transcoder) (vk.Format, error) {
+	targets := TranscodeTargets(k)
+	for _, format := range targets {
+		if _, _, _, compressed := CompressedBlock(format); compressed && supported == nil {
+			continue
+		}
+		if (supported == nil || supported(format)) && t.Supports(format) {
+			return format, nil
+		}
+	}
+	return vk.FormatUndefined, fmt.Errorf("the transcoder produces none of the %d candidate formats", len(targets))
+}
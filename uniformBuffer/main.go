@@ -1,9 +1,13 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"path/filepath"
 	"time"
 
+	"github.com/vulkan-samples/pixels"
 	"github.com/vulkan-samples/uniformBuffer/uniform"
 
 	"github.com/vulkan-go/glfw/v3.3/glfw"
@@ -19,7 +23,59 @@ var appInfo = &vk.ApplicationInfo{
 	PEngineName:        "vulkangltf.com\x00",
 }
 
+var (
+	reference = flag.String("reference", "",
+		"render the first frame with the software rasterizer into this PNG file and exit")
+	offscreen = flag.String("offscreen", "",
+		"render the first frame without a window into this PNG file and exit")
+	screenshots = flag.String("screenshots", "",
+		"save a screenshot into this directory when F12 is pressed")
+)
+
+// renderOffscreen renders the first frame on a headless device.
+func renderOffscreen(path string, width, height uint32) error {
+	r, err := uniform.InitializeHeadless(appInfo, vk.Extent2D{Width: width, Height: height})
+	if err != nil {
+		return err
+	}
+	defer uniform.DestroyInOrder(&r)
+	frame, err := uniform.DrawOffscreenFrame(&r, 1.0)
+	if err != nil {
+		return err
+	}
+	return pixels.SavePNG(path, frame)
+}
+
+func saveScreenshot(r *uniform.VulkanRenderInfo, dir string) {
+	frame, err := uniform.Screenshot(r)
+	if err != nil {
+		log.Println("[WARN] screenshot failed:", err)
+		return
+	}
+	path := filepath.Join(dir, fmt.Sprintf("screenshot-%s.png", time.Now().Format("20060102-150405.000")))
+	if err := pixels.SavePNG(path, frame); err != nil {
+		log.Println("[WARN] screenshot failed:", err)
+		return
+	}
+	log.Println("[INFO] saved", path)
+}
+
 func main() {
+	const width = 640
+	const height = 480
+
+	flag.Parse()
+	if *reference != "" {
+		orPanic(uniform.RenderReference(width, height, 1.0).SavePNG(*reference))
+		return
+	}
+	if *offscreen != "" {
+		orPanic(vk.SetDefaultGetInstanceProcAddr())
+		orPanic(vk.Init())
+		orPanic(renderOffscreen(*offscreen, width, height))
+		return
+	}
+
 	procAddr := glfw.GetVulkanGetInstanceProcAddress()
 	if procAddr == nil {
 		panic("GetInstanceProcAddress is nil")
@@ -35,8 +91,6 @@ func main() {
 	)
 
 	glfw.WindowHint(glfw.ClientAPI, glfw.NoAPI)
-	const width = 640
-	const height = 480
 
 	window, err := glfw.CreateWindow(width, height, "Vulkan uniform buffer", nil, nil)
 	orPanic(err)
@@ -47,9 +101,21 @@ func main() {
 		return surface
 	}
 
+	fbWidth, fbHeight := window.GetFramebufferSize()
 	r, err = uniform.Initialize(appInfo, window.GLFWWindow(), window.GetRequiredInstanceExtensions(),
-		createSurface, float32(width)/float32(height))
+		createSurface, vk.Extent2D{Width: uint32(fbWidth), Height: uint32(fbHeight)})
 	orPanic(err)
+	window.SetFramebufferSizeCallback(func(w *glfw.Window, width int, height int) {
+		uniform.Resize(width, height)
+	})
+	screenshotRequested := false
+	if *screenshots != "" {
+		window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			if key == glfw.KeyF12 && action == glfw.Press {
+				screenshotRequested = true
+			}
+		})
+	}
 
 	// Some sync logic
 	doneC := make(chan struct{}, 2)
@@ -79,8 +145,20 @@ func main() {
 				continue
 			}
 			glfw.PollEvents()
-			uniform.VulkanDrawFrame(r, spinAngle)
+			if screenshotRequested {
+				if err := uniform.RequestScreenshot(&r); err != nil {
+					log.Println("[WARN] screenshot failed:", err)
+					screenshotRequested = false
+				}
+			}
+			// The frame copies its image for the screenshot before it
+			// is presented, a skipped frame leaves the request pending.
+			drawn := uniform.VulkanDrawFrame(&r, spinAngle)
 			spinAngle += 1.0
+			if drawn && screenshotRequested {
+				screenshotRequested = false
+				saveScreenshot(&r, *screenshots)
+			}
 		}
 	}
 }
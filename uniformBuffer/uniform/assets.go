@@ -0,0 +1,14 @@
+package uniform
+
+import (
+	"embed"
+
+	"github.com/vulkan-samples/asset"
+)
+
+//go:embed shaders/*.spv
+var embedded embed.FS
+
+// Assets are the embedded shaders, overlaid by the directories listed in
+// asset.OverlayEnv. Assets.Shaders finds the shader programs among them.
+var Assets = asset.New(embedded, asset.EnvOverlays()...)
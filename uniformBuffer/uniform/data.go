@@ -1,19 +1,23 @@
 package uniform
 
 import (
-	"unsafe"
 	"github.com/xlab/linmath"
+	"github.com/vulkan-samples/layout"
+	"github.com/vulkan-samples/util"
 )
 
 type vkTriUniform struct {
 	mvp      linmath.Mat4x4
 }
 
-const vkTriUniformSize = uint32(unsafe.Sizeof(vkTriUniform{}))
+var vkTriUniformLayout = layout.MustCompile(layout.Std140, vkTriUniform{})
+
+var vkTriUniformSize = uint32(vkTriUniformLayout.Size)
 
 func (u *vkTriUniform) Data() []byte {
-	const m = 0x7fffffff
-	return (*[m]byte)(unsafe.Pointer(u))[:vkTriUniformSize]
+	data, err := vkTriUniformLayout.Encode(u)
+	util.OrPanic(err)
+	return data
 }
 
 var gVertexData = linmath.ArrayFloat32([]float32{
@@ -0,0 +1,119 @@
+package uniform
+
+import (
+	"fmt"
+	"log"
+
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/pixels"
+	"github.com/vulkan-samples/renderer"
+)
+
+// InitializeHeadless sets the sample up without a window or swapchain. It
+// renders into offscreen images of extent, DrawOffscreenFrame returns what
+// each frame rendered. Release it with DestroyInOrder.
+func InitializeHeadless(appInfo *vk.ApplicationInfo, extent vk.Extent2D) (VulkanRenderInfo, error) {
+	var err error
+	v, err = renderer.NewHeadlessDevice(appInfo, nil)
+	if err != nil {
+		err = fmt.Errorf("renderer.NewHeadlessDevice failed with %s", err)
+		return r, err
+	}
+	depthFormat, err := v.FindDepthFormat()
+	if err != nil {
+		err = fmt.Errorf("renderer.FindDepthFormat failed with %s", err)
+		return r, err
+	}
+	samples := v.ChooseSampleCount(SampleCount, true)
+	format := renderer.DefaultOffscreenFormat
+	r, err = createRenderer(v.Device, format, depthFormat, samples,
+		float32(extent.Width)/float32(extent.Height), true)
+	if err != nil {
+		err = fmt.Errorf("renderer.createRenderer failed with %s", err)
+		return r, err
+	}
+	err = r.initPipelines()
+	if err != nil {
+		return r, err
+	}
+	r.frames, err = v.CreateFrameScheduler(renderer.DefaultFramesInFlight, r.cmdPool, 0)
+	if err != nil {
+		err = fmt.Errorf("renderer.CreateFrameScheduler failed with %s", err)
+		return r, err
+	}
+	r.uniforms, err = v.NewUniformRing(renderer.DefaultUniformRingSize, uint64(vkTriUniformSize), r.frames.Len())
+	if err != nil {
+		err = fmt.Errorf("renderer.NewUniformRing failed with %s", err)
+		return r, err
+	}
+	r.targets, err = v.CreateRenderTargets(format, depthFormat, extent, samples)
+	if err != nil {
+		err = fmt.Errorf("renderer.CreateRenderTargets failed with %s", err)
+		return r, err
+	}
+	r.offscreen, err = v.CreateOffscreenTarget(r.RenderPass, format, extent, r.targets, r.frames.Len())
+	if err != nil {
+		err = fmt.Errorf("renderer.CreateOffscreenTarget failed with %s", err)
+		return r, err
+	}
+
+	// The same layout as the one of the swapchain descriptor set.
+	descLayout, err := v.Layouts.Get(renderer.NewLayoutBuilder().
+		UniformBufferDynamic(0, vk.ShaderStageVertexBit).
+		CombinedImageSamplers(1, 0, vk.ShaderStageFragmentBit))
+	if err != nil {
+		return r, err
+	}
+	r.descriptors = renderer.NewDescriptorAllocator(v.Device, 0, nil)
+	r.descriptorSet, err = r.descriptors.Allocate(descLayout)
+	if err != nil {
+		return r, err
+	}
+	renderer.NewDescriptorWriter().
+		Buffer(r.descriptorSet, 0, vk.DescriptorTypeUniformBufferDynamic, r.uniforms.Buffer(), 0, r.uniforms.Range()).
+		Update(v.Device)
+
+	err = createBuffers()
+	if err != nil {
+		return r, err
+	}
+	gfx, err = createGraphicsPipeline(r.reloader, r.RenderPass, r.pipelineCache, r.targets.SampleCount(), v.Layouts)
+	if err != nil {
+		err = fmt.Errorf("uniform.createGraphicsPipeline failed with %s", err)
+		return r, err
+	}
+	log.Println("[INFO] rendering offscreen:", extent.Width, "x", extent.Height)
+	return r, nil
+}
+
+// DrawOffscreenFrame renders a frame of a headless renderer, waits for it
+// and returns what it rendered.
+func DrawOffscreenFrame(r *VulkanRenderInfo, spinAngle float32) (pixels.Buffer, error) {
+	if r.offscreen == nil {
+		return pixels.Buffer{}, fmt.Errorf("the renderer was not initialized headless")
+	}
+	r.reloadShaders()
+
+	frame, err := r.frames.BeginOffscreenFrame(r.offscreen)
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	uniformOffset, err := r.pushTransform(frame, spinAngle)
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	err = recordCommandBuffer(r, frame, r.offscreen, r.descriptorSet, uniformOffset)
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	err = r.frames.Submit(v.Queue, frame)
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	err = r.frames.WaitFrame(frame)
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	r.presented = int(frame.ImageIndex)
+	return r.offscreen.Readback(frame.ImageIndex)
+}
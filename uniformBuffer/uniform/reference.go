@@ -0,0 +1,53 @@
+package uniform
+
+import (
+	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/softraster"
+)
+
+// ReferenceDraw is the cube as VulkanDrawFrame draws it, with the same
+// matrices and the rasterization state of the pipeline, for rendering
+// with softraster.
+func ReferenceDraw(aspect, spinAngle float32) softraster.Draw {
+	const stride = 6 // position, color
+	mesh := &softraster.Mesh{}
+	for i := 0; i+stride <= len(gVertexData); i += stride {
+		v := gVertexData[i : i+stride]
+		mesh.Vertices = append(mesh.Vertices, softraster.Vertex{
+			Position: [3]float32{v[0], v[1], v[2]},
+			Color:    [4]float32{v[3], v[4], v[5], 1},
+		})
+	}
+	for _, index := range gIndexData {
+		mesh.Indices = append(mesh.Indices, uint32(index))
+	}
+
+	projection := cameraProjection(aspect)
+	view := cameraView()
+	draw := softraster.Draw{
+		Label:     "cube",
+		Mesh:      mesh,
+		MVP:       cubeMVP(&projection, &view, spinAngle),
+		DepthTest: true,
+	}
+	raster := cubePipelineBuilder().Desc().Raster
+	switch raster.CullMode {
+	case vk.CullModeBackBit:
+		draw.Cull = softraster.CullBack
+	case vk.CullModeFrontBit:
+		draw.Cull = softraster.CullFront
+	}
+	if raster.FrontFace == vk.FrontFaceClockwise {
+		draw.FrontFace = softraster.Clockwise
+	}
+	return draw
+}
+
+// RenderReference renders ReferenceDraw over the clear color of the
+// sample.
+func RenderReference(width, height int, spinAngle float32) *softraster.Target {
+	t := softraster.NewTarget(width, height)
+	t.Clear([4]float32{0.0, 0.0, 0.0, 1}, 1.0)
+	t.Render(ReferenceDraw(float32(width)/float32(height), spinAngle))
+	return t
+}
@@ -0,0 +1,20 @@
+package uniform
+
+import (
+	"testing"
+
+	"github.com/vulkan-samples/imgdiff"
+)
+
+// TestRenderReference catches changes to the matrices, culling and
+// winding of the cube, run with IMGDIFF_UPDATE=1 after intended ones.
+func TestRenderReference(t *testing.T) {
+	got := RenderReference(160, 120, 1.0).Image()
+	res, err := imgdiff.Golden("testdata/cube.png", got, imgdiff.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Width != 160 || res.Height != 120 {
+		t.Errorf("compared %dx%d, want 160x120", res.Width, res.Height)
+	}
+}
@@ -3,12 +3,15 @@ package uniform
 import (
 	"fmt"
 	"log"
-	"unsafe"
+	"os"
+	"path/filepath"
 
 	"github.com/xlab/linmath"
 	vk "github.com/vulkan-go/vulkan"
+	"github.com/vulkan-samples/asset"
+	"github.com/vulkan-samples/gpu"
+	"github.com/vulkan-samples/pixels"
 	"github.com/vulkan-samples/renderer"
-	"github.com/vulkan-samples/util"
 )
 
 // // enableDebug is disabled by default since VK_EXT_debug_report
@@ -22,405 +25,417 @@ type VulkanRenderInfo struct {
 
 	RenderPass vk.RenderPass
 	cmdPool    vk.CommandPool
-	cmdBuffers []vk.CommandBuffer
-	semaphores []vk.Semaphore
-	fences     []vk.Fence
+	frames     *renderer.FrameScheduler
+	pipelineCache *renderer.PipelineCache
+	uniforms   *renderer.UniformRing
+	targets    *renderer.RenderTargets
+	reloader   *renderer.PipelineReloader
+	backend    *renderer.VulkanBackend
+	watchers   []*asset.Watcher
+	// offscreen is rendered to instead of the swapchain by headless
+	// renderers, with their own descriptor set.
+	offscreen     *renderer.OffscreenTarget
+	descriptors   *renderer.DescriptorAllocator
+	descriptorSet vk.DescriptorSet
+	// presented is the swapchain image shown last, or the offscreen image
+	// of the last completed frame, -1 before the first frame and after the
+	// swapchain was recreated.
+	presented int
+	// capture is copied the swapchain image of the next frame when
+	// captureNext is set, captured once a frame did.
+	capture     *renderer.SwapchainCapture
+	captureNext bool
+	captured    bool
 
 	viewMatrix	linmath.Mat4x4
 	projectionMatrix linmath.Mat4x4
 }
 
 type VulkanGfxPipelineInfo struct {
-	device vk.Device
-
-	pipelineLayout   vk.PipelineLayout
-	pipelineCache    vk.PipelineCache
-	pipeline 				 vk.Pipeline
-}
-
-func (v *VulkanRenderInfo) DefaultFence() vk.Fence {
-	return v.fences[0]
+	pipeline *renderer.Pipeline
 }
 
-func (v *VulkanRenderInfo) DefaultSemaphore() vk.Semaphore {
-	return v.semaphores[0]
+// scene is what a frame draws, in handles of a gpu.Device.
+type scene struct {
+	renderPass    gpu.RenderPass
+	framebuffer   gpu.Framebuffer
+	extent        vk.Extent2D
+	clear         []gpu.ClearValue
+	pipeline      gpu.Pipeline
+	descriptorSet gpu.DescriptorSet
+	uniformOffset uint32
+	vertexBuffers []gpu.Buffer
+	indexBuffer   gpu.Buffer
+	indexCount    uint32
+	// readback is copied to readbackBuffer after the render pass, when
+	// it is set.
+	readback       gpu.Image
+	readbackBuffer gpu.Buffer
 }
 
-func vulkanInit() {
-
-	clearValues := []vk.ClearValue{
-		vk.NewClearValue([]float32{0.0, 0.0, 0.0, 1}),
-	}
-	for i := range r.cmdBuffers {
-		cmdBufferBeginInfo := vk.CommandBufferBeginInfo{
-			SType: vk.StructureTypeCommandBufferBeginInfo,
-		}
-		renderPassBeginInfo := vk.RenderPassBeginInfo{
-			SType:       vk.StructureTypeRenderPassBeginInfo,
-			RenderPass:  r.RenderPass,
-			Framebuffer: s.Framebuffers[i],
-			RenderArea: vk.Rect2D{
-				Offset: vk.Offset2D{
-					X: 0, Y: 0,
-				},
-				Extent: s.DisplaySize,
-			},
-			ClearValueCount: 1,
-			PClearValues:    clearValues,
-		}
-		ret := vk.BeginCommandBuffer(r.cmdBuffers[i], &cmdBufferBeginInfo)
-		util.Check(ret, "vk.BeginCommandBuffer")
-
-		vk.CmdBeginRenderPass(r.cmdBuffers[i], &renderPassBeginInfo, vk.SubpassContentsInline)
-		vk.CmdBindPipeline(r.cmdBuffers[i], vk.PipelineBindPointGraphics, gfx.pipeline)
-		offsets := make([]vk.DeviceSize, vb.GetBufferLen())
-		vk.CmdBindDescriptorSets(r.cmdBuffers[i], vk.PipelineBindPointGraphics, gfx.pipelineLayout,
-			0, 1, []vk.DescriptorSet{s.DescriptorSet[i]}, 0, nil)
-
-		vk.CmdBindVertexBuffers(r.cmdBuffers[i], 0, 1, *vb.GetBuffers(), offsets)
-		vk.CmdBindIndexBuffer(r.cmdBuffers[i], ib.DefaultBuffer(), 0, vk.IndexTypeUint16);
-		vk.CmdDrawIndexed(r.cmdBuffers[i], (uint32)(len(gIndexData)), 1, 0, 0, 0)
-		vk.CmdEndRenderPass(r.cmdBuffers[i])
-
-		ret = vk.EndCommandBuffer(r.cmdBuffers[i])
-		util.Check(ret, "vk.EndCommandBuffer")
-	}
-	fenceCreateInfo := vk.FenceCreateInfo{
-		SType: vk.StructureTypeFenceCreateInfo,
-	}
-	semaphoreCreateInfo := vk.SemaphoreCreateInfo{
-		SType: vk.StructureTypeSemaphoreCreateInfo,
-	}
-	r.fences = make([]vk.Fence, 1)
-	ret := vk.CreateFence(v.Device, &fenceCreateInfo, nil, &r.fences[0])
-	util.Check(ret, "vk.CreateFence")
-	r.semaphores = make([]vk.Semaphore, 1)
-	ret = vk.CreateSemaphore(v.Device, &semaphoreCreateInfo, nil, &r.semaphores[0])
-	util.Check(ret, "vk.CreateSemaphore")
+// drawScene records the cube into one render pass.
+func drawScene(enc gpu.CommandEncoder, sc scene) {
+	area := gpu.Rect{Width: sc.extent.Width, Height: sc.extent.Height}
+	viewport := renderer.Viewport(sc.extent)
+	enc.BeginRenderPass(sc.renderPass, sc.framebuffer, area, sc.clear)
+	enc.BindPipeline(sc.pipeline)
+	enc.SetViewport(gpu.Viewport{
+		X: viewport.X, Y: viewport.Y,
+		Width: viewport.Width, Height: viewport.Height,
+		MinDepth: viewport.MinDepth, MaxDepth: viewport.MaxDepth,
+	})
+	enc.SetScissor(area)
+	enc.BindDescriptorSets(sc.pipeline, 0, []gpu.DescriptorSet{sc.descriptorSet}, []uint32{sc.uniformOffset})
+	enc.BindVertexBuffers(0, sc.vertexBuffers, make([]uint64, len(sc.vertexBuffers)))
+	enc.BindIndexBuffer(sc.indexBuffer, 0, gpu.IndexUint16)
+	enc.DrawIndexed(sc.indexCount, 1, 0, 0, 0)
+	enc.EndRenderPass()
 }
 
-func LoadShader(device vk.Device, name string) (vk.ShaderModule, error) {
-	var module vk.ShaderModule
-	data, err := Asset(name)
+// recordFrame records the scene into cmd, for the swapchain or an
+// offscreen target.
+func recordFrame(dev gpu.Device, cmd gpu.CommandBuffer, sc scene) error {
+	enc, err := dev.Record(cmd)
 	if err != nil {
-		err := fmt.Errorf("asset %s not found: %s", name, err)
-		return module, err
-	}
-
-	// Phase 1: vk.CreateShaderModule
-
-	shaderModuleCreateInfo := vk.ShaderModuleCreateInfo{
-		SType:    vk.StructureTypeShaderModuleCreateInfo,
-		CodeSize: uint(len(data)),
-		PCode:    util.RepackUint32(data),
+		return err
 	}
-	err = vk.Error(vk.CreateShaderModule(device, &shaderModuleCreateInfo, nil, &module))
-	if err != nil {
-		err = fmt.Errorf("vk.CreateShaderModule failed with %s", err)
-		return module, err
+	drawScene(enc, sc)
+	if sc.readback != 0 {
+		enc.CopyImageToBuffer(sc.readback, sc.readbackBuffer, sc.extent.Width, sc.extent.Height)
 	}
-	return module, nil
+	return dev.End(enc)
 }
 
-func createGraphicsPipeline(device vk.Device,
-	displaySize vk.Extent2D, renderPass vk.RenderPass, descLayout vk.DescriptorSetLayout) (VulkanGfxPipelineInfo, error) {
+// recordCommandBuffer records the frame for the swapchain or an offscreen
+// target, offscreen frames are copied to their readback buffer as well and
+// swapchain frames to the capture after RequestScreenshot.
+func recordCommandBuffer(r *VulkanRenderInfo, frame *renderer.Frame, target renderer.FrameTarget,
+	descriptorSet vk.DescriptorSet, uniformOffset uint32) error {
+
+	vertexBuffers := make([]gpu.Buffer, 0, vb.GetBufferLen())
+	for _, buffer := range *vb.GetBuffers() {
+		vertexBuffers = append(vertexBuffers, r.backend.ImportBuffer(buffer))
+	}
+	sc := scene{
+		renderPass:    r.backend.ImportRenderPass(r.RenderPass),
+		framebuffer:   r.backend.ImportFramebuffer(target.Framebuffer(frame.ImageIndex)),
+		extent:        target.Extent(),
+		clear:         r.targets.ClearValues(gpu.ClearColor(0.0, 0.0, 0.0, 1)),
+		pipeline:      r.backend.ImportPipeline(gfx.pipeline),
+		descriptorSet: r.backend.ImportDescriptorSet(descriptorSet),
+		uniformOffset: uniformOffset,
+		vertexBuffers: vertexBuffers,
+		indexBuffer:   r.backend.ImportBuffer(ib.DefaultBuffer()),
+		indexCount:    uint32(len(gIndexData)),
+	}
+	if frame.Offscreen {
+		sc.readback = r.backend.ImportImage(r.offscreen.Image(frame.ImageIndex))
+		sc.readbackBuffer = r.backend.ImportBuffer(r.offscreen.ReadbackBuffer(frame.ImageIndex))
+	} else if r.captureNext && r.capture != nil {
+		sc.readback = r.backend.ImportPresentImage(s.DisplayImages[frame.ImageIndex])
+		sc.readbackBuffer = r.backend.ImportBuffer(r.capture.Buffer())
+	}
+	return recordFrame(r.backend, r.backend.ImportCommandBuffer(frame.CommandBuffer), sc)
+}
 
-	var gfxPipeline VulkanGfxPipelineInfo
-	// Phase 1: vk.CreatePipelineLayout
-	//			create pipeline layout (empty)
+// createGraphicsPipeline derives the vertex input and the descriptor set
+// layout from the shaders. The layout comes from the same cache as the one
+// of the swapchain, so both are the same handle.
+func createGraphicsPipeline(reloader *renderer.PipelineReloader, renderPass vk.RenderPass, cache *renderer.PipelineCache,
+	samples vk.SampleCountFlagBits, layouts *renderer.LayoutCache) (VulkanGfxPipelineInfo, error) {
 
-	pipelineLayoutCreateInfo := vk.PipelineLayoutCreateInfo{
-		SType: vk.StructureTypePipelineLayoutCreateInfo,
-		SetLayoutCount: 1,
-		PSetLayouts: []vk.DescriptorSetLayout{
-			descLayout,
-		},
+	var gfxPipeline VulkanGfxPipelineInfo
+	shaders, err := Assets.Shaders()
+	if err != nil {
+		return gfxPipeline, err
 	}
-	err := vk.Error(vk.CreatePipelineLayout(device, &pipelineLayoutCreateInfo, nil, &gfxPipeline.pipelineLayout))
+	program, err := shaders.Program("shaders/tri", vk.ShaderStageVertexBit, vk.ShaderStageFragmentBit)
 	if err != nil {
-		err = fmt.Errorf("vk.CreatePipelineLayout failed with %s", err)
 		return gfxPipeline, err
 	}
-	dynamicState := vk.PipelineDynamicStateCreateInfo{
-		SType: vk.StructureTypePipelineDynamicStateCreateInfo,
-		// no dynamic state for this demo
+	vertName, _ := program.Shader(vk.ShaderStageVertexBit)
+	fragName, _ := program.Shader(vk.ShaderStageFragmentBit)
+	vert, err := renderer.ReflectShader(vertName, shaders.Load)
+	if err != nil {
+		return gfxPipeline, err
 	}
-
-	// Phase 2: load shaders and specify shader stages
-
-	vertexShader, err := LoadShader(device, "shaders/tri-vert.spv")
-	if err != nil { // err has enough info
+	frag, err := renderer.ReflectShader(fragName, shaders.Load)
+	if err != nil {
 		return gfxPipeline, err
 	}
-	defer vk.DestroyShaderModule(device, vertexShader, nil)
-
-	fragmentShader, err := LoadShader(device, "shaders/tri-frag.spv")
-	if err != nil { // err has enough info
+	formats, err := renderer.VertexFormats(vert)
+	if err != nil {
 		return gfxPipeline, err
 	}
-	defer vk.DestroyShaderModule(device, fragmentShader, nil)
-
-	shaderStages := []vk.PipelineShaderStageCreateInfo{
-		{
-			SType:  vk.StructureTypePipelineShaderStageCreateInfo,
-			Stage:  vk.ShaderStageVertexBit,
-			Module: vertexShader,
-			PName:  "main\x00",
-		},
-		{
-			SType:  vk.StructureTypePipelineShaderStageCreateInfo,
-			Stage:  vk.ShaderStageFragmentBit,
-			Module: fragmentShader,
-			PName:  "main\x00",
-		},
-	}
-
-	// Phase 3: specify viewport state
-
-	viewports := []vk.Viewport{{
-		MinDepth: 0.0,
-		MaxDepth: 1.0,
-		X:        0,
-		Y:        0,
-		Width:    float32(displaySize.Width),
-		Height:   float32(displaySize.Height),
-	}}
-	scissors := []vk.Rect2D{{
-		Extent: displaySize,
-		Offset: vk.Offset2D{
-			X: 0, Y: 0,
-		},
-	}}
-	viewportState := vk.PipelineViewportStateCreateInfo{
-		SType:         vk.StructureTypePipelineViewportStateCreateInfo,
-		ViewportCount: 1,
-		PViewports:    viewports,
-		ScissorCount:  1,
-		PScissors:     scissors,
-	}
-
-	// Phase 4: specify multisample state
-	//					color blend state
-	//					rasterizer state
-
-	sampleMask := []vk.SampleMask{vk.SampleMask(vk.MaxUint32)}
-	multisampleState := vk.PipelineMultisampleStateCreateInfo{
-		SType:                vk.StructureTypePipelineMultisampleStateCreateInfo,
-		RasterizationSamples: vk.SampleCount1Bit,
-		SampleShadingEnable:  vk.False,
-		PSampleMask:          sampleMask,
-	}
-	attachmentStates := []vk.PipelineColorBlendAttachmentState{{
-		ColorWriteMask: vk.ColorComponentFlags(
-			vk.ColorComponentRBit | vk.ColorComponentGBit |
-				vk.ColorComponentBBit | vk.ColorComponentABit,
-		),
-		BlendEnable: vk.False,
-	}}
-	colorBlendState := vk.PipelineColorBlendStateCreateInfo{
-		SType:           vk.StructureTypePipelineColorBlendStateCreateInfo,
-		LogicOpEnable:   vk.False,
-		LogicOp:         vk.LogicOpCopy,
-		AttachmentCount: 1,
-		PAttachments:    attachmentStates,
-	}
-	rasterState := vk.PipelineRasterizationStateCreateInfo{
-		SType:                   vk.StructureTypePipelineRasterizationStateCreateInfo,
-		DepthClampEnable:        vk.False,
-		RasterizerDiscardEnable: vk.False,
-		PolygonMode:             vk.PolygonModeFill,
-		CullMode:                vk.CullModeFlags(vk.CullModeBackBit),
-		FrontFace:               vk.FrontFaceCounterClockwise,
-		DepthBiasEnable:         vk.False,
-		LineWidth:               1,
-	}
-
-	// Phase 5: specify input assembly state
-	//					vertex input state and attributes
-
-	inputAssemblyState := vk.PipelineInputAssemblyStateCreateInfo{
-		SType:                  vk.StructureTypePipelineInputAssemblyStateCreateInfo,
-		Topology:               vk.PrimitiveTopologyTriangleList,
-		PrimitiveRestartEnable: vk.False,
-	}
-	vertexInputBindings := []vk.VertexInputBindingDescription{{
-		Binding:   0,
-		Stride:    6 * 4, // 4 = sizeof(float32)
-		InputRate: vk.VertexInputRateVertex,
-	}}
-	vertexInputAttributes := []vk.VertexInputAttributeDescription{{
-		Binding:  0,
-		Location: 0,
-		Format:   vk.FormatR32g32b32Sfloat,
-		Offset:   0,
-	},
-	{
-		Binding:  0,
-		Location: 1,
-		Format:   vk.FormatR32g32b32Sfloat,
-		Offset:   3 * 4, // 4 = sizeof(float32)
-	}}
-	vertexInputState := vk.PipelineVertexInputStateCreateInfo{
-		SType:                           vk.StructureTypePipelineVertexInputStateCreateInfo,
-		VertexBindingDescriptionCount:   1,
-		PVertexBindingDescriptions:      vertexInputBindings,
-		VertexAttributeDescriptionCount: uint32(len(vertexInputAttributes)),//1,
-		PVertexAttributeDescriptions:    vertexInputAttributes,
-	}
-
-	// Phase 5: vk.CreatePipelineCache
-	//			vk.CreateGraphicsPipelines
-
-	pipelineCacheInfo := vk.PipelineCacheCreateInfo{
-		SType: vk.StructureTypePipelineCacheCreateInfo,
-	}
-	err = vk.Error(vk.CreatePipelineCache(device, &pipelineCacheInfo, nil, &gfxPipeline.pipelineCache))
-	if err != nil {
-		err = fmt.Errorf("vk.CreatePipelineCache failed with %s", err)
+	layoutBuilder, err := renderer.ReflectLayout(0, vert, frag)
+	if err != nil {
 		return gfxPipeline, err
 	}
-	pipelineCreateInfos := []vk.GraphicsPipelineCreateInfo{{
-		SType:               vk.StructureTypeGraphicsPipelineCreateInfo,
-		StageCount:          2, // vert + frag
-		PStages:             shaderStages,
-		PVertexInputState:   &vertexInputState,
-		PInputAssemblyState: &inputAssemblyState,
-		PViewportState:      &viewportState,
-		PRasterizationState: &rasterState,
-		PMultisampleState:   &multisampleState,
-		PColorBlendState:    &colorBlendState,
-		PDynamicState:       &dynamicState,
-		Layout:              gfxPipeline.pipelineLayout,
-		RenderPass:          renderPass,
-	}}
-	pipelines := make([]vk.Pipeline, 1)
-	err = vk.Error(vk.CreateGraphicsPipelines(device,
-		gfxPipeline.pipelineCache, 1, pipelineCreateInfos, nil, pipelines))
-	if err != nil {
-		err = fmt.Errorf("vk.CreateGraphicsPipelines failed with %s", err)
+	descLayout, err := layouts.Get(layoutBuilder.Dynamic(0))
+	if err != nil {
 		return gfxPipeline, err
 	}
-	gfxPipeline.pipeline = pipelines[0]
-	gfxPipeline.device = device
-
+	pipeline, err := reloader.Build(cubePipelineBuilder().
+		Shader(vk.ShaderStageVertexBit, vertName).
+		Shader(vk.ShaderStageFragmentBit, fragName).
+		Vertex(0, formats...).
+		Samples(samples).
+		SetLayouts(descLayout).
+		Cache(cache.Handle()), renderPass)
+	if err != nil {
+		return gfxPipeline, err
+	}
+	gfxPipeline.pipeline = pipeline
 	return gfxPipeline, nil
 }
 
-func VulkanDrawFrame(r VulkanRenderInfo, spinAngle float32) bool {
-	var nextIdx uint32
+// cubePipelineBuilder is the fixed function state of the cube pipeline,
+// ReferenceDraw rasterizes with the same culling and winding.
+func cubePipelineBuilder() *renderer.PipelineBuilder {
+	return renderer.NewPipelineBuilder().DepthTest(true)
+}
+
+// Resize is called from the window framebuffer size callback.
+func Resize(width, height int) {
+	v.WindowExtent = vk.Extent2D{
+		Width:  uint32(width),
+		Height: uint32(height),
+	}
+	if lifecycle != nil {
+		lifecycle.Invalidate()
+	}
+}
+
+// recreateSwapchain rebuilds the swapchain and everything that depends
+// on its images, format or extent.
+func recreateSwapchain(r *VulkanRenderInfo) error {
+	err := vk.Error(vk.DeviceWaitIdle(v.Device))
+	if err != nil {
+		return fmt.Errorf("vk.DeviceWaitIdle failed with %s", err)
+	}
+	gfx.Destroy()
+	r.backend.ForgetImported()
+	// The capture has the size of the old images.
+	r.capture.Destroy()
+	r.capture, r.captured = nil, false
+
+	oldFormat := s.DisplayFormat
+	s, err = v.RecreateSwapchain(&s, make([]*renderer.Texture, 0, 0))
+	if err != nil {
+		return fmt.Errorf("renderer.RecreateSwapchain failed with %s", err)
+	}
+	if s.DisplayFormat != oldFormat {
+		vk.DestroyRenderPass(v.Device, r.RenderPass, nil)
+		r.RenderPass, err = renderer.CreateRenderPass(v.Device, s.DisplayFormat,
+			r.targets.DepthFormat(), r.targets.SampleCount())
+		if err != nil {
+			return err
+		}
+	}
+	r.targets, err = v.RecreateRenderTargets(r.targets, s.DisplayFormat, s.DisplaySize)
+	if err != nil {
+		return fmt.Errorf("renderer.RecreateRenderTargets failed with %s", err)
+	}
+	err = s.CreateFramebuffers(r.RenderPass, r.targets)
+	if err != nil {
+		return fmt.Errorf("renderer.CreateFramebuffers failed with %s", err)
+	}
+	err = s.CreateDescriptorSet(r.uniforms, make([]*renderer.Texture, 0, 0))
+	if err != nil {
+		return fmt.Errorf("renderer.CreateDescriptorSet failed with %s", err)
+	}
+	gfx, err = createGraphicsPipeline(r.reloader, r.RenderPass, r.pipelineCache, r.targets.SampleCount(), v.Layouts)
+	if err != nil {
+		return fmt.Errorf("uniform.createGraphicsPipeline failed with %s", err)
+	}
+	r.frames.ResetImages(s.DefaultSwapchainLen())
+	r.presented = -1
+	if r.captureNext {
+		r.capture, err = v.CreateSwapchainCapture(&s)
+		if err != nil {
+			log.Println("[WARN] screenshot dropped:", err)
+			r.captureNext = false
+		}
+	}
+	r.setProjection(float32(s.DisplaySize.Width) / float32(s.DisplaySize.Height))
+
+	lifecycle.Recreated(s.DisplaySize)
+	log.Println("[INFO] swapchain recreated:", s.DisplaySize.Width, "x", s.DisplaySize.Height)
+	return nil
+}
+
+func VulkanDrawFrame(r *VulkanRenderInfo, spinAngle float32) bool {
+	// Phase 0: recreate the swapchain if the surface has changed,
+	//			skip the frame while the window is minimized
 
-	// Phase 1: vk.AcquireNextImage
-	// 			get the framebuffer index we should draw in
+	action, err := lifecycle.Prepare()
+	if err != nil {
+		log.Println("[WARN]", err)
+		return false
+	}
+	switch action {
+	case renderer.FrameSkip:
+		return false
+	case renderer.FrameRecreate:
+		if err := recreateSwapchain(r); err != nil {
+			log.Println("[WARN]", err)
+			return false
+		}
+	}
+	r.reloadShaders()
+
+	// Phase 1: vk.WaitForFences
+	//			vk.AcquireNextImage
+	// 			wait for this frame slot to be free and get the
+	//			framebuffer index we should draw in
 	//
 	//			N.B. non-infinite timeouts may be not yet implemented
 	//			by your Vulkan driver
 
-	err := vk.Error(vk.AcquireNextImage(v.Device, s.DefaultSwapchain(),
-		vk.MaxUint64, r.DefaultSemaphore(), vk.NullFence, &nextIdx))
-	if err != nil {
-		err = fmt.Errorf("vk.AcquireNextImage failed with %s", err)
-		log.Println("[WARN]", err)
+	frame, ret := r.frames.BeginFrame(&s)
+	if ok, err := lifecycle.Observe(ret); !ok {
+		if err != nil {
+			err = fmt.Errorf("vk.AcquireNextImage failed with %s", err)
+			log.Println("[WARN]", err)
+		}
 		return false
 	}
 
-	// Rotate cube and set uniform buffer
-	var MVP linmath.Mat4x4
-	var modelMatrix linmath.Mat4x4
-	modelMatrix.Identity()
-	modelMatrix.Rotate(&modelMatrix, 0.0, 1.0, 0.0, linmath.DegreesToRadians(spinAngle))
-	MVP.Mult(&r.projectionMatrix, &r.viewMatrix)
-	MVP.Mult(&MVP, &modelMatrix)
-	data := MVP.Data()
-	var pData unsafe.Pointer
-
-	vk.MapMemory(v.Device, s.UniformBuffer[nextIdx].GetMemory(), 0, vk.DeviceSize(len(data)), 0, &pData)
-	n := vk.Memcopy(pData, data)
-	if n != len(data) {
-		log.Printf("vulkan warning: failed to copy data, %d != %d", n, len(data))
-	}
-	vk.UnmapMemory(v.Device, s.UniformBuffer[nextIdx].GetMemory())
-
-	// Phase 2: vk.QueueSubmit
-	//			vk.WaitForFences
-
-	vk.ResetFences(v.Device, 1, r.fences)
-	submitInfo := []vk.SubmitInfo{{
-		SType:              vk.StructureTypeSubmitInfo,
-		WaitSemaphoreCount: 1,
-		PWaitSemaphores:    r.semaphores,
-		PWaitDstStageMask:  []vk.PipelineStageFlags{vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit)},
-		CommandBufferCount: 1,
-		PCommandBuffers:    r.cmdBuffers[nextIdx:],
-	}}
-	err = vk.Error(vk.QueueSubmit(v.Queue, 1, submitInfo, r.DefaultFence()))
-	if err != nil {
-		err = fmt.Errorf("vk.QueueSubmit failed with %s", err)
+	// An acquired image signals ImageAvailable, a frame that fails
+	// before it is submitted still has to wait on it.
+	abandon := func(err error) bool {
 		log.Println("[WARN]", err)
+		if err := r.frames.Abandon(v.Queue, frame); err != nil {
+			log.Println("[WARN]", err)
+		}
 		return false
 	}
 
-	const timeoutNano = 10 * 1000 * 1000 * 1000 // 10 sec
-	err = vk.Error(vk.WaitForFences(v.Device, 1, r.fences, vk.True, timeoutNano))
+	uniformOffset, err := r.pushTransform(frame, spinAngle)
 	if err != nil {
-		err = fmt.Errorf("vk.WaitForFences failed with %s", err)
-		log.Println("[WARN]", err)
-		return false
+		return abandon(err)
 	}
 
-	// Phase 3: vk.QueuePresent
+	// Phase 2: record the command buffer of this frame
+	//			vk.QueueSubmit
 
-	imageIndices := []uint32{nextIdx}
-	presentInfo := vk.PresentInfo{
-		SType:          vk.StructureTypePresentInfo,
-		SwapchainCount: 1,
-		PSwapchains:    s.Swapchains,
-		PImageIndices:  imageIndices,
+	err = recordCommandBuffer(r, frame, &s, s.DescriptorSet[0], uniformOffset)
+	if err != nil {
+		return abandon(err)
 	}
-	err = vk.Error(vk.QueuePresent(v.Queue, &presentInfo))
+	err = r.frames.Submit(v.Queue, frame)
 	if err != nil {
-		err = fmt.Errorf("vk.QueuePresent failed with %s", err)
-		log.Println("[WARN]", err)
+		return abandon(err)
+	}
+	if r.captureNext && r.capture != nil {
+		r.captureNext, r.captured = false, true
+	}
+
+	// Phase 3: vk.QueuePresent
+
+	ret = r.frames.Present(v.Queue, &s, frame)
+	if ok, err := lifecycle.Observe(ret); !ok {
+		if err != nil {
+			err = fmt.Errorf("vk.QueuePresent failed with %s", err)
+			log.Println("[WARN]", err)
+		}
 		return false
 	}
+	r.presented = int(frame.ImageIndex)
 	return true
 }
 
-func createRenderer(device vk.Device, displayFormat vk.Format, aspect float32) (VulkanRenderInfo, error) {
-	attachmentDescriptions := []vk.AttachmentDescription{{
-		Format:         displayFormat,
-		Samples:        vk.SampleCount1Bit,
-		LoadOp:         vk.AttachmentLoadOpClear,
-		StoreOp:        vk.AttachmentStoreOpStore,
-		StencilLoadOp:  vk.AttachmentLoadOpDontCare,
-		StencilStoreOp: vk.AttachmentStoreOpDontCare,
-		InitialLayout:  vk.ImageLayoutColorAttachmentOptimal,
-		FinalLayout:    vk.ImageLayoutColorAttachmentOptimal,
-	}}
-	colorAttachments := []vk.AttachmentReference{{
-		Attachment: 0,
-		Layout:     vk.ImageLayoutColorAttachmentOptimal,
-	}}
-	subpassDescriptions := []vk.SubpassDescription{{
-		PipelineBindPoint:    vk.PipelineBindPointGraphics,
-		ColorAttachmentCount: 1,
-		PColorAttachments:    colorAttachments,
-	}}
-	renderPassCreateInfo := vk.RenderPassCreateInfo{
-		SType:           vk.StructureTypeRenderPassCreateInfo,
-		AttachmentCount: 1,
-		PAttachments:    attachmentDescriptions,
-		SubpassCount:    1,
-		PSubpasses:      subpassDescriptions,
+// pushTransform rotates the cube and pushes its transform to the uniform
+// ring, it returns the dynamic offset of the transform.
+func (r *VulkanRenderInfo) pushTransform(frame *renderer.Frame, spinAngle float32) (uint32, error) {
+	r.uniforms.BeginFrame(frame.Index)
+	uniformData := vkTriUniform{
+		mvp: cubeMVP(&r.projectionMatrix, &r.viewMatrix, spinAngle),
 	}
+	uniformOffset, err := r.uniforms.Push(uniformData.Data())
+	if err != nil {
+		return 0, fmt.Errorf("failed to update uniform buffer: %s", err)
+	}
+	return uniformOffset, r.uniforms.Flush()
+}
+
+// RequestScreenshot makes the next frame copy its swapchain image before
+// it is presented, Screenshot returns the copy once the frame was drawn.
+// Headless renderers read back every frame and need no request.
+func RequestScreenshot(r *VulkanRenderInfo) error {
+	if r.offscreen != nil {
+		return nil
+	}
+	if r.capture == nil {
+		capture, err := v.CreateSwapchainCapture(&s)
+		if err != nil {
+			return fmt.Errorf("renderer.CreateSwapchainCapture failed with %s", err)
+		}
+		r.capture = capture
+	}
+	r.captureNext = true
+	return nil
+}
+
+// Screenshot reads back what the last frame rendered to the offscreen
+// target of a headless renderer, or the swapchain image the last frame
+// drawn after RequestScreenshot copied. It waits for the frames in flight.
+func Screenshot(r *VulkanRenderInfo) (pixels.Buffer, error) {
+	if r.offscreen != nil {
+		if r.presented < 0 {
+			return pixels.Buffer{}, fmt.Errorf("no frame was rendered yet")
+		}
+		err := r.frames.Wait()
+		if err != nil {
+			return pixels.Buffer{}, err
+		}
+		return r.offscreen.Readback(uint32(r.presented))
+	}
+	if !r.captured {
+		return pixels.Buffer{}, fmt.Errorf("no frame was captured, request a screenshot before drawing it")
+	}
+	err := r.frames.Wait()
+	if err != nil {
+		return pixels.Buffer{}, err
+	}
+	return r.capture.Read()
+}
+
+// reloadShaders rebuilds the pipelines whose shaders changed in the
+// watched overlay directories.
+func (r *VulkanRenderInfo) reloadShaders() {
+	var changed []string
+	for _, w := range r.watchers {
+		names, err := w.Poll()
+		if err != nil {
+			log.Println("[WARN] shader reload:", err)
+		}
+		changed = append(changed, names...)
+	}
+	if len(changed) == 0 {
+		return
+	}
+	log.Println("[INFO] shaders changed:", changed)
+	if n := r.reloader.Reload(changed); n > 0 {
+		log.Println("[INFO] pipelines reloaded:", n)
+	}
+}
+
+func createRenderer(device vk.Device, displayFormat, depthFormat vk.Format,
+	samples vk.SampleCountFlagBits, aspect float32, offscreen bool) (VulkanRenderInfo, error) {
 	cmdPoolCreateInfo := vk.CommandPoolCreateInfo{
 		SType:            vk.StructureTypeCommandPoolCreateInfo,
 		Flags:            vk.CommandPoolCreateFlags(vk.CommandPoolCreateResetCommandBufferBit),
 		QueueFamilyIndex: 0,
 	}
 	var r VulkanRenderInfo
-	err := vk.Error(vk.CreateRenderPass(device, &renderPassCreateInfo, nil, &r.RenderPass))
+	var err error
+	createRenderPass := renderer.CreateRenderPass
+	if offscreen {
+		createRenderPass = renderer.CreateOffscreenRenderPass
+	}
+	r.RenderPass, err = createRenderPass(device, displayFormat, depthFormat, samples)
 	if err != nil {
-		err = fmt.Errorf("vk.CreateRenderPass failed with %s", err)
 		return r, err
 	}
 	err = vk.Error(vk.CreateCommandPool(device, &cmdPoolCreateInfo, nil, &r.cmdPool))
@@ -430,16 +445,44 @@ func createRenderer(device vk.Device, displayFormat vk.Format, aspect float32) (
 	}
 
 	// Create MVP matrix
+	r.setProjection(aspect)
+	r.viewMatrix = cameraView()
+
+	r.device = device
+	r.presented = -1
+	return r, nil
+}
+
+func (r *VulkanRenderInfo) setProjection(aspect float32) {
+	r.projectionMatrix = cameraProjection(aspect)
+}
+
+func cameraView() linmath.Mat4x4 {
 	eyeVec := &linmath.Vec3{0.0, 3.0, 5.0}
 	origin := &linmath.Vec3{0.0, 0.0, 0.0}
 	upVec := &linmath.Vec3{0.0, 1.0, 0.0}
 
-	r.projectionMatrix.Perspective(linmath.DegreesToRadians(45.0), aspect, 0.1, 100.0);
-	r.viewMatrix.LookAt(eyeVec, origin, upVec)
-	r.projectionMatrix[1][1] *= -1 // Flip projection matrix from GL to Vulkan orientation.
+	var view linmath.Mat4x4
+	view.LookAt(eyeVec, origin, upVec)
+	return view
+}
 
-	r.device = device
-	return r, nil
+func cameraProjection(aspect float32) linmath.Mat4x4 {
+	var projection linmath.Mat4x4
+	projection.Perspective(linmath.DegreesToRadians(45.0), aspect, 0.1, 100.0)
+	projection[1][1] *= -1 // Flip projection matrix from GL to Vulkan orientation.
+	return projection
+}
+
+// cubeMVP rotates the cube around Y by spinAngle degrees.
+func cubeMVP(projection, view *linmath.Mat4x4, spinAngle float32) linmath.Mat4x4 {
+	var MVP linmath.Mat4x4
+	var modelMatrix linmath.Mat4x4
+	modelMatrix.Identity()
+	modelMatrix.Rotate(&modelMatrix, 0.0, 1.0, 0.0, linmath.DegreesToRadians(spinAngle))
+	MVP.Mult(projection, view)
+	MVP.Mult(&MVP, &modelMatrix)
+	return MVP
 }
 
 var (
@@ -449,10 +492,30 @@ var (
 	vb  renderer.VulkanBufferInfo
 	ib  renderer.VulkanBufferInfo
 	gfx VulkanGfxPipelineInfo
+
+	lifecycle *renderer.SwapchainLifecycle
+
+	// SampleCount is the MSAA sample count to render with, it is
+	// clamped to what the GPU supports in Initialize.
+	SampleCount = vk.SampleCount4Bit
+	// PipelineCacheDir is where compiled pipelines are kept between runs,
+	// empty disables the cache.
+	PipelineCacheDir = defaultPipelineCacheDir()
+	// ShaderCompiler compiles GLSL sources changed in the asset overlay
+	// directories, nil only reloads changed .spv files.
+	ShaderCompiler asset.Compiler
 )
 
+func defaultPipelineCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "vulkan-samples")
+}
+
 func Initialize(appInfo *vk.ApplicationInfo, window uintptr, instanceExtensions []string,
-								createSurfaceFunc func(interface{}) uintptr, ratio float32) (VulkanRenderInfo, error) {
+								createSurfaceFunc func(interface{}) uintptr, windowExtent vk.Extent2D) (VulkanRenderInfo, error) {
 
 	var err error
 	v, err = renderer.NewVulkanDevice(appInfo, window, instanceExtensions, createSurfaceFunc)
@@ -460,66 +523,127 @@ func Initialize(appInfo *vk.ApplicationInfo, window uintptr, instanceExtensions
 		err = fmt.Errorf("renderer.NewVulkanDevice failed with %s", err)
 		return r, err
 	}
+	v.WindowExtent = windowExtent
 
-	var MVP linmath.Mat4x4
-	uniformData := vkTriUniform{
-		mvp: MVP,
-	}
-
-	s, err = v.CreateSwapchain(uniformData.Data(), make([]*renderer.Texture, 0, 0))
+	s, err = v.CreateSwapchain(make([]*renderer.Texture, 0, 0))
 	if err != nil {
 		err = fmt.Errorf("renderer.CreateSwapchain failed with %s", err)
 		return r, err
 	}
-	r, err = createRenderer(v.Device, s.DisplayFormat, ratio)
+	depthFormat, err := v.FindDepthFormat()
+	if err != nil {
+		err = fmt.Errorf("renderer.FindDepthFormat failed with %s", err)
+		return r, err
+	}
+	samples := v.ChooseSampleCount(SampleCount, true)
+	log.Println("[INFO] MSAA samples:", samples)
+	r, err = createRenderer(v.Device, s.DisplayFormat, depthFormat, samples,
+		float32(s.DisplaySize.Width)/float32(s.DisplaySize.Height), false)
 	if err != nil {
 		err = fmt.Errorf("renderer.createRenderer failed with %s", err)
 		return r, err
 	}
-	err = s.CreateDescriptorPool(make([]*renderer.Texture, 0, 0))
+	err = r.initPipelines()
 	if err != nil {
-		err = fmt.Errorf("renderer.CreateDescriptorPool failed with %s", err)
 		return r, err
 	}
-
-	err = s.CreateFramebuffers(r.RenderPass, nil)
+	r.frames, err = v.CreateFrameScheduler(renderer.DefaultFramesInFlight, r.cmdPool,
+		s.DefaultSwapchainLen())
 	if err != nil {
-		err = fmt.Errorf("renderer.CreateFramebuffers failed with %s", err)
+		err = fmt.Errorf("renderer.CreateFrameScheduler failed with %s", err)
 		return r, err
 	}
-	// We don't use any textures in descriptor.
-	err = s.CreateDescriptorSet(vk.DeviceSize(len(uniformData.Data())), make([]*renderer.Texture, 0, 0))
+	r.uniforms, err = v.NewUniformRing(renderer.DefaultUniformRingSize, uint64(vkTriUniformSize), r.frames.Len())
 	if err != nil {
-		err = fmt.Errorf("renderer.CreateDescriptorSet failed with %s", err)
+		err = fmt.Errorf("renderer.NewUniformRing failed with %s", err)
 		return r, err
 	}
-	vb, err = v.CreateVertexBuffers(gVertexData.Data(), uint32(gVertexData.Sizeof()))
+
+	r.targets, err = v.CreateRenderTargets(s.DisplayFormat, depthFormat, s.DisplaySize, samples)
 	if err != nil {
-		err = fmt.Errorf("renderer.CreateVertexBuffers failed with %s", err)
+		err = fmt.Errorf("renderer.CreateRenderTargets failed with %s", err)
 		return r, err
 	}
-	ib, err = v.CreateIndexBuffers(gIndexData.Data(), uint32(gIndexData.Sizeof()))
+	err = s.CreateFramebuffers(r.RenderPass, r.targets)
 	if err != nil {
-		err = fmt.Errorf("renderer.CreateIndexBuffers failed with %s", err)
+		err = fmt.Errorf("renderer.CreateFramebuffers failed with %s", err)
 		return r, err
 	}
-	gfx, err = createGraphicsPipeline(v.Device, s.DisplaySize, r.RenderPass, s.DescLayout)
+	// We don't use any textures in descriptor.
+	err = s.CreateDescriptorSet(r.uniforms, make([]*renderer.Texture, 0, 0))
 	if err != nil {
-		err = fmt.Errorf("uniform.createGraphicsPipeline failed with %s", err)
+		err = fmt.Errorf("renderer.CreateDescriptorSet failed with %s", err)
 		return r, err
 	}
-	log.Println("[INFO] swapchain lengths:", s.SwapchainLen)
-	r.cmdBuffers, err = v.CreateCommandBuffers(s.DefaultSwapchainLen(), r.cmdPool)
+	err = createBuffers()
+	if err != nil {
+		return r, err
+	}
+	gfx, err = createGraphicsPipeline(r.reloader, r.RenderPass, r.pipelineCache, r.targets.SampleCount(), v.Layouts)
 	if err != nil {
-		err = fmt.Errorf("renderer.CreateCommandBuffers failed with %s", err)
+		err = fmt.Errorf("uniform.createGraphicsPipeline failed with %s", err)
 		return r, err
 	}
+	log.Println("[INFO] swapchain lengths:", s.SwapchainLen)
+	log.Println("[INFO] frames in flight:", r.frames.Len())
 
-	vulkanInit()
+	lifecycle = renderer.NewSwapchainLifecycle(&v, s.DisplaySize)
 
 	return r, nil
 }
 
+// initPipelines loads the pipeline cache and sets up pipeline building.
+func (r *VulkanRenderInfo) initPipelines() error {
+	var err error
+	if PipelineCacheDir != "" {
+		r.pipelineCache, err = v.LoadPipelineCache(PipelineCacheDir)
+		if err != nil {
+			err = fmt.Errorf("renderer.LoadPipelineCache failed with %s", err)
+			return err
+		}
+	}
+	shaders, err := Assets.Shaders()
+	if err != nil {
+		err = fmt.Errorf("asset.Shaders failed with %s", err)
+		return err
+	}
+	// Shaders in the overlay directories are reloaded when they change.
+	r.reloader = renderer.NewPipelineReloader(v.Device, shaders.Load)
+	r.backend = v.NewBackend(r.cmdPool, shaders.Load)
+	for _, dir := range Assets.Overlays() {
+		w, err := asset.NewWatcher(dir, ShaderCompiler)
+		if err != nil {
+			log.Println("[WARN] not watching", dir+":", err)
+			continue
+		}
+		r.watchers = append(r.watchers, w)
+	}
+	return nil
+}
+
+// createBuffers uploads the cube.
+func createBuffers() error {
+	var err error
+	vb, err = v.CreateVertexBuffers(gVertexData.Data(), uint32(gVertexData.Sizeof()))
+	if err != nil {
+		err = fmt.Errorf("renderer.CreateVertexBuffers failed with %s", err)
+		return err
+	}
+	ib, err = v.CreateIndexBuffers(gIndexData.Data(), uint32(gIndexData.Sizeof()))
+	if err != nil {
+		err = fmt.Errorf("renderer.CreateIndexBuffers failed with %s", err)
+		return err
+	}
+	// Draws submitted to the graphics queue are ordered after the copies,
+	// no need to wait for them here.
+	_, err = v.Uploads.Flush()
+	if err != nil {
+		err = fmt.Errorf("renderer.UploadManager.Flush failed with %s", err)
+		return err
+	}
+	return nil
+}
+
 func UniformDataSize() uint32 {
 	return vkTriUniformSize
 }
@@ -528,26 +652,42 @@ func (gfx *VulkanGfxPipelineInfo) Destroy() {
 	if gfx == nil {
 		return
 	}
-	vk.DestroyPipeline(gfx.device, gfx.pipeline, nil)
-	vk.DestroyPipelineCache(gfx.device, gfx.pipelineCache, nil)
-	vk.DestroyPipelineLayout(gfx.device, gfx.pipelineLayout, nil)
+	gfx.pipeline.Destroy()
+	gfx.pipeline = nil
 }
 
 func DestroyInOrder(r *VulkanRenderInfo) {
 
-	vk.FreeCommandBuffers(v.Device, r.cmdPool, uint32(len(r.cmdBuffers)), r.cmdBuffers)
-	r.cmdBuffers = nil
-	for _, fence := range r.fences {
-		vk.DestroyFence(v.Device, fence, nil)
-	}
-
+	vk.DeviceWaitIdle(v.Device)
+	r.frames.Destroy()
+	r.frames = nil
+	r.uniforms.Destroy()
+	r.uniforms = nil
+	r.targets.Destroy()
+	r.offscreen.Destroy()
+	r.capture.Destroy()
+	r.descriptors.Destroy()
+
+	r.backend.Destroy()
 	vk.DestroyCommandPool(v.Device, r.cmdPool, nil)
 	vk.DestroyRenderPass(v.Device, r.RenderPass, nil)
 
-	s.Destroy()
+	if !v.Headless {
+		s.Destroy()
+	}
 	gfx.Destroy()
+	if r.pipelineCache != nil {
+		if err := r.pipelineCache.Save(); err != nil {
+			log.Println("[WARN] failed to save pipeline cache:", err)
+		}
+		r.pipelineCache.Destroy()
+	}
 	vb.Destroy()
 	ib.Destroy()
+	v.Uploads.Destroy()
+	v.Variants.Destroy()
+	v.Layouts.Destroy()
+	v.Memory.Destroy()
 	vk.DestroyDevice(v.Device, nil)
 	if v.Dbg != vk.NullDebugReportCallback {
 		vk.DestroyDebugReportCallback(v.Instance, v.Dbg, nil)
@@ -0,0 +1,106 @@
+package uniform
+
+import (
+	"reflect"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+
+	"github.com/vulkan-samples/gpu"
+	"github.com/vulkan-samples/gpu/fake"
+)
+
+func TestRecordFrame(t *testing.T) {
+	d := fake.New()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	extent := vk.Extent2D{Width: 64, Height: 48}
+	pass, err := d.CreateRenderPass(gpu.RenderPassDesc{Label: "cube",
+		ColorFormats: []gpu.Format{gpu.Format(vk.FormatR8g8b8a8Unorm)}, Samples: 1})
+	must(err)
+	color, err := d.CreateImage(gpu.ImageDesc{Label: "color", Width: extent.Width, Height: extent.Height,
+		Usage: gpu.ImageColorAttachment | gpu.ImageTransferSrc})
+	must(err)
+	framebuffer, err := d.CreateFramebuffer(gpu.FramebufferDesc{RenderPass: pass,
+		Attachments: []gpu.Image{color}, Width: extent.Width, Height: extent.Height})
+	must(err)
+	pipeline, err := d.CreatePipeline(gpu.PipelineDesc{Label: "cube", RenderPass: pass})
+	must(err)
+	set, err := d.AllocateDescriptorSet([]gpu.DescriptorBinding{{Binding: 0,
+		Type: gpu.DescriptorUniformBufferDynamic, Count: 1, Stages: gpu.StageVertex}})
+	must(err)
+	vertices, err := d.CreateBuffer(gpu.BufferDesc{Label: "vertices", Usage: gpu.BufferVertex})
+	must(err)
+	indices, err := d.CreateBuffer(gpu.BufferDesc{Label: "indices", Usage: gpu.BufferIndex})
+	must(err)
+	readback, err := d.CreateBuffer(gpu.BufferDesc{Label: "readback", Usage: gpu.BufferTransferDst,
+		Size: uint64(extent.Width * extent.Height * 4), HostVisible: true})
+	must(err)
+	cmd, err := d.CreateCommandBuffer("frame")
+	must(err)
+
+	sc := scene{
+		renderPass:     pass,
+		framebuffer:    framebuffer,
+		extent:         extent,
+		clear:          []gpu.ClearValue{gpu.ClearColor(0, 0, 0, 1)},
+		pipeline:       pipeline,
+		descriptorSet:  set,
+		uniformOffset:  256,
+		vertexBuffers:  []gpu.Buffer{vertices},
+		indexBuffer:    indices,
+		indexCount:     uint32(len(gIndexData)),
+		readback:       color,
+		readbackBuffer: readback,
+	}
+	must(recordFrame(d, cmd, sc))
+	sc.readback, sc.readbackBuffer = 0, 0
+	must(recordFrame(d, cmd, sc))
+
+	if d.Frames() != 2 {
+		t.Fatalf("%d frames recorded, want 2", d.Frames())
+	}
+	draws := d.Draws(0)
+	if len(draws) != 1 {
+		t.Fatalf("frame 0 has %d draws, want 1", len(draws))
+	}
+	draw := draws[0]
+	if draw.Pipeline != pipeline || !reflect.DeepEqual(draw.Sets, []gpu.DescriptorSet{set}) {
+		t.Errorf("frame 0 bound pipeline %d and sets %v, want %d and [%d]", draw.Pipeline, draw.Sets, pipeline, set)
+	}
+	if !draw.Indexed || draw.Count != 36 || draw.IndexBuffer != indices {
+		t.Errorf("frame 0 drew %+v, want 36 indices of buffer %d", draw, indices)
+	}
+	if !reflect.DeepEqual(draw.DynamicOffsets, []uint32{256}) {
+		t.Errorf("frame 0 dynamic offsets %v, want [256]", draw.DynamicOffsets)
+	}
+	frame := d.Frame(0)
+	if last := frame[len(frame)-1]; last.Op != fake.OpCopyImageToBuffer || last.Image != color ||
+		last.Buffers[0] != readback {
+		t.Errorf("frame 0 ends with %+v, want the copy of image %d to the readback buffer", last, color)
+	}
+	frame = d.Frame(1)
+	if last := frame[len(frame)-1]; last.Op != fake.OpEndRenderPass {
+		t.Errorf("frame 1 without readback ends with %s", last.Op)
+	}
+
+	d.DestroyCommandBuffer(cmd)
+	d.DestroyBuffer(readback)
+	d.DestroyBuffer(indices)
+	d.DestroyBuffer(vertices)
+	d.FreeDescriptorSet(set)
+	d.DestroyPipeline(pipeline)
+	d.DestroyFramebuffer(framebuffer)
+	d.DestroyImage(color)
+	d.DestroyRenderPass(pass)
+	if live := d.Live(); len(live) != 0 {
+		t.Errorf("leaked %v", live)
+	}
+	if violations := d.Violations(); len(violations) != 0 {
+		t.Errorf("violations %v", violations)
+	}
+}